@@ -1,25 +1,34 @@
 package main
 
 import (
+	"context"
 	"log"
 	"os"
 	"os/signal"
+	"strings"
 	"syscall"
 
 	"github.com/gofiber/fiber/v3"
 	"github.com/gofiber/fiber/v3/middleware/cors"
 	"github.com/gofiber/fiber/v3/middleware/logger"
 	"github.com/gofiber/fiber/v3/middleware/recover"
-	"github.com/gofiber/fiber/v3/middleware/session"
+	"github.com/gofiber/fiber/v3/middleware/requestid"
 
+	"pets_rest/internal/bootstrap"
 	"pets_rest/internal/config"
 	"pets_rest/internal/database"
+	"pets_rest/internal/middleware"
+	"pets_rest/internal/oauth"
 	"pets_rest/internal/routes"
+	"pets_rest/internal/sessions"
 )
 
 func main() {
 	// Load configuration
 	cfg := config.Load()
+	if err := cfg.Validate(); err != nil {
+		log.Fatal("Invalid configuration:", err)
+	}
 
 	// Connect to database
 	db, err := database.Connect(cfg)
@@ -32,8 +41,58 @@ func main() {
 		}
 	}()
 
+	// Background workers stop when this context is cancelled
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	services, err := bootstrap.New(ctx, cfg, db)
+	if err != nil {
+		log.Fatal("Failed to create services:", err)
+	}
+
+	if services.Telegram.Enabled() {
+		if cfg.TelegramMode == "polling" {
+			go services.Telegram.Poll(ctx)
+		} else if cfg.TelegramWebhookSecret == "" {
+			log.Fatal("Refusing to set the Telegram webhook without TELEGRAM_WEBHOOK_SECRET")
+		} else if err := services.Telegram.Client().SetWebhook(ctx, cfg.BaseURL+"/telegram/webhook", cfg.TelegramWebhookSecret); err != nil {
+			log.Printf("Failed to set Telegram webhook: %v", err)
+		}
+	}
+
+	// Run background jobs in this process unless a separate worker does
+	workerDone := make(chan struct{})
+	go func() {
+		defer close(workerDone)
+		if cfg.JobsEmbedded {
+			services.Jobs.Work(ctx, cfg.JobsConcurrency)
+		}
+	}()
+
+	// Sessions hold OAuth state between login and callback
+	sessionStorage, err := sessions.NewStorage(ctx, cfg, db)
+	if err != nil {
+		log.Fatal("Failed to create session storage:", err)
+	}
+	defer func() {
+		if err := sessionStorage.Close(); err != nil {
+			log.Printf("Failed to close session storage: %v", err)
+		}
+	}()
+
+	// Sign-in providers enabled in the configuration
+	oauthProviders, err := oauth.NewRegistryFromConfig(cfg)
+	if err != nil {
+		log.Fatal("Failed to configure sign-in providers:", err)
+	}
+
 	// Create Fiber app
 	app := fiber.New(fiber.Config{
+		// Reports and rate limits tell visitors apart by c.IP()
+		TrustProxy:         cfg.TrustedProxies != "",
+		TrustProxyConfig:   fiber.TrustProxyConfig{Proxies: splitList(cfg.TrustedProxies)},
+		ProxyHeader:        cfg.ProxyHeader,
+		EnableIPValidation: true,
 		ErrorHandler: func(c fiber.Ctx, err error) error {
 			code := fiber.StatusInternalServerError
 			if e, ok := err.(*fiber.Error); ok {
@@ -46,17 +105,31 @@ func main() {
 	})
 
 	// Middleware
+	app.Use(requestid.New())
 	app.Use(logger.New())
 	app.Use(recover.New())
 	app.Use(cors.New(cors.Config{
 		AllowOrigins:     []string{"*"},
-		AllowMethods:     []string{"GET", "POST", "PUT", "DELETE", "OPTIONS"},
-		AllowHeaders:     []string{"Origin", "Content-Type", "Accept", "Authorization"},
+		AllowMethods:     []string{"GET", "POST", "PUT", "PATCH", "DELETE", "OPTIONS"},
+		AllowHeaders:     []string{"Origin", "Content-Type", "Accept", "Authorization", "If-Match"},
+		ExposeHeaders:    []string{"ETag"},
 		AllowCredentials: false,
 	}))
-	app.Use(session.New())
+	app.Use(sessions.Middleware(cfg, sessionStorage))
+	app.Use(middleware.Audit())
 	// Initialize routes
-	routes.SetupRoutes(app, db, cfg)
+	routes.SetupRoutes(app, db, cfg, &routes.Services{
+		Notify:     services.Notify,
+		Broker:     services.Broker,
+		Alerts:     services.Alerts,
+		Listings:   services.Listings,
+		Telegram:   services.Telegram,
+		Webhooks:   services.Webhooks,
+		OAuth:      oauthProviders,
+		Phones:     services.Phones,
+		Moderation: services.Moderation,
+		Privacy:    services.Privacy,
+	})
 
 	// Start server in goroutine
 	go func() {
@@ -72,8 +145,25 @@ func main() {
 	<-quit
 
 	log.Println("Shutting down server...")
+	cancel()
+	// Close event streams first, otherwise they keep connections open
+	if err := services.Close(); err != nil {
+		log.Printf("Failed to close realtime broker: %v", err)
+	}
 	if err := app.Shutdown(); err != nil {
 		log.Printf("Server forced to shutdown: %v", err)
 	}
+	<-workerDone
 	log.Println("Server exited")
 }
+
+// splitList splits a comma-separated setting into trimmed items
+func splitList(value string) []string {
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
@@ -0,0 +1,47 @@
+package main
+
+import (
+	"context"
+	"log"
+	"os/signal"
+	"syscall"
+
+	"pets_rest/internal/bootstrap"
+	"pets_rest/internal/config"
+	"pets_rest/internal/database"
+)
+
+func main() {
+	// Load configuration
+	cfg := config.Load()
+	if err := cfg.Validate(); err != nil {
+		log.Fatal("Invalid configuration:", err)
+	}
+
+	// Connect to database
+	db, err := database.Connect(cfg)
+	if err != nil {
+		log.Fatal("Failed to connect to database:", err)
+	}
+	defer func() {
+		if err := db.Close(); err != nil {
+			log.Printf("Failed to close database connection: %v", err)
+		}
+	}()
+
+	// Stop taking new jobs on interrupt; running jobs are allowed to finish
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	services, err := bootstrap.New(ctx, cfg, db)
+	if err != nil {
+		log.Fatal("Failed to create services:", err)
+	}
+
+	services.Jobs.Work(ctx, cfg.JobsConcurrency)
+
+	if err := services.Close(); err != nil {
+		log.Printf("Failed to close realtime broker: %v", err)
+	}
+	log.Println("Worker exited")
+}
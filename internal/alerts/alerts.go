@@ -0,0 +1,210 @@
+// Package alerts notifies subscribers about new listings in their area
+package alerts
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"pets_rest/internal/config"
+	"pets_rest/internal/database"
+	"pets_rest/internal/notify"
+	"pets_rest/internal/realtime"
+	"pets_rest/internal/webhooks"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// Publisher queues webhook events for the endpoints of a user
+type Publisher interface {
+	PublishToUser(ctx context.Context, userID int, eventType string, data any) error
+}
+
+// maxSuggestions bounds the listings suggested to owners when one is activated
+const maxSuggestions = 20
+
+// Service matches activated listings against subscriptions and queues
+// alerts, and suggests possible matches to the owners of lost and found
+// listings live
+type Service struct {
+	cfg      *config.Config
+	db       *database.DB
+	alerts   *database.AlertRepository
+	listings *database.ListingRepository
+	notify   *notify.Service
+	webhooks Publisher
+	broker   realtime.Broker
+}
+
+// NewService creates a new alerts service
+func NewService(db *database.DB, cfg *config.Config, notifier *notify.Service, hooks Publisher, broker realtime.Broker) *Service {
+	return &Service{
+		cfg:      cfg,
+		db:       db,
+		alerts:   database.NewAlertRepository(db),
+		listings: database.NewListingRepository(db),
+		notify:   notifier,
+		webhooks: hooks,
+		broker:   broker,
+	}
+}
+
+// OnListingActivated records matches for a listing that became active and
+// queues instant alerts; digest subscriptions are picked up by SendDigests.
+// Subscribers and the owners of possibly matching listings see the match
+// on their event stream right away.
+func (s *Service) OnListingActivated(ctx context.Context, listing *database.Listing) {
+	s.suggestMatches(ctx, listing)
+
+	subs, err := s.alerts.Matching(ctx, listing)
+	if err != nil {
+		log.Printf("Failed to match alert subscriptions for listing %d: %v", listing.ID, err)
+		return
+	}
+
+	for _, sub := range subs {
+		var isNew bool
+		err := s.db.WithTx(ctx, func(tx *sqlx.Tx) error {
+			alerts := s.alerts.WithTx(tx)
+
+			recorded, err := alerts.RecordMatch(ctx, sub.ID, listing.ID)
+			isNew = recorded
+			if err != nil || !recorded || sub.Frequency != database.AlertFrequencyInstant {
+				return err
+			}
+
+			if err := s.notify.SendTx(ctx, tx, s.notification(sub, []*database.Listing{listing})); err != nil {
+				return err
+			}
+			return alerts.MarkSent(ctx, sub.ID, []int{listing.ID})
+		})
+		if err != nil {
+			log.Printf("Failed to queue alert for subscription %d: %v", sub.ID, err)
+			continue
+		}
+		if !isNew {
+			continue
+		}
+
+		err = s.webhooks.PublishToUser(ctx, sub.UserID, webhooks.EventMatchFound, map[string]any{
+			"subscription_id": sub.ID,
+			"listing":         listing.Public(),
+		})
+		if err != nil {
+			log.Printf("Failed to queue match webhook for subscription %d: %v", sub.ID, err)
+		}
+
+		err = s.broker.Publish(ctx, sub.UserID, realtime.EventMatchFound, map[string]any{
+			"subscription_id": sub.ID,
+			"listing":         listing.Public(),
+		})
+		if err != nil {
+			log.Printf("Failed to publish match for subscription %d: %v", sub.ID, err)
+		}
+	}
+}
+
+// suggestMatches tells the owner of a lost or found listing about listings
+// of the opposite type that may be about the same animal, and their owners
+// about it
+func (s *Service) suggestMatches(ctx context.Context, listing *database.Listing) {
+	candidates, err := s.listings.MatchCandidates(ctx, listing, maxSuggestions)
+	if err != nil {
+		log.Printf("Failed to find matches for listing %d: %v", listing.ID, err)
+		return
+	}
+
+	for _, candidate := range candidates {
+		suggestions := []struct {
+			userID     int
+			listingID  int
+			suggestion *database.Listing
+		}{
+			{listing.UserID, listing.ID, candidate},
+			{candidate.UserID, candidate.ID, listing},
+		}
+		for _, sg := range suggestions {
+			err := s.broker.Publish(ctx, sg.userID, realtime.EventMatchSuggested, map[string]any{
+				"listing_id": sg.listingID,
+				"match":      sg.suggestion.Public(),
+			})
+			if err != nil {
+				log.Printf("Failed to publish match suggestion for listing %d: %v", sg.listingID, err)
+			}
+		}
+	}
+}
+
+// SendDigests queues one batched alert per digest subscription that has
+// pending matches and has not received a digest within the interval
+func (s *Service) SendDigests(ctx context.Context, interval time.Duration) error {
+	subs, err := s.alerts.DigestsDue(ctx, time.Now().Add(-interval))
+	if err != nil {
+		return err
+	}
+
+	for _, sub := range subs {
+		err := s.db.WithTx(ctx, func(tx *sqlx.Tx) error {
+			alerts := s.alerts.WithTx(tx)
+
+			listings, err := alerts.PendingListings(ctx, sub.ID)
+			if err != nil {
+				return err
+			}
+			if len(listings) > 0 {
+				if err := s.notify.SendTx(ctx, tx, s.notification(sub, listings)); err != nil {
+					return err
+				}
+			}
+			return alerts.MarkDigestSent(ctx, sub.ID)
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// notification builds the alert for a subscription, delivered through the
+// channel chosen in the subscription
+func (s *Service) notification(sub *database.AlertSubscription, listings []*database.Listing) notify.Notification {
+	items := make([]map[string]any, len(listings))
+	for i, l := range listings {
+		item := map[string]any{
+			"id":    l.ID,
+			"type":  l.Type,
+			"title": l.Title,
+		}
+		if l.City != nil {
+			item["city"] = *l.City
+		}
+		if l.Slug != nil {
+			item["url"] = s.cfg.FrontendURL + "/p/" + *l.Slug
+		}
+		items[i] = item
+	}
+
+	return notify.Notification{
+		UserID:   sub.UserID,
+		Kind:     notify.KindAlertMatch,
+		Channels: []database.NotificationChannel{channels[sub.Channel]},
+		Data: map[string]any{
+			"subscription_id": sub.ID,
+			"listings":        items,
+			"unsubscribe_url": s.UnsubscribeURL(sub),
+		},
+	}
+}
+
+// channels maps subscription channels to notification channels
+var channels = map[database.AlertChannel]database.NotificationChannel{
+	database.AlertChannelEmail:    database.NotificationChannelEmail,
+	database.AlertChannelPush:     database.NotificationChannelInApp,
+	database.AlertChannelTelegram: database.NotificationChannelTelegram,
+}
+
+// UnsubscribeURL returns the one-click unsubscribe link for a subscription
+func (s *Service) UnsubscribeURL(sub *database.AlertSubscription) string {
+	return s.cfg.BaseURL + "/alerts/unsubscribe/" + sub.UnsubscribeToken
+}
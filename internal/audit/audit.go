@@ -0,0 +1,193 @@
+// Package audit records who created, changed or deleted users and listings.
+// Entries are written in the transaction of the change, so the log never
+// misses a committed change or shows one that was rolled back.
+package audit
+
+import (
+	"context"
+	"encoding/json"
+	"reflect"
+
+	"pets_rest/internal/database"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// Actor is who made a change and from where; changes made by the system,
+// such as background jobs, have a zero actor
+type Actor struct {
+	UserID    *int
+	IP        string
+	RequestID string
+}
+
+// ActorKey is the context key the Actor is stored under; HTTP middleware
+// sets it with fiber.Locals so that handlers can pass the request as context
+type ActorKey struct{}
+
+// NewContext returns a copy of ctx that carries the actor
+func NewContext(ctx context.Context, actor Actor) context.Context {
+	return context.WithValue(ctx, ActorKey{}, actor)
+}
+
+// FromContext returns the actor carried by ctx
+func FromContext(ctx context.Context) Actor {
+	actor, _ := ctx.Value(ActorKey{}).(Actor)
+	return actor
+}
+
+// Change is the old and new value of a column; a created record has no old
+// values and a deleted one no new values
+type Change struct {
+	Old json.RawMessage `json:"old,omitempty"`
+	New json.RawMessage `json:"new,omitempty"`
+}
+
+// ignoredColumns change on every update and would only add noise
+var ignoredColumns = map[string]bool{"updated_at": true, "version": true}
+
+// Diff compares two records of the same struct type column by column, using
+// their db tags; either may be nil for a created or deleted record
+func Diff(before, after any) (map[string]Change, error) {
+	b, a := structValue(before), structValue(after)
+	var t reflect.Type
+	switch {
+	case a.IsValid():
+		t = a.Type()
+	case b.IsValid():
+		t = b.Type()
+	default:
+		return map[string]Change{}, nil
+	}
+
+	changes := make(map[string]Change)
+	for i := range t.NumField() {
+		column := t.Field(i).Tag.Get("db")
+		if column == "" || column == "-" || ignoredColumns[column] {
+			continue
+		}
+
+		var change Change
+		var err error
+		if b.IsValid() {
+			if change.Old, err = json.Marshal(b.Field(i).Interface()); err != nil {
+				return nil, err
+			}
+		}
+		if a.IsValid() {
+			if change.New, err = json.Marshal(a.Field(i).Interface()); err != nil {
+				return nil, err
+			}
+		}
+		if string(change.Old) != string(change.New) {
+			changes[column] = change
+		}
+	}
+
+	return changes, nil
+}
+
+// structValue dereferences a pointer to a struct; nil gives an invalid value
+func structValue(record any) reflect.Value {
+	v := reflect.ValueOf(record)
+	if v.Kind() == reflect.Pointer {
+		if v.IsNil() {
+			return reflect.Value{}
+		}
+		v = v.Elem()
+	}
+	return v
+}
+
+// Log writes audit entries
+type Log struct {
+	entries *database.AuditRepository
+}
+
+// NewLog creates a new audit log
+func NewLog(db *database.DB) *Log {
+	return &Log{entries: database.NewAuditRepository(db)}
+}
+
+// WithTx returns a copy of the log that writes in tx, the transaction of
+// the change being recorded
+func (l *Log) WithTx(tx *sqlx.Tx) *Log {
+	return &Log{entries: l.entries.WithTx(tx)}
+}
+
+// Record writes the change of an entity from before to after on behalf of
+// the actor in ctx; before is nil for a created record and after for a
+// deleted one. Updates that change nothing are not recorded.
+func (l *Log) Record(ctx context.Context, entity database.AuditEntity, id int, before, after any) error {
+	action := database.AuditActionUpdate
+	switch {
+	case !structValue(before).IsValid():
+		action = database.AuditActionCreate
+	case !structValue(after).IsValid():
+		action = database.AuditActionDelete
+	}
+
+	changes, err := Diff(before, after)
+	if err != nil {
+		return err
+	}
+	if len(changes) == 0 && action == database.AuditActionUpdate {
+		return nil
+	}
+
+	data, err := json.Marshal(changes)
+	if err != nil {
+		return err
+	}
+
+	actor := FromContext(ctx)
+	entry := &database.AuditEntry{
+		ActorID:    actor.UserID,
+		Action:     action,
+		EntityType: entity,
+		EntityID:   id,
+		Changes:    data,
+	}
+	if actor.IP != "" {
+		entry.IPAddress = &actor.IP
+	}
+	if actor.RequestID != "" {
+		entry.RequestID = &actor.RequestID
+	}
+
+	return l.entries.Create(ctx, entry)
+}
+
+// Erase records the erasure of records on behalf of the actor in ctx and
+// removes the values recorded about them before, which may hold personal
+// data; the entries themselves stay, so the log still shows who changed
+// what and when
+func (l *Log) Erase(ctx context.Context, entity database.AuditEntity, ids ...int) error {
+	if err := l.entries.Scrub(ctx, entity, ids); err != nil {
+		return err
+	}
+
+	actor := FromContext(ctx)
+	for _, id := range ids {
+		// The IP is left out as the erased user may be the actor
+		entry := &database.AuditEntry{
+			ActorID:    actor.UserID,
+			Action:     database.AuditActionDelete,
+			EntityType: entity,
+			EntityID:   id,
+			Changes:    json.RawMessage(`{}`),
+		}
+		if actor.RequestID != "" {
+			entry.RequestID = &actor.RequestID
+		}
+		if err := l.entries.Create(ctx, entry); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ForgetActor removes the IP addresses of the changes an erased user made
+func (l *Log) ForgetActor(ctx context.Context, userID int) error {
+	return l.entries.AnonymizeActor(ctx, userID)
+}
@@ -0,0 +1,75 @@
+package audit
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"pets_rest/internal/database"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDiffUpdate(t *testing.T) {
+	city := "Kyiv"
+	now := time.Now()
+	before := &database.Listing{ID: 1, Title: "Grey cat", Status: database.ListingStatusDraft}
+	after := *before
+	after.Title = "Grey cat with a collar"
+	after.City = &city
+	after.UpdatedAt = &now
+
+	changes, err := Diff(before, &after)
+	require.NoError(t, err)
+
+	// updated_at змінюється щоразу, тож до журналу не потрапляє
+	assert.Len(t, changes, 2)
+	assert.JSONEq(t, `"Grey cat"`, string(changes["title"].Old))
+	assert.JSONEq(t, `"Grey cat with a collar"`, string(changes["title"].New))
+	assert.JSONEq(t, `null`, string(changes["city"].Old))
+	assert.JSONEq(t, `"Kyiv"`, string(changes["city"].New))
+}
+
+func TestDiffIncludesColumnsHiddenFromJSON(t *testing.T) {
+	chatID := int64(100)
+	before := &database.User{ID: 7}
+	after := &database.User{ID: 7, TelegramChatID: &chatID}
+
+	changes, err := Diff(before, after)
+	require.NoError(t, err)
+	assert.JSONEq(t, `100`, string(changes["telegram_chat_id"].New))
+}
+
+func TestDiffCreateAndDelete(t *testing.T) {
+	user := &database.User{ID: 7, Email: "owner@example.com"}
+
+	created, err := Diff(nil, user)
+	require.NoError(t, err)
+	assert.Nil(t, created["email"].Old)
+	assert.JSONEq(t, `"owner@example.com"`, string(created["email"].New))
+
+	deleted, err := Diff(user, (*database.User)(nil))
+	require.NoError(t, err)
+	assert.JSONEq(t, `"owner@example.com"`, string(deleted["email"].Old))
+	assert.Nil(t, deleted["email"].New)
+
+	// Порожні значення нового запису не відкидаються, щоб видно було весь стан
+	data, err := json.Marshal(created["phone"])
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"new": null}`, string(data))
+}
+
+func TestContextCarriesActor(t *testing.T) {
+	userID := 7
+	ctx := NewContext(context.Background(), Actor{UserID: &userID, IP: "203.0.113.1", RequestID: "abc"})
+
+	actor := FromContext(ctx)
+	require.NotNil(t, actor.UserID)
+	assert.Equal(t, 7, *actor.UserID)
+	assert.Equal(t, "203.0.113.1", actor.IP)
+
+	// Фонові задачі працюють без актора
+	assert.Equal(t, Actor{}, FromContext(context.Background()))
+}
@@ -0,0 +1,127 @@
+// Package auth issues and validates the access tokens handed out after login
+package auth
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"slices"
+	"strconv"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// ErrInvalidToken is returned when a token is malformed, expired or signed with another key
+var ErrInvalidToken = errors.New("invalid access token")
+
+// IssueToken creates a signed access token for the given user
+func IssueToken(secret string, userID int, ttl time.Duration) (string, error) {
+	now := time.Now()
+	claims := jwt.RegisteredClaims{
+		Subject:   strconv.Itoa(userID),
+		IssuedAt:  jwt.NewNumericDate(now),
+		ExpiresAt: jwt.NewNumericDate(now.Add(ttl)),
+	}
+
+	return jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString([]byte(secret))
+}
+
+// ParseToken validates an access token and returns the user ID it was issued for
+func ParseToken(secret, token string) (int, error) {
+	claims, err := parse(secret, token, "")
+	if err != nil {
+		return 0, err
+	}
+	return subject(claims)
+}
+
+// linkAudience marks tickets that start linking a sign-in method to an
+// account; access tokens carry no audience, so neither is accepted in place
+// of the other
+const linkAudience = "account-link"
+
+// LinkTicket is the content of a link ticket. The signature only proves it
+// was issued; the caller stores ID to let each ticket be redeemed once.
+type LinkTicket struct {
+	// ID is the jti of the ticket
+	ID        string
+	UserID    int
+	ExpiresAt time.Time
+}
+
+// IssueLinkTicket creates a short-lived ticket that lets the browser start
+// linking a new sign-in method to the user's account
+func IssueLinkTicket(secret string, userID int, ttl time.Duration) (string, *LinkTicket, error) {
+	id := make([]byte, 16)
+	if _, err := rand.Read(id); err != nil {
+		return "", nil, err
+	}
+
+	now := time.Now()
+	ticket := &LinkTicket{
+		ID:        hex.EncodeToString(id),
+		UserID:    userID,
+		ExpiresAt: now.Add(ttl),
+	}
+	claims := jwt.RegisteredClaims{
+		ID:        ticket.ID,
+		Subject:   strconv.Itoa(userID),
+		Audience:  jwt.ClaimStrings{linkAudience},
+		IssuedAt:  jwt.NewNumericDate(now),
+		ExpiresAt: jwt.NewNumericDate(ticket.ExpiresAt),
+	}
+
+	signed, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString([]byte(secret))
+	if err != nil {
+		return "", nil, err
+	}
+	return signed, ticket, nil
+}
+
+// ParseLinkTicket validates a link ticket and returns its content
+func ParseLinkTicket(secret, ticket string) (*LinkTicket, error) {
+	claims, err := parse(secret, ticket, linkAudience)
+	if err != nil {
+		return nil, err
+	}
+	if claims.ID == "" {
+		return nil, ErrInvalidToken
+	}
+
+	userID, err := subject(claims)
+	if err != nil {
+		return nil, err
+	}
+
+	return &LinkTicket{ID: claims.ID, UserID: userID, ExpiresAt: claims.ExpiresAt.Time}, nil
+}
+
+func parse(secret, token, audience string) (*jwt.RegisteredClaims, error) {
+	claims := &jwt.RegisteredClaims{}
+	_, err := jwt.ParseWithClaims(token, claims, func(*jwt.Token) (any, error) {
+		return []byte(secret), nil
+	}, jwt.WithValidMethods([]string{jwt.SigningMethodHS256.Alg()}), jwt.WithExpirationRequired())
+	if err != nil {
+		return nil, ErrInvalidToken
+	}
+
+	if audience == "" && len(claims.Audience) > 0 {
+		return nil, ErrInvalidToken
+	}
+	if audience != "" && !slices.Contains(claims.Audience, audience) {
+		return nil, ErrInvalidToken
+	}
+
+	return claims, nil
+}
+
+// subject returns the user ID a token was issued for
+func subject(claims *jwt.RegisteredClaims) (int, error) {
+	userID, err := strconv.Atoi(claims.Subject)
+	if err != nil {
+		return 0, ErrInvalidToken
+	}
+
+	return userID, nil
+}
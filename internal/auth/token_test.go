@@ -0,0 +1,78 @@
+package auth
+
+import (
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAccessToken(t *testing.T) {
+	token, err := IssueToken("secret", 7, time.Hour)
+	require.NoError(t, err)
+
+	userID, err := ParseToken("secret", token)
+	require.NoError(t, err)
+	assert.Equal(t, 7, userID)
+
+	_, err = ParseToken("other", token)
+	assert.ErrorIs(t, err, ErrInvalidToken)
+
+	expired, err := IssueToken("secret", 7, -time.Minute)
+	require.NoError(t, err)
+	_, err = ParseToken("secret", expired)
+	assert.ErrorIs(t, err, ErrInvalidToken)
+}
+
+func TestLinkTicketIsNotAccessToken(t *testing.T) {
+	ticket, issued, err := IssueLinkTicket("secret", 7, time.Minute)
+	require.NoError(t, err)
+
+	parsed, err := ParseLinkTicket("secret", ticket)
+	require.NoError(t, err)
+	assert.Equal(t, 7, parsed.UserID)
+	assert.Equal(t, issued.ID, parsed.ID)
+	assert.WithinDuration(t, issued.ExpiresAt, parsed.ExpiresAt, time.Second)
+
+	// Квиток прив'язки не відкриває API, а токен доступу не прив'язує входи
+	_, err = ParseToken("secret", ticket)
+	assert.ErrorIs(t, err, ErrInvalidToken)
+
+	token, err := IssueToken("secret", 7, time.Hour)
+	require.NoError(t, err)
+	_, err = ParseLinkTicket("secret", token)
+	assert.ErrorIs(t, err, ErrInvalidToken)
+}
+
+func TestLinkTicketHasUniqueID(t *testing.T) {
+	// Квитки зберігаються за jti, тож два квитки не можуть його ділити
+	seen := make(map[string]bool)
+	for range 100 {
+		ticket, issued, err := IssueLinkTicket("secret", 7, time.Minute)
+		require.NoError(t, err)
+		assert.Len(t, issued.ID, 32)
+		assert.False(t, seen[issued.ID])
+		seen[issued.ID] = true
+
+		parsed, err := ParseLinkTicket("secret", ticket)
+		require.NoError(t, err)
+		assert.Equal(t, issued.ID, parsed.ID)
+	}
+}
+
+func TestLinkTicketRequiresID(t *testing.T) {
+	// Квиток без jti неможливо погасити, тож він недійсний
+	now := time.Now()
+	claims := jwt.RegisteredClaims{
+		Subject:   "7",
+		Audience:  jwt.ClaimStrings{linkAudience},
+		ExpiresAt: jwt.NewNumericDate(now.Add(time.Minute)),
+	}
+	ticket, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString([]byte("secret"))
+	require.NoError(t, err)
+
+	_, err = ParseLinkTicket("secret", ticket)
+	assert.ErrorIs(t, err, ErrInvalidToken)
+}
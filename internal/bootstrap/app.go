@@ -0,0 +1,95 @@
+// Package bootstrap wires the long-lived services shared by the API server and
+// the background worker
+package bootstrap
+
+import (
+	"context"
+	"fmt"
+
+	"pets_rest/internal/alerts"
+	"pets_rest/internal/config"
+	"pets_rest/internal/database"
+	"pets_rest/internal/jobs"
+	"pets_rest/internal/listings"
+	"pets_rest/internal/mailer"
+	"pets_rest/internal/moderation"
+	"pets_rest/internal/notify"
+	"pets_rest/internal/phones"
+	"pets_rest/internal/privacy"
+	"pets_rest/internal/realtime"
+	"pets_rest/internal/sms"
+	"pets_rest/internal/spam"
+	"pets_rest/internal/telegram"
+	"pets_rest/internal/webhooks"
+)
+
+// App holds the services of one process
+type App struct {
+	Config     *config.Config
+	DB         *database.DB
+	Broker     realtime.Broker
+	Listings   *listings.Service
+	Telegram   *telegram.Bot
+	Webhooks   *webhooks.Dispatcher
+	Notify     *notify.Service
+	Alerts     *alerts.Service
+	Phones     *phones.Service
+	Moderation *moderation.Service
+	Privacy    *privacy.Service
+	Jobs       *jobs.Queue
+}
+
+// New creates the services, connects listing hooks and registers job
+// handlers; background loops started by the services stop with ctx
+func New(ctx context.Context, cfg *config.Config, db *database.DB) (*App, error) {
+	broker, err := realtime.New(ctx, cfg)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create realtime broker: %w", err)
+	}
+
+	a := &App{
+		Config:   cfg,
+		DB:       db,
+		Broker:   broker,
+		Webhooks: webhooks.NewDispatcher(db, cfg),
+		Jobs:     jobs.NewQueue(db, cfg.JobsPollInterval),
+	}
+	a.Listings = listings.NewService(db, spam.NewEngineFromConfig(cfg), a.Jobs)
+	a.Telegram = telegram.New(cfg, telegram.NewUsers(db), database.NewTelegramDialogueRepository(db), a.Listings)
+
+	a.Notify, err = notify.NewService(db,
+		notify.NewEmailChannel(mailer.New(cfg)),
+		notify.NewTelegramChannel(a.Telegram),
+		notify.NewWebhookChannel(a.Webhooks),
+		notify.NewInAppChannel(broker),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create notification service: %w", err)
+	}
+
+	a.Alerts = alerts.NewService(db, cfg, a.Notify, a.Webhooks, broker)
+	a.Moderation = moderation.NewService(db, cfg, a.Notify, a.Listings)
+	a.Privacy = privacy.NewService(db, cfg, a.Jobs, a.Notify)
+
+	sender, err := sms.NewSender(cfg)
+	if err != nil {
+		return nil, err
+	}
+	a.Phones = phones.NewService(db, cfg, sender)
+
+	a.Listings.On(listings.EventActivated, a.Telegram.Broadcast)
+	a.Listings.On(listings.EventActivated, a.Webhooks.OnListingActivated)
+	a.Listings.On(listings.EventResolved, a.Webhooks.OnListingResolved)
+	a.Listings.On(listings.EventActivated, a.Alerts.OnListingActivated)
+
+	if err := a.registerJobs(); err != nil {
+		return nil, err
+	}
+
+	return a, nil
+}
+
+// Close releases connections held by the services
+func (a *App) Close() error {
+	return a.Broker.Close()
+}
@@ -0,0 +1,118 @@
+package bootstrap
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"pets_rest/internal/database"
+	"pets_rest/internal/jobs"
+	"pets_rest/internal/listings"
+	"pets_rest/internal/privacy"
+)
+
+// Job types handled by the worker
+const (
+	JobAlertDigests    = "alerts.digests"
+	JobWebhooks        = "webhooks.deliver"
+	JobNotifications   = "notifications.flush"
+	JobPurgeCompleted  = "jobs.purge"
+	JobPurgeDeleted    = "deleted.purge"
+	JobDataExport      = privacy.JobExport
+	JobPurgeExports    = "exports.purge"
+	JobPurgeChallenges = "challenges.purge"
+	JobListingEvent    = listings.JobEvent
+	JobPurgeDialogues  = "telegram.dialogues.purge"
+	JobPurgeTickets    = "link_tickets.purge"
+)
+
+// completedJobRetention is how long finished jobs are kept for inspection
+const completedJobRetention = 24 * time.Hour
+
+func (a *App) registerJobs() error {
+	jobs.Register(a.Jobs, JobAlertDigests, func(ctx context.Context, _ struct{}) error {
+		return a.Alerts.SendDigests(ctx, a.Config.AlertDigestInterval)
+	})
+	jobs.Register(a.Jobs, JobWebhooks, func(ctx context.Context, _ struct{}) error {
+		return a.Webhooks.Deliver(ctx)
+	})
+	jobs.Register(a.Jobs, JobNotifications, func(ctx context.Context, _ struct{}) error {
+		return a.Notify.Flush(ctx)
+	})
+	jobs.Register(a.Jobs, JobPurgeCompleted, func(ctx context.Context, _ struct{}) error {
+		n, err := a.Jobs.PurgeFinished(ctx, completedJobRetention)
+		if n > 0 {
+			log.Printf("Purged %d completed jobs", n)
+		}
+		return err
+	})
+	jobs.Register(a.Jobs, JobPurgeDeleted, func(ctx context.Context, _ struct{}) error {
+		return a.purgeDeleted(ctx)
+	})
+	jobs.Register(a.Jobs, JobDataExport, func(ctx context.Context, payload privacy.ExportJob) error {
+		return a.Privacy.BuildExport(ctx, payload.ExportID)
+	})
+	jobs.Register(a.Jobs, JobListingEvent, a.Listings.RunHooks)
+	jobs.Register(a.Jobs, JobPurgeExports, func(ctx context.Context, _ struct{}) error {
+		n, err := a.Privacy.PurgeExpiredExports(ctx)
+		if n > 0 {
+			log.Printf("Purged %d expired data exports", n)
+		}
+		return err
+	})
+	jobs.Register(a.Jobs, JobPurgeChallenges, func(ctx context.Context, _ struct{}) error {
+		_, err := database.NewChallengeRepository(a.DB).PurgeExpired(ctx)
+		return err
+	})
+
+	jobs.Register(a.Jobs, JobPurgeDialogues, func(ctx context.Context, _ struct{}) error {
+		_, err := database.NewTelegramDialogueRepository(a.DB).PurgeExpired(ctx)
+		return err
+	})
+
+	jobs.Register(a.Jobs, JobPurgeTickets, func(ctx context.Context, _ struct{}) error {
+		_, err := database.NewLinkTicketRepository(a.DB).PurgeExpired(ctx)
+		return err
+	})
+
+	schedules := []struct {
+		spec, jobType string
+	}{
+		{"@every 1m", JobAlertDigests},
+		{"@every " + a.Config.WebhookPollInterval.String(), JobWebhooks},
+		{"@every " + a.Config.OutboxPollInterval.String(), JobNotifications},
+		{"@hourly", JobPurgeCompleted},
+		{"@hourly", JobPurgeDeleted},
+		{"@hourly", JobPurgeExports},
+		{"@hourly", JobPurgeChallenges},
+		{"@hourly", JobPurgeDialogues},
+		{"@hourly", JobPurgeTickets},
+	}
+	for _, s := range schedules {
+		if err := a.Jobs.Schedule(s.jobType, s.spec, s.jobType, nil); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// purgeDeleted removes users and listings deleted longer than
+// Config.DeletedRetention ago; the database cascades to what they own
+func (a *App) purgeDeleted(ctx context.Context) error {
+	before := time.Now().Add(-a.Config.DeletedRetention)
+
+	listings, err := database.NewListingRepository(a.DB).PurgeDeleted(ctx, before)
+	if err != nil {
+		return err
+	}
+	users, err := database.NewUserRepository(a.DB).PurgeDeleted(ctx, before)
+	if err != nil {
+		return err
+	}
+
+	if listings > 0 || users > 0 {
+		log.Printf("Purged %d deleted listings and %d deleted users", listings, users)
+	}
+	return nil
+}
@@ -0,0 +1,163 @@
+// Package captcha implements a proof-of-work challenge that makes bulk
+// scraping of protected actions expensive without third-party services
+package captcha
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"math/bits"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+var (
+	// ErrInvalidChallenge is returned for tampered, foreign or malformed challenges
+	ErrInvalidChallenge = errors.New("invalid challenge")
+	// ErrExpiredChallenge is returned when the challenge is older than its TTL
+	ErrExpiredChallenge = errors.New("challenge expired")
+	// ErrInvalidSolution is returned when the nonce does not satisfy the difficulty
+	ErrInvalidSolution = errors.New("invalid challenge solution")
+	// ErrChallengeUsed is returned when a solved challenge is submitted twice
+	ErrChallengeUsed = errors.New("challenge already used")
+)
+
+// Challenge is sent to the client, which must find a nonce such that
+// sha256(token + ":" + nonce) starts with Difficulty zero bits
+type Challenge struct {
+	Token      string `json:"challenge"`
+	Difficulty int    `json:"difficulty"`
+	ExpiresAt  int64  `json:"expires_at"`
+}
+
+// UsedStore remembers solved challenges until they expire; it must be
+// shared by all API instances for single use to hold across them
+type UsedStore interface {
+	// MarkUsed records a challenge and reports whether it was the first
+	// to do so
+	MarkUsed(ctx context.Context, id string, expiresAt time.Time) (bool, error)
+}
+
+// ProofOfWork issues and verifies stateless, HMAC-signed challenges
+type ProofOfWork struct {
+	secret     []byte
+	difficulty int
+	ttl        time.Duration
+	used       UsedStore
+}
+
+// NewProofOfWork creates a challenge issuer that keeps solved challenges
+// in used
+func NewProofOfWork(secret string, difficulty int, ttl time.Duration, used UsedStore) *ProofOfWork {
+	return &ProofOfWork{
+		secret:     []byte(secret),
+		difficulty: difficulty,
+		ttl:        ttl,
+		used:       used,
+	}
+}
+
+// New issues a challenge bound to scope, e.g. a listing slug
+func (p *ProofOfWork) New(scope string) Challenge {
+	nonce := make([]byte, 12)
+	_, _ = rand.Read(nonce)
+
+	expires := time.Now().Add(p.ttl).Unix()
+	payload := strconv.FormatInt(expires, 10) + "." + base64.RawURLEncoding.EncodeToString(nonce)
+
+	return Challenge{
+		Token:      payload + "." + p.sign(scope, payload),
+		Difficulty: p.difficulty,
+		ExpiresAt:  expires,
+	}
+}
+
+// Verify checks the signature, expiry and solution of a challenge; each
+// challenge can be used once
+func (p *ProofOfWork) Verify(ctx context.Context, scope, token, solution string) error {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return ErrInvalidChallenge
+	}
+
+	payload := parts[0] + "." + parts[1]
+	if !hmac.Equal([]byte(parts[2]), []byte(p.sign(scope, payload))) {
+		return ErrInvalidChallenge
+	}
+
+	expires, err := strconv.ParseInt(parts[0], 10, 64)
+	if err != nil {
+		return ErrInvalidChallenge
+	}
+	if time.Now().Unix() > expires {
+		return ErrExpiredChallenge
+	}
+
+	if leadingZeroBits(sha256.Sum256([]byte(token+":"+solution))) < p.difficulty {
+		return ErrInvalidSolution
+	}
+
+	// The signed payload identifies the challenge whatever the solution
+	first, err := p.used.MarkUsed(ctx, payload, time.Unix(expires, 0))
+	if err != nil {
+		return err
+	}
+	if !first {
+		return ErrChallengeUsed
+	}
+	return nil
+}
+
+func (p *ProofOfWork) sign(scope, payload string) string {
+	mac := hmac.New(sha256.New, p.secret)
+	mac.Write([]byte(scope + "|" + payload))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// MemoryStore is a UsedStore for a single process, used in tests
+type MemoryStore struct {
+	mu   sync.Mutex
+	used map[string]time.Time
+}
+
+// NewMemoryStore creates an empty in-memory store
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{used: make(map[string]time.Time)}
+}
+
+// MarkUsed records a challenge and drops the expired ones
+func (s *MemoryStore) MarkUsed(_ context.Context, id string, expiresAt time.Time) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	for t, exp := range s.used {
+		if now.After(exp) {
+			delete(s.used, t)
+		}
+	}
+
+	if _, ok := s.used[id]; ok {
+		return false, nil
+	}
+	s.used[id] = expiresAt
+	return true, nil
+}
+
+func leadingZeroBits(sum [sha256.Size]byte) int {
+	n := 0
+	for i := 0; i < len(sum); i += 8 {
+		word := binary.BigEndian.Uint64(sum[i : i+8])
+		n += bits.LeadingZeros64(word)
+		if word != 0 {
+			break
+		}
+	}
+	return n
+}
@@ -0,0 +1,76 @@
+package captcha
+
+import (
+	"context"
+	"crypto/sha256"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// solve підбирає nonce так само, як це робить клієнт
+func solve(t *testing.T, c Challenge) string {
+	t.Helper()
+	for i := 0; i < 1<<24; i++ {
+		nonce := strconv.Itoa(i)
+		if leadingZeroBits(sha256.Sum256([]byte(c.Token+":"+nonce))) >= c.Difficulty {
+			return nonce
+		}
+	}
+	t.Fatal("no solution found")
+	return ""
+}
+
+func TestProofOfWorkVerify(t *testing.T) {
+	pow := NewProofOfWork("secret", 8, time.Minute, NewMemoryStore())
+	c := pow.New("lost-cat")
+
+	nonce := solve(t, c)
+	require.NoError(t, pow.Verify(context.Background(), "lost-cat", c.Token, nonce))
+
+	// Повторне використання заборонене
+	assert.ErrorIs(t, pow.Verify(context.Background(), "lost-cat", c.Token, nonce), ErrChallengeUsed)
+}
+
+func TestProofOfWorkSharesUsedChallenges(t *testing.T) {
+	used := NewMemoryStore()
+	first := NewProofOfWork("secret", 8, time.Minute, used)
+	second := NewProofOfWork("secret", 8, time.Minute, used)
+	c := first.New("lost-cat")
+
+	// Інший екземпляр API зі спільним сховищем теж відхиляє повтор,
+	// навіть з іншим розв'язком
+	require.NoError(t, first.Verify(context.Background(), "lost-cat", c.Token, solve(t, c)))
+	var other string
+	for i := 1 << 24; ; i++ {
+		other = strconv.Itoa(i)
+		if leadingZeroBits(sha256.Sum256([]byte(c.Token+":"+other))) >= c.Difficulty {
+			break
+		}
+	}
+	assert.ErrorIs(t, second.Verify(context.Background(), "lost-cat", c.Token, other), ErrChallengeUsed)
+}
+
+func TestProofOfWorkRejectsForeignScope(t *testing.T) {
+	pow := NewProofOfWork("secret", 8, time.Minute, NewMemoryStore())
+	c := pow.New("lost-cat")
+
+	assert.ErrorIs(t, pow.Verify(context.Background(), "found-dog", c.Token, solve(t, c)), ErrInvalidChallenge)
+}
+
+func TestProofOfWorkRejectsExpired(t *testing.T) {
+	pow := NewProofOfWork("secret", 1, -time.Second, NewMemoryStore())
+	c := pow.New("lost-cat")
+
+	assert.ErrorIs(t, pow.Verify(context.Background(), "lost-cat", c.Token, solve(t, c)), ErrExpiredChallenge)
+}
+
+func TestProofOfWorkRejectsWrongSolution(t *testing.T) {
+	pow := NewProofOfWork("secret", 64, time.Minute, NewMemoryStore())
+	c := pow.New("lost-cat")
+
+	assert.ErrorIs(t, pow.Verify(context.Background(), "lost-cat", c.Token, "0"), ErrInvalidSolution)
+}
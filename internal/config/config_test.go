@@ -0,0 +1,56 @@
+package config
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValidateTelegram(t *testing.T) {
+	tests := []struct {
+		name    string
+		cfg     Config
+		wantErr bool
+	}{
+		{"бот вимкнено", Config{Env: "development", TelegramMode: "webhook"}, false},
+		{"вебхук із секретом", Config{Env: "development", TelegramBotToken: "t", TelegramMode: "webhook", TelegramWebhookSecret: "s"}, false},
+		{"вебхук без секрету", Config{Env: "development", TelegramBotToken: "t", TelegramMode: "webhook"}, true},
+		{"опитування без секрету", Config{Env: "development", TelegramBotToken: "t", TelegramMode: "polling"}, false},
+		{"невідомий режим", Config{Env: "development", TelegramBotToken: "t", TelegramMode: "push"}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.cfg.Validate()
+			if tt.wantErr {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
+
+func TestValidateJWTSecret(t *testing.T) {
+	tests := []struct {
+		name    string
+		cfg     Config
+		wantErr bool
+	}{
+		{"розробка з типовим секретом", Config{Env: "development", JWTSecret: defaultJWTSecret}, false},
+		{"розробка без секрету", Config{Env: "development"}, false},
+		{"продакшн з власним секретом", Config{Env: "production", JWTSecret: "s3cr3t-from-vault"}, false},
+		{"продакшн з типовим секретом", Config{Env: "production", JWTSecret: defaultJWTSecret}, true},
+		{"продакшн без секрету", Config{Env: "production"}, true},
+		{"невказане середовище", Config{JWTSecret: defaultJWTSecret}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.cfg.Validate()
+			if tt.wantErr {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
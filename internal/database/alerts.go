@@ -0,0 +1,254 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+)
+
+const alertSubscriptionColumns = `id, user_id, city, latitude, longitude, radius_km, types, species, channel, frequency, unsubscribe_token, last_digest_at, created_at`
+
+// AlertRepository handles alert subscription database operations
+type AlertRepository struct {
+	db      Executor
+	timeout time.Duration
+}
+
+// NewAlertRepository creates a new alert repository
+func NewAlertRepository(db *DB) *AlertRepository {
+	return &AlertRepository{db: db, timeout: db.queryTimeout}
+}
+
+// WithTx returns a copy of the repository that runs its queries in tx
+func (r *AlertRepository) WithTx(tx *sqlx.Tx) *AlertRepository {
+	return &AlertRepository{db: tx, timeout: r.timeout}
+}
+
+// Create creates a new subscription
+func (r *AlertRepository) Create(ctx context.Context, sub *AlertSubscription) error {
+	ctx, cancel := withQueryTimeout(ctx, r.timeout)
+	defer cancel()
+
+	query := `
+		INSERT INTO alert_subscriptions (user_id, city, latitude, longitude, radius_km, types, species, channel, frequency, unsubscribe_token, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)
+		RETURNING id, created_at`
+
+	err := r.db.QueryRowContext(ctx, query,
+		sub.UserID,
+		sub.City,
+		sub.Latitude,
+		sub.Longitude,
+		sub.RadiusKm,
+		pq.Array(sub.Types),
+		pq.Array(sub.Species),
+		sub.Channel,
+		sub.Frequency,
+		sub.UnsubscribeToken,
+		time.Now()).
+		Scan(&sub.ID, &sub.CreatedAt)
+
+	return err
+}
+
+// GetByID retrieves a subscription by ID
+func (r *AlertRepository) GetByID(ctx context.Context, id int) (*AlertSubscription, error) {
+	ctx, cancel := withQueryTimeout(ctx, r.timeout)
+	defer cancel()
+
+	sub := &AlertSubscription{}
+	query := `SELECT ` + alertSubscriptionColumns + ` FROM alert_subscriptions WHERE id = $1`
+
+	err := r.db.GetContext(ctx, sub, query, id)
+	if err != nil {
+		return nil, err
+	}
+
+	return sub, nil
+}
+
+// ListByUser retrieves all subscriptions of a user
+func (r *AlertRepository) ListByUser(ctx context.Context, userID int) ([]*AlertSubscription, error) {
+	ctx, cancel := withQueryTimeout(ctx, r.timeout)
+	defer cancel()
+
+	subs := []*AlertSubscription{}
+	query := `
+		SELECT ` + alertSubscriptionColumns + `
+		FROM alert_subscriptions 
+		WHERE user_id = $1 
+		ORDER BY created_at DESC`
+
+	err := r.db.SelectContext(ctx, &subs, query, userID)
+	return subs, err
+}
+
+// Delete deletes a subscription
+func (r *AlertRepository) Delete(ctx context.Context, id int) error {
+	ctx, cancel := withQueryTimeout(ctx, r.timeout)
+	defer cancel()
+
+	query := `DELETE FROM alert_subscriptions WHERE id = $1`
+
+	result, err := r.db.ExecContext(ctx, query, id)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected == 0 {
+		return fmt.Errorf("subscription with id %d not found", id)
+	}
+
+	return nil
+}
+
+// DeleteByToken deletes the subscription with the given unsubscribe token
+// and reports whether one existed
+func (r *AlertRepository) DeleteByToken(ctx context.Context, token string) (bool, error) {
+	ctx, cancel := withQueryTimeout(ctx, r.timeout)
+	defer cancel()
+
+	query := `DELETE FROM alert_subscriptions WHERE unsubscribe_token = $1`
+
+	result, err := r.db.ExecContext(ctx, query, token)
+	if err != nil {
+		return false, err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	return rowsAffected > 0, err
+}
+
+// Matching returns subscriptions of other users whose area, listing types
+// and species match the listing. Areas match by city name or by great-circle
+// distance from the subscription point.
+func (r *AlertRepository) Matching(ctx context.Context, listing *Listing) ([]*AlertSubscription, error) {
+	ctx, cancel := withQueryTimeout(ctx, r.timeout)
+	defer cancel()
+
+	subs := []*AlertSubscription{}
+	query := `
+		SELECT ` + alertSubscriptionColumns + `
+		FROM alert_subscriptions 
+		WHERE user_id <> $1
+		AND (cardinality(types) = 0 OR $2 = ANY(types))
+		AND (cardinality(species) = 0 OR $3 = ANY(species))
+		AND (
+			(city IS NOT NULL AND LOWER(city) = LOWER($4))
+			OR (
+				latitude IS NOT NULL AND $5::DOUBLE PRECISION IS NOT NULL AND $6::DOUBLE PRECISION IS NOT NULL
+				AND 6371 * 2 * ASIN(SQRT(
+					POWER(SIN(RADIANS($5 - latitude) / 2), 2)
+					+ COS(RADIANS(latitude)) * COS(RADIANS($5)) * POWER(SIN(RADIANS($6 - longitude) / 2), 2)
+				)) <= radius_km
+			)
+		)`
+
+	err := r.db.SelectContext(ctx, &subs, query,
+		listing.UserID,
+		listing.Type,
+		listing.Species,
+		listing.City,
+		listing.Latitude,
+		listing.Longitude)
+	return subs, err
+}
+
+// RecordMatch stores a match and reports whether it is new, so that a
+// listing activated twice does not alert the same subscription again
+func (r *AlertRepository) RecordMatch(ctx context.Context, subscriptionID, listingID int) (bool, error) {
+	ctx, cancel := withQueryTimeout(ctx, r.timeout)
+	defer cancel()
+
+	query := `
+		INSERT INTO alert_matches (subscription_id, listing_id, created_at)
+		VALUES ($1, $2, $3)
+		ON CONFLICT DO NOTHING`
+
+	result, err := r.db.ExecContext(ctx, query, subscriptionID, listingID, time.Now())
+	if err != nil {
+		return false, err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	return rowsAffected > 0, err
+}
+
+// MarkSent marks matches of a subscription as delivered
+func (r *AlertRepository) MarkSent(ctx context.Context, subscriptionID int, listingIDs []int) error {
+	ctx, cancel := withQueryTimeout(ctx, r.timeout)
+	defer cancel()
+
+	query := `
+		UPDATE alert_matches SET sent_at = $3 
+		WHERE subscription_id = $1 AND listing_id = ANY($2)`
+
+	_, err := r.db.ExecContext(ctx, query, subscriptionID, pq.Array(listingIDs), time.Now())
+	return err
+}
+
+// DigestsDue returns digest subscriptions with undelivered matches whose
+// last digest was sent before the given time
+func (r *AlertRepository) DigestsDue(ctx context.Context, before time.Time) ([]*AlertSubscription, error) {
+	ctx, cancel := withQueryTimeout(ctx, r.timeout)
+	defer cancel()
+
+	subs := []*AlertSubscription{}
+	query := `
+		SELECT ` + alertSubscriptionColumns + `
+		FROM alert_subscriptions s
+		WHERE frequency = 'digest'
+		AND (last_digest_at IS NULL OR last_digest_at < $1)
+		AND EXISTS (
+			SELECT 1 FROM alert_matches m 
+			WHERE m.subscription_id = s.id AND m.sent_at IS NULL
+		)`
+
+	err := r.db.SelectContext(ctx, &subs, query, before)
+	return subs, err
+}
+
+// PendingListings returns still-visible listings matched by a subscription
+// that have not been delivered yet
+func (r *AlertRepository) PendingListings(ctx context.Context, subscriptionID int) ([]*Listing, error) {
+	ctx, cancel := withQueryTimeout(ctx, r.timeout)
+	defer cancel()
+
+	listings := []*Listing{}
+	query := `
+		SELECT ` + prefixColumns("l", listingColumns) + `
+		FROM alert_matches m
+		JOIN listings l ON l.id = m.listing_id
+		WHERE m.subscription_id = $1 AND m.sent_at IS NULL AND l.deleted_at IS NULL
+			AND l.status = 'active' AND l.moderation IN ('pending', 'approved')
+		ORDER BY m.created_at`
+
+	err := r.db.SelectContext(ctx, &listings, query, subscriptionID)
+	return listings, err
+}
+
+// MarkDigestSent marks all pending matches of a subscription as delivered
+// and records the digest time
+func (r *AlertRepository) MarkDigestSent(ctx context.Context, subscriptionID int) error {
+	ctx, cancel := withQueryTimeout(ctx, r.timeout)
+	defer cancel()
+
+	now := time.Now()
+	query := `
+		WITH sent AS (
+			UPDATE alert_matches SET sent_at = $2 
+			WHERE subscription_id = $1 AND sent_at IS NULL
+		)
+		UPDATE alert_subscriptions SET last_digest_at = $2 WHERE id = $1`
+
+	_, err := r.db.ExecContext(ctx, query, subscriptionID, now)
+	return err
+}
@@ -0,0 +1,132 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+)
+
+// auditEntryColumns is the column list selected into AuditEntry
+const auditEntryColumns = `id, actor_id, action, entity_type, entity_id, changes, host(ip_address) AS ip_address, request_id, created_at`
+
+// AuditRepository stores the audit log of changes to users and listings
+type AuditRepository struct {
+	db      Executor
+	timeout time.Duration
+}
+
+// NewAuditRepository creates a new audit repository
+func NewAuditRepository(db *DB) *AuditRepository {
+	return &AuditRepository{db: db, timeout: db.queryTimeout}
+}
+
+// WithTx returns a copy of the repository that runs its queries in tx
+func (r *AuditRepository) WithTx(tx *sqlx.Tx) *AuditRepository {
+	return &AuditRepository{db: tx, timeout: r.timeout}
+}
+
+// Create appends an entry to the audit log
+func (r *AuditRepository) Create(ctx context.Context, entry *AuditEntry) error {
+	ctx, cancel := withQueryTimeout(ctx, r.timeout)
+	defer cancel()
+
+	query := `
+		INSERT INTO audit_log (actor_id, action, entity_type, entity_id, changes, ip_address, request_id, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+		RETURNING id, created_at`
+
+	return r.db.QueryRowContext(ctx, query,
+		entry.ActorID,
+		entry.Action,
+		entry.EntityType,
+		entry.EntityID,
+		string(entry.Changes),
+		entry.IPAddress,
+		entry.RequestID,
+		time.Now()).
+		Scan(&entry.ID, &entry.CreatedAt)
+}
+
+// AuditFilter narrows down the audit log; zero fields match everything
+type AuditFilter struct {
+	EntityType AuditEntity
+	EntityID   int
+	ActorID    int
+}
+
+// where returns the WHERE clause of the filter and its arguments
+func (f AuditFilter) where() (string, []any) {
+	conditions := []string{"TRUE"}
+	args := []any{}
+
+	if f.EntityType != "" {
+		args = append(args, f.EntityType)
+		conditions = append(conditions, fmt.Sprintf("entity_type = $%d", len(args)))
+	}
+	if f.EntityID != 0 {
+		args = append(args, f.EntityID)
+		conditions = append(conditions, fmt.Sprintf("entity_id = $%d", len(args)))
+	}
+	if f.ActorID != 0 {
+		args = append(args, f.ActorID)
+		conditions = append(conditions, fmt.Sprintf("actor_id = $%d", len(args)))
+	}
+
+	return strings.Join(conditions, " AND "), args
+}
+
+// List returns audit log entries matching the filter, newest first
+func (r *AuditRepository) List(ctx context.Context, filter AuditFilter, limit, offset int) ([]*AuditEntry, error) {
+	ctx, cancel := withQueryTimeout(ctx, r.timeout)
+	defer cancel()
+
+	entries := []*AuditEntry{}
+	where, args := filter.where()
+	query := fmt.Sprintf(`
+		SELECT `+auditEntryColumns+`
+		FROM audit_log
+		WHERE %s
+		ORDER BY created_at DESC, id DESC
+		LIMIT $%d OFFSET $%d`, where, len(args)+1, len(args)+2)
+
+	err := r.db.SelectContext(ctx, &entries, query, append(args, limit, offset)...)
+	return entries, err
+}
+
+// Count returns the number of audit log entries matching the filter
+func (r *AuditRepository) Count(ctx context.Context, filter AuditFilter) (int, error) {
+	ctx, cancel := withQueryTimeout(ctx, r.timeout)
+	defer cancel()
+
+	var count int
+	where, args := filter.where()
+	query := `SELECT COUNT(*) FROM audit_log WHERE ` + where
+
+	err := r.db.GetContext(ctx, &count, query, args...)
+	return count, err
+}
+
+// Scrub removes the recorded values from the entries about the given
+// records, which may hold personal data of an erased user
+func (r *AuditRepository) Scrub(ctx context.Context, entity AuditEntity, ids []int) error {
+	ctx, cancel := withQueryTimeout(ctx, r.timeout)
+	defer cancel()
+
+	query := `UPDATE audit_log SET changes = '{}' WHERE entity_type = $1 AND entity_id = ANY($2)`
+
+	_, err := r.db.ExecContext(ctx, query, entity, pq.Array(ids))
+	return err
+}
+
+// AnonymizeActor removes the IP addresses the user made changes from
+func (r *AuditRepository) AnonymizeActor(ctx context.Context, userID int) error {
+	ctx, cancel := withQueryTimeout(ctx, r.timeout)
+	defer cancel()
+
+	_, err := r.db.ExecContext(ctx, `UPDATE audit_log SET ip_address = NULL WHERE actor_id = $1`, userID)
+	return err
+}
@@ -0,0 +1,54 @@
+package database
+
+import (
+	"context"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// ChallengeRepository remembers solved proof-of-work challenges so that
+// every API instance refuses them afterwards
+type ChallengeRepository struct {
+	db      Executor
+	timeout time.Duration
+}
+
+// NewChallengeRepository creates a new challenge repository
+func NewChallengeRepository(db *DB) *ChallengeRepository {
+	return &ChallengeRepository{db: db, timeout: db.queryTimeout}
+}
+
+// WithTx returns a copy of the repository that runs its queries in tx
+func (r *ChallengeRepository) WithTx(tx *sqlx.Tx) *ChallengeRepository {
+	return &ChallengeRepository{db: tx, timeout: r.timeout}
+}
+
+// MarkUsed records a challenge until it expires and reports whether it was
+// the first to do so
+func (r *ChallengeRepository) MarkUsed(ctx context.Context, id string, expiresAt time.Time) (bool, error) {
+	ctx, cancel := withQueryTimeout(ctx, r.timeout)
+	defer cancel()
+
+	query := `INSERT INTO used_challenges (id, expires_at) VALUES ($1, $2) ON CONFLICT (id) DO NOTHING`
+
+	result, err := r.db.ExecContext(ctx, query, id, expiresAt)
+	if err != nil {
+		return false, err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	return rowsAffected > 0, err
+}
+
+// PurgeExpired removes challenges that can no longer be submitted anyway
+func (r *ChallengeRepository) PurgeExpired(ctx context.Context) (int64, error) {
+	ctx, cancel := withQueryTimeout(ctx, r.timeout)
+	defer cancel()
+
+	result, err := r.db.ExecContext(ctx, `DELETE FROM used_challenges WHERE expires_at < $1`, time.Now())
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
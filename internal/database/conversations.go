@@ -0,0 +1,172 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+)
+
+const conversationColumns = `id, listing_id, owner_id, finder_id, owner_unread, finder_unread, last_message_at, created_at, updated_at`
+
+// ConversationRepository handles conversation and message database operations
+type ConversationRepository struct {
+	db      Executor
+	timeout time.Duration
+}
+
+// NewConversationRepository creates a new conversation repository
+func NewConversationRepository(db *DB) *ConversationRepository {
+	return &ConversationRepository{db: db, timeout: db.queryTimeout}
+}
+
+// WithTx returns a copy of the repository that runs its queries in tx
+func (r *ConversationRepository) WithTx(tx *sqlx.Tx) *ConversationRepository {
+	return &ConversationRepository{db: tx, timeout: r.timeout}
+}
+
+// GetOrCreate returns the conversation of a finder about a listing, creating it if needed
+func (r *ConversationRepository) GetOrCreate(ctx context.Context, listingID, ownerID, finderID int) (*Conversation, error) {
+	ctx, cancel := withQueryTimeout(ctx, r.timeout)
+	defer cancel()
+
+	conversation := &Conversation{}
+	query := `
+		WITH inserted AS (
+			INSERT INTO conversations (listing_id, owner_id, finder_id, created_at)
+			VALUES ($1, $2, $3, $4)
+			ON CONFLICT (listing_id, finder_id) DO NOTHING
+			RETURNING ` + conversationColumns + `
+		)
+		SELECT ` + conversationColumns + ` FROM inserted
+		UNION ALL
+		SELECT ` + conversationColumns + ` FROM conversations WHERE listing_id = $1 AND finder_id = $3
+		LIMIT 1`
+
+	err := r.db.GetContext(ctx, conversation, query, listingID, ownerID, finderID, time.Now())
+	if errors.Is(err, sql.ErrNoRows) {
+		// The row inserted by a concurrent request is outside this
+		// statement's snapshot; a new statement sees it
+		query = `SELECT ` + conversationColumns + ` FROM conversations WHERE listing_id = $1 AND finder_id = $2`
+		err = r.db.GetContext(ctx, conversation, query, listingID, finderID)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return conversation, nil
+}
+
+// GetByID retrieves a conversation by ID
+func (r *ConversationRepository) GetByID(ctx context.Context, id int) (*Conversation, error) {
+	ctx, cancel := withQueryTimeout(ctx, r.timeout)
+	defer cancel()
+
+	conversation := &Conversation{}
+	query := `SELECT ` + conversationColumns + ` FROM conversations WHERE id = $1`
+
+	err := r.db.GetContext(ctx, conversation, query, id)
+	if err != nil {
+		return nil, err
+	}
+
+	return conversation, nil
+}
+
+// ListByUser retrieves conversations the user participates in, most recent first
+func (r *ConversationRepository) ListByUser(ctx context.Context, userID, limit, offset int) ([]*Conversation, error) {
+	ctx, cancel := withQueryTimeout(ctx, r.timeout)
+	defer cancel()
+
+	conversations := []*Conversation{}
+	query := `
+		SELECT ` + conversationColumns + `
+		FROM conversations 
+		WHERE owner_id = $1 OR finder_id = $1 
+		ORDER BY COALESCE(last_message_at, created_at) DESC 
+		LIMIT $2 OFFSET $3`
+
+	err := r.db.SelectContext(ctx, &conversations, query, userID, limit, offset)
+	return conversations, err
+}
+
+// AddMessage stores a message and increments the unread counter of the
+// other participant in a single statement
+func (r *ConversationRepository) AddMessage(ctx context.Context, message *Message) error {
+	ctx, cancel := withQueryTimeout(ctx, r.timeout)
+	defer cancel()
+
+	query := `
+		WITH inserted AS (
+			INSERT INTO messages (conversation_id, sender_id, body, images, created_at)
+			VALUES ($1, $2, $3, $4, $5)
+			RETURNING id, created_at
+		), bumped AS (
+			UPDATE conversations SET
+				owner_unread = owner_unread + CASE WHEN finder_id = $2 THEN 1 ELSE 0 END,
+				finder_unread = finder_unread + CASE WHEN owner_id = $2 THEN 1 ELSE 0 END,
+				last_message_at = $5
+			WHERE id = $1
+		)
+		SELECT id, created_at FROM inserted`
+
+	err := r.db.QueryRowContext(ctx, query,
+		message.ConversationID,
+		message.SenderID,
+		message.Body,
+		pq.Array(message.Images),
+		time.Now()).
+		Scan(&message.ID, &message.CreatedAt)
+
+	return err
+}
+
+// ListMessages retrieves messages of a conversation, newest first
+func (r *ConversationRepository) ListMessages(ctx context.Context, conversationID, limit, offset int) ([]*Message, error) {
+	ctx, cancel := withQueryTimeout(ctx, r.timeout)
+	defer cancel()
+
+	messages := []*Message{}
+	query := `
+		SELECT id, conversation_id, sender_id, body, images, created_at 
+		FROM messages 
+		WHERE conversation_id = $1 
+		ORDER BY created_at DESC, id DESC 
+		LIMIT $2 OFFSET $3`
+
+	err := r.db.SelectContext(ctx, &messages, query, conversationID, limit, offset)
+	return messages, err
+}
+
+// MarkRead resets the unread counter of the participant
+func (r *ConversationRepository) MarkRead(ctx context.Context, conversationID, userID int) error {
+	ctx, cancel := withQueryTimeout(ctx, r.timeout)
+	defer cancel()
+
+	query := `
+		UPDATE conversations SET
+			owner_unread = CASE WHEN owner_id = $2 THEN 0 ELSE owner_unread END,
+			finder_unread = CASE WHEN finder_id = $2 THEN 0 ELSE finder_unread END
+		WHERE id = $1`
+
+	_, err := r.db.ExecContext(ctx, query, conversationID, userID)
+	return err
+}
+
+// UnreadTotal returns the number of unread messages across all conversations of a user
+func (r *ConversationRepository) UnreadTotal(ctx context.Context, userID int) (int, error) {
+	ctx, cancel := withQueryTimeout(ctx, r.timeout)
+	defer cancel()
+
+	var count int
+	query := `
+		SELECT COALESCE(SUM(CASE WHEN owner_id = $1 THEN owner_unread ELSE finder_unread END), 0)
+		FROM conversations 
+		WHERE owner_id = $1 OR finder_id = $1`
+
+	err := r.db.GetContext(ctx, &count, query, userID)
+	return count, err
+}
@@ -1,13 +1,18 @@
 package database
 
 import (
+	"context"
+	"database/sql"
+	"errors"
 	"fmt"
 	"log"
+	"strings"
+	"time"
 
 	"pets_rest/internal/config"
 
 	"github.com/jmoiron/sqlx"
-	_ "github.com/lib/pq" // PostgreSQL driver
+	"github.com/lib/pq" // PostgreSQL driver
 )
 
 // Interface defines the interface for database operations
@@ -19,6 +24,18 @@ type Interface interface {
 // DB holds the database connection
 type DB struct {
 	*sqlx.DB
+	// queryTimeout bounds each repository call; zero disables it
+	queryTimeout time.Duration
+}
+
+// Executor runs queries; it is implemented by both *DB and *sqlx.Tx so that
+// repositories can work inside a transaction
+type Executor interface {
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+	QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row
+	GetContext(ctx context.Context, dest any, query string, args ...any) error
+	SelectContext(ctx context.Context, dest any, query string, args ...any) error
 }
 
 // Connect creates a new database connection and runs migrations
@@ -36,7 +53,7 @@ func Connect(cfg *config.Config) (*DB, error) {
 
 	log.Println("Successfully connected to PostgreSQL database")
 
-	return &DB{db}, nil
+	return &DB{DB: db, queryTimeout: cfg.DBQueryTimeout}, nil
 }
 
 // Close closes the database connection
@@ -48,3 +65,59 @@ func (db *DB) Close() error {
 func (db *DB) Health() error {
 	return db.Ping()
 }
+
+// WithTx runs fn as a unit of work: repositories switched to tx with their
+// WithTx methods make their changes together, committed when fn returns nil
+// and rolled back when it fails or panics. A fiber.Ctx never reports
+// cancellation, so for HTTP handlers only DB_QUERY_TIMEOUT bounds the
+// queries; contexts of jobs and other callers are honoured as usual.
+func (db *DB) WithTx(ctx context.Context, fn func(tx *sqlx.Tx) error) error {
+	tx, err := db.BeginTxx(ctx, nil)
+	if err != nil {
+		return err
+	}
+
+	// A panic recovered further up must not leave the connection holding
+	// an open transaction and its row locks
+	defer func() {
+		if p := recover(); p != nil {
+			_ = tx.Rollback()
+			panic(p)
+		}
+	}()
+
+	if err := fn(tx); err != nil {
+		if rbErr := tx.Rollback(); rbErr != nil {
+			log.Printf("Failed to roll back transaction: %v", rbErr)
+		}
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// withQueryTimeout bounds ctx by the query timeout of a repository; the
+// returned function must be called once the query results are read. It is
+// the only limit on queries run for HTTP requests, whose fiber.Ctx is never
+// cancelled.
+func withQueryTimeout(ctx context.Context, timeout time.Duration) (context.Context, context.CancelFunc) {
+	if timeout <= 0 {
+		return context.WithCancel(ctx)
+	}
+	return context.WithTimeout(ctx, timeout)
+}
+
+// prefixColumns qualifies every column in a comma-separated list with a table alias
+func prefixColumns(alias, columns string) string {
+	parts := strings.Split(columns, ",")
+	for i, col := range parts {
+		parts[i] = alias + "." + strings.TrimSpace(col)
+	}
+	return strings.Join(parts, ", ")
+}
+
+// IsUniqueViolation reports whether err is a PostgreSQL unique constraint violation
+func IsUniqueViolation(err error) bool {
+	var pqErr *pq.Error
+	return errors.As(err, &pqErr) && pqErr.Code == "23505"
+}
@@ -0,0 +1,99 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWithQueryTimeout(t *testing.T) {
+	ctx, cancel := withQueryTimeout(context.Background(), time.Minute)
+	defer cancel()
+
+	deadline, ok := ctx.Deadline()
+	assert.True(t, ok)
+	assert.WithinDuration(t, time.Now().Add(time.Minute), deadline, time.Second)
+
+	// Нульовий таймаут вимикає обмеження, але запит усе ще можна скасувати
+	ctx, cancel = withQueryTimeout(context.Background(), 0)
+	_, ok = ctx.Deadline()
+	assert.False(t, ok)
+	cancel()
+	assert.ErrorIs(t, ctx.Err(), context.Canceled)
+
+	// Коротший дедлайн запиту лишається в силі
+	parent, cancelParent := context.WithTimeout(context.Background(), time.Second)
+	defer cancelParent()
+	ctx, cancel = withQueryTimeout(parent, time.Hour)
+	defer cancel()
+	parentDeadline, _ := parent.Deadline()
+	deadline, _ = ctx.Deadline()
+	assert.Equal(t, parentDeadline, deadline)
+}
+
+// txDriver records how the transactions it opens end
+type txDriver struct {
+	mu    sync.Mutex
+	ended []string
+}
+
+func (d *txDriver) Open(string) (driver.Conn, error) { return &txConn{d: d}, nil }
+
+func (d *txDriver) endings() []string {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return append([]string(nil), d.ended...)
+}
+
+type txConn struct{ d *txDriver }
+
+func (c *txConn) Prepare(string) (driver.Stmt, error) { return nil, errors.New("not supported") }
+func (c *txConn) Close() error                        { return nil }
+func (c *txConn) Begin() (driver.Tx, error)           { return c, nil }
+func (c *txConn) Commit() error                       { return c.end("commit") }
+func (c *txConn) Rollback() error                     { return c.end("rollback") }
+
+func (c *txConn) end(how string) error {
+	c.d.mu.Lock()
+	defer c.d.mu.Unlock()
+	c.d.ended = append(c.d.ended, how)
+	return nil
+}
+
+func newTxDB(t *testing.T) (*DB, *txDriver) {
+	t.Helper()
+
+	drv := &txDriver{}
+	name := "txdriver-" + t.Name()
+	sql.Register(name, drv)
+
+	db, err := sqlx.Open(name, "")
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = db.Close() })
+	return &DB{DB: db}, drv
+}
+
+func TestWithTx(t *testing.T) {
+	db, drv := newTxDB(t)
+	ctx := context.Background()
+
+	require.NoError(t, db.WithTx(ctx, func(*sqlx.Tx) error { return nil }))
+
+	failed := errors.New("failed")
+	assert.ErrorIs(t, db.WithTx(ctx, func(*sqlx.Tx) error { return failed }), failed)
+
+	// Паніка відкочує транзакцію і летить далі до recover-мідлвера
+	assert.PanicsWithValue(t, "boom", func() {
+		_ = db.WithTx(ctx, func(*sqlx.Tx) error { panic("boom") })
+	})
+
+	assert.Equal(t, []string{"commit", "rollback", "rollback"}, drv.endings())
+}
@@ -0,0 +1,77 @@
+package database
+
+import (
+	"context"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// TelegramDialogueRepository stores the listing creation dialogues of the
+// Telegram bot
+type TelegramDialogueRepository struct {
+	db      Executor
+	timeout time.Duration
+}
+
+// NewTelegramDialogueRepository creates a new dialogue repository
+func NewTelegramDialogueRepository(db *DB) *TelegramDialogueRepository {
+	return &TelegramDialogueRepository{db: db, timeout: db.queryTimeout}
+}
+
+// WithTx returns a copy of the repository that runs its queries in tx
+func (r *TelegramDialogueRepository) WithTx(tx *sqlx.Tx) *TelegramDialogueRepository {
+	return &TelegramDialogueRepository{db: tx, timeout: r.timeout}
+}
+
+// Get retrieves the unexpired dialogue of a chat
+func (r *TelegramDialogueRepository) Get(ctx context.Context, chatID int64) (*TelegramDialogue, error) {
+	ctx, cancel := withQueryTimeout(ctx, r.timeout)
+	defer cancel()
+
+	dialogue := &TelegramDialogue{}
+	query := `SELECT chat_id, step, listing, expires_at FROM telegram_dialogues WHERE chat_id = $1 AND expires_at > $2`
+
+	err := r.db.GetContext(ctx, dialogue, query, chatID, time.Now())
+	if err != nil {
+		return nil, err
+	}
+
+	return dialogue, nil
+}
+
+// Save creates or replaces the dialogue of a chat
+func (r *TelegramDialogueRepository) Save(ctx context.Context, dialogue *TelegramDialogue) error {
+	ctx, cancel := withQueryTimeout(ctx, r.timeout)
+	defer cancel()
+
+	query := `
+		INSERT INTO telegram_dialogues (chat_id, step, listing, expires_at)
+		VALUES ($1, $2, $3, $4)
+		ON CONFLICT (chat_id) DO UPDATE
+		SET step = EXCLUDED.step, listing = EXCLUDED.listing, expires_at = EXCLUDED.expires_at`
+
+	_, err := r.db.ExecContext(ctx, query, dialogue.ChatID, dialogue.Step, string(dialogue.Listing), dialogue.ExpiresAt)
+	return err
+}
+
+// Delete ends the dialogue of a chat; a chat without one is not an error
+func (r *TelegramDialogueRepository) Delete(ctx context.Context, chatID int64) error {
+	ctx, cancel := withQueryTimeout(ctx, r.timeout)
+	defer cancel()
+
+	_, err := r.db.ExecContext(ctx, `DELETE FROM telegram_dialogues WHERE chat_id = $1`, chatID)
+	return err
+}
+
+// PurgeExpired removes dialogues abandoned long enough to have expired
+func (r *TelegramDialogueRepository) PurgeExpired(ctx context.Context) (int64, error) {
+	ctx, cancel := withQueryTimeout(ctx, r.timeout)
+	defer cancel()
+
+	result, err := r.db.ExecContext(ctx, `DELETE FROM telegram_dialogues WHERE expires_at < $1`, time.Now())
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
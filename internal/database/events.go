@@ -1,27 +1,39 @@
 package database
 
 import (
+	"context"
 	"time"
+
+	"github.com/jmoiron/sqlx"
 )
 
 // EventRepository handles event database operations
 type EventRepository struct {
-	db *DB
+	db      Executor
+	timeout time.Duration
 }
 
 // NewEventRepository creates a new event repository
 func NewEventRepository(db *DB) *EventRepository {
-	return &EventRepository{db: db}
+	return &EventRepository{db: db, timeout: db.queryTimeout}
+}
+
+// WithTx returns a copy of the repository that runs its queries in tx
+func (r *EventRepository) WithTx(tx *sqlx.Tx) *EventRepository {
+	return &EventRepository{db: tx, timeout: r.timeout}
 }
 
 // Create creates a new event
-func (r *EventRepository) Create(event *Event) error {
+func (r *EventRepository) Create(ctx context.Context, event *Event) error {
+	ctx, cancel := withQueryTimeout(ctx, r.timeout)
+	defer cancel()
+
 	query := `
 		INSERT INTO events (user_id, listing_id, type, payload, ip_address, user_agent, created_at)
 		VALUES ($1, $2, $3, $4, $5, $6, $7)
 		RETURNING id, created_at`
 
-	err := r.db.QueryRow(query,
+	err := r.db.QueryRowContext(ctx, query,
 		event.UserID,
 		event.ListingID,
 		event.Type,
@@ -35,7 +47,10 @@ func (r *EventRepository) Create(event *Event) error {
 }
 
 // GetByListingID retrieves events for a specific listing
-func (r *EventRepository) GetByListingID(listingID, limit, offset int) ([]*Event, error) {
+func (r *EventRepository) GetByListingID(ctx context.Context, listingID, limit, offset int) ([]*Event, error) {
+	ctx, cancel := withQueryTimeout(ctx, r.timeout)
+	defer cancel()
+
 	events := []*Event{}
 	query := `
 		SELECT id, user_id, listing_id, type, payload, ip_address, user_agent, created_at 
@@ -44,12 +59,15 @@ func (r *EventRepository) GetByListingID(listingID, limit, offset int) ([]*Event
 		ORDER BY created_at DESC 
 		LIMIT $2 OFFSET $3`
 
-	err := r.db.Select(&events, query, listingID, limit, offset)
+	err := r.db.SelectContext(ctx, &events, query, listingID, limit, offset)
 	return events, err
 }
 
 // GetByUserID retrieves events for a specific user
-func (r *EventRepository) GetByUserID(userID, limit, offset int) ([]*Event, error) {
+func (r *EventRepository) GetByUserID(ctx context.Context, userID, limit, offset int) ([]*Event, error) {
+	ctx, cancel := withQueryTimeout(ctx, r.timeout)
+	defer cancel()
+
 	events := []*Event{}
 	query := `
 		SELECT id, user_id, listing_id, type, payload, ip_address, user_agent, created_at 
@@ -58,12 +76,15 @@ func (r *EventRepository) GetByUserID(userID, limit, offset int) ([]*Event, erro
 		ORDER BY created_at DESC 
 		LIMIT $2 OFFSET $3`
 
-	err := r.db.Select(&events, query, userID, limit, offset)
+	err := r.db.SelectContext(ctx, &events, query, userID, limit, offset)
 	return events, err
 }
 
 // GetAnalytics returns analytics data for a listing
-func (r *EventRepository) GetAnalytics(listingID int) (map[string]int, error) {
+func (r *EventRepository) GetAnalytics(ctx context.Context, listingID int) (map[string]int, error) {
+	ctx, cancel := withQueryTimeout(ctx, r.timeout)
+	defer cancel()
+
 	analytics := make(map[string]int)
 
 	query := `
@@ -72,7 +93,7 @@ func (r *EventRepository) GetAnalytics(listingID int) (map[string]int, error) {
 		WHERE listing_id = $1 
 		GROUP BY type`
 
-	rows, err := r.db.Query(query, listingID)
+	rows, err := r.db.QueryContext(ctx, query, listingID)
 	if err != nil {
 		return nil, err
 	}
@@ -93,7 +114,10 @@ func (r *EventRepository) GetAnalytics(listingID int) (map[string]int, error) {
 }
 
 // GetDailyAnalytics returns daily analytics for the last N days
-func (r *EventRepository) GetDailyAnalytics(listingID, days int) ([]map[string]interface{}, error) {
+func (r *EventRepository) GetDailyAnalytics(ctx context.Context, listingID, days int) ([]map[string]interface{}, error) {
+	ctx, cancel := withQueryTimeout(ctx, r.timeout)
+	defer cancel()
+
 	query := `
 		SELECT 
 			DATE(created_at) as date,
@@ -101,11 +125,11 @@ func (r *EventRepository) GetDailyAnalytics(listingID, days int) ([]map[string]i
 			COUNT(*) as count
 		FROM events 
 		WHERE listing_id = $1 
-		AND created_at >= NOW() - INTERVAL '%d days'
+		AND created_at >= NOW() - make_interval(days => $2)
 		GROUP BY DATE(created_at), type
 		ORDER BY date DESC, type`
 
-	rows, err := r.db.Query(query, listingID, days)
+	rows, err := r.db.QueryContext(ctx, query, listingID, days)
 	if err != nil {
 		return nil, err
 	}
@@ -133,19 +157,45 @@ func (r *EventRepository) GetDailyAnalytics(listingID, days int) ([]map[string]i
 }
 
 // CountByListing returns the total number of events for a listing
-func (r *EventRepository) CountByListing(listingID int) (int, error) {
+func (r *EventRepository) CountByListing(ctx context.Context, listingID int) (int, error) {
+	ctx, cancel := withQueryTimeout(ctx, r.timeout)
+	defer cancel()
+
 	var count int
 	query := `SELECT COUNT(*) FROM events WHERE listing_id = $1`
 
-	err := r.db.Get(&count, query, listingID)
+	err := r.db.GetContext(ctx, &count, query, listingID)
 	return count, err
 }
 
 // CountByUser returns the total number of events for a user
-func (r *EventRepository) CountByUser(userID int) (int, error) {
+func (r *EventRepository) CountByUser(ctx context.Context, userID int) (int, error) {
+	ctx, cancel := withQueryTimeout(ctx, r.timeout)
+	defer cancel()
+
 	var count int
 	query := `SELECT COUNT(*) FROM events WHERE user_id = $1`
 
-	err := r.db.Get(&count, query, userID)
+	err := r.db.GetContext(ctx, &count, query, userID)
 	return count, err
 }
+
+// GetPlacementAnalytics returns QR scan counts per placement of a listing
+func (r *EventRepository) GetPlacementAnalytics(ctx context.Context, listingID int) ([]*PlacementStats, error) {
+	ctx, cancel := withQueryTimeout(ctx, r.timeout)
+	defer cancel()
+
+	stats := []*PlacementStats{}
+	query := `
+		SELECT p.id AS placement_id, p.name, p.code, COUNT(e.id) AS scans
+		FROM qr_placements p
+		LEFT JOIN events e 
+			ON e.type = 'qr_scan' 
+			AND (e.payload->>'placement_id')::INTEGER = p.id
+		WHERE p.listing_id = $1
+		GROUP BY p.id, p.name, p.code
+		ORDER BY scans DESC, p.id`
+
+	err := r.db.SelectContext(ctx, &stats, query, listingID)
+	return stats, err
+}
@@ -0,0 +1,98 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"io"
+	"testing"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// rowsDriver answers every query with the same rows and records the
+// arguments it was given
+type rowsDriver struct {
+	columns []string
+	values  [][]driver.Value
+
+	args []driver.NamedValue
+}
+
+func (d *rowsDriver) Open(string) (driver.Conn, error) { return &rowsConn{d: d}, nil }
+
+type rowsConn struct{ d *rowsDriver }
+
+func (c *rowsConn) Prepare(string) (driver.Stmt, error) { return nil, errors.New("not supported") }
+func (c *rowsConn) Close() error                        { return nil }
+func (c *rowsConn) Begin() (driver.Tx, error)           { return nil, errors.New("not supported") }
+
+func (c *rowsConn) QueryContext(_ context.Context, _ string, args []driver.NamedValue) (driver.Rows, error) {
+	c.d.args = args
+	return &stubRows{columns: c.d.columns, values: c.d.values}, nil
+}
+
+type stubRows struct {
+	columns []string
+	values  [][]driver.Value
+}
+
+func (r *stubRows) Columns() []string { return r.columns }
+func (r *stubRows) Close() error      { return nil }
+
+func (r *stubRows) Next(dest []driver.Value) error {
+	if len(r.values) == 0 {
+		return io.EOF
+	}
+	copy(dest, r.values[0])
+	r.values = r.values[1:]
+	return nil
+}
+
+func newRowsDB(t *testing.T, drv *rowsDriver) *DB {
+	t.Helper()
+
+	name := "rowsdriver-" + t.Name()
+	sql.Register(name, drv)
+
+	db, err := sqlx.Open(name, "")
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = db.Close() })
+	return &DB{DB: db}
+}
+
+func TestGetPlacementAnalytics(t *testing.T) {
+	drv := &rowsDriver{
+		columns: []string{"placement_id", "name", "code", "scans"},
+		values: [][]driver.Value{
+			{int64(2), "Зупинка", "k7mp2x", int64(5)},
+			{int64(1), "Під'їзд", "ab3cde", int64(0)},
+		},
+	}
+	events := NewEventRepository(newRowsDB(t, drv))
+
+	stats, err := events.GetPlacementAnalytics(context.Background(), 42)
+	require.NoError(t, err)
+	assert.Equal(t, []*PlacementStats{
+		{PlacementID: 2, Name: "Зупинка", Code: "k7mp2x", Scans: 5},
+		{PlacementID: 1, Name: "Під'їзд", Code: "ab3cde", Scans: 0},
+	}, stats)
+
+	require.Len(t, drv.args, 1)
+	assert.Equal(t, int64(42), drv.args[0].Value)
+}
+
+func TestGetPlacementAnalyticsEmpty(t *testing.T) {
+	events := NewEventRepository(newRowsDB(t, &rowsDriver{
+		columns: []string{"placement_id", "name", "code", "scans"},
+	}))
+
+	// Оголошення без розміщень дає порожній список, а не null у JSON
+	stats, err := events.GetPlacementAnalytics(context.Background(), 42)
+	require.NoError(t, err)
+	assert.NotNil(t, stats)
+	assert.Empty(t, stats)
+}
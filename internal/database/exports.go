@@ -0,0 +1,182 @@
+package database
+
+import (
+	"context"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// dataExportColumns is the column list selected into DataExport; the
+// archive is left out because it can be large
+const dataExportColumns = `id, user_id, status, size, error, expires_at, created_at, completed_at`
+
+// DataExportRepository handles personal data exports
+type DataExportRepository struct {
+	db      Executor
+	timeout time.Duration
+}
+
+// NewDataExportRepository creates a new data export repository
+func NewDataExportRepository(db *DB) *DataExportRepository {
+	return &DataExportRepository{db: db, timeout: db.queryTimeout}
+}
+
+// WithTx returns a copy of the repository that runs its queries in tx
+func (r *DataExportRepository) WithTx(tx *sqlx.Tx) *DataExportRepository {
+	return &DataExportRepository{db: tx, timeout: r.timeout}
+}
+
+// Create stores a new pending export
+func (r *DataExportRepository) Create(ctx context.Context, export *DataExport) error {
+	ctx, cancel := withQueryTimeout(ctx, r.timeout)
+	defer cancel()
+
+	query := `
+		INSERT INTO data_exports (user_id, status, created_at)
+		VALUES ($1, $2, $3)
+		RETURNING id, size, created_at`
+
+	export.Status = DataExportStatusPending
+	return r.db.QueryRowContext(ctx, query, export.UserID, export.Status, time.Now()).
+		Scan(&export.ID, &export.Size, &export.CreatedAt)
+}
+
+// GetByID retrieves an export by ID
+func (r *DataExportRepository) GetByID(ctx context.Context, id int) (*DataExport, error) {
+	ctx, cancel := withQueryTimeout(ctx, r.timeout)
+	defer cancel()
+
+	export := &DataExport{}
+	query := `SELECT ` + dataExportColumns + ` FROM data_exports WHERE id = $1`
+
+	err := r.db.GetContext(ctx, export, query, id)
+	if err != nil {
+		return nil, err
+	}
+
+	return export, nil
+}
+
+// Latest returns the most recent export of a user that has not expired
+func (r *DataExportRepository) Latest(ctx context.Context, userID int) (*DataExport, error) {
+	ctx, cancel := withQueryTimeout(ctx, r.timeout)
+	defer cancel()
+
+	export := &DataExport{}
+	query := `
+		SELECT ` + dataExportColumns + `
+		FROM data_exports
+		WHERE user_id = $1 AND (expires_at IS NULL OR expires_at > NOW())
+		ORDER BY created_at DESC, id DESC
+		LIMIT 1`
+
+	err := r.db.GetContext(ctx, export, query, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	return export, nil
+}
+
+// Archive returns the ZIP archive of a ready export
+func (r *DataExportRepository) Archive(ctx context.Context, id int) ([]byte, error) {
+	ctx, cancel := withQueryTimeout(ctx, r.timeout)
+	defer cancel()
+
+	var archive []byte
+	query := `SELECT archive FROM data_exports WHERE id = $1 AND status = 'ready'`
+
+	err := r.db.GetContext(ctx, &archive, query, id)
+	return archive, err
+}
+
+// Complete stores the archive of an export and marks it ready until expiresAt
+func (r *DataExportRepository) Complete(ctx context.Context, id int, archive []byte, expiresAt time.Time) error {
+	ctx, cancel := withQueryTimeout(ctx, r.timeout)
+	defer cancel()
+
+	query := `
+		UPDATE data_exports
+		SET status = 'ready', archive = $2, size = $3, error = NULL, expires_at = $4, completed_at = $5
+		WHERE id = $1`
+
+	_, err := r.db.ExecContext(ctx, query, id, archive, len(archive), expiresAt, time.Now())
+	return err
+}
+
+// Fail marks an export failed with the reason
+func (r *DataExportRepository) Fail(ctx context.Context, id int, reason string) error {
+	ctx, cancel := withQueryTimeout(ctx, r.timeout)
+	defer cancel()
+
+	query := `UPDATE data_exports SET status = 'failed', error = $2, completed_at = $3 WHERE id = $1`
+
+	_, err := r.db.ExecContext(ctx, query, id, reason, time.Now())
+	return err
+}
+
+// PurgeExpired removes exports that expired before the given time and
+// returns how many there were
+func (r *DataExportRepository) PurgeExpired(ctx context.Context, before time.Time) (int64, error) {
+	ctx, cancel := withQueryTimeout(ctx, r.timeout)
+	defer cancel()
+
+	result, err := r.db.ExecContext(ctx, `DELETE FROM data_exports WHERE expires_at < $1`, before)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+// UserData collects everything stored about a user: the profile, sign-in
+// methods, listings including deleted ones, the events the user caused and
+// the conversations the user takes part in with all their messages
+func (r *DataExportRepository) UserData(ctx context.Context, userID int) (*UserData, error) {
+	ctx, cancel := withQueryTimeout(ctx, r.timeout)
+	defer cancel()
+
+	data := &UserData{
+		User:          &User{},
+		Identities:    []*UserIdentity{},
+		Listings:      []*Listing{},
+		Events:        []*Event{},
+		Conversations: []*Conversation{},
+		Messages:      []*Message{},
+	}
+
+	if err := r.db.GetContext(ctx, data.User, `SELECT `+userColumns+` FROM users WHERE id = $1`, userID); err != nil {
+		return nil, err
+	}
+
+	queries := []struct {
+		dest  any
+		query string
+	}{
+		{&data.Identities, `SELECT ` + identityColumns + ` FROM user_identities WHERE user_id = $1 ORDER BY id`},
+		{&data.Listings, `SELECT ` + listingColumns + ` FROM listings WHERE user_id = $1 ORDER BY id`},
+		{&data.Events, `
+			SELECT id, user_id, listing_id, type, payload, host(ip_address) AS ip_address, user_agent, created_at
+			FROM events
+			WHERE user_id = $1
+			ORDER BY id`},
+		{&data.Conversations, `
+			SELECT ` + conversationColumns + `
+			FROM conversations
+			WHERE owner_id = $1 OR finder_id = $1
+			ORDER BY id`},
+		{&data.Messages, `
+			SELECT m.id, m.conversation_id, m.sender_id, m.body, m.images, m.created_at
+			FROM messages m
+			JOIN conversations c ON c.id = m.conversation_id
+			WHERE c.owner_id = $1 OR c.finder_id = $1
+			ORDER BY m.conversation_id, m.id`},
+	}
+	for _, q := range queries {
+		if err := r.db.SelectContext(ctx, q.dest, q.query, userID); err != nil {
+			return nil, err
+		}
+	}
+
+	return data, nil
+}
@@ -0,0 +1,120 @@
+package database
+
+import (
+	"context"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// identityColumns is the column list selected into UserIdentity
+const identityColumns = `id, user_id, provider, provider_id, email, last_login_at, created_at`
+
+// IdentityRepository handles the sign-in methods linked to users
+type IdentityRepository struct {
+	db      Executor
+	timeout time.Duration
+}
+
+// NewIdentityRepository creates a new identity repository
+func NewIdentityRepository(db *DB) *IdentityRepository {
+	return &IdentityRepository{db: db, timeout: db.queryTimeout}
+}
+
+// WithTx returns a copy of the repository that runs its queries in tx
+func (r *IdentityRepository) WithTx(tx *sqlx.Tx) *IdentityRepository {
+	return &IdentityRepository{db: tx, timeout: r.timeout}
+}
+
+// Create links a provider account to a user
+func (r *IdentityRepository) Create(ctx context.Context, identity *UserIdentity) error {
+	ctx, cancel := withQueryTimeout(ctx, r.timeout)
+	defer cancel()
+
+	query := `
+		INSERT INTO user_identities (user_id, provider, provider_id, email, last_login_at, created_at)
+		VALUES ($1, $2, $3, $4, $5, $5)
+		RETURNING id, last_login_at, created_at`
+
+	err := r.db.QueryRowContext(ctx, query,
+		identity.UserID,
+		identity.Provider,
+		identity.ProviderID,
+		identity.Email,
+		time.Now()).
+		Scan(&identity.ID, &identity.LastLoginAt, &identity.CreatedAt)
+
+	return err
+}
+
+// GetByProvider retrieves the identity of a provider account
+func (r *IdentityRepository) GetByProvider(ctx context.Context, provider, providerID string) (*UserIdentity, error) {
+	ctx, cancel := withQueryTimeout(ctx, r.timeout)
+	defer cancel()
+
+	identity := &UserIdentity{}
+	query := `SELECT ` + identityColumns + ` FROM user_identities WHERE provider = $1 AND provider_id = $2`
+
+	err := r.db.GetContext(ctx, identity, query, provider, providerID)
+	if err != nil {
+		return nil, err
+	}
+
+	return identity, nil
+}
+
+// ListByUser retrieves the identities of a user, oldest first
+func (r *IdentityRepository) ListByUser(ctx context.Context, userID int) ([]*UserIdentity, error) {
+	ctx, cancel := withQueryTimeout(ctx, r.timeout)
+	defer cancel()
+
+	identities := []*UserIdentity{}
+	query := `
+		SELECT ` + identityColumns + `
+		FROM user_identities
+		WHERE user_id = $1
+		ORDER BY created_at, id`
+
+	err := r.db.SelectContext(ctx, &identities, query, userID)
+	return identities, err
+}
+
+// ListByUserForUpdate is ListByUser that locks the rows until the
+// transaction ends, so concurrent unlinks cannot remove every identity
+func (r *IdentityRepository) ListByUserForUpdate(ctx context.Context, userID int) ([]*UserIdentity, error) {
+	ctx, cancel := withQueryTimeout(ctx, r.timeout)
+	defer cancel()
+
+	identities := []*UserIdentity{}
+	query := `
+		SELECT ` + identityColumns + `
+		FROM user_identities
+		WHERE user_id = $1
+		ORDER BY created_at, id
+		FOR UPDATE`
+
+	err := r.db.SelectContext(ctx, &identities, query, userID)
+	return identities, err
+}
+
+// TouchLogin records a sign-in with the identity and refreshes its email
+func (r *IdentityRepository) TouchLogin(ctx context.Context, id int, email *string) error {
+	ctx, cancel := withQueryTimeout(ctx, r.timeout)
+	defer cancel()
+
+	query := `UPDATE user_identities SET last_login_at = $2, email = COALESCE($3, email) WHERE id = $1`
+
+	_, err := r.db.ExecContext(ctx, query, id, time.Now(), email)
+	return err
+}
+
+// Delete unlinks an identity
+func (r *IdentityRepository) Delete(ctx context.Context, id int) error {
+	ctx, cancel := withQueryTimeout(ctx, r.timeout)
+	defer cancel()
+
+	query := `DELETE FROM user_identities WHERE id = $1`
+
+	_, err := r.db.ExecContext(ctx, query, id)
+	return err
+}
@@ -0,0 +1,258 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+)
+
+const jobColumns = `id, type, payload, status, attempts, max_attempts, run_at, locked_until, unique_key, last_error, finished_at, created_at, updated_at`
+
+// JobRepository handles background job database operations
+type JobRepository struct {
+	db      Executor
+	timeout time.Duration
+}
+
+// NewJobRepository creates a new job repository
+func NewJobRepository(db *DB) *JobRepository {
+	return &JobRepository{db: db, timeout: db.queryTimeout}
+}
+
+// WithTx returns a copy of the repository that runs its queries in tx
+func (r *JobRepository) WithTx(tx *sqlx.Tx) *JobRepository {
+	return &JobRepository{db: tx, timeout: r.timeout}
+}
+
+// Create queues a job; a job whose unique key is already queued or running
+// is skipped and reported with false
+func (r *JobRepository) Create(ctx context.Context, job *Job) (bool, error) {
+	ctx, cancel := withQueryTimeout(ctx, r.timeout)
+	defer cancel()
+
+	query := `
+		INSERT INTO jobs (type, payload, max_attempts, run_at, unique_key, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $6)
+		ON CONFLICT (unique_key) WHERE unique_key IS NOT NULL AND status IN ('pending', 'running') DO NOTHING
+		RETURNING id, status, created_at, updated_at`
+
+	err := r.db.QueryRowContext(ctx, query,
+		job.Type,
+		string(job.Payload),
+		job.MaxAttempts,
+		job.RunAt,
+		job.UniqueKey,
+		time.Now()).
+		Scan(&job.ID, &job.Status, &job.CreatedAt, &job.UpdatedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return false, nil
+	}
+
+	return err == nil, err
+}
+
+// Claim locks the next due job of the given types until lockUntil and counts
+// the attempt. Running jobs whose lock expired, e.g. after a worker crash,
+// are picked up again. It returns sql.ErrNoRows when nothing is due.
+func (r *JobRepository) Claim(ctx context.Context, types []string, lockUntil time.Time) (*Job, error) {
+	ctx, cancel := withQueryTimeout(ctx, r.timeout)
+	defer cancel()
+
+	job := &Job{}
+	query := `
+		UPDATE jobs 
+		SET status = 'running', attempts = attempts + 1, locked_until = $2, updated_at = NOW()
+		WHERE id = (
+			SELECT id 
+			FROM jobs 
+			WHERE type = ANY($1)
+			AND (
+				(status = 'pending' AND run_at <= NOW())
+				OR (status = 'running' AND locked_until < NOW())
+			)
+			ORDER BY run_at
+			LIMIT 1
+			FOR UPDATE SKIP LOCKED
+		)
+		RETURNING ` + jobColumns
+
+	err := r.db.GetContext(ctx, job, query, pq.Array(types), lockUntil)
+	if err != nil {
+		return nil, err
+	}
+
+	return job, nil
+}
+
+// Complete marks a job as done. Like Retry and Bury it only applies while
+// the job still holds the lock from its Claim, given by lockedUntil, and
+// returns sql.ErrNoRows once the lock expired and another worker took it.
+func (r *JobRepository) Complete(ctx context.Context, id int64, lockedUntil time.Time) error {
+	ctx, cancel := withQueryTimeout(ctx, r.timeout)
+	defer cancel()
+
+	query := `
+		UPDATE jobs 
+		SET status = 'done', locked_until = NULL, last_error = NULL, finished_at = $3, updated_at = $3
+		WHERE id = $1 AND status = 'running' AND locked_until = $2`
+
+	result, err := r.db.ExecContext(ctx, query, id, lockedUntil, time.Now())
+	return lockHeld(result, err)
+}
+
+// Retry puts a failed job back in the queue to run at runAt
+func (r *JobRepository) Retry(ctx context.Context, id int64, lockedUntil time.Time, lastError string, runAt time.Time) error {
+	ctx, cancel := withQueryTimeout(ctx, r.timeout)
+	defer cancel()
+
+	query := `
+		UPDATE jobs 
+		SET status = 'pending', locked_until = NULL, last_error = $3, run_at = $4, updated_at = NOW()
+		WHERE id = $1 AND status = 'running' AND locked_until = $2`
+
+	result, err := r.db.ExecContext(ctx, query, id, lockedUntil, lastError, runAt)
+	return lockHeld(result, err)
+}
+
+// Bury moves a job that will not be retried to the dead-letter state
+func (r *JobRepository) Bury(ctx context.Context, id int64, lockedUntil time.Time, lastError string) error {
+	ctx, cancel := withQueryTimeout(ctx, r.timeout)
+	defer cancel()
+
+	query := `
+		UPDATE jobs 
+		SET status = 'dead', locked_until = NULL, last_error = $3, finished_at = $4, updated_at = $4
+		WHERE id = $1 AND status = 'running' AND locked_until = $2`
+
+	result, err := r.db.ExecContext(ctx, query, id, lockedUntil, lastError, time.Now())
+	return lockHeld(result, err)
+}
+
+// lockHeld turns an update of a locked job that matched no row into
+// sql.ErrNoRows
+func lockHeld(result sql.Result, err error) error {
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected == 0 {
+		return sql.ErrNoRows
+	}
+
+	return nil
+}
+
+// ListDead retrieves dead jobs, newest first
+func (r *JobRepository) ListDead(ctx context.Context, limit, offset int) ([]*Job, error) {
+	ctx, cancel := withQueryTimeout(ctx, r.timeout)
+	defer cancel()
+
+	jobs := []*Job{}
+	query := `
+		SELECT ` + jobColumns + `
+		FROM jobs 
+		WHERE status = 'dead' 
+		ORDER BY finished_at DESC 
+		LIMIT $1 OFFSET $2`
+
+	err := r.db.SelectContext(ctx, &jobs, query, limit, offset)
+	return jobs, err
+}
+
+// CountDead returns the number of dead jobs
+func (r *JobRepository) CountDead(ctx context.Context) (int, error) {
+	ctx, cancel := withQueryTimeout(ctx, r.timeout)
+	defer cancel()
+
+	var count int
+	err := r.db.GetContext(ctx, &count, `SELECT COUNT(*) FROM jobs WHERE status = 'dead'`)
+	return count, err
+}
+
+// Requeue moves a dead job back to the queue with a fresh attempt budget.
+// It fails with a unique violation while another job with the same unique
+// key is queued or running.
+func (r *JobRepository) Requeue(ctx context.Context, id int64) error {
+	ctx, cancel := withQueryTimeout(ctx, r.timeout)
+	defer cancel()
+
+	query := `
+		UPDATE jobs 
+		SET status = 'pending', attempts = 0, run_at = NOW(), finished_at = NULL, updated_at = NOW()
+		WHERE id = $1 AND status = 'dead'`
+
+	result, err := r.db.ExecContext(ctx, query, id)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected == 0 {
+		return sql.ErrNoRows
+	}
+
+	return nil
+}
+
+// PurgeFinished deletes done jobs finished before the given time
+func (r *JobRepository) PurgeFinished(ctx context.Context, before time.Time) (int64, error) {
+	ctx, cancel := withQueryTimeout(ctx, r.timeout)
+	defer cancel()
+
+	result, err := r.db.ExecContext(ctx, `DELETE FROM jobs WHERE status = 'done' AND finished_at < $1`, before)
+	if err != nil {
+		return 0, err
+	}
+
+	return result.RowsAffected()
+}
+
+// UpsertSchedule registers a recurring job; next_run_at is kept unless the
+// spec changed
+func (r *JobRepository) UpsertSchedule(ctx context.Context, name, spec string, nextRunAt time.Time) error {
+	ctx, cancel := withQueryTimeout(ctx, r.timeout)
+	defer cancel()
+
+	query := `
+		INSERT INTO job_schedules (name, spec, next_run_at)
+		VALUES ($1, $2, $3)
+		ON CONFLICT (name) DO UPDATE 
+		SET spec = EXCLUDED.spec,
+			next_run_at = CASE WHEN job_schedules.spec = EXCLUDED.spec THEN job_schedules.next_run_at ELSE EXCLUDED.next_run_at END`
+
+	_, err := r.db.ExecContext(ctx, query, name, spec, nextRunAt)
+	return err
+}
+
+// ClaimSchedule moves a due schedule to its next run and reports whether
+// this caller won the run
+func (r *JobRepository) ClaimSchedule(ctx context.Context, name string, nextRunAt time.Time) (bool, error) {
+	ctx, cancel := withQueryTimeout(ctx, r.timeout)
+	defer cancel()
+
+	query := `
+		UPDATE job_schedules 
+		SET next_run_at = $2, last_run_at = NOW()
+		WHERE name = $1 AND next_run_at <= NOW()`
+
+	result, err := r.db.ExecContext(ctx, query, name, nextRunAt)
+	if err != nil {
+		return false, err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	return rowsAffected > 0, err
+}
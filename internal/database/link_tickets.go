@@ -0,0 +1,96 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// LinkTicketRepository stores the link tickets issued to users so that each
+// can be redeemed only once
+type LinkTicketRepository struct {
+	db      Executor
+	timeout time.Duration
+}
+
+// NewLinkTicketRepository creates a new link ticket repository
+func NewLinkTicketRepository(db *DB) *LinkTicketRepository {
+	return &LinkTicketRepository{db: db, timeout: db.queryTimeout}
+}
+
+// WithTx returns a copy of the repository that runs its queries in tx
+func (r *LinkTicketRepository) WithTx(tx *sqlx.Tx) *LinkTicketRepository {
+	return &LinkTicketRepository{db: tx, timeout: r.timeout}
+}
+
+// Create stores an issued ticket
+func (r *LinkTicketRepository) Create(ctx context.Context, ticket *LinkTicket) error {
+	ctx, cancel := withQueryTimeout(ctx, r.timeout)
+	defer cancel()
+
+	query := `
+		INSERT INTO link_tickets (id, user_id, provider, expires_at, created_at)
+		VALUES ($1, $2, $3, $4, $5)
+		RETURNING created_at`
+
+	return r.db.QueryRowContext(ctx, query, ticket.ID, ticket.UserID, ticket.Provider, ticket.ExpiresAt, time.Now()).
+		Scan(&ticket.CreatedAt)
+}
+
+// Redeem binds an unexpired ticket that was not redeemed yet to the session
+// opening it; sql.ErrNoRows is returned for any other ticket
+func (r *LinkTicketRepository) Redeem(ctx context.Context, id string, userID int, provider, sessionID string) error {
+	ctx, cancel := withQueryTimeout(ctx, r.timeout)
+	defer cancel()
+
+	query := `
+		UPDATE link_tickets SET session_id = $4, redeemed_at = $5
+		WHERE id = $1 AND user_id = $2 AND provider = $3 AND redeemed_at IS NULL AND expires_at > $5`
+
+	result, err := r.db.ExecContext(ctx, query, id, userID, provider, sessionID, time.Now())
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected == 0 {
+		return sql.ErrNoRows
+	}
+
+	return nil
+}
+
+// Consume removes a ticket redeemed by the session and returns the user it
+// was issued to; sql.ErrNoRows is returned when the session has no such
+// unexpired ticket
+func (r *LinkTicketRepository) Consume(ctx context.Context, id, sessionID string) (int, error) {
+	ctx, cancel := withQueryTimeout(ctx, r.timeout)
+	defer cancel()
+
+	var userID int
+	query := `
+		DELETE FROM link_tickets
+		WHERE id = $1 AND session_id = $2 AND redeemed_at IS NOT NULL AND expires_at > $3
+		RETURNING user_id`
+
+	err := r.db.QueryRowContext(ctx, query, id, sessionID, time.Now()).Scan(&userID)
+	return userID, err
+}
+
+// PurgeExpired removes tickets that can no longer be redeemed anyway
+func (r *LinkTicketRepository) PurgeExpired(ctx context.Context) (int64, error) {
+	ctx, cancel := withQueryTimeout(ctx, r.timeout)
+	defer cancel()
+
+	result, err := r.db.ExecContext(ctx, `DELETE FROM link_tickets WHERE expires_at < $1`, time.Now())
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
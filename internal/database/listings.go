@@ -1,56 +1,127 @@
 package database
 
 import (
+	"context"
+	"database/sql"
 	"fmt"
+	"strings"
 	"time"
 
+	"github.com/jmoiron/sqlx"
 	"github.com/lib/pq"
 )
 
+// listingColumns is the column list selected into Listing
+const listingColumns = `id, user_id, type, title, description, city, location, species, latitude, longitude, contact_phone, contact_tg, contacts_hidden, verified_contact, status, moderation, held_for_review, slug, images, version, deleted_at, created_at, updated_at`
+
+// visibleListing is the condition for listings shown publicly, see Listing.Visible
+const visibleListing = `deleted_at IS NULL AND status = 'active' AND moderation IN ('pending', 'approved')`
+
+// refreshVerifiedContactQuery recomputes the verified contact badge of the
+// listings of the users in $1 after their phone numbers change
+const refreshVerifiedContactQuery = `
+	UPDATE listings l
+	SET verified_contact = EXISTS (
+		SELECT 1 FROM users u WHERE u.id = l.user_id AND u.phone_verified AND u.phone = l.contact_phone
+	)
+	WHERE l.user_id = ANY($1)`
+
 // ListingRepository handles listing database operations
 type ListingRepository struct {
-	db *DB
+	db      Executor
+	timeout time.Duration
 }
 
 // NewListingRepository creates a new listing repository
 func NewListingRepository(db *DB) *ListingRepository {
-	return &ListingRepository{db: db}
+	return &ListingRepository{db: db, timeout: db.queryTimeout}
+}
+
+// WithTx returns a copy of the repository that runs its queries in tx
+func (r *ListingRepository) WithTx(tx *sqlx.Tx) *ListingRepository {
+	return &ListingRepository{db: tx, timeout: r.timeout}
 }
 
 // Create creates a new listing
-func (r *ListingRepository) Create(listing *Listing) error {
+func (r *ListingRepository) Create(ctx context.Context, listing *Listing) error {
+	ctx, cancel := withQueryTimeout(ctx, r.timeout)
+	defer cancel()
+
 	query := `
-		INSERT INTO listings (user_id, type, title, description, city, location, contact_phone, contact_tg, status, slug, images, created_at)
-		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12)
-		RETURNING id, created_at`
+		INSERT INTO listings (user_id, type, title, description, city, location, species, latitude, longitude, contact_phone, contact_tg, contacts_hidden, status, slug, images, created_at, held_for_review, verified_contact)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16, $17,
+			EXISTS (SELECT 1 FROM users WHERE id = $1 AND phone_verified AND phone = $10))
+		RETURNING id, verified_contact, moderation, version, created_at`
 
-	err := r.db.QueryRow(query,
+	err := r.db.QueryRowContext(ctx, query,
 		listing.UserID,
 		listing.Type,
 		listing.Title,
 		listing.Description,
 		listing.City,
 		listing.Location,
+		listing.Species,
+		listing.Latitude,
+		listing.Longitude,
 		listing.ContactPhone,
 		listing.ContactTg,
+		listing.ContactsHidden,
 		listing.Status,
 		listing.Slug,
 		pq.Array(listing.Images),
-		time.Now()).
-		Scan(&listing.ID, &listing.CreatedAt)
+		time.Now(),
+		listing.HeldForReview).
+		Scan(&listing.ID, &listing.VerifiedContact, &listing.Moderation, &listing.Version, &listing.CreatedAt)
 
 	return err
 }
 
 // GetByID retrieves a listing by ID
-func (r *ListingRepository) GetByID(id int) (*Listing, error) {
+func (r *ListingRepository) GetByID(ctx context.Context, id int) (*Listing, error) {
+	ctx, cancel := withQueryTimeout(ctx, r.timeout)
+	defer cancel()
+
 	listing := &Listing{}
 	query := `
-		SELECT id, user_id, type, title, description, city, location, contact_phone, contact_tg, status, slug, images, created_at, updated_at 
+		SELECT ` + listingColumns + `
 		FROM listings 
-		WHERE id = $1`
+		WHERE id = $1 AND deleted_at IS NULL`
+
+	err := r.db.GetContext(ctx, listing, query, id)
+	if err != nil {
+		return nil, err
+	}
+
+	return listing, nil
+}
 
-	err := r.db.Get(listing, query, id)
+// GetByIDForUpdate retrieves a listing by ID and locks the row until the
+// transaction ends
+func (r *ListingRepository) GetByIDForUpdate(ctx context.Context, id int) (*Listing, error) {
+	ctx, cancel := withQueryTimeout(ctx, r.timeout)
+	defer cancel()
+
+	listing := &Listing{}
+	query := `SELECT ` + listingColumns + ` FROM listings WHERE id = $1 AND deleted_at IS NULL FOR UPDATE`
+
+	err := r.db.GetContext(ctx, listing, query, id)
+	if err != nil {
+		return nil, err
+	}
+
+	return listing, nil
+}
+
+// GetDeletedByID retrieves a deleted listing that can still be restored,
+// deleted after since, and locks the row until the transaction ends
+func (r *ListingRepository) GetDeletedByID(ctx context.Context, id int, since time.Time) (*Listing, error) {
+	ctx, cancel := withQueryTimeout(ctx, r.timeout)
+	defer cancel()
+
+	listing := &Listing{}
+	query := `SELECT ` + listingColumns + ` FROM listings WHERE id = $1 AND deleted_at >= $2 FOR UPDATE`
+
+	err := r.db.GetContext(ctx, listing, query, id, since)
 	if err != nil {
 		return nil, err
 	}
@@ -59,14 +130,17 @@ func (r *ListingRepository) GetByID(id int) (*Listing, error) {
 }
 
 // GetBySlug retrieves a listing by slug
-func (r *ListingRepository) GetBySlug(slug string) (*Listing, error) {
+func (r *ListingRepository) GetBySlug(ctx context.Context, slug string) (*Listing, error) {
+	ctx, cancel := withQueryTimeout(ctx, r.timeout)
+	defer cancel()
+
 	listing := &Listing{}
 	query := `
-		SELECT id, user_id, type, title, description, city, location, contact_phone, contact_tg, status, slug, images, created_at, updated_at 
+		SELECT ` + listingColumns + `
 		FROM listings 
-		WHERE slug = $1 AND status = 'active'`
+		WHERE slug = $1 AND ` + visibleListing
 
-	err := r.db.Get(listing, query, slug)
+	err := r.db.GetContext(ctx, listing, query, slug)
 	if err != nil {
 		return nil, err
 	}
@@ -74,37 +148,138 @@ func (r *ListingRepository) GetBySlug(slug string) (*Listing, error) {
 	return listing, nil
 }
 
-// Update updates a listing
-func (r *ListingRepository) Update(listing *Listing) error {
+// Update updates a listing; a listing sent back for edits or held by spam
+// screening returns to the moderation queue
+func (r *ListingRepository) Update(ctx context.Context, listing *Listing) error {
+	ctx, cancel := withQueryTimeout(ctx, r.timeout)
+	defer cancel()
+
 	query := `
 		UPDATE listings 
-		SET type = $2, title = $3, description = $4, city = $5, location = $6, contact_phone = $7, contact_tg = $8, status = $9, slug = $10, images = $11, updated_at = $12
-		WHERE id = $1
-		RETURNING updated_at`
-
-	err := r.db.QueryRow(query,
+		SET type = $2, title = $3, description = $4, city = $5, location = $6, species = $7, latitude = $8, longitude = $9, contact_phone = $10, contact_tg = $11, contacts_hidden = $12, status = $13, slug = $14, images = $15, updated_at = $16,
+			verified_contact = EXISTS (SELECT 1 FROM users u WHERE u.id = listings.user_id AND u.phone_verified AND u.phone = $10),
+			held_for_review = $17,
+			moderation = CASE WHEN $17 OR moderation = 'changes_requested' THEN 'pending' ELSE moderation END
+		WHERE id = $1 AND deleted_at IS NULL
+		RETURNING verified_contact, moderation, version, updated_at`
+
+	err := r.db.QueryRowContext(ctx, query,
 		listing.ID,
 		listing.Type,
 		listing.Title,
 		listing.Description,
 		listing.City,
 		listing.Location,
+		listing.Species,
+		listing.Latitude,
+		listing.Longitude,
 		listing.ContactPhone,
 		listing.ContactTg,
+		listing.ContactsHidden,
 		listing.Status,
 		listing.Slug,
 		pq.Array(listing.Images),
-		time.Now()).
-		Scan(&listing.UpdatedAt)
+		time.Now(),
+		listing.HeldForReview).
+		Scan(&listing.VerifiedContact, &listing.Moderation, &listing.Version, &listing.UpdatedAt)
 
 	return err
 }
 
-// Delete deletes a listing
-func (r *ListingRepository) Delete(id int) error {
-	query := `DELETE FROM listings WHERE id = $1`
+// SetContactsHidden stores whether the contacts of a listing are
+// hidden on the public page
+func (r *ListingRepository) SetContactsHidden(ctx context.Context, listing *Listing) error {
+	ctx, cancel := withQueryTimeout(ctx, r.timeout)
+	defer cancel()
+
+	query := `
+		UPDATE listings SET contacts_hidden = $2, updated_at = $3
+		WHERE id = $1 AND deleted_at IS NULL
+		RETURNING version, updated_at`
+
+	return r.db.QueryRowContext(ctx, query, listing.ID, listing.ContactsHidden, time.Now()).
+		Scan(&listing.Version, &listing.UpdatedAt)
+}
+
+// SetModeration changes the moderation state of a listing and reports
+// whether it was different before
+func (r *ListingRepository) SetModeration(ctx context.Context, id int, state ModerationState) (bool, error) {
+	ctx, cancel := withQueryTimeout(ctx, r.timeout)
+	defer cancel()
 
-	result, err := r.db.Exec(query, id)
+	query := `UPDATE listings SET moderation = $2, updated_at = $3 WHERE id = $1 AND moderation <> $2 AND deleted_at IS NULL`
+
+	result, err := r.db.ExecContext(ctx, query, id, state, time.Now())
+	if err != nil {
+		return false, err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	return rowsAffected > 0, err
+}
+
+// Release publishes a listing held by spam screening and reports whether
+// it was held
+func (r *ListingRepository) Release(ctx context.Context, id int) (bool, error) {
+	ctx, cancel := withQueryTimeout(ctx, r.timeout)
+	defer cancel()
+
+	query := `UPDATE listings SET held_for_review = FALSE, status = 'active', updated_at = $2 WHERE id = $1 AND held_for_review AND deleted_at IS NULL`
+
+	result, err := r.db.ExecContext(ctx, query, id, time.Now())
+	if err != nil {
+		return false, err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	return rowsAffected > 0, err
+}
+
+// Signals counts what spam screening needs to know about a listing; recent
+// listings are those of the owner created after since. Deleted listings
+// count too, so that deleting and posting again does not reset the signals.
+func (r *ListingRepository) Signals(ctx context.Context, listing *Listing, since time.Time) (*ListingSignals, error) {
+	ctx, cancel := withQueryTimeout(ctx, r.timeout)
+	defer cancel()
+
+	signals := &ListingSignals{}
+	query := `
+		SELECT
+			(SELECT COUNT(DISTINCT user_id) FROM listings
+			 WHERE contact_phone = $2 AND user_id <> $1) AS phone_accounts,
+			(SELECT COUNT(*) FROM listings
+			 WHERE md5(lower(btrim(description))) = md5(lower(btrim($3))) AND user_id <> $1) AS duplicate_descriptions,
+			(SELECT COUNT(*) FROM listings
+			 WHERE user_id = $1 AND created_at > $4 AND id <> $5) AS recent_listings`
+
+	err := r.db.GetContext(ctx, signals, query, listing.UserID, listing.ContactPhone, listing.Description, since, listing.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	return signals, nil
+}
+
+// HideByUser hides all listings of a user
+func (r *ListingRepository) HideByUser(ctx context.Context, userID int) error {
+	ctx, cancel := withQueryTimeout(ctx, r.timeout)
+	defer cancel()
+
+	query := `UPDATE listings SET moderation = 'hidden', updated_at = $2 WHERE user_id = $1 AND deleted_at IS NULL`
+
+	_, err := r.db.ExecContext(ctx, query, userID, time.Now())
+	return err
+}
+
+// Delete marks a listing deleted; it disappears everywhere but can be
+// restored until PurgeDeleted removes it
+func (r *ListingRepository) Delete(ctx context.Context, id int) error {
+	ctx, cancel := withQueryTimeout(ctx, r.timeout)
+	defer cancel()
+
+	query := `UPDATE listings SET deleted_at = $2 WHERE id = $1 AND deleted_at IS NULL`
+
+	result, err := r.db.ExecContext(ctx, query, id, time.Now())
 	if err != nil {
 		return err
 	}
@@ -115,34 +290,105 @@ func (r *ListingRepository) Delete(id int) error {
 	}
 
 	if rowsAffected == 0 {
-		return fmt.Errorf("listing with id %d not found", id)
+		return sql.ErrNoRows
 	}
 
 	return nil
 }
 
+// Restore brings back a listing deleted after since and reports whether
+// there was one
+func (r *ListingRepository) Restore(ctx context.Context, id int, since time.Time) (bool, error) {
+	ctx, cancel := withQueryTimeout(ctx, r.timeout)
+	defer cancel()
+
+	query := `UPDATE listings SET deleted_at = NULL, updated_at = $3 WHERE id = $1 AND deleted_at >= $2`
+
+	result, err := r.db.ExecContext(ctx, query, id, since, time.Now())
+	if err != nil {
+		return false, err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	return rowsAffected > 0, err
+}
+
+// PurgeDeleted removes listings deleted before the given time for good and
+// returns how many there were
+func (r *ListingRepository) PurgeDeleted(ctx context.Context, before time.Time) (int64, error) {
+	ctx, cancel := withQueryTimeout(ctx, r.timeout)
+	defer cancel()
+
+	result, err := r.db.ExecContext(ctx, `DELETE FROM listings WHERE deleted_at < $1`, before)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
 // ListByUser retrieves all listings for a user
-func (r *ListingRepository) ListByUser(userID, limit, offset int) ([]*Listing, error) {
+func (r *ListingRepository) ListByUser(ctx context.Context, userID, limit, offset int) ([]*Listing, error) {
+	ctx, cancel := withQueryTimeout(ctx, r.timeout)
+	defer cancel()
+
 	listings := []*Listing{}
 	query := `
-		SELECT id, user_id, type, title, description, city, location, contact_phone, contact_tg, status, slug, images, created_at, updated_at 
+		SELECT ` + listingColumns + `
 		FROM listings 
-		WHERE user_id = $1 
+		WHERE user_id = $1 AND deleted_at IS NULL
 		ORDER BY created_at DESC 
 		LIMIT $2 OFFSET $3`
 
-	err := r.db.Select(&listings, query, userID, limit, offset)
+	err := r.db.SelectContext(ctx, &listings, query, userID, limit, offset)
+	return listings, err
+}
+
+// ListAllByUser retrieves every listing of a user, locking them for the
+// rest of the transaction; used by changes that touch all of them at once
+func (r *ListingRepository) ListAllByUser(ctx context.Context, userID int) ([]*Listing, error) {
+	ctx, cancel := withQueryTimeout(ctx, r.timeout)
+	defer cancel()
+
+	listings := []*Listing{}
+	query := `
+		SELECT ` + listingColumns + `
+		FROM listings
+		WHERE user_id = $1 AND deleted_at IS NULL
+		ORDER BY id
+		FOR UPDATE`
+
+	err := r.db.SelectContext(ctx, &listings, query, userID)
+	return listings, err
+}
+
+// ListDeletedWithUser retrieves the listings deleted together with a user,
+// see UserRepository.Delete
+func (r *ListingRepository) ListDeletedWithUser(ctx context.Context, user *User) ([]*Listing, error) {
+	ctx, cancel := withQueryTimeout(ctx, r.timeout)
+	defer cancel()
+
+	listings := []*Listing{}
+	query := `
+		SELECT ` + listingColumns + `
+		FROM listings
+		WHERE user_id = $1 AND deleted_at = $2
+		ORDER BY id`
+
+	err := r.db.SelectContext(ctx, &listings, query, user.ID, user.DeletedAt)
 	return listings, err
 }
 
 // ListActive retrieves all active listings with optional filters
-func (r *ListingRepository) ListActive(listingType *ListingType, city *string, limit, offset int) ([]*Listing, error) {
+func (r *ListingRepository) ListActive(ctx context.Context, listingType *ListingType, city *string, limit, offset int) ([]*Listing, error) {
+	ctx, cancel := withQueryTimeout(ctx, r.timeout)
+	defer cancel()
+
 	listings := []*Listing{}
 
 	baseQuery := `
-		SELECT id, user_id, type, title, description, city, location, contact_phone, contact_tg, status, slug, images, created_at, updated_at 
+		SELECT ` + listingColumns + `
 		FROM listings 
-		WHERE status = 'active'`
+		WHERE ` + visibleListing
 
 	args := []interface{}{}
 	argCount := 0
@@ -173,40 +419,181 @@ func (r *ListingRepository) ListActive(listingType *ListingType, city *string, l
 		}
 	}
 
-	err := r.db.Select(&listings, baseQuery, args...)
+	err := r.db.SelectContext(ctx, &listings, baseQuery, args...)
 	return listings, err
 }
 
 // Search searches listings by title and description
-func (r *ListingRepository) Search(query string, limit, offset int) ([]*Listing, error) {
+func (r *ListingRepository) Search(ctx context.Context, query string, limit, offset int) ([]*Listing, error) {
+	ctx, cancel := withQueryTimeout(ctx, r.timeout)
+	defer cancel()
+
 	listings := []*Listing{}
 	searchQuery := `
-		SELECT id, user_id, type, title, description, city, location, contact_phone, contact_tg, status, slug, images, created_at, updated_at 
+		SELECT ` + listingColumns + `
 		FROM listings 
-		WHERE status = 'active' 
+		WHERE ` + visibleListing + `
 		AND (title ILIKE $1 OR description ILIKE $1)
 		ORDER BY created_at DESC 
 		LIMIT $2 OFFSET $3`
 
 	searchTerm := "%" + query + "%"
-	err := r.db.Select(&listings, searchQuery, searchTerm, limit, offset)
+	err := r.db.SelectContext(ctx, &listings, searchQuery, searchTerm, limit, offset)
+	return listings, err
+}
+
+// ListingFilter narrows down listing lists for administrators; zero fields
+// match everything
+type ListingFilter struct {
+	// Query matches the title or description
+	Query      string
+	Status     ListingStatus
+	Moderation ModerationState
+	UserID     int
+	// Deleted lists deleted listings instead of the others
+	Deleted bool
+}
+
+// where returns the WHERE clause of the filter and its arguments
+func (f ListingFilter) where() (string, []any) {
+	conditions := []string{"deleted_at IS NULL"}
+	if f.Deleted {
+		conditions[0] = "deleted_at IS NOT NULL"
+	}
+	args := []any{}
+
+	if f.Query != "" {
+		args = append(args, "%"+f.Query+"%")
+		conditions = append(conditions, fmt.Sprintf("(title ILIKE $%d OR description ILIKE $%d)", len(args), len(args)))
+	}
+	if f.Status != "" {
+		args = append(args, f.Status)
+		conditions = append(conditions, fmt.Sprintf("status = $%d", len(args)))
+	}
+	if f.Moderation != "" {
+		args = append(args, f.Moderation)
+		conditions = append(conditions, fmt.Sprintf("moderation = $%d", len(args)))
+	}
+	if f.UserID != 0 {
+		args = append(args, f.UserID)
+		conditions = append(conditions, fmt.Sprintf("user_id = $%d", len(args)))
+	}
+
+	return strings.Join(conditions, " AND "), args
+}
+
+// List retrieves listings matching the filter, including drafts and hidden
+// ones, with pagination
+func (r *ListingRepository) List(ctx context.Context, filter ListingFilter, limit, offset int) ([]*Listing, error) {
+	ctx, cancel := withQueryTimeout(ctx, r.timeout)
+	defer cancel()
+
+	listings := []*Listing{}
+	where, args := filter.where()
+	query := fmt.Sprintf(`
+		SELECT `+listingColumns+`
+		FROM listings 
+		WHERE %s
+		ORDER BY created_at DESC 
+		LIMIT $%d OFFSET $%d`, where, len(args)+1, len(args)+2)
+
+	err := r.db.SelectContext(ctx, &listings, query, append(args, limit, offset)...)
 	return listings, err
 }
 
-// Count returns the total number of listings
-func (r *ListingRepository) Count() (int, error) {
+// Count returns the number of listings matching the filter
+func (r *ListingRepository) Count(ctx context.Context, filter ListingFilter) (int, error) {
+	ctx, cancel := withQueryTimeout(ctx, r.timeout)
+	defer cancel()
+
 	var count int
-	query := `SELECT COUNT(*) FROM listings`
+	where, args := filter.where()
+	query := `SELECT COUNT(*) FROM listings WHERE ` + where
 
-	err := r.db.Get(&count, query)
+	err := r.db.GetContext(ctx, &count, query, args...)
 	return count, err
 }
 
+// Archive takes a listing down regardless of its owner and reports whether
+// it was not archived before
+func (r *ListingRepository) Archive(ctx context.Context, id int) (bool, error) {
+	ctx, cancel := withQueryTimeout(ctx, r.timeout)
+	defer cancel()
+
+	query := `UPDATE listings SET status = 'archived', held_for_review = FALSE, updated_at = $2 WHERE id = $1 AND status <> 'archived' AND deleted_at IS NULL`
+
+	result, err := r.db.ExecContext(ctx, query, id, time.Now())
+	if err != nil {
+		return false, err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	return rowsAffected > 0, err
+}
+
 // CountByUser returns the total number of listings for a user
-func (r *ListingRepository) CountByUser(userID int) (int, error) {
+func (r *ListingRepository) CountByUser(ctx context.Context, userID int) (int, error) {
+	ctx, cancel := withQueryTimeout(ctx, r.timeout)
+	defer cancel()
+
 	var count int
-	query := `SELECT COUNT(*) FROM listings WHERE user_id = $1`
+	query := `SELECT COUNT(*) FROM listings WHERE user_id = $1 AND deleted_at IS NULL`
 
-	err := r.db.Get(&count, query, userID)
+	err := r.db.GetContext(ctx, &count, query, userID)
 	return count, err
 }
+
+// matchRadiusKm is how far apart listings with coordinates may be to match
+const matchRadiusKm = 10
+
+// counterpartTypes maps a listing type to the type of listings that may be
+// about the same animal
+var counterpartTypes = map[ListingType]ListingType{
+	ListingTypeLost:  ListingTypeFound,
+	ListingTypeFound: ListingTypeLost,
+}
+
+// MatchCandidates returns visible listings of other owners that may
+// be about the same animal: found ones for a lost listing and the other way
+// round, of the same species when both name one, in the same city or within
+// matchRadiusKm, newest first
+func (r *ListingRepository) MatchCandidates(ctx context.Context, listing *Listing, limit int) ([]*Listing, error) {
+	listings := []*Listing{}
+	counterpart, ok := counterpartTypes[listing.Type]
+	if !ok {
+		return listings, nil
+	}
+
+	ctx, cancel := withQueryTimeout(ctx, r.timeout)
+	defer cancel()
+
+	query := `
+		SELECT ` + listingColumns + `
+		FROM listings
+		WHERE ` + visibleListing + `
+		AND user_id <> $1 AND type = $2
+		AND (species IS NULL OR $3::TEXT IS NULL OR LOWER(species) = LOWER($3))
+		AND (
+			(city IS NOT NULL AND LOWER(city) = LOWER($4))
+			OR (
+				latitude IS NOT NULL AND $5::DOUBLE PRECISION IS NOT NULL AND $6::DOUBLE PRECISION IS NOT NULL
+				AND 6371 * 2 * ASIN(SQRT(
+					POWER(SIN(RADIANS($5 - latitude) / 2), 2)
+					+ COS(RADIANS(latitude)) * COS(RADIANS($5)) * POWER(SIN(RADIANS($6 - longitude) / 2), 2)
+				)) <= $7
+			)
+		)
+		ORDER BY created_at DESC
+		LIMIT $8`
+
+	err := r.db.SelectContext(ctx, &listings, query,
+		listing.UserID,
+		counterpart,
+		listing.Species,
+		listing.City,
+		listing.Latitude,
+		listing.Longitude,
+		matchRadiusKm,
+		limit)
+	return listings, err
+}
@@ -9,14 +9,63 @@ import (
 	"github.com/lib/pq"
 )
 
+// Role decides what a user is allowed to do besides managing their own data
+type Role string
+
+const (
+	RoleUser      Role = "user"
+	RoleModerator Role = "moderator"
+	RoleAdmin     Role = "admin"
+	// RoleShelter is a partner shelter or volunteer group
+	RoleShelter Role = "shelter"
+)
+
+// Valid reports whether the role is one of the known roles
+func (r Role) Valid() bool {
+	switch r {
+	case RoleUser, RoleModerator, RoleAdmin, RoleShelter:
+		return true
+	}
+	return false
+}
+
 // User represents a user in the system
 type User struct {
-	ID        int        `json:"id" db:"id"`
-	Email     string     `json:"email" db:"email"`
-	Phone     *string    `json:"phone,omitempty" db:"phone"`
-	Name      *string    `json:"name,omitempty" db:"name"`
-	CreatedAt time.Time  `json:"created_at" db:"created_at"`
-	UpdatedAt *time.Time `json:"updated_at,omitempty" db:"updated_at"`
+	ID             int        `json:"id" db:"id"`
+	Email          string     `json:"email" db:"email"`
+	Phone          *string    `json:"phone,omitempty" db:"phone"`
+	PhoneVerified  bool       `json:"phone_verified" db:"phone_verified"`
+	Name           *string    `json:"name,omitempty" db:"name"`
+	TelegramChatID *int64     `json:"-" db:"telegram_chat_id"`
+	Locale         string     `json:"locale" db:"locale"`
+	Role           Role       `json:"role" db:"role"`
+	BannedAt       *time.Time `json:"banned_at,omitempty" db:"banned_at"`
+	DeletedAt      *time.Time `json:"deleted_at,omitempty" db:"deleted_at"`
+	CreatedAt      time.Time  `json:"created_at" db:"created_at"`
+	UpdatedAt      *time.Time `json:"updated_at,omitempty" db:"updated_at"`
+}
+
+// UserIdentity is a provider account (Google, Apple, ...) a user signs in with
+type UserIdentity struct {
+	ID          int        `json:"id" db:"id"`
+	UserID      int        `json:"user_id" db:"user_id"`
+	Provider    string     `json:"provider" db:"provider"`
+	ProviderID  string     `json:"-" db:"provider_id"`
+	Email       *string    `json:"email,omitempty" db:"email"`
+	LastLoginAt *time.Time `json:"last_login_at,omitempty" db:"last_login_at"`
+	CreatedAt   time.Time  `json:"created_at" db:"created_at"`
+}
+
+// PhoneVerification is a one-time code sent to a phone number
+type PhoneVerification struct {
+	ID         int        `json:"id" db:"id"`
+	UserID     int        `json:"user_id" db:"user_id"`
+	Phone      string     `json:"phone" db:"phone"`
+	CodeHash   string     `json:"-" db:"code_hash"`
+	Attempts   int        `json:"attempts" db:"attempts"`
+	ExpiresAt  time.Time  `json:"expires_at" db:"expires_at"`
+	VerifiedAt *time.Time `json:"verified_at,omitempty" db:"verified_at"`
+	CreatedAt  time.Time  `json:"created_at" db:"created_at"`
 }
 
 // ListingType represents the type of listing
@@ -37,22 +86,183 @@ const (
 	ListingStatusArchived ListingStatus = "archived"
 )
 
+// ModerationState tells whether moderators let a listing be shown
+type ModerationState string
+
+const (
+	// ModerationPending listings are shown but wait in the moderation queue
+	ModerationPending          ModerationState = "pending"
+	ModerationApproved         ModerationState = "approved"
+	ModerationHidden           ModerationState = "hidden"
+	ModerationChangesRequested ModerationState = "changes_requested"
+)
+
 // Listing represents a pet listing
 type Listing struct {
-	ID           int            `json:"id" db:"id"`
-	UserID       int            `json:"user_id" db:"user_id"`
-	Type         ListingType    `json:"type" db:"type"`
-	Title        string         `json:"title" db:"title"`
-	Description  *string        `json:"description,omitempty" db:"description"`
-	City         *string        `json:"city,omitempty" db:"city"`
-	Location     *string        `json:"location,omitempty" db:"location"`
-	ContactPhone *string        `json:"contact_phone,omitempty" db:"contact_phone"`
-	ContactTg    *string        `json:"contact_tg,omitempty" db:"contact_tg"`
-	Status       ListingStatus  `json:"status" db:"status"`
-	Slug         *string        `json:"slug,omitempty" db:"slug"`
-	Images       pq.StringArray `json:"images" db:"images"`
-	CreatedAt    time.Time      `json:"created_at" db:"created_at"`
-	UpdatedAt    *time.Time     `json:"updated_at,omitempty" db:"updated_at"`
+	ID             int         `json:"id" db:"id"`
+	UserID         int         `json:"user_id" db:"user_id"`
+	Type           ListingType `json:"type" db:"type"`
+	Title          string      `json:"title" db:"title"`
+	Description    *string     `json:"description,omitempty" db:"description"`
+	City           *string     `json:"city,omitempty" db:"city"`
+	Location       *string     `json:"location,omitempty" db:"location"`
+	Species        *string     `json:"species,omitempty" db:"species"`
+	Latitude       *float64    `json:"latitude,omitempty" db:"latitude"`
+	Longitude      *float64    `json:"longitude,omitempty" db:"longitude"`
+	ContactPhone   *string     `json:"contact_phone,omitempty" db:"contact_phone"`
+	ContactTg      *string     `json:"contact_tg,omitempty" db:"contact_tg"`
+	ContactsHidden bool        `json:"contacts_hidden" db:"contacts_hidden"`
+	// VerifiedContact is set when ContactPhone is the owner's verified number
+	VerifiedContact bool            `json:"verified_contact" db:"verified_contact"`
+	Status          ListingStatus   `json:"status" db:"status"`
+	Moderation      ModerationState `json:"moderation" db:"moderation"`
+	// HeldForReview keeps a listing in draft until a moderator approves it
+	HeldForReview bool           `json:"held_for_review" db:"held_for_review"`
+	Slug          *string        `json:"slug,omitempty" db:"slug"`
+	Images        pq.StringArray `json:"images" db:"images"`
+	// Version grows with every change and is sent to clients as the ETag
+	Version   int        `json:"version" db:"version"`
+	DeletedAt *time.Time `json:"deleted_at,omitempty" db:"deleted_at"`
+	CreatedAt time.Time  `json:"created_at" db:"created_at"`
+	UpdatedAt *time.Time `json:"updated_at,omitempty" db:"updated_at"`
+}
+
+// RevisionContent is the part of a listing kept in its revision history
+type RevisionContent struct {
+	Title          string         `json:"title" db:"title"`
+	Description    *string        `json:"description,omitempty" db:"description"`
+	ContactPhone   *string        `json:"contact_phone,omitempty" db:"contact_phone"`
+	ContactTg      *string        `json:"contact_tg,omitempty" db:"contact_tg"`
+	ContactsHidden bool           `json:"contacts_hidden" db:"contacts_hidden"`
+	Status         ListingStatus  `json:"status" db:"status"`
+	Images         pq.StringArray `json:"images" db:"images"`
+}
+
+// Revision returns the content of the listing kept in its history
+func (l *Listing) Revision() RevisionContent {
+	return RevisionContent{
+		Title:          l.Title,
+		Description:    l.Description,
+		ContactPhone:   l.ContactPhone,
+		ContactTg:      l.ContactTg,
+		ContactsHidden: l.ContactsHidden,
+		Status:         l.Status,
+		Images:         l.Images,
+	}
+}
+
+// Revert replaces the content of the listing with that of a revision
+func (l *Listing) Revert(content RevisionContent) {
+	l.Title = content.Title
+	l.Description = content.Description
+	l.ContactPhone = content.ContactPhone
+	l.ContactTg = content.ContactTg
+	l.ContactsHidden = content.ContactsHidden
+	l.Status = content.Status
+	l.Images = content.Images
+}
+
+// ListingRevision is the content of a listing at one of its versions and
+// who made the change
+type ListingRevision struct {
+	ID        int  `json:"id" db:"id"`
+	ListingID int  `json:"listing_id" db:"listing_id"`
+	Version   int  `json:"version" db:"version"`
+	EditorID  *int `json:"editor_id,omitempty" db:"editor_id"`
+	RevisionContent
+	CreatedAt time.Time `json:"created_at" db:"created_at"`
+}
+
+// ListingSignals are counts about a listing's owner and content used by
+// spam screening
+type ListingSignals struct {
+	// PhoneAccounts is the number of other accounts using the contact phone
+	PhoneAccounts int `db:"phone_accounts"`
+	// DuplicateDescriptions is the number of other accounts' listings with
+	// the same description
+	DuplicateDescriptions int `db:"duplicate_descriptions"`
+	// RecentListings is the number of other listings the owner created recently
+	RecentListings int `db:"recent_listings"`
+}
+
+// Visible reports whether the listing may be shown publicly
+func (l *Listing) Visible() bool {
+	return l.DeletedAt == nil && l.Status == ListingStatusActive &&
+		(l.Moderation == ModerationPending || l.Moderation == ModerationApproved)
+}
+
+// Public returns a copy of the listing that is safe to show to anonymous
+// visitors: contacts are removed when the owner chose to hide them
+func (l *Listing) Public() *Listing {
+	public := *l
+	if public.ContactsHidden {
+		public.ContactPhone = nil
+		public.ContactTg = nil
+	}
+	return &public
+}
+
+// ReportReason is why a visitor reported a listing
+type ReportReason string
+
+const (
+	ReportReasonScam          ReportReason = "scam"
+	ReportReasonSpam          ReportReason = "spam"
+	ReportReasonAbuse         ReportReason = "abuse"
+	ReportReasonAnimalWelfare ReportReason = "animal_welfare"
+	ReportReasonOther         ReportReason = "other"
+)
+
+// Valid reports whether the reason is one of the known reasons
+func (r ReportReason) Valid() bool {
+	switch r {
+	case ReportReasonScam, ReportReasonSpam, ReportReasonAbuse, ReportReasonAnimalWelfare, ReportReasonOther:
+		return true
+	}
+	return false
+}
+
+// ListingReport is a complaint about a listing
+type ListingReport struct {
+	ID         int          `json:"id" db:"id"`
+	ListingID  int          `json:"listing_id" db:"listing_id"`
+	ReporterID *int         `json:"reporter_id,omitempty" db:"reporter_id"`
+	Reason     ReportReason `json:"reason" db:"reason"`
+	Comment    *string      `json:"comment,omitempty" db:"comment"`
+	IPAddress  *string      `json:"-" db:"ip_address"`
+	ResolvedAt *time.Time   `json:"resolved_at,omitempty" db:"resolved_at"`
+	CreatedAt  time.Time    `json:"created_at" db:"created_at"`
+}
+
+// ModerationActionType is a decision taken on a listing or its owner
+type ModerationActionType string
+
+const (
+	ModerationActionApprove      ModerationActionType = "approve"
+	ModerationActionHide         ModerationActionType = "hide"
+	ModerationActionRequireEdits ModerationActionType = "require_edits"
+	ModerationActionBanUser      ModerationActionType = "ban_user"
+	// ModerationActionAutoHide is taken by the system when reports pile up
+	ModerationActionAutoHide ModerationActionType = "auto_hide"
+	// ModerationActionHold is taken by spam screening
+	ModerationActionHold ModerationActionType = "hold"
+)
+
+// ModerationAction is an audit trail entry of a moderation decision
+type ModerationAction struct {
+	ID           int                  `json:"id" db:"id"`
+	ListingID    *int                 `json:"listing_id,omitempty" db:"listing_id"`
+	TargetUserID *int                 `json:"target_user_id,omitempty" db:"target_user_id"`
+	ModeratorID  *int                 `json:"moderator_id,omitempty" db:"moderator_id"`
+	Action       ModerationActionType `json:"action" db:"action"`
+	Note         *string              `json:"note,omitempty" db:"note"`
+	CreatedAt    time.Time            `json:"created_at" db:"created_at"`
+}
+
+// QueuedListing is a listing waiting for a moderator with its open reports
+type QueuedListing struct {
+	Listing
+	OpenReports int `json:"open_reports" db:"open_reports"`
 }
 
 // EventType represents the type of event
@@ -102,3 +312,302 @@ type Event struct {
 	UserAgent *string     `json:"user_agent,omitempty" db:"user_agent"`
 	CreatedAt time.Time   `json:"created_at" db:"created_at"`
 }
+
+// QRPlacement represents a named printed poster of a listing with its own QR code
+type QRPlacement struct {
+	ID        int       `json:"id" db:"id"`
+	ListingID int       `json:"listing_id" db:"listing_id"`
+	Name      string    `json:"name" db:"name"`
+	Code      string    `json:"code" db:"code"`
+	Latitude  *float64  `json:"latitude,omitempty" db:"latitude"`
+	Longitude *float64  `json:"longitude,omitempty" db:"longitude"`
+	CreatedAt time.Time `json:"created_at" db:"created_at"`
+}
+
+// PlacementStats holds the number of QR scans attributed to a placement
+type PlacementStats struct {
+	PlacementID int    `json:"placement_id" db:"placement_id"`
+	Name        string `json:"name" db:"name"`
+	Code        string `json:"code" db:"code"`
+	Scans       int    `json:"scans" db:"scans"`
+}
+
+// ShortLinkSource tells where a short link is published
+type ShortLinkSource string
+
+const (
+	ShortLinkSourceQR   ShortLinkSource = "qr"
+	ShortLinkSourceLink ShortLinkSource = "link"
+)
+
+// ShortLink represents a short code that redirects to a listing's public page
+type ShortLink struct {
+	ID        int             `json:"id" db:"id"`
+	ListingID int             `json:"listing_id" db:"listing_id"`
+	Code      string          `json:"code" db:"code"`
+	Source    ShortLinkSource `json:"source" db:"source"`
+	CreatedAt time.Time       `json:"created_at" db:"created_at"`
+}
+
+// Conversation represents a message thread between a listing owner and a finder
+type Conversation struct {
+	ID            int        `json:"id" db:"id"`
+	ListingID     int        `json:"listing_id" db:"listing_id"`
+	OwnerID       int        `json:"owner_id" db:"owner_id"`
+	FinderID      int        `json:"finder_id" db:"finder_id"`
+	OwnerUnread   int        `json:"-" db:"owner_unread"`
+	FinderUnread  int        `json:"-" db:"finder_unread"`
+	LastMessageAt *time.Time `json:"last_message_at,omitempty" db:"last_message_at"`
+	CreatedAt     time.Time  `json:"created_at" db:"created_at"`
+	UpdatedAt     *time.Time `json:"updated_at,omitempty" db:"updated_at"`
+}
+
+// HasParticipant reports whether the user is the owner or the finder
+func (c *Conversation) HasParticipant(userID int) bool {
+	return c.OwnerID == userID || c.FinderID == userID
+}
+
+// OtherParticipant returns the ID of the participant who is not userID
+func (c *Conversation) OtherParticipant(userID int) int {
+	if c.OwnerID == userID {
+		return c.FinderID
+	}
+	return c.OwnerID
+}
+
+// UnreadFor returns the number of unread messages for the participant
+func (c *Conversation) UnreadFor(userID int) int {
+	if c.OwnerID == userID {
+		return c.OwnerUnread
+	}
+	return c.FinderUnread
+}
+
+// Message represents a message in a conversation
+type Message struct {
+	ID             int            `json:"id" db:"id"`
+	ConversationID int            `json:"conversation_id" db:"conversation_id"`
+	SenderID       int            `json:"sender_id" db:"sender_id"`
+	Body           string         `json:"body" db:"body"`
+	Images         pq.StringArray `json:"images" db:"images"`
+	CreatedAt      time.Time      `json:"created_at" db:"created_at"`
+}
+
+// AlertChannel represents how alert notifications are delivered
+type AlertChannel string
+
+const (
+	AlertChannelEmail    AlertChannel = "email"
+	AlertChannelPush     AlertChannel = "push"
+	AlertChannelTelegram AlertChannel = "telegram"
+)
+
+// AlertFrequency represents whether alerts are sent one by one or batched
+type AlertFrequency string
+
+const (
+	AlertFrequencyInstant AlertFrequency = "instant"
+	AlertFrequencyDigest  AlertFrequency = "digest"
+)
+
+// AlertSubscription represents a user's subscription to new listings in an area
+type AlertSubscription struct {
+	ID               int            `json:"id" db:"id"`
+	UserID           int            `json:"user_id" db:"user_id"`
+	City             *string        `json:"city,omitempty" db:"city"`
+	Latitude         *float64       `json:"latitude,omitempty" db:"latitude"`
+	Longitude        *float64       `json:"longitude,omitempty" db:"longitude"`
+	RadiusKm         *float64       `json:"radius_km,omitempty" db:"radius_km"`
+	Types            pq.StringArray `json:"types" db:"types"`
+	Species          pq.StringArray `json:"species" db:"species"`
+	Channel          AlertChannel   `json:"channel" db:"channel"`
+	Frequency        AlertFrequency `json:"frequency" db:"frequency"`
+	UnsubscribeToken string         `json:"-" db:"unsubscribe_token"`
+	LastDigestAt     *time.Time     `json:"last_digest_at,omitempty" db:"last_digest_at"`
+	CreatedAt        time.Time      `json:"created_at" db:"created_at"`
+}
+
+// WebhookEndpoint represents a partner URL that receives signed listing events
+type WebhookEndpoint struct {
+	ID                  int            `json:"id" db:"id"`
+	UserID              int            `json:"user_id" db:"user_id"`
+	URL                 string         `json:"url" db:"url"`
+	Secret              string         `json:"-" db:"secret"`
+	EventTypes          pq.StringArray `json:"event_types" db:"event_types"`
+	Active              bool           `json:"active" db:"active"`
+	ConsecutiveFailures int            `json:"consecutive_failures" db:"consecutive_failures"`
+	DisabledAt          *time.Time     `json:"disabled_at,omitempty" db:"disabled_at"`
+	CreatedAt           time.Time      `json:"created_at" db:"created_at"`
+	UpdatedAt           time.Time      `json:"updated_at" db:"updated_at"`
+}
+
+// WebhookDeliveryStatus represents the state of a webhook delivery
+type WebhookDeliveryStatus string
+
+const (
+	WebhookDeliveryPending   WebhookDeliveryStatus = "pending"
+	WebhookDeliveryDelivered WebhookDeliveryStatus = "delivered"
+	WebhookDeliveryFailed    WebhookDeliveryStatus = "failed"
+)
+
+// WebhookDelivery represents one event sent (or to be sent) to an endpoint
+type WebhookDelivery struct {
+	ID             int                   `json:"id" db:"id"`
+	EndpointID     int                   `json:"endpoint_id" db:"endpoint_id"`
+	EventType      string                `json:"event_type" db:"event_type"`
+	Payload        json.RawMessage       `json:"payload" db:"payload"`
+	Status         WebhookDeliveryStatus `json:"status" db:"status"`
+	Attempts       int                   `json:"attempts" db:"attempts"`
+	NextAttemptAt  time.Time             `json:"next_attempt_at" db:"next_attempt_at"`
+	ResponseStatus *int                  `json:"response_status,omitempty" db:"response_status"`
+	LastError      *string               `json:"last_error,omitempty" db:"last_error"`
+	DeliveredAt    *time.Time            `json:"delivered_at,omitempty" db:"delivered_at"`
+	CreatedAt      time.Time             `json:"created_at" db:"created_at"`
+}
+
+// NotificationChannel represents a way of delivering notifications
+type NotificationChannel string
+
+const (
+	NotificationChannelEmail    NotificationChannel = "email"
+	NotificationChannelTelegram NotificationChannel = "telegram"
+	NotificationChannelWebhook  NotificationChannel = "webhook"
+	NotificationChannelInApp    NotificationChannel = "in_app"
+)
+
+// NotificationStatus represents the state of an outbox entry
+type NotificationStatus string
+
+const (
+	NotificationStatusPending NotificationStatus = "pending"
+	NotificationStatusSent    NotificationStatus = "sent"
+	NotificationStatusFailed  NotificationStatus = "failed"
+)
+
+// OutboxNotification is a notification waiting to be sent through one channel
+type OutboxNotification struct {
+	ID            int                 `json:"id" db:"id"`
+	UserID        int                 `json:"user_id" db:"user_id"`
+	Kind          string              `json:"kind" db:"kind"`
+	Channel       NotificationChannel `json:"channel" db:"channel"`
+	Data          JSONPayload         `json:"data" db:"data"`
+	Status        NotificationStatus  `json:"status" db:"status"`
+	Attempts      int                 `json:"attempts" db:"attempts"`
+	NextAttemptAt time.Time           `json:"next_attempt_at" db:"next_attempt_at"`
+	LastError     *string             `json:"last_error,omitempty" db:"last_error"`
+	SentAt        *time.Time          `json:"sent_at,omitempty" db:"sent_at"`
+	CreatedAt     time.Time           `json:"created_at" db:"created_at"`
+}
+
+// JobStatus represents the state of a background job
+type JobStatus string
+
+const (
+	JobStatusPending JobStatus = "pending"
+	JobStatusRunning JobStatus = "running"
+	JobStatusDone    JobStatus = "done"
+	JobStatusDead    JobStatus = "dead"
+)
+
+// Job is a unit of background work
+type Job struct {
+	ID          int64           `json:"id" db:"id"`
+	Type        string          `json:"type" db:"type"`
+	Payload     json.RawMessage `json:"payload" db:"payload"`
+	Status      JobStatus       `json:"status" db:"status"`
+	Attempts    int             `json:"attempts" db:"attempts"`
+	MaxAttempts int             `json:"max_attempts" db:"max_attempts"`
+	RunAt       time.Time       `json:"run_at" db:"run_at"`
+	LockedUntil *time.Time      `json:"locked_until,omitempty" db:"locked_until"`
+	UniqueKey   *string         `json:"unique_key,omitempty" db:"unique_key"`
+	LastError   *string         `json:"last_error,omitempty" db:"last_error"`
+	FinishedAt  *time.Time      `json:"finished_at,omitempty" db:"finished_at"`
+	CreatedAt   time.Time       `json:"created_at" db:"created_at"`
+	UpdatedAt   time.Time       `json:"updated_at" db:"updated_at"`
+}
+
+// AuditAction is the kind of change recorded in the audit log
+type AuditAction string
+
+const (
+	AuditActionCreate AuditAction = "create"
+	AuditActionUpdate AuditAction = "update"
+	AuditActionDelete AuditAction = "delete"
+)
+
+// AuditEntity is the type of record an audit log entry is about
+type AuditEntity string
+
+const (
+	AuditEntityUser    AuditEntity = "user"
+	AuditEntityListing AuditEntity = "listing"
+)
+
+// AuditEntry records who changed a user or listing and how; Changes maps
+// column names to their old and new values
+type AuditEntry struct {
+	ID         int64           `json:"id" db:"id"`
+	ActorID    *int            `json:"actor_id,omitempty" db:"actor_id"`
+	Action     AuditAction     `json:"action" db:"action"`
+	EntityType AuditEntity     `json:"entity_type" db:"entity_type"`
+	EntityID   int             `json:"entity_id" db:"entity_id"`
+	Changes    json.RawMessage `json:"changes" db:"changes"`
+	IPAddress  *string         `json:"ip_address,omitempty" db:"ip_address"`
+	RequestID  *string         `json:"request_id,omitempty" db:"request_id"`
+	CreatedAt  time.Time       `json:"created_at" db:"created_at"`
+}
+
+// DataExportStatus represents the progress of a personal data export
+type DataExportStatus string
+
+const (
+	DataExportStatusPending DataExportStatus = "pending"
+	DataExportStatusReady   DataExportStatus = "ready"
+	DataExportStatusFailed  DataExportStatus = "failed"
+)
+
+// DataExport is a ZIP archive of everything stored about a user; the
+// archive itself is loaded separately, see DataExportRepository.Archive
+type DataExport struct {
+	ID          int              `json:"id" db:"id"`
+	UserID      int              `json:"-" db:"user_id"`
+	Status      DataExportStatus `json:"status" db:"status"`
+	Size        int64            `json:"size" db:"size"`
+	Error       *string          `json:"error,omitempty" db:"error"`
+	ExpiresAt   *time.Time       `json:"expires_at,omitempty" db:"expires_at"`
+	CreatedAt   time.Time        `json:"created_at" db:"created_at"`
+	CompletedAt *time.Time       `json:"completed_at,omitempty" db:"completed_at"`
+}
+
+// UserData is the personal data of a user collected for an export
+type UserData struct {
+	User          *User           `json:"user"`
+	Identities    []*UserIdentity `json:"identities"`
+	Listings      []*Listing      `json:"listings"`
+	Events        []*Event        `json:"events"`
+	Conversations []*Conversation `json:"conversations"`
+	Messages      []*Message      `json:"messages"`
+}
+
+// TelegramDialogue is the saved state of a listing creation dialogue in a
+// Telegram chat
+type TelegramDialogue struct {
+	ChatID int64 `json:"chat_id" db:"chat_id"`
+	Step   int   `json:"step" db:"step"`
+	// Listing is the draft built from the answers so far
+	Listing   json.RawMessage `json:"listing" db:"listing"`
+	ExpiresAt time.Time       `json:"expires_at" db:"expires_at"`
+}
+
+// LinkTicket is an issued ticket to link a sign-in method to an account,
+// stored under its jti
+type LinkTicket struct {
+	ID       string `json:"id" db:"id"`
+	UserID   int    `json:"user_id" db:"user_id"`
+	Provider string `json:"provider" db:"provider"`
+	// SessionID is the session that redeemed the ticket
+	SessionID  *string    `json:"-" db:"session_id"`
+	RedeemedAt *time.Time `json:"redeemed_at,omitempty" db:"redeemed_at"`
+	ExpiresAt  time.Time  `json:"expires_at" db:"expires_at"`
+	CreatedAt  time.Time  `json:"created_at" db:"created_at"`
+}
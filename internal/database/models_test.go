@@ -0,0 +1,18 @@
+package database
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestConversationUnreadFor(t *testing.T) {
+	conversation := &Conversation{OwnerID: 1, FinderID: 2, OwnerUnread: 3, FinderUnread: 5}
+
+	// Кожен учасник бачить лише свій лічильник
+	assert.Equal(t, 3, conversation.UnreadFor(1))
+	assert.Equal(t, 5, conversation.UnreadFor(2))
+
+	assert.Equal(t, 2, conversation.OtherParticipant(1))
+	assert.Equal(t, 1, conversation.OtherParticipant(2))
+}
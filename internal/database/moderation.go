@@ -0,0 +1,193 @@
+package database
+
+import (
+	"context"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+)
+
+const (
+	// listingReportColumns is the column list selected into ListingReport
+	listingReportColumns = `id, listing_id, reporter_id, reason, comment, host(ip_address) AS ip_address, resolved_at, created_at`
+	// moderationActionColumns is the column list selected into ModerationAction
+	moderationActionColumns = `id, listing_id, target_user_id, moderator_id, action, note, created_at`
+)
+
+// ModerationRepository handles listing reports and the moderation audit trail
+type ModerationRepository struct {
+	db      Executor
+	timeout time.Duration
+}
+
+// NewModerationRepository creates a new moderation repository
+func NewModerationRepository(db *DB) *ModerationRepository {
+	return &ModerationRepository{db: db, timeout: db.queryTimeout}
+}
+
+// WithTx returns a copy of the repository that runs its queries in tx
+func (r *ModerationRepository) WithTx(tx *sqlx.Tx) *ModerationRepository {
+	return &ModerationRepository{db: tx, timeout: r.timeout}
+}
+
+// CreateReport stores a report; it returns false when the same reporter,
+// or for anonymous visitors the same address, already has an open report
+// about the listing
+func (r *ModerationRepository) CreateReport(ctx context.Context, report *ListingReport) (bool, error) {
+	ctx, cancel := withQueryTimeout(ctx, r.timeout)
+	defer cancel()
+
+	query := `
+		INSERT INTO listing_reports (listing_id, reporter_id, reason, comment, ip_address, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		ON CONFLICT DO NOTHING
+		RETURNING id, created_at`
+
+	rows, err := r.db.QueryContext(ctx, query, report.ListingID, report.ReporterID, report.Reason, report.Comment, report.IPAddress, time.Now())
+	if err != nil {
+		return false, err
+	}
+	defer rows.Close()
+
+	if !rows.Next() {
+		return false, rows.Err()
+	}
+	return true, rows.Scan(&report.ID, &report.CreatedAt)
+}
+
+// ReportCounts are the unresolved reports about a listing
+type ReportCounts struct {
+	// Open counts all of them
+	Open int `db:"open"`
+	// SignedIn counts those from signed-in users
+	SignedIn int `db:"signed_in"`
+}
+
+// CountOpenReports returns the number of unresolved reports about a listing
+// made after since
+func (r *ModerationRepository) CountOpenReports(ctx context.Context, listingID int, since time.Time) (*ReportCounts, error) {
+	ctx, cancel := withQueryTimeout(ctx, r.timeout)
+	defer cancel()
+
+	counts := &ReportCounts{}
+	query := `
+		SELECT COUNT(*) AS open, COUNT(reporter_id) AS signed_in
+		FROM listing_reports
+		WHERE listing_id = $1 AND resolved_at IS NULL AND created_at > $2`
+
+	err := r.db.GetContext(ctx, counts, query, listingID, since)
+	return counts, err
+}
+
+// ListReports returns the reports about a listing, open ones first
+func (r *ModerationRepository) ListReports(ctx context.Context, listingID int) ([]*ListingReport, error) {
+	ctx, cancel := withQueryTimeout(ctx, r.timeout)
+	defer cancel()
+
+	reports := []*ListingReport{}
+	query := `
+		SELECT ` + listingReportColumns + `
+		FROM listing_reports
+		WHERE listing_id = $1
+		ORDER BY resolved_at IS NOT NULL, created_at DESC`
+
+	err := r.db.SelectContext(ctx, &reports, query, listingID)
+	return reports, err
+}
+
+// ResolveReports closes the open reports about a listing
+func (r *ModerationRepository) ResolveReports(ctx context.Context, listingID int) error {
+	ctx, cancel := withQueryTimeout(ctx, r.timeout)
+	defer cancel()
+
+	query := `UPDATE listing_reports SET resolved_at = $2 WHERE listing_id = $1 AND resolved_at IS NULL`
+
+	_, err := r.db.ExecContext(ctx, query, listingID, time.Now())
+	return err
+}
+
+// ResolveReportsByUser closes the open reports about all listings of a user
+func (r *ModerationRepository) ResolveReportsByUser(ctx context.Context, userID int) error {
+	ctx, cancel := withQueryTimeout(ctx, r.timeout)
+	defer cancel()
+
+	query := `
+		UPDATE listing_reports SET resolved_at = $2
+		WHERE resolved_at IS NULL AND listing_id IN (SELECT id FROM listings WHERE user_id = $1)`
+
+	_, err := r.db.ExecContext(ctx, query, userID, time.Now())
+	return err
+}
+
+// CreateAction records a moderation decision in the audit trail
+func (r *ModerationRepository) CreateAction(ctx context.Context, action *ModerationAction) error {
+	ctx, cancel := withQueryTimeout(ctx, r.timeout)
+	defer cancel()
+
+	query := `
+		INSERT INTO moderation_actions (listing_id, target_user_id, moderator_id, action, note, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		RETURNING id, created_at`
+
+	err := r.db.QueryRowContext(ctx, query, action.ListingID, action.TargetUserID, action.ModeratorID, action.Action, action.Note, time.Now()).
+		Scan(&action.ID, &action.CreatedAt)
+
+	return err
+}
+
+// ListActions returns the audit trail of a listing and of its owner,
+// newest first
+func (r *ModerationRepository) ListActions(ctx context.Context, listingID int) ([]*ModerationAction, error) {
+	ctx, cancel := withQueryTimeout(ctx, r.timeout)
+	defer cancel()
+
+	actions := []*ModerationAction{}
+	query := `
+		SELECT ` + moderationActionColumns + `
+		FROM moderation_actions
+		WHERE listing_id = $1
+			OR target_user_id = (SELECT user_id FROM listings WHERE id = $1)
+		ORDER BY created_at DESC, id DESC`
+
+	err := r.db.SelectContext(ctx, &actions, query, listingID)
+	return actions, err
+}
+
+// LastApprovedAt returns when a moderator last approved a listing, or nil
+// when nobody has
+func (r *ModerationRepository) LastApprovedAt(ctx context.Context, listingID int) (*time.Time, error) {
+	ctx, cancel := withQueryTimeout(ctx, r.timeout)
+	defer cancel()
+
+	var approvedAt *time.Time
+	query := `SELECT MAX(created_at) FROM moderation_actions WHERE listing_id = $1 AND action = 'approve'`
+
+	err := r.db.GetContext(ctx, &approvedAt, query, listingID)
+	return approvedAt, err
+}
+
+// Queue returns listings waiting for a moderator: reported ones first, by
+// number of open reports, then published listings and listings held by spam
+// screening that nobody has reviewed yet, in the order they were created
+func (r *ModerationRepository) Queue(ctx context.Context, limit, offset int) ([]*QueuedListing, error) {
+	ctx, cancel := withQueryTimeout(ctx, r.timeout)
+	defer cancel()
+
+	queue := []*QueuedListing{}
+	query := `
+		SELECT ` + prefixColumns("l", listingColumns) + `, COALESCE(rp.open_reports, 0) AS open_reports
+		FROM listings l
+		LEFT JOIN (
+			SELECT listing_id, COUNT(*) AS open_reports
+			FROM listing_reports
+			WHERE resolved_at IS NULL
+			GROUP BY listing_id
+		) rp ON rp.listing_id = l.id
+		WHERE l.deleted_at IS NULL
+			AND ((l.moderation = 'pending' AND (l.status = 'active' OR l.held_for_review)) OR rp.open_reports > 0)
+		ORDER BY COALESCE(rp.open_reports, 0) DESC, l.created_at
+		LIMIT $1 OFFSET $2`
+
+	err := r.db.SelectContext(ctx, &queue, query, limit, offset)
+	return queue, err
+}
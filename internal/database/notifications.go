@@ -0,0 +1,147 @@
+package database
+
+import (
+	"context"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+)
+
+const outboxColumns = `id, user_id, kind, channel, data, status, attempts, next_attempt_at, last_error, sent_at, created_at`
+
+// NotificationRepository handles notification preferences and the outbox
+type NotificationRepository struct {
+	db      Executor
+	timeout time.Duration
+}
+
+// NewNotificationRepository creates a new notification repository
+func NewNotificationRepository(db *DB) *NotificationRepository {
+	return &NotificationRepository{db: db, timeout: db.queryTimeout}
+}
+
+// WithTx returns a copy of the repository that runs its queries in tx
+func (r *NotificationRepository) WithTx(tx *sqlx.Tx) *NotificationRepository {
+	return &NotificationRepository{db: tx, timeout: r.timeout}
+}
+
+// Preferences returns the channels a user chose per notification kind;
+// kinds without a row use their default channels
+func (r *NotificationRepository) Preferences(ctx context.Context, userID int) (map[string][]NotificationChannel, error) {
+	ctx, cancel := withQueryTimeout(ctx, r.timeout)
+	defer cancel()
+
+	rows, err := r.db.QueryContext(ctx, `SELECT kind, channels FROM notification_preferences WHERE user_id = $1`, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = rows.Close() }()
+
+	prefs := make(map[string][]NotificationChannel)
+	for rows.Next() {
+		var kind string
+		var channels pq.StringArray
+		if err := rows.Scan(&kind, &channels); err != nil {
+			return nil, err
+		}
+		prefs[kind] = make([]NotificationChannel, len(channels))
+		for i, ch := range channels {
+			prefs[kind][i] = NotificationChannel(ch)
+		}
+	}
+
+	return prefs, rows.Err()
+}
+
+// SetPreference stores the channels of one notification kind for a user
+func (r *NotificationRepository) SetPreference(ctx context.Context, userID int, kind string, channels []NotificationChannel) error {
+	ctx, cancel := withQueryTimeout(ctx, r.timeout)
+	defer cancel()
+
+	query := `
+		INSERT INTO notification_preferences (user_id, kind, channels, updated_at)
+		VALUES ($1, $2, $3, $4)
+		ON CONFLICT (user_id, kind) DO UPDATE SET channels = EXCLUDED.channels, updated_at = EXCLUDED.updated_at`
+
+	names := make([]string, len(channels))
+	for i, ch := range channels {
+		names[i] = string(ch)
+	}
+
+	_, err := r.db.ExecContext(ctx, query, userID, kind, pq.Array(names), time.Now())
+	return err
+}
+
+// Enqueue adds a notification to the outbox
+func (r *NotificationRepository) Enqueue(ctx context.Context, n *OutboxNotification) error {
+	ctx, cancel := withQueryTimeout(ctx, r.timeout)
+	defer cancel()
+
+	query := `
+		INSERT INTO notification_outbox (user_id, kind, channel, data, next_attempt_at, created_at)
+		VALUES ($1, $2, $3, $4, $5, $5)
+		RETURNING id, status, next_attempt_at, created_at`
+
+	err := r.db.QueryRowContext(ctx, query,
+		n.UserID,
+		n.Kind,
+		n.Channel,
+		n.Data,
+		time.Now()).
+		Scan(&n.ID, &n.Status, &n.NextAttemptAt, &n.CreatedAt)
+
+	return err
+}
+
+// ClaimDue picks pending notifications whose attempt is due, counts the
+// attempt and leases them until the given time so that concurrent workers
+// skip them
+func (r *NotificationRepository) ClaimDue(ctx context.Context, limit int, leaseUntil time.Time) ([]*OutboxNotification, error) {
+	ctx, cancel := withQueryTimeout(ctx, r.timeout)
+	defer cancel()
+
+	notifications := []*OutboxNotification{}
+	query := `
+		UPDATE notification_outbox 
+		SET attempts = attempts + 1, next_attempt_at = $2
+		WHERE id IN (
+			SELECT id 
+			FROM notification_outbox 
+			WHERE status = 'pending' AND next_attempt_at <= NOW()
+			ORDER BY next_attempt_at
+			LIMIT $1
+			FOR UPDATE SKIP LOCKED
+		)
+		RETURNING ` + outboxColumns
+
+	err := r.db.SelectContext(ctx, &notifications, query, limit, leaseUntil)
+	return notifications, err
+}
+
+// MarkSent records a successful delivery
+func (r *NotificationRepository) MarkSent(ctx context.Context, id int) error {
+	ctx, cancel := withQueryTimeout(ctx, r.timeout)
+	defer cancel()
+
+	query := `UPDATE notification_outbox SET status = 'sent', last_error = NULL, sent_at = $2 WHERE id = $1`
+
+	_, err := r.db.ExecContext(ctx, query, id, time.Now())
+	return err
+}
+
+// MarkFailed records a failed attempt; the notification is retried at
+// nextAttempt or given up on when nextAttempt is nil
+func (r *NotificationRepository) MarkFailed(ctx context.Context, id int, lastError string, nextAttempt *time.Time) error {
+	ctx, cancel := withQueryTimeout(ctx, r.timeout)
+	defer cancel()
+
+	query := `
+		UPDATE notification_outbox 
+		SET status = CASE WHEN $3::TIMESTAMPTZ IS NULL THEN 'failed' ELSE 'pending' END,
+			last_error = $2, next_attempt_at = COALESCE($3, next_attempt_at)
+		WHERE id = $1`
+
+	_, err := r.db.ExecContext(ctx, query, id, lastError, nextAttempt)
+	return err
+}
@@ -0,0 +1,120 @@
+package database
+
+import (
+	"context"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// phoneVerificationColumns is the column list selected into PhoneVerification
+const phoneVerificationColumns = `id, user_id, phone, code_hash, attempts, expires_at, verified_at, created_at`
+
+// PhoneVerificationRepository handles one-time phone verification codes
+type PhoneVerificationRepository struct {
+	db      Executor
+	timeout time.Duration
+}
+
+// NewPhoneVerificationRepository creates a new phone verification repository
+func NewPhoneVerificationRepository(db *DB) *PhoneVerificationRepository {
+	return &PhoneVerificationRepository{db: db, timeout: db.queryTimeout}
+}
+
+// WithTx returns a copy of the repository that runs its queries in tx
+func (r *PhoneVerificationRepository) WithTx(tx *sqlx.Tx) *PhoneVerificationRepository {
+	return &PhoneVerificationRepository{db: tx, timeout: r.timeout}
+}
+
+// Create stores a new code
+func (r *PhoneVerificationRepository) Create(ctx context.Context, v *PhoneVerification) error {
+	ctx, cancel := withQueryTimeout(ctx, r.timeout)
+	defer cancel()
+
+	query := `
+		INSERT INTO phone_verifications (user_id, phone, code_hash, expires_at, created_at)
+		VALUES ($1, $2, $3, $4, $5)
+		RETURNING id, attempts, created_at`
+
+	err := r.db.QueryRowContext(ctx, query, v.UserID, v.Phone, v.CodeHash, v.ExpiresAt, time.Now()).
+		Scan(&v.ID, &v.Attempts, &v.CreatedAt)
+
+	return err
+}
+
+// Latest returns the most recent code sent for a user
+func (r *PhoneVerificationRepository) Latest(ctx context.Context, userID int) (*PhoneVerification, error) {
+	ctx, cancel := withQueryTimeout(ctx, r.timeout)
+	defer cancel()
+
+	v := &PhoneVerification{}
+	query := `
+		SELECT ` + phoneVerificationColumns + `
+		FROM phone_verifications
+		WHERE user_id = $1
+		ORDER BY created_at DESC, id DESC
+		LIMIT 1`
+
+	err := r.db.GetContext(ctx, v, query, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	return v, nil
+}
+
+// CountSince returns how many codes were sent to a user and to a phone
+// number since the given time
+func (r *PhoneVerificationRepository) CountSince(ctx context.Context, userID int, phone string, since time.Time) (byUser, byPhone int, err error) {
+	ctx, cancel := withQueryTimeout(ctx, r.timeout)
+	defer cancel()
+
+	query := `
+		SELECT
+			COUNT(*) FILTER (WHERE user_id = $1),
+			COUNT(*) FILTER (WHERE phone = $2)
+		FROM phone_verifications
+		WHERE (user_id = $1 OR phone = $2) AND created_at >= $3`
+
+	err = r.db.QueryRowContext(ctx, query, userID, phone, since).Scan(&byUser, &byPhone)
+	return byUser, byPhone, err
+}
+
+// Attempt counts a guess against the user's latest unexpired, unverified
+// code and returns it; there is no row when the code has expired, was
+// already used or ran out of attempts. Counting in the same statement keeps
+// parallel guesses from exceeding maxAttempts.
+func (r *PhoneVerificationRepository) Attempt(ctx context.Context, userID, maxAttempts int) (*PhoneVerification, error) {
+	ctx, cancel := withQueryTimeout(ctx, r.timeout)
+	defer cancel()
+
+	v := &PhoneVerification{}
+	query := `
+		UPDATE phone_verifications SET attempts = attempts + 1
+		WHERE id = (
+			SELECT id FROM phone_verifications
+			WHERE user_id = $1
+			ORDER BY created_at DESC, id DESC
+			LIMIT 1
+		)
+		AND verified_at IS NULL AND expires_at > NOW() AND attempts < $2
+		RETURNING ` + phoneVerificationColumns
+
+	err := r.db.GetContext(ctx, v, query, userID, maxAttempts)
+	if err != nil {
+		return nil, err
+	}
+
+	return v, nil
+}
+
+// MarkVerified records that the code was entered correctly
+func (r *PhoneVerificationRepository) MarkVerified(ctx context.Context, id int) error {
+	ctx, cancel := withQueryTimeout(ctx, r.timeout)
+	defer cancel()
+
+	query := `UPDATE phone_verifications SET verified_at = $2 WHERE id = $1`
+
+	_, err := r.db.ExecContext(ctx, query, id, time.Now())
+	return err
+}
@@ -0,0 +1,112 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// PlacementRepository handles QR placement database operations
+type PlacementRepository struct {
+	db      Executor
+	timeout time.Duration
+}
+
+// NewPlacementRepository creates a new placement repository
+func NewPlacementRepository(db *DB) *PlacementRepository {
+	return &PlacementRepository{db: db, timeout: db.queryTimeout}
+}
+
+// Create creates a new placement
+func (r *PlacementRepository) Create(ctx context.Context, placement *QRPlacement) error {
+	ctx, cancel := withQueryTimeout(ctx, r.timeout)
+	defer cancel()
+
+	query := `
+		INSERT INTO qr_placements (listing_id, name, code, latitude, longitude, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		RETURNING id, created_at`
+
+	err := r.db.QueryRowContext(ctx, query,
+		placement.ListingID,
+		placement.Name,
+		placement.Code,
+		placement.Latitude,
+		placement.Longitude,
+		time.Now()).
+		Scan(&placement.ID, &placement.CreatedAt)
+
+	return err
+}
+
+// GetByID retrieves a placement by ID
+func (r *PlacementRepository) GetByID(ctx context.Context, id int) (*QRPlacement, error) {
+	ctx, cancel := withQueryTimeout(ctx, r.timeout)
+	defer cancel()
+
+	placement := &QRPlacement{}
+	query := `SELECT id, listing_id, name, code, latitude, longitude, created_at FROM qr_placements WHERE id = $1`
+
+	err := r.db.GetContext(ctx, placement, query, id)
+	if err != nil {
+		return nil, err
+	}
+
+	return placement, nil
+}
+
+// GetByCode retrieves a placement by its QR code
+func (r *PlacementRepository) GetByCode(ctx context.Context, code string) (*QRPlacement, error) {
+	ctx, cancel := withQueryTimeout(ctx, r.timeout)
+	defer cancel()
+
+	placement := &QRPlacement{}
+	query := `SELECT id, listing_id, name, code, latitude, longitude, created_at FROM qr_placements WHERE code = $1`
+
+	err := r.db.GetContext(ctx, placement, query, code)
+	if err != nil {
+		return nil, err
+	}
+
+	return placement, nil
+}
+
+// ListByListing retrieves all placements of a listing
+func (r *PlacementRepository) ListByListing(ctx context.Context, listingID int) ([]*QRPlacement, error) {
+	ctx, cancel := withQueryTimeout(ctx, r.timeout)
+	defer cancel()
+
+	placements := []*QRPlacement{}
+	query := `
+		SELECT id, listing_id, name, code, latitude, longitude, created_at 
+		FROM qr_placements 
+		WHERE listing_id = $1 
+		ORDER BY created_at`
+
+	err := r.db.SelectContext(ctx, &placements, query, listingID)
+	return placements, err
+}
+
+// Delete deletes a placement
+func (r *PlacementRepository) Delete(ctx context.Context, id int) error {
+	ctx, cancel := withQueryTimeout(ctx, r.timeout)
+	defer cancel()
+
+	query := `DELETE FROM qr_placements WHERE id = $1`
+
+	result, err := r.db.ExecContext(ctx, query, id)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected == 0 {
+		return fmt.Errorf("placement with id %d not found", id)
+	}
+
+	return nil
+}
@@ -0,0 +1,115 @@
+package database
+
+import (
+	"context"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// listingRevisionColumns is the column list selected into ListingRevision
+const listingRevisionColumns = `id, listing_id, version, editor_id, title, description, contact_phone, contact_tg, contacts_hidden, status, images, created_at`
+
+// ListingRevisionRepository stores the edit history of listings
+type ListingRevisionRepository struct {
+	db      Executor
+	timeout time.Duration
+}
+
+// NewListingRevisionRepository creates a new listing revision repository
+func NewListingRevisionRepository(db *DB) *ListingRevisionRepository {
+	return &ListingRevisionRepository{db: db, timeout: db.queryTimeout}
+}
+
+// WithTx returns a copy of the repository that runs its queries in tx
+func (r *ListingRevisionRepository) WithTx(tx *sqlx.Tx) *ListingRevisionRepository {
+	return &ListingRevisionRepository{db: tx, timeout: r.timeout}
+}
+
+// Record adds the stored content of a listing to its history unless the
+// latest revision already has it, and reports whether it did. Recording
+// what is in the row rather than what the caller holds keeps the history
+// right whichever query changed the listing.
+func (r *ListingRevisionRepository) Record(ctx context.Context, listingID int, editorID *int) (bool, error) {
+	ctx, cancel := withQueryTimeout(ctx, r.timeout)
+	defer cancel()
+
+	query := `
+		INSERT INTO listing_revisions (listing_id, version, editor_id, title, description, contact_phone, contact_tg, contacts_hidden, status, images, created_at)
+		SELECT l.id, l.version, $2, l.title, l.description, l.contact_phone, l.contact_tg, l.contacts_hidden, l.status, l.images, $3
+		FROM listings l
+		WHERE l.id = $1 AND NOT EXISTS (
+			SELECT 1 FROM (
+				SELECT * FROM listing_revisions WHERE listing_id = l.id ORDER BY version DESC LIMIT 1
+			) latest
+			WHERE (latest.title, latest.description, latest.contact_phone, latest.contact_tg, latest.contacts_hidden, latest.status, latest.images)
+				IS NOT DISTINCT FROM (l.title, l.description, l.contact_phone, l.contact_tg, l.contacts_hidden, l.status, l.images)
+		)
+		ON CONFLICT (listing_id, version) DO NOTHING`
+
+	result, err := r.db.ExecContext(ctx, query, listingID, editorID, time.Now())
+	if err != nil {
+		return false, err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	return rowsAffected > 0, err
+}
+
+// GetByVersion retrieves the revision of a listing made at a version
+func (r *ListingRevisionRepository) GetByVersion(ctx context.Context, listingID, version int) (*ListingRevision, error) {
+	ctx, cancel := withQueryTimeout(ctx, r.timeout)
+	defer cancel()
+
+	revision := &ListingRevision{}
+	query := `SELECT ` + listingRevisionColumns + ` FROM listing_revisions WHERE listing_id = $1 AND version = $2`
+
+	err := r.db.GetContext(ctx, revision, query, listingID, version)
+	if err != nil {
+		return nil, err
+	}
+
+	return revision, nil
+}
+
+// ListByListing retrieves the revisions of a listing, newest first
+func (r *ListingRevisionRepository) ListByListing(ctx context.Context, listingID, limit, offset int) ([]*ListingRevision, error) {
+	ctx, cancel := withQueryTimeout(ctx, r.timeout)
+	defer cancel()
+
+	revisions := []*ListingRevision{}
+	query := `
+		SELECT ` + listingRevisionColumns + `
+		FROM listing_revisions
+		WHERE listing_id = $1
+		ORDER BY version DESC
+		LIMIT $2 OFFSET $3`
+
+	err := r.db.SelectContext(ctx, &revisions, query, listingID, limit, offset)
+	return revisions, err
+}
+
+// CountByListing returns the number of revisions of a listing
+func (r *ListingRevisionRepository) CountByListing(ctx context.Context, listingID int) (int, error) {
+	ctx, cancel := withQueryTimeout(ctx, r.timeout)
+	defer cancel()
+
+	var count int
+	query := `SELECT COUNT(*) FROM listing_revisions WHERE listing_id = $1`
+
+	err := r.db.GetContext(ctx, &count, query, listingID)
+	return count, err
+}
+
+// CountSince returns the number of revisions of a listing made after the
+// given time
+func (r *ListingRevisionRepository) CountSince(ctx context.Context, listingID int, since time.Time) (int, error) {
+	ctx, cancel := withQueryTimeout(ctx, r.timeout)
+	defer cancel()
+
+	var count int
+	query := `SELECT COUNT(*) FROM listing_revisions WHERE listing_id = $1 AND created_at > $2`
+
+	err := r.db.GetContext(ctx, &count, query, listingID, since)
+	return count, err
+}
@@ -0,0 +1,80 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+)
+
+// SessionRepository stores HTTP session data
+type SessionRepository struct {
+	db      Executor
+	timeout time.Duration
+}
+
+// NewSessionRepository creates a new session repository
+func NewSessionRepository(db *DB) *SessionRepository {
+	return &SessionRepository{db: db, timeout: db.queryTimeout}
+}
+
+// Get returns the data of an unexpired session, or nil when there is none
+func (r *SessionRepository) Get(ctx context.Context, key string) ([]byte, error) {
+	ctx, cancel := withQueryTimeout(ctx, r.timeout)
+	defer cancel()
+
+	var data []byte
+	query := `SELECT data FROM sessions WHERE key = $1 AND (expires_at IS NULL OR expires_at > NOW())`
+
+	err := r.db.GetContext(ctx, &data, query, key)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+
+	return data, err
+}
+
+// Set stores session data; a nil expiresAt keeps it until deleted
+func (r *SessionRepository) Set(ctx context.Context, key string, data []byte, expiresAt *time.Time) error {
+	ctx, cancel := withQueryTimeout(ctx, r.timeout)
+	defer cancel()
+
+	query := `
+		INSERT INTO sessions (key, data, expires_at)
+		VALUES ($1, $2, $3)
+		ON CONFLICT (key) DO UPDATE SET data = EXCLUDED.data, expires_at = EXCLUDED.expires_at`
+
+	_, err := r.db.ExecContext(ctx, query, key, data, expiresAt)
+	return err
+}
+
+// Delete deletes a session
+func (r *SessionRepository) Delete(ctx context.Context, key string) error {
+	ctx, cancel := withQueryTimeout(ctx, r.timeout)
+	defer cancel()
+
+	_, err := r.db.ExecContext(ctx, `DELETE FROM sessions WHERE key = $1`, key)
+	return err
+}
+
+// DeleteAll deletes every session
+func (r *SessionRepository) DeleteAll(ctx context.Context) error {
+	ctx, cancel := withQueryTimeout(ctx, r.timeout)
+	defer cancel()
+
+	_, err := r.db.ExecContext(ctx, `DELETE FROM sessions`)
+	return err
+}
+
+// DeleteExpired deletes expired sessions and returns how many were removed
+func (r *SessionRepository) DeleteExpired(ctx context.Context) (int64, error) {
+	ctx, cancel := withQueryTimeout(ctx, r.timeout)
+	defer cancel()
+
+	result, err := r.db.ExecContext(ctx, `DELETE FROM sessions WHERE expires_at <= NOW()`)
+	if err != nil {
+		return 0, err
+	}
+
+	return result.RowsAffected()
+}
@@ -0,0 +1,65 @@
+package database
+
+import (
+	"context"
+	"time"
+)
+
+// ShortLinkRepository handles short link database operations
+type ShortLinkRepository struct {
+	db      Executor
+	timeout time.Duration
+}
+
+// NewShortLinkRepository creates a new short link repository
+func NewShortLinkRepository(db *DB) *ShortLinkRepository {
+	return &ShortLinkRepository{db: db, timeout: db.queryTimeout}
+}
+
+// Create creates a new short link
+func (r *ShortLinkRepository) Create(ctx context.Context, link *ShortLink) error {
+	ctx, cancel := withQueryTimeout(ctx, r.timeout)
+	defer cancel()
+
+	query := `
+		INSERT INTO short_links (listing_id, code, source, created_at)
+		VALUES ($1, $2, $3, $4)
+		RETURNING id, created_at`
+
+	err := r.db.QueryRowContext(ctx, query, link.ListingID, link.Code, link.Source, time.Now()).
+		Scan(&link.ID, &link.CreatedAt)
+
+	return err
+}
+
+// GetByCode retrieves a short link by code
+func (r *ShortLinkRepository) GetByCode(ctx context.Context, code string) (*ShortLink, error) {
+	ctx, cancel := withQueryTimeout(ctx, r.timeout)
+	defer cancel()
+
+	link := &ShortLink{}
+	query := `SELECT id, listing_id, code, source, created_at FROM short_links WHERE code = $1`
+
+	err := r.db.GetContext(ctx, link, query, code)
+	if err != nil {
+		return nil, err
+	}
+
+	return link, nil
+}
+
+// ListByListing retrieves all short links of a listing
+func (r *ShortLinkRepository) ListByListing(ctx context.Context, listingID int) ([]*ShortLink, error) {
+	ctx, cancel := withQueryTimeout(ctx, r.timeout)
+	defer cancel()
+
+	links := []*ShortLink{}
+	query := `
+		SELECT id, listing_id, code, source, created_at 
+		FROM short_links 
+		WHERE listing_id = $1 
+		ORDER BY created_at`
+
+	err := r.db.SelectContext(ctx, &links, query, listingID)
+	return links, err
+}
@@ -0,0 +1,106 @@
+package database
+
+import (
+	"context"
+	"time"
+)
+
+// DailyCount is the number of things that happened on one day
+type DailyCount struct {
+	Date  string `json:"date" db:"date"`
+	Count int    `json:"count" db:"count"`
+}
+
+// PlatformStats summarizes platform activity since a point in time
+type PlatformStats struct {
+	Since         time.Time `json:"since"`
+	TotalUsers    int       `json:"total_users" db:"total_users"`
+	TotalListings int       `json:"total_listings" db:"total_listings"`
+	NewUsers      int       `json:"new_users" db:"new_users"`
+	NewListings   int       `json:"new_listings" db:"new_listings"`
+	// ActiveUsers made a change recorded in the audit log, sent a message
+	// or signed in with a linked identity
+	ActiveUsers int `json:"active_users" db:"active_users"`
+	// PublishedListings are lost and found listings created in the period
+	// that were published; ResolvedListings are those of them their owners
+	// archived since, so listings taken down by moderators do not count
+	PublishedListings int     `json:"published_listings" db:"published_listings"`
+	ResolvedListings  int     `json:"resolved_listings" db:"resolved_listings"`
+	ResolutionRate    float64 `json:"resolution_rate"`
+
+	NewListingsPerDay []DailyCount   `json:"new_listings_per_day"`
+	EventsByType      map[string]int `json:"events_by_type"`
+}
+
+// StatsRepository computes platform statistics for administrators
+type StatsRepository struct {
+	db      Executor
+	timeout time.Duration
+}
+
+// NewStatsRepository creates a new stats repository
+func NewStatsRepository(db *DB) *StatsRepository {
+	return &StatsRepository{db: db, timeout: db.queryTimeout}
+}
+
+// Platform returns the statistics of the period since the given time
+func (r *StatsRepository) Platform(ctx context.Context, since time.Time) (*PlatformStats, error) {
+	ctx, cancel := withQueryTimeout(ctx, r.timeout)
+	defer cancel()
+
+	stats := &PlatformStats{Since: since}
+	query := `
+		SELECT
+			(SELECT COUNT(*) FROM users WHERE deleted_at IS NULL) AS total_users,
+			(SELECT COUNT(*) FROM listings WHERE deleted_at IS NULL) AS total_listings,
+			(SELECT COUNT(*) FROM users WHERE deleted_at IS NULL AND created_at >= $1) AS new_users,
+			(SELECT COUNT(*) FROM listings WHERE deleted_at IS NULL AND created_at >= $1) AS new_listings,
+			(SELECT COUNT(*) FROM users u WHERE u.deleted_at IS NULL AND (
+				EXISTS (SELECT 1 FROM audit_log a WHERE a.actor_id = u.id AND a.created_at >= $1)
+				OR EXISTS (SELECT 1 FROM messages m WHERE m.sender_id = u.id AND m.created_at >= $1)
+				OR EXISTS (SELECT 1 FROM user_identities i WHERE i.user_id = u.id AND i.last_login_at >= $1)
+			)) AS active_users,
+			(SELECT COUNT(*) FROM listings
+			 WHERE deleted_at IS NULL AND created_at >= $1 AND type IN ('lost', 'found') AND status IN ('active', 'archived')) AS published_listings,
+			(SELECT COUNT(*) FROM listings l
+			 WHERE l.deleted_at IS NULL AND l.created_at >= $1 AND l.type IN ('lost', 'found') AND l.status = 'archived'
+			 AND EXISTS (
+				SELECT 1 FROM listing_revisions r
+				WHERE r.listing_id = l.id AND r.status = 'archived' AND r.editor_id = l.user_id
+			 )) AS resolved_listings`
+
+	if err := r.db.GetContext(ctx, stats, query, since); err != nil {
+		return nil, err
+	}
+	if stats.PublishedListings > 0 {
+		stats.ResolutionRate = float64(stats.ResolvedListings) / float64(stats.PublishedListings)
+	}
+
+	query = `
+		SELECT to_char(d, 'YYYY-MM-DD') AS date, COUNT(l.id) AS count
+		FROM generate_series(date_trunc('day', $1::timestamptz), date_trunc('day', NOW()), INTERVAL '1 day') d
+		LEFT JOIN listings l ON l.created_at >= d AND l.created_at < d + INTERVAL '1 day' AND l.deleted_at IS NULL
+		GROUP BY d
+		ORDER BY d`
+
+	stats.NewListingsPerDay = []DailyCount{}
+	if err := r.db.SelectContext(ctx, &stats.NewListingsPerDay, query, since); err != nil {
+		return nil, err
+	}
+
+	var events []struct {
+		Type  string `db:"type"`
+		Count int    `db:"count"`
+	}
+	query = `SELECT type, COUNT(*) AS count FROM events WHERE created_at >= $1 GROUP BY type`
+	if err := r.db.SelectContext(ctx, &events, query, since); err != nil {
+		return nil, err
+	}
+
+	stats.EventsByType = make(map[string]int, len(events))
+	for _, e := range events {
+		stats.EventsByType[e.Type] = e.Count
+	}
+
+	return stats, nil
+}
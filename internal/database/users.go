@@ -1,39 +1,77 @@
 package database
 
 import (
+	"context"
+	"database/sql"
 	"fmt"
+	"strings"
 	"time"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
 )
 
+// userColumns is the column list selected into User
+const userColumns = `id, email, phone, phone_verified, name, telegram_chat_id, locale, role, banned_at, deleted_at, created_at, updated_at`
+
 // UserRepository handles user database operations
 type UserRepository struct {
-	db *DB
+	db      Executor
+	timeout time.Duration
 }
 
 // NewUserRepository creates a new user repository
 func NewUserRepository(db *DB) *UserRepository {
-	return &UserRepository{db: db}
+	return &UserRepository{db: db, timeout: db.queryTimeout}
+}
+
+// WithTx returns a copy of the repository that runs its queries in tx
+func (r *UserRepository) WithTx(tx *sqlx.Tx) *UserRepository {
+	return &UserRepository{db: tx, timeout: r.timeout}
 }
 
 // Create creates a new user
-func (r *UserRepository) Create(user *User) error {
+func (r *UserRepository) Create(ctx context.Context, user *User) error {
+	ctx, cancel := withQueryTimeout(ctx, r.timeout)
+	defer cancel()
+
 	query := `
 		INSERT INTO users (email, phone, name, created_at)
 		VALUES ($1, $2, $3, $4)
-		RETURNING id, created_at`
+		RETURNING id, locale, role, created_at`
 
-	err := r.db.QueryRow(query, user.Email, user.Phone, user.Name, time.Now()).
-		Scan(&user.ID, &user.CreatedAt)
+	err := r.db.QueryRowContext(ctx, query, user.Email, user.Phone, user.Name, time.Now()).
+		Scan(&user.ID, &user.Locale, &user.Role, &user.CreatedAt)
 
 	return err
 }
 
 // GetByID retrieves a user by ID
-func (r *UserRepository) GetByID(id int) (*User, error) {
+func (r *UserRepository) GetByID(ctx context.Context, id int) (*User, error) {
+	ctx, cancel := withQueryTimeout(ctx, r.timeout)
+	defer cancel()
+
+	user := &User{}
+	query := `SELECT ` + userColumns + ` FROM users WHERE id = $1 AND deleted_at IS NULL`
+
+	err := r.db.GetContext(ctx, user, query, id)
+	if err != nil {
+		return nil, err
+	}
+
+	return user, nil
+}
+
+// GetByIDForUpdate retrieves a user by ID and locks the row until the
+// transaction ends
+func (r *UserRepository) GetByIDForUpdate(ctx context.Context, id int) (*User, error) {
+	ctx, cancel := withQueryTimeout(ctx, r.timeout)
+	defer cancel()
+
 	user := &User{}
-	query := `SELECT id, email, phone, name, created_at, updated_at FROM users WHERE id = $1`
+	query := `SELECT ` + userColumns + ` FROM users WHERE id = $1 AND deleted_at IS NULL FOR UPDATE`
 
-	err := r.db.Get(user, query, id)
+	err := r.db.GetContext(ctx, user, query, id)
 	if err != nil {
 		return nil, err
 	}
@@ -42,11 +80,30 @@ func (r *UserRepository) GetByID(id int) (*User, error) {
 }
 
 // GetByEmail retrieves a user by email
-func (r *UserRepository) GetByEmail(email string) (*User, error) {
+func (r *UserRepository) GetByEmail(ctx context.Context, email string) (*User, error) {
+	ctx, cancel := withQueryTimeout(ctx, r.timeout)
+	defer cancel()
+
+	user := &User{}
+	query := `SELECT ` + userColumns + ` FROM users WHERE email = $1 AND deleted_at IS NULL`
+
+	err := r.db.GetContext(ctx, user, query, email)
+	if err != nil {
+		return nil, err
+	}
+
+	return user, nil
+}
+
+// GetByTelegramChatID retrieves the user linked to a Telegram chat
+func (r *UserRepository) GetByTelegramChatID(ctx context.Context, chatID int64) (*User, error) {
+	ctx, cancel := withQueryTimeout(ctx, r.timeout)
+	defer cancel()
+
 	user := &User{}
-	query := `SELECT id, email, phone, name, created_at, updated_at FROM users WHERE email = $1`
+	query := `SELECT ` + userColumns + ` FROM users WHERE telegram_chat_id = $1 AND deleted_at IS NULL`
 
-	err := r.db.Get(user, query, email)
+	err := r.db.GetContext(ctx, user, query, chatID)
 	if err != nil {
 		return nil, err
 	}
@@ -54,25 +111,148 @@ func (r *UserRepository) GetByEmail(email string) (*User, error) {
 	return user, nil
 }
 
-// Update updates a user
-func (r *UserRepository) Update(user *User) error {
+// SetTelegramChatID links a Telegram chat to a user, unlinking it
+// from any other user. It must run inside a transaction.
+func (r *UserRepository) SetTelegramChatID(ctx context.Context, userID int, chatID int64) error {
+	ctx, cancel := withQueryTimeout(ctx, r.timeout)
+	defer cancel()
+
+	// telegram_chat_id is unique and a data-modifying CTE would run after
+	// the main update, so the previous owner releases the chat first
+	query := `UPDATE users SET telegram_chat_id = NULL WHERE telegram_chat_id = $2 AND id <> $1`
+	if _, err := r.db.ExecContext(ctx, query, userID, chatID); err != nil {
+		return err
+	}
+
+	_, err := r.db.ExecContext(ctx, `UPDATE users SET telegram_chat_id = $2 WHERE id = $1`, userID, chatID)
+	return err
+}
+
+// GetAccess returns the role of a user and whether the user is banned
+func (r *UserRepository) GetAccess(ctx context.Context, id int) (role Role, banned bool, err error) {
+	ctx, cancel := withQueryTimeout(ctx, r.timeout)
+	defer cancel()
+
+	query := `SELECT role, banned_at IS NOT NULL FROM users WHERE id = $1 AND deleted_at IS NULL`
+
+	err = r.db.QueryRowContext(ctx, query, id).Scan(&role, &banned)
+	return role, banned, err
+}
+
+// SetRole changes the role of a user
+func (r *UserRepository) SetRole(ctx context.Context, userID int, role Role) error {
+	ctx, cancel := withQueryTimeout(ctx, r.timeout)
+	defer cancel()
+
+	query := `UPDATE users SET role = $2, updated_at = $3 WHERE id = $1 AND deleted_at IS NULL`
+
+	result, err := r.db.ExecContext(ctx, query, userID, role, time.Now())
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rowsAffected == 0 {
+		return sql.ErrNoRows
+	}
+
+	return nil
+}
+
+// Ban blocks a user from signing in and using the API
+func (r *UserRepository) Ban(ctx context.Context, userID int) error {
+	ctx, cancel := withQueryTimeout(ctx, r.timeout)
+	defer cancel()
+
+	query := `UPDATE users SET banned_at = $2, updated_at = $2 WHERE id = $1 AND banned_at IS NULL AND deleted_at IS NULL`
+
+	_, err := r.db.ExecContext(ctx, query, userID, time.Now())
+	return err
+}
+
+// Unban lets a banned user sign in again
+func (r *UserRepository) Unban(ctx context.Context, userID int) error {
+	ctx, cancel := withQueryTimeout(ctx, r.timeout)
+	defer cancel()
+
+	query := `UPDATE users SET banned_at = NULL, updated_at = $2 WHERE id = $1 AND banned_at IS NOT NULL AND deleted_at IS NULL`
+
+	_, err := r.db.ExecContext(ctx, query, userID, time.Now())
+	return err
+}
+
+// SetLocale changes the language of a user's notifications
+func (r *UserRepository) SetLocale(ctx context.Context, userID int, locale string) error {
+	ctx, cancel := withQueryTimeout(ctx, r.timeout)
+	defer cancel()
+
+	query := `UPDATE users SET locale = $2, updated_at = $3 WHERE id = $1 AND deleted_at IS NULL`
+
+	_, err := r.db.ExecContext(ctx, query, userID, locale, time.Now())
+	return err
+}
+
+// Update updates a user; changing the phone clears its verification
+func (r *UserRepository) Update(ctx context.Context, user *User) error {
+	ctx, cancel := withQueryTimeout(ctx, r.timeout)
+	defer cancel()
+
 	query := `
 		UPDATE users 
-		SET phone = $2, name = $3, updated_at = $4
-		WHERE id = $1
-		RETURNING updated_at`
+		SET phone = $2, name = $3, updated_at = $4,
+			phone_verified = phone_verified AND phone IS NOT DISTINCT FROM $2
+		WHERE id = $1 AND deleted_at IS NULL
+		RETURNING phone_verified, updated_at`
 
-	err := r.db.QueryRow(query, user.ID, user.Phone, user.Name, time.Now()).
-		Scan(&user.UpdatedAt)
+	err := r.db.QueryRowContext(ctx, query, user.ID, user.Phone, user.Name, time.Now()).
+		Scan(&user.PhoneVerified, &user.UpdatedAt)
+	if err != nil {
+		return err
+	}
 
+	_, err = r.db.ExecContext(ctx, refreshVerifiedContactQuery, pq.Array([]int{user.ID}))
 	return err
 }
 
-// Delete deletes a user
-func (r *UserRepository) Delete(id int) error {
-	query := `DELETE FROM users WHERE id = $1`
+// SetVerifiedPhone stores a phone number confirmed by the user and takes
+// the verification away from other users with the same number, since
+// numbers get reassigned; their listings lose the badge accordingly
+func (r *UserRepository) SetVerifiedPhone(ctx context.Context, userID int, phone string) error {
+	ctx, cancel := withQueryTimeout(ctx, r.timeout)
+	defer cancel()
+
+	var affected []int
+	query := `
+		UPDATE users SET phone_verified = FALSE, updated_at = $3
+		WHERE phone = $2 AND phone_verified AND id <> $1
+		RETURNING id`
 
-	result, err := r.db.Exec(query, id)
+	if err := r.db.SelectContext(ctx, &affected, query, userID, phone, time.Now()); err != nil {
+		return err
+	}
+
+	query = `UPDATE users SET phone = $2, phone_verified = TRUE, updated_at = $3 WHERE id = $1 AND deleted_at IS NULL`
+	if _, err := r.db.ExecContext(ctx, query, userID, phone, time.Now()); err != nil {
+		return err
+	}
+
+	_, err := r.db.ExecContext(ctx, refreshVerifiedContactQuery, pq.Array(append(affected, userID)))
+	return err
+}
+
+// Delete marks a user and their listings deleted; they disappear
+// everywhere but can be restored until PurgeDeleted removes them
+func (r *UserRepository) Delete(ctx context.Context, id int) error {
+	ctx, cancel := withQueryTimeout(ctx, r.timeout)
+	defer cancel()
+
+	now := time.Now()
+	query := `UPDATE users SET deleted_at = $2 WHERE id = $1 AND deleted_at IS NULL`
+
+	result, err := r.db.ExecContext(ctx, query, id, now)
 	if err != nil {
 		return err
 	}
@@ -83,30 +263,256 @@ func (r *UserRepository) Delete(id int) error {
 	}
 
 	if rowsAffected == 0 {
-		return fmt.Errorf("user with id %d not found", id)
+		return sql.ErrNoRows
 	}
 
-	return nil
+	// Listings share the timestamp, so restoring the user brings back only
+	// the listings deleted with them
+	_, err = r.db.ExecContext(ctx, `UPDATE listings SET deleted_at = $2 WHERE user_id = $1 AND deleted_at IS NULL`, id, now)
+	return err
+}
+
+// GetDeletedByID retrieves a deleted user that can still be restored,
+// deleted after since, and locks the row until the transaction ends
+func (r *UserRepository) GetDeletedByID(ctx context.Context, id int, since time.Time) (*User, error) {
+	ctx, cancel := withQueryTimeout(ctx, r.timeout)
+	defer cancel()
+
+	user := &User{}
+	query := `SELECT ` + userColumns + ` FROM users WHERE id = $1 AND deleted_at >= $2 FOR UPDATE`
+
+	err := r.db.GetContext(ctx, user, query, id, since)
+	if err != nil {
+		return nil, err
+	}
+
+	return user, nil
+}
+
+// Restore brings back a user deleted after since together with the
+// listings deleted with them; it returns sql.ErrNoRows when there is no
+// such user and a unique violation when the email was taken in the meantime.
+// It must run inside a transaction.
+func (r *UserRepository) Restore(ctx context.Context, id int, since time.Time) error {
+	ctx, cancel := withQueryTimeout(ctx, r.timeout)
+	defer cancel()
+
+	user, err := r.GetDeletedByID(ctx, id, since)
+	if err != nil {
+		return err
+	}
+
+	query := `UPDATE listings SET deleted_at = NULL WHERE user_id = $1 AND deleted_at = $2`
+	if _, err := r.db.ExecContext(ctx, query, id, user.DeletedAt); err != nil {
+		return err
+	}
+
+	_, err = r.db.ExecContext(ctx, `UPDATE users SET deleted_at = NULL, updated_at = $2 WHERE id = $1`, id, time.Now())
+	return err
 }
 
-// List retrieves all users with pagination
-func (r *UserRepository) List(limit, offset int) ([]*User, error) {
+// PurgeDeleted removes users deleted before the given time for good,
+// together with everything they own, and returns how many there were
+func (r *UserRepository) PurgeDeleted(ctx context.Context, before time.Time) (int64, error) {
+	ctx, cancel := withQueryTimeout(ctx, r.timeout)
+	defer cancel()
+
+	result, err := r.db.ExecContext(ctx, `DELETE FROM users WHERE deleted_at < $1`, before)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+// eraseStatements remove personal data a user left outside of what is
+// deleted with them: their events and reports stay for statistics and
+// moderation but lose who made them
+var eraseStatements = []string{
+	`UPDATE events SET user_id = NULL, ip_address = NULL, user_agent = NULL WHERE user_id = $1`,
+	`UPDATE listing_reports SET reporter_id = NULL, ip_address = NULL WHERE reporter_id = $1`,
+}
+
+// Erase removes a user for good, including a deleted one, together with
+// their listings, conversations, messages and everything else they own,
+// and returns the IDs of the removed listings. It must run inside a
+// transaction.
+func (r *UserRepository) Erase(ctx context.Context, id int) ([]int, error) {
+	ctx, cancel := withQueryTimeout(ctx, r.timeout)
+	defer cancel()
+
+	for _, stmt := range eraseStatements {
+		if _, err := r.db.ExecContext(ctx, stmt, id); err != nil {
+			return nil, err
+		}
+	}
+
+	listingIDs := []int{}
+	if err := r.db.SelectContext(ctx, &listingIDs, `DELETE FROM listings WHERE user_id = $1 RETURNING id`, id); err != nil {
+		return nil, err
+	}
+
+	result, err := r.db.ExecContext(ctx, `DELETE FROM users WHERE id = $1`, id)
+	if err != nil {
+		return nil, err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return nil, err
+	}
+
+	if rowsAffected == 0 {
+		return nil, sql.ErrNoRows
+	}
+
+	return listingIDs, nil
+}
+
+// UserFilter narrows down user lists; zero fields match everything
+type UserFilter struct {
+	// Query matches the email or name
+	Query     string
+	Role      Role
+	Suspended *bool
+	// Deleted lists deleted users instead of the others
+	Deleted bool
+}
+
+// where returns the WHERE clause of the filter and its arguments
+func (f UserFilter) where() (string, []any) {
+	conditions := []string{"deleted_at IS NULL"}
+	if f.Deleted {
+		conditions[0] = "deleted_at IS NOT NULL"
+	}
+	args := []any{}
+
+	if f.Query != "" {
+		args = append(args, "%"+f.Query+"%")
+		conditions = append(conditions, fmt.Sprintf("(email ILIKE $%d OR name ILIKE $%d)", len(args), len(args)))
+	}
+	if f.Role != "" {
+		args = append(args, f.Role)
+		conditions = append(conditions, fmt.Sprintf("role = $%d", len(args)))
+	}
+	if f.Suspended != nil {
+		args = append(args, *f.Suspended)
+		conditions = append(conditions, fmt.Sprintf("(banned_at IS NOT NULL) = $%d", len(args)))
+	}
+
+	return strings.Join(conditions, " AND "), args
+}
+
+// List retrieves users matching the filter with pagination
+func (r *UserRepository) List(ctx context.Context, filter UserFilter, limit, offset int) ([]*User, error) {
+	ctx, cancel := withQueryTimeout(ctx, r.timeout)
+	defer cancel()
+
 	users := []*User{}
-	query := `
-		SELECT id, email, phone, name, created_at, updated_at 
+	where, args := filter.where()
+	query := fmt.Sprintf(`
+		SELECT `+userColumns+`
 		FROM users 
+		WHERE %s
 		ORDER BY created_at DESC 
-		LIMIT $1 OFFSET $2`
+		LIMIT $%d OFFSET $%d`, where, len(args)+1, len(args)+2)
 
-	err := r.db.Select(&users, query, limit, offset)
+	err := r.db.SelectContext(ctx, &users, query, append(args, limit, offset)...)
 	return users, err
 }
 
-// Count returns the total number of users
-func (r *UserRepository) Count() (int, error) {
+// Count returns the number of users matching the filter
+func (r *UserRepository) Count(ctx context.Context, filter UserFilter) (int, error) {
+	ctx, cancel := withQueryTimeout(ctx, r.timeout)
+	defer cancel()
+
 	var count int
-	query := `SELECT COUNT(*) FROM users`
+	where, args := filter.where()
+	query := `SELECT COUNT(*) FROM users WHERE ` + where
 
-	err := r.db.Get(&count, query)
+	err := r.db.GetContext(ctx, &count, query, args...)
 	return count, err
 }
+
+// mergeStatements move everything owned by user $1 to user $2, in order.
+// Conversations that would end up between the merged account and itself
+// are dropped, and a finder's duplicate conversation about the same listing
+// is folded into the one the target already has.
+var mergeStatements = []string{
+	`DELETE FROM conversations
+	 WHERE (owner_id = $1 AND finder_id = $2) OR (owner_id = $2 AND finder_id = $1)`,
+	`UPDATE messages m SET conversation_id = t.id
+	 FROM conversations s
+	 JOIN conversations t ON t.listing_id = s.listing_id AND t.finder_id = $2
+	 WHERE m.conversation_id = s.id AND s.finder_id = $1`,
+	`DELETE FROM conversations s
+	 USING conversations t
+	 WHERE s.finder_id = $1 AND t.listing_id = s.listing_id AND t.finder_id = $2`,
+	`UPDATE conversations SET owner_id = $2 WHERE owner_id = $1`,
+	`UPDATE conversations SET finder_id = $2 WHERE finder_id = $1`,
+	`UPDATE messages SET sender_id = $2 WHERE sender_id = $1`,
+	`UPDATE listings SET user_id = $2 WHERE user_id = $1`,
+	`UPDATE events SET user_id = $2 WHERE user_id = $1`,
+	`UPDATE alert_subscriptions SET user_id = $2 WHERE user_id = $1`,
+	`UPDATE webhook_endpoints SET user_id = $2 WHERE user_id = $1`,
+	`UPDATE notification_outbox SET user_id = $2 WHERE user_id = $1`,
+	`INSERT INTO notification_preferences (user_id, kind, channels)
+	 SELECT $2, kind, channels FROM notification_preferences WHERE user_id = $1
+	 ON CONFLICT (user_id, kind) DO NOTHING`,
+	`UPDATE user_identities SET user_id = $2 WHERE user_id = $1`,
+}
+
+// Merge moves the listings, events, conversations, messages, subscriptions
+// and sign-in methods of user fromID to user intoID and deletes fromID; the
+// target keeps its own profile and role, filling gaps from the source; a
+// role of the source is not carried over. It must run inside a transaction.
+func (r *UserRepository) Merge(ctx context.Context, fromID, intoID int) error {
+	ctx, cancel := withQueryTimeout(ctx, r.timeout)
+	defer cancel()
+
+	// Locking both rows in id order keeps concurrent merges from deadlocking
+	query := `SELECT ` + userColumns + ` FROM users WHERE id = ANY($1) AND deleted_at IS NULL ORDER BY id FOR UPDATE`
+
+	var users []User
+	if err := r.db.SelectContext(ctx, &users, query, pq.Array([]int{fromID, intoID})); err != nil {
+		return err
+	}
+	if len(users) != 2 || fromID == intoID {
+		return sql.ErrNoRows
+	}
+	source := users[0]
+	if source.ID != fromID {
+		source = users[1]
+	}
+
+	for _, stmt := range mergeStatements {
+		if _, err := r.db.ExecContext(ctx, stmt, fromID, intoID); err != nil {
+			return err
+		}
+	}
+
+	// telegram_chat_id is unique, so the source releases it before the
+	// target takes it over
+	if _, err := r.db.ExecContext(ctx, `UPDATE users SET telegram_chat_id = NULL WHERE id = $1`, fromID); err != nil {
+		return err
+	}
+
+	query = `
+		UPDATE users
+		SET phone_verified = CASE WHEN phone IS NULL THEN $3 ELSE phone_verified END,
+			phone = COALESCE(phone, $2), name = COALESCE(name, $4),
+			telegram_chat_id = COALESCE(telegram_chat_id, $5), updated_at = $6
+		WHERE id = $1`
+
+	_, err := r.db.ExecContext(ctx, query, intoID, source.Phone, source.PhoneVerified, source.Name, source.TelegramChatID, time.Now())
+	if err != nil {
+		return err
+	}
+
+	// Nothing is left to restore, so the source is removed right away
+	if _, err := r.db.ExecContext(ctx, `DELETE FROM users WHERE id = $1`, fromID); err != nil {
+		return err
+	}
+
+	_, err = r.db.ExecContext(ctx, refreshVerifiedContactQuery, pq.Array([]int{intoID}))
+	return err
+}
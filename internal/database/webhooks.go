@@ -0,0 +1,311 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+const (
+	webhookEndpointColumns = `id, user_id, url, secret, event_types, active, consecutive_failures, disabled_at, created_at, updated_at`
+	webhookDeliveryColumns = `id, endpoint_id, event_type, payload, status, attempts, next_attempt_at, response_status, last_error, delivered_at, created_at`
+)
+
+// WebhookRepository handles webhook endpoint and delivery database operations
+type WebhookRepository struct {
+	db      Executor
+	timeout time.Duration
+}
+
+// NewWebhookRepository creates a new webhook repository
+func NewWebhookRepository(db *DB) *WebhookRepository {
+	return &WebhookRepository{db: db, timeout: db.queryTimeout}
+}
+
+// CreateEndpoint creates a new webhook endpoint
+func (r *WebhookRepository) CreateEndpoint(ctx context.Context, endpoint *WebhookEndpoint) error {
+	ctx, cancel := withQueryTimeout(ctx, r.timeout)
+	defer cancel()
+
+	query := `
+		INSERT INTO webhook_endpoints (user_id, url, secret, event_types, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		RETURNING id, active, consecutive_failures, created_at, updated_at`
+
+	now := time.Now()
+	err := r.db.QueryRowContext(ctx, query,
+		endpoint.UserID,
+		endpoint.URL,
+		endpoint.Secret,
+		pq.Array(endpoint.EventTypes),
+		now,
+		now).
+		Scan(&endpoint.ID, &endpoint.Active, &endpoint.ConsecutiveFailures, &endpoint.CreatedAt, &endpoint.UpdatedAt)
+
+	return err
+}
+
+// GetEndpoint retrieves a webhook endpoint by ID
+func (r *WebhookRepository) GetEndpoint(ctx context.Context, id int) (*WebhookEndpoint, error) {
+	ctx, cancel := withQueryTimeout(ctx, r.timeout)
+	defer cancel()
+
+	endpoint := &WebhookEndpoint{}
+	query := `SELECT ` + webhookEndpointColumns + ` FROM webhook_endpoints WHERE id = $1`
+
+	err := r.db.GetContext(ctx, endpoint, query, id)
+	if err != nil {
+		return nil, err
+	}
+
+	return endpoint, nil
+}
+
+// ListEndpointsByUser retrieves all webhook endpoints of a user
+func (r *WebhookRepository) ListEndpointsByUser(ctx context.Context, userID int) ([]*WebhookEndpoint, error) {
+	ctx, cancel := withQueryTimeout(ctx, r.timeout)
+	defer cancel()
+
+	endpoints := []*WebhookEndpoint{}
+	query := `
+		SELECT ` + webhookEndpointColumns + `
+		FROM webhook_endpoints 
+		WHERE user_id = $1 
+		ORDER BY created_at DESC`
+
+	err := r.db.SelectContext(ctx, &endpoints, query, userID)
+	return endpoints, err
+}
+
+// UpdateEndpoint updates the URL, event types and active flag of an
+// endpoint; re-enabling an endpoint clears its failure streak
+func (r *WebhookRepository) UpdateEndpoint(ctx context.Context, endpoint *WebhookEndpoint) error {
+	ctx, cancel := withQueryTimeout(ctx, r.timeout)
+	defer cancel()
+
+	query := `
+		UPDATE webhook_endpoints 
+		SET url = $2, event_types = $3, active = $4,
+			consecutive_failures = CASE WHEN $4 AND NOT active THEN 0 ELSE consecutive_failures END,
+			disabled_at = CASE WHEN $4 THEN NULL ELSE COALESCE(disabled_at, $5) END,
+			updated_at = $5
+		WHERE id = $1
+		RETURNING consecutive_failures, disabled_at, updated_at`
+
+	err := r.db.QueryRowContext(ctx, query,
+		endpoint.ID,
+		endpoint.URL,
+		pq.Array(endpoint.EventTypes),
+		endpoint.Active,
+		time.Now()).
+		Scan(&endpoint.ConsecutiveFailures, &endpoint.DisabledAt, &endpoint.UpdatedAt)
+
+	return err
+}
+
+// DeleteEndpoint deletes a webhook endpoint together with its delivery log
+func (r *WebhookRepository) DeleteEndpoint(ctx context.Context, id int) error {
+	ctx, cancel := withQueryTimeout(ctx, r.timeout)
+	defer cancel()
+
+	query := `DELETE FROM webhook_endpoints WHERE id = $1`
+
+	result, err := r.db.ExecContext(ctx, query, id)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected == 0 {
+		return fmt.Errorf("webhook endpoint with id %d not found", id)
+	}
+
+	return nil
+}
+
+// EnqueueEvent queues a delivery of the payload to every active endpoint
+// subscribed to the event type, optionally limited to endpoints of one user,
+// and returns the number of queued deliveries
+func (r *WebhookRepository) EnqueueEvent(ctx context.Context, eventType string, payload []byte, userID *int) (int, error) {
+	ctx, cancel := withQueryTimeout(ctx, r.timeout)
+	defer cancel()
+
+	query := `
+		INSERT INTO webhook_deliveries (endpoint_id, event_type, payload, next_attempt_at, created_at)
+		SELECT id, $1, $2, $4, $4
+		FROM webhook_endpoints 
+		WHERE active AND $1 = ANY(event_types)
+		AND ($3::INTEGER IS NULL OR user_id = $3)`
+
+	result, err := r.db.ExecContext(ctx, query, eventType, string(payload), userID, time.Now())
+	if err != nil {
+		return 0, err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	return int(rowsAffected), err
+}
+
+// ClaimDueDeliveries picks pending deliveries whose next attempt is due,
+// counts the attempt and leases them until the given time so that
+// concurrent workers skip them
+func (r *WebhookRepository) ClaimDueDeliveries(ctx context.Context, limit int, leaseUntil time.Time) ([]*WebhookDelivery, error) {
+	ctx, cancel := withQueryTimeout(ctx, r.timeout)
+	defer cancel()
+
+	deliveries := []*WebhookDelivery{}
+	query := `
+		UPDATE webhook_deliveries 
+		SET attempts = attempts + 1, next_attempt_at = $2
+		WHERE id IN (
+			SELECT d.id 
+			FROM webhook_deliveries d
+			JOIN webhook_endpoints e ON e.id = d.endpoint_id
+			WHERE d.status = 'pending' AND d.next_attempt_at <= NOW() AND e.active
+			ORDER BY d.next_attempt_at
+			LIMIT $1
+			FOR UPDATE OF d SKIP LOCKED
+		)
+		RETURNING ` + webhookDeliveryColumns
+
+	err := r.db.SelectContext(ctx, &deliveries, query, limit, leaseUntil)
+	return deliveries, err
+}
+
+// MarkDelivered records a successful delivery
+func (r *WebhookRepository) MarkDelivered(ctx context.Context, id, responseStatus int) error {
+	ctx, cancel := withQueryTimeout(ctx, r.timeout)
+	defer cancel()
+
+	query := `
+		UPDATE webhook_deliveries 
+		SET status = 'delivered', response_status = $2, last_error = NULL, delivered_at = $3
+		WHERE id = $1`
+
+	_, err := r.db.ExecContext(ctx, query, id, responseStatus, time.Now())
+	return err
+}
+
+// MarkAttemptFailed records a failed attempt; the delivery is retried at
+// nextAttempt or given up on when nextAttempt is nil
+func (r *WebhookRepository) MarkAttemptFailed(ctx context.Context, id int, responseStatus *int, lastError string, nextAttempt *time.Time) error {
+	ctx, cancel := withQueryTimeout(ctx, r.timeout)
+	defer cancel()
+
+	query := `
+		UPDATE webhook_deliveries 
+		SET status = CASE WHEN $4::TIMESTAMPTZ IS NULL THEN 'failed' ELSE 'pending' END,
+			response_status = $2, last_error = $3, next_attempt_at = COALESCE($4, next_attempt_at)
+		WHERE id = $1`
+
+	_, err := r.db.ExecContext(ctx, query, id, responseStatus, lastError, nextAttempt)
+	return err
+}
+
+// RecordEndpointSuccess resets the failure streak of an endpoint
+func (r *WebhookRepository) RecordEndpointSuccess(ctx context.Context, id int) error {
+	ctx, cancel := withQueryTimeout(ctx, r.timeout)
+	defer cancel()
+
+	query := `UPDATE webhook_endpoints SET consecutive_failures = 0 WHERE id = $1 AND consecutive_failures > 0`
+
+	_, err := r.db.ExecContext(ctx, query, id)
+	return err
+}
+
+// RecordEndpointFailure extends the failure streak of an endpoint and
+// disables it once the streak reaches the limit; it reports whether the
+// endpoint was disabled by this call. Pending deliveries of a disabled
+// endpoint are marked as failed.
+func (r *WebhookRepository) RecordEndpointFailure(ctx context.Context, id, limit int) (bool, error) {
+	ctx, cancel := withQueryTimeout(ctx, r.timeout)
+	defer cancel()
+
+	query := `
+		UPDATE webhook_endpoints 
+		SET consecutive_failures = consecutive_failures + 1,
+			active = consecutive_failures + 1 < $2,
+			disabled_at = CASE WHEN consecutive_failures + 1 >= $2 THEN $3 ELSE disabled_at END,
+			updated_at = $3
+		WHERE id = $1 AND active
+		RETURNING NOT active`
+
+	var disabled bool
+	err := r.db.QueryRowContext(ctx, query, id, limit, time.Now()).Scan(&disabled)
+	if errors.Is(err, sql.ErrNoRows) {
+		return false, nil
+	}
+	if err != nil || !disabled {
+		return false, err
+	}
+
+	_, err = r.db.ExecContext(ctx, `
+		UPDATE webhook_deliveries 
+		SET status = 'failed', last_error = 'endpoint disabled'
+		WHERE endpoint_id = $1 AND status = 'pending'`, id)
+
+	return true, err
+}
+
+// ListDeliveries retrieves the delivery log of an endpoint
+func (r *WebhookRepository) ListDeliveries(ctx context.Context, endpointID, limit, offset int) ([]*WebhookDelivery, error) {
+	ctx, cancel := withQueryTimeout(ctx, r.timeout)
+	defer cancel()
+
+	deliveries := []*WebhookDelivery{}
+	query := `
+		SELECT ` + webhookDeliveryColumns + `
+		FROM webhook_deliveries 
+		WHERE endpoint_id = $1 
+		ORDER BY created_at DESC, id DESC 
+		LIMIT $2 OFFSET $3`
+
+	err := r.db.SelectContext(ctx, &deliveries, query, endpointID, limit, offset)
+	return deliveries, err
+}
+
+// GetDelivery retrieves a webhook delivery by ID
+func (r *WebhookRepository) GetDelivery(ctx context.Context, id int) (*WebhookDelivery, error) {
+	ctx, cancel := withQueryTimeout(ctx, r.timeout)
+	defer cancel()
+
+	delivery := &WebhookDelivery{}
+	query := `SELECT ` + webhookDeliveryColumns + ` FROM webhook_deliveries WHERE id = $1`
+
+	err := r.db.GetContext(ctx, delivery, query, id)
+	if err != nil {
+		return nil, err
+	}
+
+	return delivery, nil
+}
+
+// Replay queues a copy of a logged delivery for immediate sending so the
+// original attempt stays in the log
+func (r *WebhookRepository) Replay(ctx context.Context, id int) (*WebhookDelivery, error) {
+	ctx, cancel := withQueryTimeout(ctx, r.timeout)
+	defer cancel()
+
+	delivery := &WebhookDelivery{}
+	query := `
+		INSERT INTO webhook_deliveries (endpoint_id, event_type, payload, next_attempt_at, created_at)
+		SELECT endpoint_id, event_type, payload, $2, $2
+		FROM webhook_deliveries 
+		WHERE id = $1
+		RETURNING ` + webhookDeliveryColumns
+
+	err := r.db.GetContext(ctx, delivery, query, id, time.Now())
+	if err != nil {
+		return nil, err
+	}
+
+	return delivery, nil
+}
@@ -0,0 +1,67 @@
+package handlers
+
+import (
+	"fmt"
+
+	"pets_rest/internal/database"
+	"pets_rest/internal/middleware"
+	"pets_rest/internal/privacy"
+
+	"github.com/gofiber/fiber/v3"
+	"github.com/gofiber/fiber/v3/middleware/session"
+)
+
+type AccountHandler struct {
+	privacy *privacy.Service
+}
+
+func NewAccountHandler(service *privacy.Service) *AccountHandler {
+	return &AccountHandler{privacy: service}
+}
+
+// Export downloads the archive of the current user's data once it is
+// built; until then it queues the export and reports its status with
+// 202 Accepted, so clients poll it until the archive arrives
+func (h *AccountHandler) Export(c fiber.Ctx) error {
+	export, err := h.privacy.RequestExport(c, middleware.UserID(c))
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to request data export",
+		})
+	}
+
+	if export.Status != database.DataExportStatusReady {
+		return c.Status(fiber.StatusAccepted).JSON(fiber.Map{
+			"export": export,
+		})
+	}
+
+	archive, err := h.privacy.Archive(c, export)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to load data export",
+		})
+	}
+
+	c.Attachment(fmt.Sprintf("pets-data-%s.zip", export.CompletedAt.Format("2006-01-02")))
+	return c.Send(archive)
+}
+
+// Delete erases the current user's account for good and ends the session
+func (h *AccountHandler) Delete(c fiber.Ctx) error {
+	if err := h.privacy.Erase(c, middleware.UserID(c)); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to delete account",
+		})
+	}
+
+	if sess := session.FromContext(c); sess != nil {
+		if err := sess.Destroy(); err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"error": "Failed to end session",
+			})
+		}
+	}
+
+	return c.SendStatus(fiber.StatusNoContent)
+}
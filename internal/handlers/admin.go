@@ -0,0 +1,562 @@
+package handlers
+
+import (
+	"database/sql"
+	"errors"
+	"strings"
+	"time"
+
+	"pets_rest/internal/audit"
+	"pets_rest/internal/config"
+	"pets_rest/internal/database"
+	"pets_rest/internal/listings"
+	"pets_rest/internal/middleware"
+
+	"github.com/gofiber/fiber/v3"
+	"github.com/jmoiron/sqlx"
+)
+
+const (
+	defaultStatsDays = 30
+	maxStatsDays     = 365
+)
+
+type AdminHandler struct {
+	db       *database.DB
+	cfg      *config.Config
+	users    *database.UserRepository
+	listings *database.ListingRepository
+	stats    *database.StatsRepository
+	entries  *database.AuditRepository
+	jobs     *database.JobRepository
+	audit    *audit.Log
+	service  *listings.Service
+}
+
+func NewAdminHandler(db *database.DB, cfg *config.Config, service *listings.Service) *AdminHandler {
+	return &AdminHandler{
+		db:       db,
+		cfg:      cfg,
+		users:    database.NewUserRepository(db),
+		listings: database.NewListingRepository(db),
+		stats:    database.NewStatsRepository(db),
+		entries:  database.NewAuditRepository(db),
+		jobs:     database.NewJobRepository(db),
+		audit:    audit.NewLog(db),
+		service:  service,
+	}
+}
+
+// Users searches users by email or name, optionally filtered by role and
+// suspension; ?deleted=true lists deleted users instead
+func (h *AdminHandler) Users(c fiber.Ctx) error {
+	limit, offset := pagination(c)
+
+	filter := database.UserFilter{
+		Query:   strings.TrimSpace(c.Query("q")),
+		Role:    database.Role(c.Query("role")),
+		Deleted: fiber.Query[bool](c, "deleted"),
+	}
+	if filter.Role != "" && !filter.Role.Valid() {
+		return fiber.NewError(fiber.StatusBadRequest, "Role must be user, moderator, admin or shelter")
+	}
+	if c.Query("suspended") != "" {
+		suspended := fiber.Query[bool](c, "suspended")
+		filter.Suspended = &suspended
+	}
+
+	users, err := h.users.List(c, filter, limit, offset)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to load users",
+		})
+	}
+
+	total, err := h.users.Count(c, filter)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to count users",
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"users": users,
+		"total": total,
+	})
+}
+
+// Suspend blocks the user from the :id route parameter from signing in and
+// using the API; their listings stay as they are
+func (h *AdminHandler) Suspend(c fiber.Ctx) error {
+	return h.setSuspended(c, true)
+}
+
+// Unsuspend lifts a suspension or a moderator's ban
+func (h *AdminHandler) Unsuspend(c fiber.Ctx) error {
+	return h.setSuspended(c, false)
+}
+
+func (h *AdminHandler) setSuspended(c fiber.Ctx, suspended bool) error {
+	userID, err := paramID(c, "id")
+	if err != nil {
+		return err
+	}
+	if userID == middleware.UserID(c) {
+		return fiber.NewError(fiber.StatusBadRequest, "You cannot suspend yourself")
+	}
+
+	var user *database.User
+	err = h.db.WithTx(c, func(tx *sqlx.Tx) error {
+		users := h.users.WithTx(tx)
+
+		before, err := users.GetByIDForUpdate(c, userID)
+		if err != nil {
+			return err
+		}
+		if suspended {
+			err = users.Ban(c, userID)
+		} else {
+			err = users.Unban(c, userID)
+		}
+		if err != nil {
+			return err
+		}
+		if user, err = users.GetByID(c, userID); err != nil {
+			return err
+		}
+		return h.audit.WithTx(tx).Record(c, database.AuditEntityUser, userID, before, user)
+	})
+	if errors.Is(err, sql.ErrNoRows) {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "User not found",
+		})
+	}
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to update user",
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"user": user,
+	})
+}
+
+// DeleteUser deletes the user from the :id route parameter together with
+// their listings; both can be restored within Config.RestoreGracePeriod
+func (h *AdminHandler) DeleteUser(c fiber.Ctx) error {
+	userID, err := paramID(c, "id")
+	if err != nil {
+		return err
+	}
+	if userID == middleware.UserID(c) {
+		return fiber.NewError(fiber.StatusBadRequest, "You cannot delete yourself")
+	}
+
+	err = h.db.WithTx(c, func(tx *sqlx.Tx) error {
+		users := h.users.WithTx(tx)
+		log := h.audit.WithTx(tx)
+
+		user, err := users.GetByIDForUpdate(c, userID)
+		if err != nil {
+			return err
+		}
+		owned, err := h.listings.WithTx(tx).ListAllByUser(c, userID)
+		if err != nil {
+			return err
+		}
+
+		if err := users.Delete(c, userID); err != nil {
+			return err
+		}
+		if err := log.Record(c, database.AuditEntityUser, userID, user, nil); err != nil {
+			return err
+		}
+		for _, listing := range owned {
+			if err := log.Record(c, database.AuditEntityListing, listing.ID, listing, nil); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if errors.Is(err, sql.ErrNoRows) {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "User not found",
+		})
+	}
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to delete user",
+		})
+	}
+
+	return c.SendStatus(fiber.StatusNoContent)
+}
+
+// RestoreUser brings back the user from the :id route parameter, deleted
+// within Config.RestoreGracePeriod, and the listings deleted with them
+func (h *AdminHandler) RestoreUser(c fiber.Ctx) error {
+	userID, err := paramID(c, "id")
+	if err != nil {
+		return err
+	}
+
+	var user *database.User
+	err = h.db.WithTx(c, func(tx *sqlx.Tx) error {
+		users := h.users.WithTx(tx)
+		listings := h.listings.WithTx(tx)
+		log := h.audit.WithTx(tx)
+		since := time.Now().Add(-h.cfg.RestoreGracePeriod)
+
+		deleted, err := users.GetDeletedByID(c, userID, since)
+		if err != nil {
+			return err
+		}
+		owned, err := listings.ListDeletedWithUser(c, deleted)
+		if err != nil {
+			return err
+		}
+
+		if err := users.Restore(c, userID, since); err != nil {
+			return err
+		}
+		if user, err = users.GetByID(c, userID); err != nil {
+			return err
+		}
+		if err := log.Record(c, database.AuditEntityUser, userID, deleted, user); err != nil {
+			return err
+		}
+		for _, listing := range owned {
+			restored := *listing
+			restored.DeletedAt = nil
+			if err := log.Record(c, database.AuditEntityListing, listing.ID, listing, &restored); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if errors.Is(err, sql.ErrNoRows) {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "User not found or can no longer be restored",
+		})
+	}
+	if database.IsUniqueViolation(err) {
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{
+			"error": "The email of this user belongs to another account now",
+		})
+	}
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to restore user",
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"user": user,
+	})
+}
+
+// Listings searches all listings, including drafts and hidden ones, by
+// title or description, optionally filtered by status, moderation state and
+// owner; ?deleted=true lists deleted listings instead
+func (h *AdminHandler) Listings(c fiber.Ctx) error {
+	limit, offset := pagination(c)
+
+	filter := database.ListingFilter{
+		Query:      strings.TrimSpace(c.Query("q")),
+		Status:     database.ListingStatus(c.Query("status")),
+		Moderation: database.ModerationState(c.Query("moderation")),
+		UserID:     fiber.Query[int](c, "user_id"),
+		Deleted:    fiber.Query[bool](c, "deleted"),
+	}
+
+	listings, err := h.listings.List(c, filter, limit, offset)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to load listings",
+		})
+	}
+
+	total, err := h.listings.Count(c, filter)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to count listings",
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"listings": listings,
+		"total":    total,
+	})
+}
+
+// ArchiveListing takes the listing from the :id route parameter down and,
+// like the owner archiving it, announces an active listing as resolved
+func (h *AdminHandler) ArchiveListing(c fiber.Ctx) error {
+	id, err := paramID(c, "id")
+	if err != nil {
+		return err
+	}
+
+	listing, err := h.service.Archive(c, id)
+	if errors.Is(err, sql.ErrNoRows) {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "Listing not found",
+		})
+	}
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to archive listing",
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"listing": listing,
+	})
+}
+
+// Stats returns platform statistics for the last ?days= days
+func (h *AdminHandler) Stats(c fiber.Ctx) error {
+	days := fiber.Query(c, "days", defaultStatsDays)
+	if days < 1 || days > maxStatsDays {
+		return fiber.NewError(fiber.StatusBadRequest, "days must be between 1 and 365")
+	}
+
+	stats, err := h.stats.Platform(c, time.Now().AddDate(0, 0, -days))
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to load stats",
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"stats": stats,
+	})
+}
+
+// Audit returns the audit log, newest first, optionally narrowed down to
+// one entity (?entity_type=user|listing&entity_id=) or one actor (?actor_id=)
+func (h *AdminHandler) Audit(c fiber.Ctx) error {
+	limit, offset := pagination(c)
+
+	filter := database.AuditFilter{
+		EntityType: database.AuditEntity(c.Query("entity_type")),
+		EntityID:   fiber.Query[int](c, "entity_id"),
+		ActorID:    fiber.Query[int](c, "actor_id"),
+	}
+	if filter.EntityType != "" && filter.EntityType != database.AuditEntityUser && filter.EntityType != database.AuditEntityListing {
+		return fiber.NewError(fiber.StatusBadRequest, "entity_type must be user or listing")
+	}
+	if filter.EntityID != 0 && filter.EntityType == "" {
+		return fiber.NewError(fiber.StatusBadRequest, "entity_id requires entity_type")
+	}
+
+	entries, err := h.entries.List(c, filter, limit, offset)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to load audit log",
+		})
+	}
+
+	total, err := h.entries.Count(c, filter)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to count audit log entries",
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"entries": entries,
+		"total":   total,
+	})
+}
+
+// DeadJobs returns background jobs that ran out of attempts, newest first
+func (h *AdminHandler) DeadJobs(c fiber.Ctx) error {
+	limit, offset := pagination(c)
+
+	jobs, err := h.jobs.ListDead(c, limit, offset)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to load jobs",
+		})
+	}
+
+	total, err := h.jobs.CountDead(c)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to count jobs",
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"jobs":  jobs,
+		"total": total,
+	})
+}
+
+// RequeueJob puts the dead job from the :id route parameter back in the
+// queue with a fresh attempt budget, e.g. once the cause has been fixed
+func (h *AdminHandler) RequeueJob(c fiber.Ctx) error {
+	id, err := paramID(c, "id")
+	if err != nil {
+		return err
+	}
+
+	err = h.jobs.Requeue(c, int64(id))
+	if errors.Is(err, sql.ErrNoRows) {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "Dead job not found",
+		})
+	}
+	if database.IsUniqueViolation(err) {
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{
+			"error": "A job with the same unique key is already queued",
+		})
+	}
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to requeue job",
+		})
+	}
+
+	return c.SendStatus(fiber.StatusNoContent)
+}
+
+type mergeRequest struct {
+	IntoUserID int `json:"into_user_id"`
+}
+
+// MergeUsers moves everything of the user from the :id route parameter to
+// into_user_id and deletes the former, for people who ended up with two
+// accounts. The target keeps its role; admins change it with SetRole.
+func (h *AdminHandler) MergeUsers(c fiber.Ctx) error {
+	fromID, err := paramID(c, "id")
+	if err != nil {
+		return err
+	}
+
+	var req mergeRequest
+	if err := c.Bind().Body(&req); err != nil {
+		return fiber.NewError(fiber.StatusBadRequest, "Invalid request body")
+	}
+	if req.IntoUserID <= 0 || req.IntoUserID == fromID {
+		return fiber.NewError(fiber.StatusBadRequest, "into_user_id must be another user")
+	}
+	if actor := middleware.UserID(c); fromID == actor || req.IntoUserID == actor {
+		return fiber.NewError(fiber.StatusBadRequest, "You cannot merge your own account")
+	}
+
+	var user *database.User
+	err = h.db.WithTx(c, func(tx *sqlx.Tx) error {
+		users := h.users.WithTx(tx)
+		listings := h.listings.WithTx(tx)
+		log := h.audit.WithTx(tx)
+
+		// Merge locks both users itself; reading them first is enough to
+		// know what changed
+		source, err := users.GetByID(c, fromID)
+		if err != nil {
+			return err
+		}
+		target, err := users.GetByID(c, req.IntoUserID)
+		if err != nil {
+			return err
+		}
+		moved, err := listings.ListAllByUser(c, fromID)
+		if err != nil {
+			return err
+		}
+
+		if err := users.Merge(c, fromID, req.IntoUserID); err != nil {
+			return err
+		}
+		if user, err = users.GetByID(c, req.IntoUserID); err != nil {
+			return err
+		}
+
+		if err := log.Record(c, database.AuditEntityUser, fromID, source, nil); err != nil {
+			return err
+		}
+		if err := log.Record(c, database.AuditEntityUser, user.ID, target, user); err != nil {
+			return err
+		}
+		for _, listing := range moved {
+			after := *listing
+			after.UserID = user.ID
+			if err := log.Record(c, database.AuditEntityListing, listing.ID, listing, &after); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if errors.Is(err, sql.ErrNoRows) {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "User not found",
+		})
+	}
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to merge users",
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"user": user,
+	})
+}
+
+type roleRequest struct {
+	Role database.Role `json:"role"`
+}
+
+// SetRole changes the role of the user from the :id route parameter;
+// administrators cannot change their own role so that one always remains
+func (h *AdminHandler) SetRole(c fiber.Ctx) error {
+	userID, err := paramID(c, "id")
+	if err != nil {
+		return err
+	}
+	if userID == middleware.UserID(c) {
+		return fiber.NewError(fiber.StatusBadRequest, "You cannot change your own role")
+	}
+
+	var req roleRequest
+	if err := c.Bind().Body(&req); err != nil {
+		return fiber.NewError(fiber.StatusBadRequest, "Invalid request body")
+	}
+	if !req.Role.Valid() {
+		return fiber.NewError(fiber.StatusBadRequest, "Role must be user, moderator, admin or shelter")
+	}
+
+	var user *database.User
+	err = h.db.WithTx(c, func(tx *sqlx.Tx) error {
+		users := h.users.WithTx(tx)
+
+		before, err := users.GetByIDForUpdate(c, userID)
+		if err != nil {
+			return err
+		}
+		if err := users.SetRole(c, userID, req.Role); err != nil {
+			return err
+		}
+		if user, err = users.GetByID(c, userID); err != nil {
+			return err
+		}
+		return h.audit.WithTx(tx).Record(c, database.AuditEntityUser, userID, before, user)
+	})
+	if errors.Is(err, sql.ErrNoRows) {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "User not found",
+		})
+	}
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to change role",
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"user": user,
+	})
+}
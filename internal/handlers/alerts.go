@@ -0,0 +1,181 @@
+package handlers
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"strings"
+
+	"pets_rest/internal/alerts"
+	"pets_rest/internal/database"
+	"pets_rest/internal/middleware"
+	"pets_rest/internal/policy"
+
+	"github.com/gofiber/fiber/v3"
+)
+
+const maxRadiusKm = 200
+
+type AlertHandler struct {
+	alerts  *database.AlertRepository
+	service *alerts.Service
+}
+
+func NewAlertHandler(db *database.DB, service *alerts.Service) *AlertHandler {
+	return &AlertHandler{
+		alerts:  database.NewAlertRepository(db),
+		service: service,
+	}
+}
+
+type alertRequest struct {
+	City      *string                 `json:"city"`
+	Latitude  *float64                `json:"latitude"`
+	Longitude *float64                `json:"longitude"`
+	RadiusKm  *float64                `json:"radius_km"`
+	Types     []string                `json:"types"`
+	Species   []string                `json:"species"`
+	Channel   database.AlertChannel   `json:"channel"`
+	Frequency database.AlertFrequency `json:"frequency"`
+}
+
+func (r *alertRequest) validate() error {
+	if r.Channel == "" {
+		r.Channel = database.AlertChannelEmail
+	}
+	if r.Frequency == "" {
+		r.Frequency = database.AlertFrequencyInstant
+	}
+	if r.City != nil {
+		city := strings.TrimSpace(*r.City)
+		r.City = &city
+		if city == "" {
+			r.City = nil
+		}
+	}
+	for i, s := range r.Species {
+		r.Species[i] = strings.ToLower(strings.TrimSpace(s))
+	}
+
+	hasPoint := r.Latitude != nil && r.Longitude != nil && r.RadiusKm != nil
+	switch {
+	case r.City == nil && !hasPoint:
+		return fiber.NewError(fiber.StatusBadRequest, "Either city or latitude, longitude and radius_km are required")
+	case r.RadiusKm != nil && (*r.RadiusKm <= 0 || *r.RadiusKm > maxRadiusKm):
+		return fiber.NewError(fiber.StatusBadRequest, "Radius must be between 0 and 200 km")
+	case r.Channel != database.AlertChannelEmail && r.Channel != database.AlertChannelPush && r.Channel != database.AlertChannelTelegram:
+		return fiber.NewError(fiber.StatusBadRequest, "Channel must be email, push or telegram")
+	case r.Frequency != database.AlertFrequencyInstant && r.Frequency != database.AlertFrequencyDigest:
+		return fiber.NewError(fiber.StatusBadRequest, "Frequency must be instant or digest")
+	}
+
+	for _, t := range r.Types {
+		switch database.ListingType(t) {
+		case database.ListingTypeLost, database.ListingTypeFound, database.ListingTypeAdopt:
+		default:
+			return fiber.NewError(fiber.StatusBadRequest, "Types must be lost, found or adopt")
+		}
+	}
+
+	return nil
+}
+
+// Create subscribes the current user to new listings in an area
+func (h *AlertHandler) Create(c fiber.Ctx) error {
+	var req alertRequest
+	if err := c.Bind().Body(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid request body",
+		})
+	}
+	if err := req.validate(); err != nil {
+		return err
+	}
+
+	sub := &database.AlertSubscription{
+		UserID:           middleware.UserID(c),
+		City:             req.City,
+		Latitude:         req.Latitude,
+		Longitude:        req.Longitude,
+		RadiusKm:         req.RadiusKm,
+		Types:            req.Types,
+		Species:          req.Species,
+		Channel:          req.Channel,
+		Frequency:        req.Frequency,
+		UnsubscribeToken: newToken(),
+	}
+	if err := h.alerts.Create(c, sub); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to create alert",
+		})
+	}
+
+	return c.Status(fiber.StatusCreated).JSON(fiber.Map{
+		"alert":           sub,
+		"unsubscribe_url": h.service.UnsubscribeURL(sub),
+	})
+}
+
+// List returns the alert subscriptions of the current user
+func (h *AlertHandler) List(c fiber.Ctx) error {
+	subs, err := h.alerts.ListByUser(c, middleware.UserID(c))
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to load alerts",
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"alerts": subs,
+	})
+}
+
+// Delete removes an alert subscription of the current user; administrators
+// may remove any
+func (h *AlertHandler) Delete(c fiber.Ctx) error {
+	id, err := paramID(c, "id")
+	if err != nil {
+		return err
+	}
+
+	sub, err := h.alerts.GetByID(c, id)
+	if err != nil || !policy.CanManageAlert(middleware.Actor(c), sub) {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "Alert not found",
+		})
+	}
+
+	if err := h.alerts.Delete(c, sub.ID); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to delete alert",
+		})
+	}
+
+	return c.SendStatus(fiber.StatusNoContent)
+}
+
+// Unsubscribe removes a subscription by the token from an alert email; it
+// answers both the link (GET) and RFC 8058 one-click (POST) requests
+func (h *AlertHandler) Unsubscribe(c fiber.Ctx) error {
+	found, err := h.alerts.DeleteByToken(c, c.Params("token"))
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to unsubscribe",
+		})
+	}
+	if !found {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "Subscription not found or already removed",
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"message": "You have been unsubscribed",
+	})
+}
+
+// newToken returns a random hex token for links sent by email
+func newToken() string {
+	b := make([]byte, 24)
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}
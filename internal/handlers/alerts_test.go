@@ -0,0 +1,58 @@
+package handlers
+
+import (
+	"testing"
+
+	"github.com/gofiber/fiber/v3"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"pets_rest/internal/database"
+)
+
+func TestAlertRequestValidate(t *testing.T) {
+	city := func(s string) *string { return &s }
+	num := func(f float64) *float64 { return &f }
+
+	tests := []struct {
+		name  string
+		req   alertRequest
+		valid bool
+	}{
+		{"місто", alertRequest{City: city("Київ")}, true},
+		{"точка з радіусом", alertRequest{Latitude: num(50.45), Longitude: num(30.52), RadiusKm: num(5)}, true},
+		{"без місця", alertRequest{}, false},
+		{"порожнє місто", alertRequest{City: city("   ")}, false},
+		{"точка без радіуса", alertRequest{Latitude: num(50.45), Longitude: num(30.52)}, false},
+		{"нульовий радіус", alertRequest{Latitude: num(50.45), Longitude: num(30.52), RadiusKm: num(0)}, false},
+		{"завеликий радіус", alertRequest{Latitude: num(50.45), Longitude: num(30.52), RadiusKm: num(maxRadiusKm + 1)}, false},
+		{"невідомий канал", alertRequest{City: city("Київ"), Channel: "sms"}, false},
+		{"невідома частота", alertRequest{City: city("Київ"), Frequency: "weekly"}, false},
+		{"відомі типи", alertRequest{City: city("Київ"), Types: []string{"lost", "found", "adopt"}}, true},
+		{"невідомий тип", alertRequest{City: city("Київ"), Types: []string{"sale"}}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.req.validate()
+			if tt.valid {
+				assert.NoError(t, err)
+				return
+			}
+			var fe *fiber.Error
+			require.ErrorAs(t, err, &fe)
+			assert.Equal(t, fiber.StatusBadRequest, fe.Code)
+		})
+	}
+}
+
+func TestAlertRequestValidateNormalizes(t *testing.T) {
+	city := "  Львів "
+	req := alertRequest{City: &city, Species: []string{" Кіт", "DOG "}}
+	require.NoError(t, req.validate())
+
+	// Типові канал і частота, обрізане місто і види в нижньому регістрі
+	assert.Equal(t, database.AlertChannelEmail, req.Channel)
+	assert.Equal(t, database.AlertFrequencyInstant, req.Frequency)
+	assert.Equal(t, "Львів", *req.City)
+	assert.Equal(t, []string{"кіт", "dog"}, req.Species)
+}
@@ -1,31 +1,110 @@
 package handlers
 
 import (
+	"context"
+	"database/sql"
+	"errors"
+	"strings"
+	"time"
+
+	"pets_rest/internal/audit"
+	"pets_rest/internal/auth"
 	"pets_rest/internal/config"
 	"pets_rest/internal/database"
 	"pets_rest/internal/oauth"
+	"pets_rest/pkg/helper"
 
 	"github.com/gofiber/fiber/v3"
 	"github.com/gofiber/fiber/v3/middleware/session"
+	"github.com/jmoiron/sqlx"
+)
+
+const (
+	// linkTicketTTL bounds the time between asking to link a sign-in
+	// method and opening the login URL
+	linkTicketTTL = 5 * time.Minute
+	// linkSessionKey marks a sign-in that links an identity instead of
+	// logging in; it holds the ID of the link ticket the session redeemed
+	linkSessionKey = "oauth_link:"
+)
+
+var (
+	errUnverifiedEmail = fiber.NewError(fiber.StatusForbidden, "Provider did not confirm your email address")
+	errIdentityTaken   = fiber.NewError(fiber.StatusConflict, "This sign-in is already linked to another account")
+	errBanned          = fiber.NewError(fiber.StatusForbidden, "Account is banned")
+	errDeleted         = fiber.NewError(fiber.StatusForbidden, "Account is deleted")
 )
 
 type AuthHandler struct {
-	db             *database.DB
-	cfg            *config.Config
-	googleProvider *oauth.GoogleProvider
+	db         *database.DB
+	cfg        *config.Config
+	users      *database.UserRepository
+	identities *database.IdentityRepository
+	tickets    *database.LinkTicketRepository
+	providers  *oauth.Registry
+	audit      *audit.Log
+	// admins are emails that get the admin role when they sign in
+	admins map[string]bool
 }
 
-func NewAuthHandler(db *database.DB, cfg *config.Config) *AuthHandler {
+func NewAuthHandler(db *database.DB, cfg *config.Config, providers *oauth.Registry) *AuthHandler {
+	admins := make(map[string]bool)
+	for _, email := range strings.Split(cfg.AdminEmails, ",") {
+		if email = strings.ToLower(strings.TrimSpace(email)); email != "" {
+			admins[email] = true
+		}
+	}
+
 	return &AuthHandler{
 		db: db, cfg: cfg,
-		googleProvider: oauth.NewGoogle(),
+		users:      database.NewUserRepository(db),
+		identities: database.NewIdentityRepository(db),
+		tickets:    database.NewLinkTicketRepository(db),
+		providers:  providers,
+		audit:      audit.NewLog(db),
+		admins:     admins,
 	}
 }
 
-func (h *AuthHandler) GoogleLogin(c fiber.Ctx) error {
+// Providers lists the enabled sign-in providers
+func (h *AuthHandler) Providers(c fiber.Ctx) error {
+	return c.JSON(fiber.Map{
+		"providers": h.providers.Names(),
+	})
+}
+
+// Login redirects to the provider named by the :provider route parameter
+func (h *AuthHandler) Login(c fiber.Ctx) error {
+	provider, err := h.provider(c)
+	if err != nil {
+		return err
+	}
+
+	// A link ticket from Link turns this sign-in into linking the identity
+	// to the ticket's account. Redeeming binds the ticket to this session,
+	// so a leaked URL is useless once opened and in any other browser.
 	sess := session.FromContext(c)
+	if raw := c.Query("link"); raw != "" {
+		ticket, err := auth.ParseLinkTicket(h.cfg.JWTSecret, raw)
+		if err == nil {
+			err = h.tickets.Redeem(c, ticket.ID, ticket.UserID, provider.Name(), sess.ID())
+		}
+		if errors.Is(err, auth.ErrInvalidToken) || errors.Is(err, sql.ErrNoRows) {
+			return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+				"error": "Invalid or expired link ticket",
+			})
+		}
+		if err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"error": "Failed to redeem link ticket",
+			})
+		}
+		sess.Set(linkSessionKey+provider.Name(), ticket.ID)
+	} else {
+		sess.Delete(linkSessionKey + provider.Name())
+	}
 
-	url, err := h.googleProvider.AuthURLWithPKCEandState(sess.Session)
+	url, err := provider.AuthURL(sess)
 	if err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
 			"error": "Failed to generate auth URL",
@@ -35,20 +114,210 @@ func (h *AuthHandler) GoogleLogin(c fiber.Ctx) error {
 	return c.Redirect().To(url)
 }
 
-func (h *AuthHandler) GoogleCallback(c fiber.Ctx) error {
-	sess := session.FromContext(c)
+// Callback completes a sign-in and issues an access token, or links the
+// identity when the sign-in was started with a link ticket. Apple posts the
+// response as a form, so state and code are read from the body as well as
+// the query.
+func (h *AuthHandler) Callback(c fiber.Ctx) error {
+	provider, err := h.provider(c)
+	if err != nil {
+		return err
+	}
 
-	state := c.Query("state")
-	code := c.Query("code")
+	state := c.Query("state", c.FormValue("state"))
+	code := c.Query("code", c.FormValue("code"))
 
-	u, err := h.googleProvider.HandleCallback(c, sess.Session, state, code)
+	sess := session.FromContext(c)
+	u, err := provider.HandleCallback(c, sess, state, code)
 	if err != nil {
 		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
 			"error": err.Error(),
 		})
 	}
 
+	if ticketID := helper.GetString(sess.Get(linkSessionKey + provider.Name())); ticketID != "" {
+		sess.Delete(linkSessionKey + provider.Name())
+
+		linkUserID, err := h.tickets.Consume(c, ticketID, sess.ID())
+		if errors.Is(err, sql.ErrNoRows) {
+			return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+				"error": "Invalid or expired link ticket",
+			})
+		}
+		if err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"error": "Failed to redeem link ticket",
+			})
+		}
+		return h.link(c, linkUserID, u)
+	}
+
+	user, err := h.signIn(c, u)
+	if err != nil {
+		var fiberErr *fiber.Error
+		if errors.As(err, &fiberErr) {
+			return err
+		}
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to load user",
+		})
+	}
+	if user.BannedAt != nil {
+		return errBanned
+	}
+
+	token, err := auth.IssueToken(h.cfg.JWTSecret, user.ID, h.cfg.JWTExpiration)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to issue access token",
+		})
+	}
+
 	return c.JSON(fiber.Map{
-		"user": u,
+		"access_token": token,
+		"user":         user,
 	})
 }
+
+// provider resolves the :provider route parameter
+func (h *AuthHandler) provider(c fiber.Ctx) (oauth.Provider, error) {
+	provider, ok := h.providers.Get(c.Params("provider"))
+	if !ok {
+		return nil, fiber.NewError(fiber.StatusNotFound, "Unknown sign-in provider")
+	}
+	return provider, nil
+}
+
+// signIn maps a provider profile onto a local user: first by the linked
+// identity, then by verified email, creating the user on first login
+func (h *AuthHandler) signIn(c fiber.Ctx, u oauth.User) (*database.User, error) {
+	var user *database.User
+	err := h.db.WithTx(c, func(tx *sqlx.Tx) error {
+		users := h.users.WithTx(tx)
+		identities := h.identities.WithTx(tx)
+		log := h.audit.WithTx(tx)
+
+		identity, err := identities.GetByProvider(c, u.Provider, u.ProviderID)
+		if err == nil {
+			if err := identities.TouchLogin(c, identity.ID, optionalString(u.Email)); err != nil {
+				return err
+			}
+			// Identities stay with a deleted account until it is purged
+			user, err = users.GetByID(c, identity.UserID)
+			if errors.Is(err, sql.ErrNoRows) {
+				return errDeleted
+			}
+			if err != nil {
+				return err
+			}
+			return h.grantAdmin(signedIn(c, user), users, log, user)
+		}
+		if !errors.Is(err, sql.ErrNoRows) {
+			return err
+		}
+
+		// Accounts are matched by email, so an unverified address would let
+		// anyone sign in as its owner
+		if u.Email == "" || !u.EmailVerified {
+			return errUnverifiedEmail
+		}
+
+		user, err = users.GetByEmail(c, u.Email)
+		if errors.Is(err, sql.ErrNoRows) {
+			user = &database.User{Email: u.Email}
+			if u.Name != "" {
+				user.Name = &u.Name
+			}
+			if err = users.Create(c, user); err == nil {
+				err = log.Record(signedIn(c, user), database.AuditEntityUser, user.ID, nil, user)
+			}
+		}
+		if err != nil {
+			return err
+		}
+		if err := h.grantAdmin(signedIn(c, user), users, log, user); err != nil {
+			return err
+		}
+
+		return identities.Create(c, &database.UserIdentity{
+			UserID:     user.ID,
+			Provider:   u.Provider,
+			ProviderID: u.ProviderID,
+			Email:      optionalString(u.Email),
+		})
+	})
+
+	return user, err
+}
+
+// signedIn is the request context with the user signing in as the actor of
+// the changes made to their account
+func signedIn(c fiber.Ctx, user *database.User) context.Context {
+	actor := audit.FromContext(c)
+	actor.UserID = &user.ID
+	return audit.NewContext(c, actor)
+}
+
+// grantAdmin gives the admin role to users listed in Config.AdminEmails, so
+// that a fresh installation has someone to hand out the other roles
+func (h *AuthHandler) grantAdmin(ctx context.Context, users *database.UserRepository, log *audit.Log, user *database.User) error {
+	if !h.admins[strings.ToLower(user.Email)] || user.Role == database.RoleAdmin {
+		return nil
+	}
+	if err := users.SetRole(ctx, user.ID, database.RoleAdmin); err != nil {
+		return err
+	}
+
+	before := *user
+	user.Role = database.RoleAdmin
+	return log.Record(ctx, database.AuditEntityUser, user.ID, &before, user)
+}
+
+// link attaches a provider identity to the account of userID; the email of
+// the identity does not need to match the account's
+func (h *AuthHandler) link(c fiber.Ctx, userID int, u oauth.User) error {
+	var identity *database.UserIdentity
+	err := h.db.WithTx(c, func(tx *sqlx.Tx) error {
+		identities := h.identities.WithTx(tx)
+
+		existing, err := identities.GetByProvider(c, u.Provider, u.ProviderID)
+		if err == nil {
+			if existing.UserID != userID {
+				return errIdentityTaken
+			}
+			identity = existing
+			return nil
+		}
+		if !errors.Is(err, sql.ErrNoRows) {
+			return err
+		}
+
+		identity = &database.UserIdentity{
+			UserID:     userID,
+			Provider:   u.Provider,
+			ProviderID: u.ProviderID,
+			Email:      optionalString(u.Email),
+		}
+		return identities.Create(c, identity)
+	})
+	if errors.Is(err, errIdentityTaken) || database.IsUniqueViolation(err) {
+		return errIdentityTaken
+	}
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to link sign-in method",
+		})
+	}
+
+	return c.Status(fiber.StatusCreated).JSON(fiber.Map{
+		"identity": identity,
+	})
+}
+
+// optionalString maps an empty string to NULL
+func optionalString(s string) *string {
+	if s == "" {
+		return nil
+	}
+	return &s
+}
@@ -0,0 +1,219 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"net/mail"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"pets_rest/internal/captcha"
+	"pets_rest/internal/config"
+	"pets_rest/internal/database"
+	"pets_rest/internal/listings"
+	"pets_rest/internal/notify"
+
+	"github.com/gofiber/fiber/v3"
+	"github.com/jmoiron/sqlx"
+)
+
+const (
+	challengeTTL        = 5 * time.Minute
+	maxRelayMessageSize = 2000
+)
+
+type ContactHandler struct {
+	cfg      *config.Config
+	db       *database.DB
+	listings *database.ListingRepository
+	events   *database.EventRepository
+	pow      *captcha.ProofOfWork
+	notify   *notify.Service
+	service  *listings.Service
+}
+
+func NewContactHandler(db *database.DB, cfg *config.Config, notifier *notify.Service, service *listings.Service) *ContactHandler {
+	return &ContactHandler{
+		cfg:      cfg,
+		db:       db,
+		listings: database.NewListingRepository(db),
+		events:   database.NewEventRepository(db),
+		pow:      captcha.NewProofOfWork(cfg.JWTSecret, cfg.ContactPoWDifficulty, challengeTTL, database.NewChallengeRepository(db)),
+		notify:   notifier,
+		service:  service,
+	}
+}
+
+type proofRequest struct {
+	Challenge string `json:"challenge"`
+	Solution  string `json:"solution"`
+}
+
+type relayRequest struct {
+	proofRequest
+	Name    string `json:"name"`
+	Email   string `json:"email"`
+	Message string `json:"message"`
+}
+
+type contactsVisibilityRequest struct {
+	Hidden bool `json:"hidden"`
+}
+
+// Challenge issues a proof-of-work challenge required to reveal contacts or
+// write to the owner
+func (h *ContactHandler) Challenge(c fiber.Ctx) error {
+	listing, err := publicListing(c, h.listings)
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(h.pow.New(*listing.Slug))
+}
+
+// Reveal returns the hidden contacts of a listing after a solved challenge
+func (h *ContactHandler) Reveal(c fiber.Ctx) error {
+	listing, err := publicListing(c, h.listings)
+	if err != nil {
+		return err
+	}
+
+	var req proofRequest
+	if err := c.Bind().Body(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid request body",
+		})
+	}
+
+	if err := h.pow.Verify(c, *listing.Slug, req.Challenge, req.Solution); err != nil {
+		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	if err := h.recordContactClick(c, h.events, listing, "reveal"); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to record contact reveal",
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"contact_phone": listing.ContactPhone,
+		"contact_tg":    listing.ContactTg,
+	})
+}
+
+// Relay forwards a finder's message to the listing owner without disclosing
+// the owner's address; by default it is emailed with the finder as Reply-To
+func (h *ContactHandler) Relay(c fiber.Ctx) error {
+	listing, err := publicListing(c, h.listings)
+	if err != nil {
+		return err
+	}
+
+	var req relayRequest
+	if err := c.Bind().Body(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid request body",
+		})
+	}
+
+	req.Name = strings.TrimSpace(req.Name)
+	req.Message = strings.TrimSpace(req.Message)
+	if _, err := mail.ParseAddress(req.Email); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Valid email is required",
+		})
+	}
+	if req.Message == "" || utf8.RuneCountInString(req.Message) > maxRelayMessageSize {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": fmt.Sprintf("Message must be between 1 and %d characters", maxRelayMessageSize),
+		})
+	}
+
+	if err := h.pow.Verify(c, *listing.Slug, req.Challenge, req.Solution); err != nil {
+		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	sender := req.Email
+	if req.Name != "" {
+		sender = req.Name + " <" + req.Email + ">"
+	}
+
+	err = h.db.WithTx(c, func(tx *sqlx.Tx) error {
+		if err := h.recordContactClick(c, h.events.WithTx(tx), listing, "relay"); err != nil {
+			return err
+		}
+		return h.notify.SendTx(c, tx, notify.Notification{
+			UserID: listing.UserID,
+			Kind:   notify.KindContactRelay,
+			Data: map[string]any{
+				"listing_title": listing.Title,
+				"listing_url":   h.cfg.FrontendURL + "/p/" + *listing.Slug,
+				"sender":        sender,
+				"message":       req.Message,
+				"reply_to":      req.Email,
+			},
+		})
+	})
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to send message",
+		})
+	}
+
+	return c.Status(fiber.StatusAccepted).JSON(fiber.Map{
+		"message": "Message sent to the owner",
+	})
+}
+
+// SetVisibility lets the owner hide or show contacts on the public page;
+// like other edits it needs the If-Match header of the current version
+func (h *ContactHandler) SetVisibility(c fiber.Ctx) error {
+	listing, err := editableListing(c, h.listings)
+	if err != nil {
+		return err
+	}
+	if err := checkIfMatch(c, listing); err != nil {
+		return err
+	}
+
+	var req contactsVisibilityRequest
+	if err := c.Bind().Body(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid request body",
+		})
+	}
+
+	err = h.service.SetContactsHidden(c, listing, req.Hidden)
+	if errors.Is(err, listings.ErrVersionConflict) {
+		return errListingChanged
+	}
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to update contacts visibility",
+		})
+	}
+
+	setListingETag(c, listing)
+	return c.JSON(fiber.Map{
+		"contacts_hidden": listing.ContactsHidden,
+	})
+}
+
+func (h *ContactHandler) recordContactClick(c fiber.Ctx, events *database.EventRepository, listing *database.Listing, channel string) error {
+	ip := c.IP()
+	ua := c.Get(fiber.HeaderUserAgent)
+	return events.Create(c, &database.Event{
+		ListingID: listing.ID,
+		Type:      database.EventTypeContactClick,
+		Payload: database.JSONPayload{
+			"channel": channel,
+		},
+		IPAddress: &ip,
+		UserAgent: &ua,
+	})
+}
@@ -0,0 +1,273 @@
+package handlers
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"net/url"
+	"strings"
+	"unicode/utf8"
+
+	"pets_rest/internal/config"
+	"pets_rest/internal/database"
+	"pets_rest/internal/middleware"
+	"pets_rest/internal/notify"
+	"pets_rest/internal/realtime"
+
+	"github.com/gofiber/fiber/v3"
+	"github.com/jmoiron/sqlx"
+)
+
+const (
+	maxMessageSize   = 4000
+	maxMessageImages = 5
+)
+
+type ConversationHandler struct {
+	cfg           *config.Config
+	db            *database.DB
+	listings      *database.ListingRepository
+	conversations *database.ConversationRepository
+	notify        *notify.Service
+	broker        realtime.Broker
+}
+
+func NewConversationHandler(db *database.DB, cfg *config.Config, notifier *notify.Service, broker realtime.Broker) *ConversationHandler {
+	return &ConversationHandler{
+		cfg:           cfg,
+		db:            db,
+		listings:      database.NewListingRepository(db),
+		conversations: database.NewConversationRepository(db),
+		notify:        notifier,
+		broker:        broker,
+	}
+}
+
+type messageRequest struct {
+	Body   string   `json:"body"`
+	Images []string `json:"images"`
+}
+
+type conversationResponse struct {
+	*database.Conversation
+	Unread int `json:"unread"`
+}
+
+// Start opens a conversation with the owner of an active listing, or reuses
+// the existing one, and posts the first message
+func (h *ConversationHandler) Start(c fiber.Ctx) error {
+	listingID, err := paramID(c, "id")
+	if err != nil {
+		return err
+	}
+
+	listing, err := h.listings.GetByID(c, listingID)
+	if err != nil || !listing.Visible() {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "Listing not found",
+		})
+	}
+
+	userID := middleware.UserID(c)
+	if listing.UserID == userID {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "You cannot message yourself",
+		})
+	}
+
+	msg, err := parseMessage(c)
+	if err != nil {
+		return err
+	}
+
+	conversation, err := h.conversations.GetOrCreate(c, listing.ID, listing.UserID, userID)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to start conversation",
+		})
+	}
+
+	if err := h.send(c, conversation, msg); err != nil {
+		return err
+	}
+
+	return c.Status(fiber.StatusCreated).JSON(fiber.Map{
+		"conversation": conversationResponse{conversation, conversation.UnreadFor(userID)},
+		"message":      msg,
+	})
+}
+
+// List returns the conversations of the current user with unread counters
+func (h *ConversationHandler) List(c fiber.Ctx) error {
+	userID := middleware.UserID(c)
+	limit, offset := pagination(c)
+
+	conversations, err := h.conversations.ListByUser(c, userID, limit, offset)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to load conversations",
+		})
+	}
+
+	unread, err := h.conversations.UnreadTotal(c, userID)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to load unread counter",
+		})
+	}
+
+	response := make([]conversationResponse, 0, len(conversations))
+	for _, conv := range conversations {
+		response = append(response, conversationResponse{conv, conv.UnreadFor(userID)})
+	}
+
+	return c.JSON(fiber.Map{
+		"conversations": response,
+		"unread":        unread,
+	})
+}
+
+// Messages returns messages of a conversation, newest first
+func (h *ConversationHandler) Messages(c fiber.Ctx) error {
+	conversation, err := h.participantConversation(c)
+	if err != nil {
+		return err
+	}
+
+	limit, offset := pagination(c)
+	messages, err := h.conversations.ListMessages(c, conversation.ID, limit, offset)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to load messages",
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"messages": messages,
+	})
+}
+
+// Send posts a message to a conversation of the current user
+func (h *ConversationHandler) Send(c fiber.Ctx) error {
+	conversation, err := h.participantConversation(c)
+	if err != nil {
+		return err
+	}
+
+	msg, err := parseMessage(c)
+	if err != nil {
+		return err
+	}
+
+	if err := h.send(c, conversation, msg); err != nil {
+		return err
+	}
+
+	return c.Status(fiber.StatusCreated).JSON(fiber.Map{
+		"message": msg,
+	})
+}
+
+// MarkRead resets the unread counter of the current user in a conversation
+func (h *ConversationHandler) MarkRead(c fiber.Ctx) error {
+	conversation, err := h.participantConversation(c)
+	if err != nil {
+		return err
+	}
+
+	if err := h.conversations.MarkRead(c, conversation.ID, middleware.UserID(c)); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to mark conversation as read",
+		})
+	}
+
+	return c.SendStatus(fiber.StatusNoContent)
+}
+
+// send stores the message and queues the recipient's notification in one
+// transaction, then pushes the message to the recipient's live stream
+func (h *ConversationHandler) send(c fiber.Ctx, conversation *database.Conversation, msg *database.Message) error {
+	msg.ConversationID = conversation.ID
+	msg.SenderID = middleware.UserID(c)
+	recipientID := conversation.OtherParticipant(msg.SenderID)
+
+	listing, err := h.listings.GetByID(c, conversation.ListingID)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to load conversation listing",
+		})
+	}
+
+	err = h.db.WithTx(c, func(tx *sqlx.Tx) error {
+		if err := h.conversations.WithTx(tx).AddMessage(c, msg); err != nil {
+			return err
+		}
+		return h.notify.SendTx(c, tx, notify.Notification{
+			UserID: recipientID,
+			Kind:   notify.KindMessageReceived,
+			Data: map[string]any{
+				"conversation_id":  conversation.ID,
+				"listing_title":    listing.Title,
+				"body":             msg.Body,
+				"conversation_url": fmt.Sprintf("%s/conversations/%d", h.cfg.FrontendURL, conversation.ID),
+			},
+		})
+	})
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to send message",
+		})
+	}
+
+	if err := h.broker.Publish(c, recipientID, realtime.EventMessageCreated, msg); err != nil {
+		log.Printf("Failed to publish message event: %v", err)
+	}
+	return nil
+}
+
+// participantConversation loads the conversation from the :id route
+// parameter and checks that the current user takes part in it
+func (h *ConversationHandler) participantConversation(c fiber.Ctx) (*database.Conversation, error) {
+	id, err := paramID(c, "id")
+	if err != nil {
+		return nil, err
+	}
+
+	conversation, err := h.conversations.GetByID(c, id)
+	if errors.Is(err, sql.ErrNoRows) || (err == nil && !conversation.HasParticipant(middleware.UserID(c))) {
+		return nil, fiber.NewError(fiber.StatusNotFound, "Conversation not found")
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return conversation, nil
+}
+
+// parseMessage reads and validates a message from the request body
+func parseMessage(c fiber.Ctx) (*database.Message, error) {
+	var req messageRequest
+	if err := c.Bind().Body(&req); err != nil {
+		return nil, fiber.NewError(fiber.StatusBadRequest, "Invalid request body")
+	}
+
+	req.Body = strings.TrimSpace(req.Body)
+	if req.Body == "" && len(req.Images) == 0 {
+		return nil, fiber.NewError(fiber.StatusBadRequest, "Message text or photo is required")
+	}
+	if utf8.RuneCountInString(req.Body) > maxMessageSize {
+		return nil, fiber.NewError(fiber.StatusBadRequest, fmt.Sprintf("Message must be at most %d characters", maxMessageSize))
+	}
+	if len(req.Images) > maxMessageImages {
+		return nil, fiber.NewError(fiber.StatusBadRequest, fmt.Sprintf("At most %d photos per message", maxMessageImages))
+	}
+	for _, img := range req.Images {
+		u, err := url.Parse(img)
+		if err != nil || (u.Scheme != "https" && u.Scheme != "http") || u.Host == "" {
+			return nil, fiber.NewError(fiber.StatusBadRequest, "Photos must be http(s) URLs")
+		}
+	}
+
+	return &database.Message{Body: req.Body, Images: req.Images}, nil
+}
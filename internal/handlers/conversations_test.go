@@ -0,0 +1,72 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gofiber/fiber/v3"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"pets_rest/internal/database"
+)
+
+func TestParseMessage(t *testing.T) {
+	var parsed *database.Message
+	app := fiber.New()
+	app.Post("/messages", func(c fiber.Ctx) error {
+		msg, err := parseMessage(c)
+		if err != nil {
+			return err
+		}
+		parsed = msg
+		return c.SendStatus(fiber.StatusCreated)
+	})
+
+	images := func(n int) []string {
+		urls := make([]string, n)
+		for i := range urls {
+			urls[i] = "https://img.example/cat.jpg"
+		}
+		return urls
+	}
+
+	tests := []struct {
+		name   string
+		body   string
+		images []string
+		status int
+	}{
+		{"текст", "  Бачила його біля парку  ", nil, fiber.StatusCreated},
+		{"лише фото", "", images(1), fiber.StatusCreated},
+		{"порожнє повідомлення", "   ", nil, fiber.StatusBadRequest},
+		// Ліміт рахує символи, а не байти кирилиці
+		{"кирилиця на межі", strings.Repeat("ї", maxMessageSize), nil, fiber.StatusCreated},
+		{"задовге", strings.Repeat("a", maxMessageSize+1), nil, fiber.StatusBadRequest},
+		{"забагато фото", "", images(maxMessageImages + 1), fiber.StatusBadRequest},
+		{"не http-адреса", "", []string{"javascript:alert(1)"}, fiber.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			parsed = nil
+			body, err := json.Marshal(messageRequest{Body: tt.body, Images: tt.images})
+			require.NoError(t, err)
+
+			req := httptest.NewRequest(http.MethodPost, "/messages", strings.NewReader(string(body)))
+			req.Header.Set(fiber.HeaderContentType, fiber.MIMEApplicationJSON)
+
+			resp, err := app.Test(req)
+			require.NoError(t, err)
+			defer func() { _ = resp.Body.Close() }()
+
+			assert.Equal(t, tt.status, resp.StatusCode)
+			if tt.status == fiber.StatusCreated {
+				require.NotNil(t, parsed)
+				assert.Equal(t, strings.TrimSpace(tt.body), parsed.Body)
+			}
+		})
+	}
+}
@@ -0,0 +1,98 @@
+package handlers
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"log"
+	"strconv"
+
+	"pets_rest/internal/database"
+	"pets_rest/internal/middleware"
+	"pets_rest/internal/policy"
+	"pets_rest/internal/realtime"
+	"pets_rest/pkg/helper"
+
+	"github.com/gofiber/fiber/v3"
+)
+
+const (
+	// uniqueCodeAttempts bounds retries when a random code collides with an existing one
+	uniqueCodeAttempts = 5
+
+	defaultPageSize = 10
+	maxPageSize     = 100
+)
+
+// paramID parses a positive integer route parameter
+func paramID(c fiber.Ctx, name string) (int, error) {
+	id, err := strconv.Atoi(c.Params(name))
+	if err != nil || id <= 0 {
+		return 0, fiber.NewError(fiber.StatusBadRequest, "Invalid "+name)
+	}
+	return id, nil
+}
+
+// pagination reads page and limit query parameters and returns limit and offset
+func pagination(c fiber.Ctx) (limit, offset int) {
+	page := fiber.Query(c, "page", 1)
+	limit = fiber.Query(c, "limit", defaultPageSize)
+
+	if page < 1 {
+		page = 1
+	}
+	if limit < 1 || limit > maxPageSize {
+		limit = defaultPageSize
+	}
+
+	return limit, (page - 1) * limit
+}
+
+// editableListing loads the listing from the :id route parameter and checks
+// that the authenticated user may edit it
+func editableListing(c fiber.Ctx, listings *database.ListingRepository) (*database.Listing, error) {
+	id, err := paramID(c, "id")
+	if err != nil {
+		return nil, err
+	}
+
+	listing, err := listings.GetByID(c, id)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, fiber.NewError(fiber.StatusNotFound, "Listing not found")
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if !policy.CanEditListing(middleware.Actor(c), listing) {
+		return nil, fiber.NewError(fiber.StatusForbidden, "You cannot edit this listing")
+	}
+
+	return listing, nil
+}
+
+// withUniqueCode calls create with fresh random codes until it does not hit
+// a unique constraint violation
+func withUniqueCode(length int, create func(code string) error) error {
+	var err error
+	for range uniqueCodeAttempts {
+		err = create(helper.RandomCode(length))
+		if !database.IsUniqueViolation(err) {
+			return err
+		}
+	}
+	return err
+}
+
+// publishScan notifies the listing owner about a QR scan in real time
+func publishScan(ctx context.Context, broker realtime.Broker, listing *database.Listing, event *database.Event) {
+	data := map[string]any{
+		"listing_id": listing.ID,
+		"event_id":   event.ID,
+		"payload":    event.Payload,
+		"created_at": event.CreatedAt,
+	}
+	if err := broker.Publish(ctx, listing.UserID, realtime.EventListingScanned, data); err != nil {
+		log.Printf("Failed to publish scan event: %v", err)
+	}
+}
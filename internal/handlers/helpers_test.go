@@ -0,0 +1,50 @@
+package handlers
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/lib/pq"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWithUniqueCode(t *testing.T) {
+	conflict := &pq.Error{Code: "23505"}
+
+	t.Run("повтор після конфлікту", func(t *testing.T) {
+		var codes []string
+		err := withUniqueCode(6, func(code string) error {
+			codes = append(codes, code)
+			if len(codes) < 3 {
+				return conflict
+			}
+			return nil
+		})
+		assert.NoError(t, err)
+		assert.Len(t, codes, 3)
+		for _, code := range codes {
+			assert.Len(t, code, 6)
+		}
+	})
+
+	t.Run("інші помилки не повторюються", func(t *testing.T) {
+		failed := errors.New("failed")
+		calls := 0
+		err := withUniqueCode(6, func(string) error {
+			calls++
+			return failed
+		})
+		assert.ErrorIs(t, err, failed)
+		assert.Equal(t, 1, calls)
+	})
+
+	t.Run("спроби скінченні", func(t *testing.T) {
+		calls := 0
+		err := withUniqueCode(6, func(string) error {
+			calls++
+			return conflict
+		})
+		assert.ErrorIs(t, err, conflict)
+		assert.Equal(t, uniqueCodeAttempts, calls)
+	})
+}
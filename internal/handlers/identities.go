@@ -0,0 +1,119 @@
+package handlers
+
+import (
+	"errors"
+	"slices"
+
+	"pets_rest/internal/auth"
+	"pets_rest/internal/config"
+	"pets_rest/internal/database"
+	"pets_rest/internal/middleware"
+	"pets_rest/internal/oauth"
+
+	"github.com/gofiber/fiber/v3"
+	"github.com/jmoiron/sqlx"
+)
+
+var (
+	errIdentityNotFound = fiber.NewError(fiber.StatusNotFound, "Sign-in method not found")
+	errLastIdentity     = fiber.NewError(fiber.StatusConflict, "Cannot remove the last sign-in method")
+)
+
+type IdentityHandler struct {
+	db         *database.DB
+	cfg        *config.Config
+	identities *database.IdentityRepository
+	tickets    *database.LinkTicketRepository
+	providers  *oauth.Registry
+}
+
+func NewIdentityHandler(db *database.DB, cfg *config.Config, providers *oauth.Registry) *IdentityHandler {
+	return &IdentityHandler{
+		db:         db,
+		cfg:        cfg,
+		identities: database.NewIdentityRepository(db),
+		tickets:    database.NewLinkTicketRepository(db),
+		providers:  providers,
+	}
+}
+
+// List returns the sign-in methods linked to the current user
+func (h *IdentityHandler) List(c fiber.Ctx) error {
+	identities, err := h.identities.ListByUser(c, middleware.UserID(c))
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to load sign-in methods",
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"identities": identities,
+		"providers":  h.providers.Names(),
+	})
+}
+
+// Link returns the login URL that links the provider from the :provider
+// route parameter to the current user; the browser has to open it so the
+// provider can authenticate the person. The ticket in the URL is stored
+// and works once.
+func (h *IdentityHandler) Link(c fiber.Ctx) error {
+	name := c.Params("provider")
+	if _, ok := h.providers.Get(name); !ok {
+		return fiber.NewError(fiber.StatusNotFound, "Unknown sign-in provider")
+	}
+
+	ticket, issued, err := auth.IssueLinkTicket(h.cfg.JWTSecret, middleware.UserID(c), linkTicketTTL)
+	if err == nil {
+		err = h.tickets.Create(c, &database.LinkTicket{
+			ID:        issued.ID,
+			UserID:    issued.UserID,
+			Provider:  name,
+			ExpiresAt: issued.ExpiresAt,
+		})
+	}
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to issue link ticket",
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"url": h.cfg.BaseURL + "/api/v1/auth/" + name + "/login?link=" + ticket,
+	})
+}
+
+// Unlink removes a sign-in method of the current user unless it is the last one
+func (h *IdentityHandler) Unlink(c fiber.Ctx) error {
+	id, err := paramID(c, "id")
+	if err != nil {
+		return err
+	}
+
+	err = h.db.WithTx(c, func(tx *sqlx.Tx) error {
+		identities := h.identities.WithTx(tx)
+
+		linked, err := identities.ListByUserForUpdate(c, middleware.UserID(c))
+		if err != nil {
+			return err
+		}
+
+		if !slices.ContainsFunc(linked, func(identity *database.UserIdentity) bool { return identity.ID == id }) {
+			return errIdentityNotFound
+		}
+		if len(linked) == 1 {
+			return errLastIdentity
+		}
+
+		return identities.Delete(c, id)
+	})
+	if errors.Is(err, errIdentityNotFound) || errors.Is(err, errLastIdentity) {
+		return err
+	}
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to remove sign-in method",
+		})
+	}
+
+	return c.SendStatus(fiber.StatusNoContent)
+}
@@ -0,0 +1,532 @@
+package handlers
+
+import (
+	"database/sql"
+	"errors"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"pets_rest/internal/audit"
+	"pets_rest/internal/config"
+	"pets_rest/internal/database"
+	"pets_rest/internal/listings"
+	"pets_rest/internal/middleware"
+	"pets_rest/internal/policy"
+
+	"github.com/gofiber/fiber/v3"
+	"github.com/jmoiron/sqlx"
+)
+
+const (
+	maxTitleLength   = 255
+	maxListingImages = 10
+)
+
+var (
+	errIfMatchRequired = fiber.NewError(fiber.StatusPreconditionRequired, "If-Match header with the listing ETag is required")
+	errListingChanged  = fiber.NewError(fiber.StatusPreconditionFailed, "Listing was changed by someone else, reload it and try again")
+)
+
+type ListingHandler struct {
+	db         *database.DB
+	cfg        *config.Config
+	listings   *database.ListingRepository
+	revisions  *database.ListingRevisionRepository
+	moderation *database.ModerationRepository
+	service    *listings.Service
+	audit      *audit.Log
+}
+
+func NewListingHandler(db *database.DB, cfg *config.Config, service *listings.Service) *ListingHandler {
+	return &ListingHandler{
+		db:         db,
+		cfg:        cfg,
+		listings:   database.NewListingRepository(db),
+		revisions:  database.NewListingRevisionRepository(db),
+		moderation: database.NewModerationRepository(db),
+		service:    service,
+		audit:      audit.NewLog(db),
+	}
+}
+
+type listingRequest struct {
+	Type           database.ListingType   `json:"type"`
+	Title          string                 `json:"title"`
+	Description    *string                `json:"description"`
+	City           *string                `json:"city"`
+	Location       *string                `json:"location"`
+	Species        *string                `json:"species"`
+	Latitude       *float64               `json:"latitude"`
+	Longitude      *float64               `json:"longitude"`
+	ContactPhone   *string                `json:"contact_phone"`
+	ContactTg      *string                `json:"contact_tg"`
+	ContactsHidden bool                   `json:"contacts_hidden"`
+	Status         database.ListingStatus `json:"status"`
+	Images         []string               `json:"images"`
+}
+
+func (r *listingRequest) validate() error {
+	r.Title = strings.TrimSpace(r.Title)
+	if r.Status == "" {
+		r.Status = database.ListingStatusDraft
+	}
+	if r.Species != nil {
+		species := strings.ToLower(strings.TrimSpace(*r.Species))
+		r.Species = &species
+	}
+
+	switch {
+	case r.Type != database.ListingTypeLost && r.Type != database.ListingTypeFound && r.Type != database.ListingTypeAdopt:
+		return fiber.NewError(fiber.StatusBadRequest, "Type must be lost, found or adopt")
+	case r.Title == "" || len(r.Title) > maxTitleLength:
+		return fiber.NewError(fiber.StatusBadRequest, "Title is required and must be at most 255 characters")
+	case r.Status != database.ListingStatusDraft && r.Status != database.ListingStatusActive && r.Status != database.ListingStatusArchived:
+		return fiber.NewError(fiber.StatusBadRequest, "Status must be draft, active or archived")
+	case (r.Latitude == nil) != (r.Longitude == nil):
+		return fiber.NewError(fiber.StatusBadRequest, "Latitude and longitude must be set together")
+	case len(r.Images) > maxListingImages:
+		return fiber.NewError(fiber.StatusBadRequest, "Too many images")
+	}
+
+	for _, img := range r.Images {
+		u, err := url.Parse(img)
+		if err != nil || (u.Scheme != "https" && u.Scheme != "http") || u.Host == "" {
+			return fiber.NewError(fiber.StatusBadRequest, "Images must be http(s) URLs")
+		}
+	}
+
+	return nil
+}
+
+// newListingRequest fills a request with the current values of a listing,
+// so that a partial update keeps the fields it does not mention
+func newListingRequest(listing *database.Listing) listingRequest {
+	return listingRequest{
+		Type:           listing.Type,
+		Title:          listing.Title,
+		Description:    listing.Description,
+		City:           listing.City,
+		Location:       listing.Location,
+		Species:        listing.Species,
+		Latitude:       listing.Latitude,
+		Longitude:      listing.Longitude,
+		ContactPhone:   listing.ContactPhone,
+		ContactTg:      listing.ContactTg,
+		ContactsHidden: listing.ContactsHidden,
+		Status:         listing.Status,
+		Images:         listing.Images,
+	}
+}
+
+func (r *listingRequest) apply(listing *database.Listing) {
+	listing.Type = r.Type
+	listing.Title = r.Title
+	listing.Description = r.Description
+	listing.City = r.City
+	listing.Location = r.Location
+	listing.Species = r.Species
+	listing.Latitude = r.Latitude
+	listing.Longitude = r.Longitude
+	listing.ContactPhone = r.ContactPhone
+	listing.ContactTg = r.ContactTg
+	listing.ContactsHidden = r.ContactsHidden
+	listing.Status = r.Status
+	listing.Images = r.Images
+}
+
+// List returns active listings, optionally filtered by type and city
+func (h *ListingHandler) List(c fiber.Ctx) error {
+	limit, offset := pagination(c)
+
+	var listingType *database.ListingType
+	if t := c.Query("type"); t != "" {
+		lt := database.ListingType(t)
+		listingType = &lt
+	}
+	var city *string
+	if v := c.Query("city"); v != "" {
+		city = &v
+	}
+
+	listings, err := h.listings.ListActive(c, listingType, city, limit, offset)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to load listings",
+		})
+	}
+
+	public := make([]*database.Listing, len(listings))
+	for i, l := range listings {
+		public[i] = l.Public()
+	}
+
+	return c.JSON(fiber.Map{
+		"listings": public,
+	})
+}
+
+// Get returns an active listing that moderators have not hidden
+func (h *ListingHandler) Get(c fiber.Ctx) error {
+	id, err := paramID(c, "id")
+	if err != nil {
+		return err
+	}
+
+	listing, err := h.listings.GetByID(c, id)
+	if err != nil || !listing.Visible() {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "Listing not found",
+		})
+	}
+
+	setListingETag(c, listing)
+	return c.JSON(fiber.Map{
+		"listing": listing.Public(),
+	})
+}
+
+// Mine returns all listings of the current user, including drafts
+func (h *ListingHandler) Mine(c fiber.Ctx) error {
+	userID := middleware.UserID(c)
+	limit, offset := pagination(c)
+
+	listings, err := h.listings.ListByUser(c, userID, limit, offset)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to load listings",
+		})
+	}
+
+	total, err := h.listings.CountByUser(c, userID)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to count listings",
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"listings": listings,
+		"total":    total,
+	})
+}
+
+// Create creates a listing owned by the current user
+func (h *ListingHandler) Create(c fiber.Ctx) error {
+	var req listingRequest
+	if err := c.Bind().Body(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid request body",
+		})
+	}
+	if err := req.validate(); err != nil {
+		return err
+	}
+
+	listing := &database.Listing{UserID: middleware.UserID(c)}
+	req.apply(listing)
+
+	if err := h.service.Create(c, listing); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to create listing",
+		})
+	}
+
+	setListingETag(c, listing)
+	return c.Status(fiber.StatusCreated).JSON(fiber.Map{
+		"listing": listing,
+	})
+}
+
+// Update replaces the editable fields of a listing; owners and moderators
+// may edit it. The If-Match header must carry the ETag of the version the
+// changes are based on.
+func (h *ListingHandler) Update(c fiber.Ctx) error {
+	return h.update(c, false)
+}
+
+// Patch changes only the fields present in the body, otherwise it works
+// like Update; a field sent as null is cleared
+func (h *ListingHandler) Patch(c fiber.Ctx) error {
+	return h.update(c, true)
+}
+
+func (h *ListingHandler) update(c fiber.Ctx, partial bool) error {
+	listing, err := editableListing(c, h.listings)
+	if err != nil {
+		return err
+	}
+	if err := checkIfMatch(c, listing); err != nil {
+		return err
+	}
+
+	var req listingRequest
+	if partial {
+		req = newListingRequest(listing)
+	}
+	if err := c.Bind().Body(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid request body",
+		})
+	}
+	if err := req.validate(); err != nil {
+		return err
+	}
+
+	previous := listing.Status
+	req.apply(listing)
+
+	err = h.service.Update(c, listing, previous)
+	if errors.Is(err, listings.ErrVersionConflict) {
+		return errListingChanged
+	}
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to update listing",
+		})
+	}
+
+	setListingETag(c, listing)
+	return c.JSON(fiber.Map{
+		"listing": listing,
+	})
+}
+
+// Delete deletes a listing; owners and moderators may delete it and
+// restore it within Config.RestoreGracePeriod
+func (h *ListingHandler) Delete(c fiber.Ctx) error {
+	listing, err := editableListing(c, h.listings)
+	if err != nil {
+		return err
+	}
+
+	err = h.db.WithTx(c, func(tx *sqlx.Tx) error {
+		if err := h.listings.WithTx(tx).Delete(c, listing.ID); err != nil {
+			return err
+		}
+		return h.audit.WithTx(tx).Record(c, database.AuditEntityListing, listing.ID, listing, nil)
+	})
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to delete listing",
+		})
+	}
+
+	return c.SendStatus(fiber.StatusNoContent)
+}
+
+// Restore brings back a listing deleted within Config.RestoreGracePeriod;
+// whoever may edit the listing may restore it. Subscribers were told about
+// the listing when it was published, so it is not announced again.
+func (h *ListingHandler) Restore(c fiber.Ctx) error {
+	id, err := paramID(c, "id")
+	if err != nil {
+		return err
+	}
+
+	var listing *database.Listing
+	err = h.db.WithTx(c, func(tx *sqlx.Tx) error {
+		listings := h.listings.WithTx(tx)
+
+		deleted, err := listings.GetDeletedByID(c, id, time.Now().Add(-h.cfg.RestoreGracePeriod))
+		if errors.Is(err, sql.ErrNoRows) {
+			return fiber.NewError(fiber.StatusNotFound, "Listing not found or can no longer be restored")
+		}
+		if err != nil {
+			return err
+		}
+		if !policy.CanEditListing(middleware.Actor(c), deleted) {
+			return fiber.NewError(fiber.StatusForbidden, "You cannot edit this listing")
+		}
+
+		if _, err := listings.Restore(c, id, *deleted.DeletedAt); err != nil {
+			return err
+		}
+		if listing, err = listings.GetByID(c, id); err != nil {
+			return err
+		}
+		return h.audit.WithTx(tx).Record(c, database.AuditEntityListing, id, deleted, listing)
+	})
+	var fiberErr *fiber.Error
+	if errors.As(err, &fiberErr) {
+		return err
+	}
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to restore listing",
+		})
+	}
+
+	setListingETag(c, listing)
+	return c.JSON(fiber.Map{
+		"listing": listing,
+	})
+}
+
+// Revisions returns the edit history of a listing, newest first, to whoever
+// may edit it. approved_at is when a moderator last approved the listing
+// and edits_since_approval how many revisions were made after that.
+func (h *ListingHandler) Revisions(c fiber.Ctx) error {
+	listing, err := editableListing(c, h.listings)
+	if err != nil {
+		return err
+	}
+	limit, offset := pagination(c)
+
+	revisions, err := h.revisions.ListByListing(c, listing.ID, limit, offset)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to load revisions",
+		})
+	}
+
+	total, err := h.revisions.CountByListing(c, listing.ID)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to count revisions",
+		})
+	}
+
+	approvedAt, err := h.moderation.LastApprovedAt(c, listing.ID)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to load approval",
+		})
+	}
+
+	var editsSinceApproval int
+	if approvedAt != nil {
+		if editsSinceApproval, err = h.revisions.CountSince(c, listing.ID, *approvedAt); err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"error": "Failed to count revisions",
+			})
+		}
+	}
+
+	return c.JSON(fiber.Map{
+		"revisions":            revisions,
+		"total":                total,
+		"approved_at":          approvedAt,
+		"edits_since_approval": editsSinceApproval,
+	})
+}
+
+// RevisionDiff returns the fields that differ between the revisions made
+// at the ?from= and ?to= versions, with their old and new values
+func (h *ListingHandler) RevisionDiff(c fiber.Ctx) error {
+	listing, err := editableListing(c, h.listings)
+	if err != nil {
+		return err
+	}
+
+	from := fiber.Query(c, "from", 0)
+	to := fiber.Query(c, "to", 0)
+	if from <= 0 || to <= 0 {
+		return fiber.NewError(fiber.StatusBadRequest, "from and to must be revision versions")
+	}
+
+	var revisions [2]*database.ListingRevision
+	for i, version := range []int{from, to} {
+		revisions[i], err = h.revisions.GetByVersion(c, listing.ID, version)
+		if errors.Is(err, sql.ErrNoRows) {
+			return fiber.NewError(fiber.StatusNotFound, "Revision not found")
+		}
+		if err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"error": "Failed to load revision",
+			})
+		}
+	}
+
+	changes, err := audit.Diff(&revisions[0].RevisionContent, &revisions[1].RevisionContent)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to compare revisions",
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"from":    revisions[0],
+		"to":      revisions[1],
+		"changes": changes,
+	})
+}
+
+// Revert brings the content of the listing back to the revision made at the
+// :version route parameter, recording it as a new revision; only the owner
+// may revert, with the If-Match header of the current version
+func (h *ListingHandler) Revert(c fiber.Ctx) error {
+	listing, err := editableListing(c, h.listings)
+	if err != nil {
+		return err
+	}
+	if !policy.CanRevertListing(middleware.Actor(c), listing) {
+		return fiber.NewError(fiber.StatusForbidden, "Only the owner can revert this listing")
+	}
+	if err := checkIfMatch(c, listing); err != nil {
+		return err
+	}
+
+	version, err := paramID(c, "version")
+	if err != nil {
+		return err
+	}
+
+	revision, err := h.revisions.GetByVersion(c, listing.ID, version)
+	if errors.Is(err, sql.ErrNoRows) {
+		return fiber.NewError(fiber.StatusNotFound, "Revision not found")
+	}
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to load revision",
+		})
+	}
+
+	previous := listing.Status
+	listing.Revert(revision.RevisionContent)
+
+	err = h.service.Update(c, listing, previous)
+	if errors.Is(err, listings.ErrVersionConflict) {
+		return errListingChanged
+	}
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to revert listing",
+		})
+	}
+
+	setListingETag(c, listing)
+	return c.JSON(fiber.Map{
+		"listing": listing,
+	})
+}
+
+// listingETag is the entity tag of a listing version
+func listingETag(listing *database.Listing) string {
+	return `"` + strconv.Itoa(listing.Version) + `"`
+}
+
+func setListingETag(c fiber.Ctx, listing *database.Listing) {
+	c.Set(fiber.HeaderETag, listingETag(listing))
+}
+
+// checkIfMatch requires the If-Match header to name the current version of
+// the listing, so that an edit made to an older version does not overwrite
+// changes the client has not seen
+func checkIfMatch(c fiber.Ctx, listing *database.Listing) error {
+	header := c.Get(fiber.HeaderIfMatch)
+	if header == "" {
+		return errIfMatchRequired
+	}
+
+	etag := listingETag(listing)
+	for tag := range strings.SplitSeq(header, ",") {
+		if tag = strings.TrimSpace(tag); tag == etag || tag == "*" {
+			return nil
+		}
+	}
+
+	setListingETag(c, listing)
+	return errListingChanged
+}
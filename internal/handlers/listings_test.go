@@ -0,0 +1,94 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gofiber/fiber/v3"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"pets_rest/internal/database"
+)
+
+func TestCheckIfMatch(t *testing.T) {
+	listing := &database.Listing{ID: 1, Version: 3}
+
+	app := fiber.New()
+	app.Put("/listings/1", func(c fiber.Ctx) error {
+		if err := checkIfMatch(c, listing); err != nil {
+			return err
+		}
+		return c.SendStatus(fiber.StatusNoContent)
+	})
+
+	tests := []struct {
+		name    string
+		ifMatch string
+		status  int
+	}{
+		{"без заголовка", "", fiber.StatusPreconditionRequired},
+		{"поточна версія", `"3"`, fiber.StatusNoContent},
+		{"одна з кількох", `"2", "3"`, fiber.StatusNoContent},
+		{"будь-яка версія", "*", fiber.StatusNoContent},
+		{"застаріла версія", `"2"`, fiber.StatusPreconditionFailed},
+		{"слабкий тег", `W/"3"`, fiber.StatusPreconditionFailed},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPut, "/listings/1", http.NoBody)
+			if tt.ifMatch != "" {
+				req.Header.Set(fiber.HeaderIfMatch, tt.ifMatch)
+			}
+
+			resp, err := app.Test(req)
+			require.NoError(t, err)
+			defer func() { _ = resp.Body.Close() }()
+
+			assert.Equal(t, tt.status, resp.StatusCode)
+			// Клієнт, що відстав, одразу дізнається поточну версію
+			if tt.status == fiber.StatusPreconditionFailed {
+				assert.Equal(t, `"3"`, resp.Header.Get(fiber.HeaderETag))
+			}
+		})
+	}
+}
+
+func TestPartialListingRequestKeepsOmittedFields(t *testing.T) {
+	description := "Рудий, з білою плямою"
+	city := "Київ"
+	listing := &database.Listing{
+		Type:        database.ListingTypeLost,
+		Title:       "Рудий кіт",
+		Description: &description,
+		City:        &city,
+		Status:      database.ListingStatusActive,
+		Images:      []string{"https://img.example/cat.jpg"},
+	}
+
+	var req listingRequest
+	app := fiber.New()
+	app.Patch("/listings/1", func(c fiber.Ctx) error {
+		req = newListingRequest(listing)
+		return c.Bind().Body(&req)
+	})
+
+	body := `{"title": "Рудий кіт з нашийником", "city": null}`
+	httpReq := httptest.NewRequest(http.MethodPatch, "/listings/1", strings.NewReader(body))
+	httpReq.Header.Set(fiber.HeaderContentType, fiber.MIMEApplicationJSON)
+
+	resp, err := app.Test(httpReq)
+	require.NoError(t, err)
+	_ = resp.Body.Close()
+
+	require.NoError(t, req.validate())
+	assert.Equal(t, "Рудий кіт з нашийником", req.Title)
+	// Явний null очищає поле, а не згадані поля лишаються як були
+	assert.Nil(t, req.City)
+	require.NotNil(t, req.Description)
+	assert.Equal(t, description, *req.Description)
+	assert.Equal(t, database.ListingStatusActive, req.Status)
+	assert.Equal(t, []string{"https://img.example/cat.jpg"}, req.Images)
+}
@@ -0,0 +1,201 @@
+package handlers
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"unicode/utf8"
+
+	"pets_rest/internal/captcha"
+	"pets_rest/internal/config"
+	"pets_rest/internal/database"
+	"pets_rest/internal/middleware"
+	"pets_rest/internal/moderation"
+
+	"github.com/gofiber/fiber/v3"
+)
+
+const maxReportCommentSize = 1000
+
+type ModerationHandler struct {
+	listings   *database.ListingRepository
+	moderation *database.ModerationRepository
+	service    *moderation.Service
+	pow        *captcha.ProofOfWork
+}
+
+func NewModerationHandler(db *database.DB, cfg *config.Config, service *moderation.Service) *ModerationHandler {
+	return &ModerationHandler{
+		listings:   database.NewListingRepository(db),
+		moderation: database.NewModerationRepository(db),
+		service:    service,
+		pow:        captcha.NewProofOfWork(cfg.JWTSecret, cfg.ContactPoWDifficulty, challengeTTL, database.NewChallengeRepository(db)),
+	}
+}
+
+type reportRequest struct {
+	proofRequest
+	Reason  database.ReportReason `json:"reason"`
+	Comment string                `json:"comment"`
+}
+
+type moderationActionRequest struct {
+	Action database.ModerationActionType `json:"action"`
+	Note   string                        `json:"note"`
+}
+
+// Report lets a visitor complain about a listing; it takes a solved
+// challenge from /p/{slug}/contacts/challenge like the contact endpoints
+func (h *ModerationHandler) Report(c fiber.Ctx) error {
+	listing, err := publicListing(c, h.listings)
+	if err != nil {
+		return err
+	}
+
+	var req reportRequest
+	if err := c.Bind().Body(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid request body",
+		})
+	}
+
+	req.Comment = strings.TrimSpace(req.Comment)
+	if !req.Reason.Valid() {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Reason must be scam, spam, abuse, animal_welfare or other",
+		})
+	}
+	if utf8.RuneCountInString(req.Comment) > maxReportCommentSize {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": fmt.Sprintf("Comment must be at most %d characters", maxReportCommentSize),
+		})
+	}
+
+	if err := h.pow.Verify(c, *listing.Slug, req.Challenge, req.Solution); err != nil {
+		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	// Signed-in visitors are told apart by account, so that visitors
+	// sharing an address can each report the listing
+	ip := c.IP()
+	report := &database.ListingReport{
+		Reason:    req.Reason,
+		IPAddress: &ip,
+	}
+	if userID := middleware.UserID(c); userID != 0 {
+		report.ReporterID = &userID
+	}
+	if req.Comment != "" {
+		report.Comment = &req.Comment
+	}
+
+	if _, err := h.service.Report(c, listing, report); errors.Is(err, moderation.ErrAlreadyReported) {
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{
+			"error": "You have already reported this listing",
+		})
+	} else if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to save report",
+		})
+	}
+
+	return c.Status(fiber.StatusAccepted).JSON(fiber.Map{
+		"message": "Thank you, moderators will review the listing",
+	})
+}
+
+// Queue returns reported listings and published listings not reviewed yet
+func (h *ModerationHandler) Queue(c fiber.Ctx) error {
+	limit, offset := pagination(c)
+
+	queue, err := h.moderation.Queue(c, limit, offset)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to load moderation queue",
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"listings": queue,
+	})
+}
+
+// Reports returns the reports about the listing from the :id route parameter
+func (h *ModerationHandler) Reports(c fiber.Ctx) error {
+	id, err := paramID(c, "id")
+	if err != nil {
+		return err
+	}
+
+	reports, err := h.moderation.ListReports(c, id)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to load reports",
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"reports": reports,
+	})
+}
+
+// History returns the moderation audit trail of a listing and its owner
+func (h *ModerationHandler) History(c fiber.Ctx) error {
+	id, err := paramID(c, "id")
+	if err != nil {
+		return err
+	}
+
+	actions, err := h.moderation.ListActions(c, id)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to load moderation history",
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"actions": actions,
+	})
+}
+
+// Act approves, hides or sends back a listing, or bans its owner
+func (h *ModerationHandler) Act(c fiber.Ctx) error {
+	id, err := paramID(c, "id")
+	if err != nil {
+		return err
+	}
+
+	var req moderationActionRequest
+	if err := c.Bind().Body(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid request body",
+		})
+	}
+
+	listing, err := h.service.Apply(c, middleware.Actor(c), id, req.Action, strings.TrimSpace(req.Note))
+	switch {
+	case errors.Is(err, moderation.ErrUnknownAction), errors.Is(err, moderation.ErrNoteRequired):
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	case errors.Is(err, moderation.ErrCannotBan):
+		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	case errors.Is(err, sql.ErrNoRows):
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "Listing not found",
+		})
+	case err != nil:
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to apply moderation action",
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"listing": listing,
+	})
+}
@@ -0,0 +1,119 @@
+package handlers
+
+import (
+	"slices"
+
+	"pets_rest/internal/audit"
+	"pets_rest/internal/database"
+	"pets_rest/internal/middleware"
+	"pets_rest/internal/notify"
+
+	"github.com/gofiber/fiber/v3"
+	"github.com/jmoiron/sqlx"
+)
+
+type NotificationHandler struct {
+	db     *database.DB
+	users  *database.UserRepository
+	notify *notify.Service
+	audit  *audit.Log
+}
+
+func NewNotificationHandler(db *database.DB, notifier *notify.Service) *NotificationHandler {
+	return &NotificationHandler{
+		db:     db,
+		users:  database.NewUserRepository(db),
+		notify: notifier,
+		audit:  audit.NewLog(db),
+	}
+}
+
+type notificationSettingsRequest struct {
+	Locale      *string                                        `json:"locale"`
+	Preferences map[notify.Kind][]database.NotificationChannel `json:"preferences"`
+}
+
+// Settings returns the notification language and channels per kind of the current user
+func (h *NotificationHandler) Settings(c fiber.Ctx) error {
+	user, err := h.users.GetByID(c, middleware.UserID(c))
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to load user",
+		})
+	}
+
+	prefs, err := h.notify.Preferences(c, user.ID)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to load notification preferences",
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"locale":      user.Locale,
+		"locales":     notify.Locales,
+		"channels":    h.notify.Channels(),
+		"preferences": prefs,
+	})
+}
+
+// UpdateSettings changes the notification language and the channels of the
+// given kinds; kinds that are not mentioned keep their channels
+func (h *NotificationHandler) UpdateSettings(c fiber.Ctx) error {
+	var req notificationSettingsRequest
+	if err := c.Bind().Body(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid request body",
+		})
+	}
+
+	userID := middleware.UserID(c)
+	if req.Locale != nil {
+		if !slices.Contains(notify.Locales, *req.Locale) {
+			return fiber.NewError(fiber.StatusBadRequest, "Unsupported locale")
+		}
+		err := h.db.WithTx(c, func(tx *sqlx.Tx) error {
+			users := h.users.WithTx(tx)
+
+			before, err := users.GetByIDForUpdate(c, userID)
+			if err != nil {
+				return err
+			}
+			if err := users.SetLocale(c, userID, *req.Locale); err != nil {
+				return err
+			}
+			after := *before
+			after.Locale = *req.Locale
+			return h.audit.WithTx(tx).Record(c, database.AuditEntityUser, userID, before, &after)
+		})
+		if err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"error": "Failed to update locale",
+			})
+		}
+	}
+
+	for kind, channels := range req.Preferences {
+		if _, ok := notify.Defaults[kind]; !ok {
+			return fiber.NewError(fiber.StatusBadRequest, "Unknown notification kind: "+string(kind))
+		}
+		available := h.notify.Channels()
+		for _, ch := range channels {
+			if !slices.Contains(available, ch) {
+				return fiber.NewError(fiber.StatusBadRequest, "Unknown notification channel: "+string(ch))
+			}
+		}
+	}
+	for kind, channels := range req.Preferences {
+		if channels == nil {
+			channels = []database.NotificationChannel{}
+		}
+		if err := h.notify.SetPreference(c, userID, kind, channels); err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"error": "Failed to update notification preferences",
+			})
+		}
+	}
+
+	return h.Settings(c)
+}
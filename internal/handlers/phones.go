@@ -0,0 +1,84 @@
+package handlers
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+
+	"pets_rest/internal/middleware"
+	"pets_rest/internal/phones"
+	"pets_rest/internal/sms"
+
+	"github.com/gofiber/fiber/v3"
+)
+
+type PhoneHandler struct {
+	phones *phones.Service
+}
+
+func NewPhoneHandler(service *phones.Service) *PhoneHandler {
+	return &PhoneHandler{phones: service}
+}
+
+type phoneCodeRequest struct {
+	Phone string `json:"phone"`
+}
+
+type phoneConfirmRequest struct {
+	Code string `json:"code"`
+}
+
+// SendCode texts a one-time code to the given number
+func (h *PhoneHandler) SendCode(c fiber.Ctx) error {
+	var req phoneCodeRequest
+	if err := c.Bind().Body(&req); err != nil {
+		return fiber.NewError(fiber.StatusBadRequest, "Invalid request body")
+	}
+
+	phone, err := h.phones.SendCode(c, middleware.UserID(c), req.Phone)
+	var rateLimit *phones.RateLimitError
+	switch {
+	case errors.Is(err, sms.ErrInvalidPhone):
+		return fiber.NewError(fiber.StatusBadRequest, "Phone must be in international format, e.g. +380671234567")
+	case errors.Is(err, phones.ErrAlreadyVerified):
+		return fiber.NewError(fiber.StatusConflict, "This phone number is already verified")
+	case errors.As(err, &rateLimit):
+		c.Set(fiber.HeaderRetryAfter, strconv.Itoa(int(rateLimit.RetryAfter.Seconds())+1))
+		return c.Status(fiber.StatusTooManyRequests).JSON(fiber.Map{
+			"error": "Too many codes requested, try again later",
+		})
+	case err != nil:
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to send verification code",
+		})
+	}
+
+	return c.Status(fiber.StatusAccepted).JSON(fiber.Map{
+		"phone":      phone,
+		"expires_in": int(phones.CodeTTL.Seconds()),
+	})
+}
+
+// Confirm checks the code and marks the number as verified
+func (h *PhoneHandler) Confirm(c fiber.Ctx) error {
+	var req phoneConfirmRequest
+	if err := c.Bind().Body(&req); err != nil {
+		return fiber.NewError(fiber.StatusBadRequest, "Invalid request body")
+	}
+
+	user, err := h.phones.Confirm(c, middleware.UserID(c), strings.TrimSpace(req.Code))
+	switch {
+	case errors.Is(err, phones.ErrWrongCode):
+		return fiber.NewError(fiber.StatusBadRequest, "Wrong code")
+	case errors.Is(err, phones.ErrNoActiveCode):
+		return fiber.NewError(fiber.StatusGone, "The code has expired or ran out of attempts, request a new one")
+	case err != nil:
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to verify phone",
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"user": user,
+	})
+}
@@ -0,0 +1,217 @@
+package handlers
+
+import (
+	"database/sql"
+	"errors"
+	"strings"
+
+	"pets_rest/internal/config"
+	"pets_rest/internal/database"
+	"pets_rest/internal/realtime"
+	"pets_rest/pkg/helper"
+
+	"github.com/gofiber/fiber/v3"
+)
+
+const placementCodeLength = 8
+
+type PlacementHandler struct {
+	cfg        *config.Config
+	listings   *database.ListingRepository
+	placements *database.PlacementRepository
+	events     *database.EventRepository
+	broker     realtime.Broker
+}
+
+func NewPlacementHandler(db *database.DB, cfg *config.Config, broker realtime.Broker) *PlacementHandler {
+	return &PlacementHandler{
+		cfg:        cfg,
+		listings:   database.NewListingRepository(db),
+		placements: database.NewPlacementRepository(db),
+		events:     database.NewEventRepository(db),
+		broker:     broker,
+	}
+}
+
+type createPlacementRequest struct {
+	Name      string   `json:"name"`
+	Latitude  *float64 `json:"latitude"`
+	Longitude *float64 `json:"longitude"`
+}
+
+func (r *createPlacementRequest) validate() error {
+	r.Name = strings.TrimSpace(r.Name)
+
+	switch {
+	case r.Name == "":
+		return fiber.NewError(fiber.StatusBadRequest, "Name is required")
+	case (r.Latitude == nil) != (r.Longitude == nil):
+		return fiber.NewError(fiber.StatusBadRequest, "Latitude and longitude must be set together")
+	case r.Latitude != nil && (*r.Latitude < -90 || *r.Latitude > 90):
+		return fiber.NewError(fiber.StatusBadRequest, "Latitude must be between -90 and 90")
+	case r.Longitude != nil && (*r.Longitude < -180 || *r.Longitude > 180):
+		return fiber.NewError(fiber.StatusBadRequest, "Longitude must be between -180 and 180")
+	}
+	return nil
+}
+
+// Create adds a named QR placement to a listing the current user may edit
+func (h *PlacementHandler) Create(c fiber.Ctx) error {
+	listing, err := editableListing(c, h.listings)
+	if err != nil {
+		return err
+	}
+
+	var req createPlacementRequest
+	if err := c.Bind().Body(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid request body",
+		})
+	}
+
+	if err := req.validate(); err != nil {
+		return err
+	}
+
+	placement := &database.QRPlacement{
+		ListingID: listing.ID,
+		Name:      req.Name,
+		Latitude:  req.Latitude,
+		Longitude: req.Longitude,
+	}
+	err = withUniqueCode(placementCodeLength, func(code string) error {
+		placement.Code = code
+		return h.placements.Create(c, placement)
+	})
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to create placement",
+		})
+	}
+
+	return c.Status(fiber.StatusCreated).JSON(fiber.Map{
+		"placement": placement,
+		"url":       h.scanURL(placement),
+	})
+}
+
+// List returns all placements of a listing the current user may edit
+func (h *PlacementHandler) List(c fiber.Ctx) error {
+	listing, err := editableListing(c, h.listings)
+	if err != nil {
+		return err
+	}
+
+	placements, err := h.placements.ListByListing(c, listing.ID)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to load placements",
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"placements": placements,
+	})
+}
+
+// Delete removes a placement; past scans stay in the events table
+func (h *PlacementHandler) Delete(c fiber.Ctx) error {
+	listing, err := editableListing(c, h.listings)
+	if err != nil {
+		return err
+	}
+
+	placementID, err := paramID(c, "placementId")
+	if err != nil {
+		return err
+	}
+
+	placement, err := h.placements.GetByID(c, placementID)
+	if err != nil || placement.ListingID != listing.ID {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "Placement not found",
+		})
+	}
+
+	if err := h.placements.Delete(c, placement.ID); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to delete placement",
+		})
+	}
+
+	return c.SendStatus(fiber.StatusNoContent)
+}
+
+// Analytics returns event totals and QR scans per placement for a listing
+func (h *PlacementHandler) Analytics(c fiber.Ctx) error {
+	listing, err := editableListing(c, h.listings)
+	if err != nil {
+		return err
+	}
+
+	totals, err := h.events.GetAnalytics(c, listing.ID)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to load analytics",
+		})
+	}
+
+	placements, err := h.events.GetPlacementAnalytics(c, listing.ID)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to load placement analytics",
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"totals":     totals,
+		"placements": placements,
+	})
+}
+
+// Scan records a QR scan for the placement and redirects to the listing page
+func (h *PlacementHandler) Scan(c fiber.Ctx) error {
+	placement, err := h.placements.GetByCode(c, helper.NormalizeCode(c.Params("code")))
+	if errors.Is(err, sql.ErrNoRows) {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "QR code not found",
+		})
+	}
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to resolve QR code",
+		})
+	}
+
+	listing, err := h.listings.GetByID(c, placement.ListingID)
+	if err != nil || listing.Slug == nil || !listing.Visible() {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "Listing not found",
+		})
+	}
+
+	ip := c.IP()
+	ua := c.Get(fiber.HeaderUserAgent)
+	event := &database.Event{
+		ListingID: listing.ID,
+		Type:      database.EventTypeQRScan,
+		Payload: database.JSONPayload{
+			"placement_id": placement.ID,
+		},
+		IPAddress: &ip,
+		UserAgent: &ua,
+	}
+	if err := h.events.Create(c, event); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to record scan",
+		})
+	}
+
+	publishScan(c, h.broker, listing, event)
+
+	return c.Redirect().To(h.cfg.FrontendURL + "/p/" + *listing.Slug)
+}
+
+func (h *PlacementHandler) scanURL(p *database.QRPlacement) string {
+	return h.cfg.BaseURL + "/q/" + p.Code
+}
@@ -0,0 +1,39 @@
+package handlers
+
+import (
+	"testing"
+
+	"github.com/gofiber/fiber/v3"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCreatePlacementRequestValidate(t *testing.T) {
+	num := func(f float64) *float64 { return &f }
+
+	tests := []struct {
+		name  string
+		req   createPlacementRequest
+		valid bool
+	}{
+		{"лише назва", createPlacementRequest{Name: "Під'їзд"}, true},
+		{"з координатами", createPlacementRequest{Name: "Зупинка", Latitude: num(50.45), Longitude: num(30.52)}, true},
+		{"межі діапазону", createPlacementRequest{Name: "Край", Latitude: num(-90), Longitude: num(180)}, true},
+		{"порожня назва", createPlacementRequest{Name: "   "}, false},
+		{"лише широта", createPlacementRequest{Name: "Зупинка", Latitude: num(50.45)}, false},
+		{"широта за межами", createPlacementRequest{Name: "Зупинка", Latitude: num(90.5), Longitude: num(30.52)}, false},
+		{"довгота за межами", createPlacementRequest{Name: "Зупинка", Latitude: num(50.45), Longitude: num(-181)}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.req.validate()
+			if tt.valid {
+				assert.NoError(t, err)
+				return
+			}
+			var fe *fiber.Error
+			require.ErrorAs(t, err, &fe)
+			assert.Equal(t, fiber.StatusBadRequest, fe.Code)
+		})
+	}
+}
@@ -0,0 +1,59 @@
+package handlers
+
+import (
+	"database/sql"
+	"errors"
+
+	"pets_rest/internal/database"
+
+	"github.com/gofiber/fiber/v3"
+)
+
+type PublicHandler struct {
+	listings *database.ListingRepository
+	events   *database.EventRepository
+}
+
+func NewPublicHandler(db *database.DB) *PublicHandler {
+	return &PublicHandler{
+		listings: database.NewListingRepository(db),
+		events:   database.NewEventRepository(db),
+	}
+}
+
+// Show returns the public view of an active listing and records a page view
+func (h *PublicHandler) Show(c fiber.Ctx) error {
+	listing, err := publicListing(c, h.listings)
+	if err != nil {
+		return err
+	}
+
+	ip := c.IP()
+	ua := c.Get(fiber.HeaderUserAgent)
+	if err := h.events.Create(c, &database.Event{
+		ListingID: listing.ID,
+		Type:      database.EventTypeView,
+		IPAddress: &ip,
+		UserAgent: &ua,
+	}); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to record view",
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"listing": listing.Public(),
+	})
+}
+
+// publicListing loads an active listing from the :slug route parameter
+func publicListing(c fiber.Ctx, listings *database.ListingRepository) (*database.Listing, error) {
+	listing, err := listings.GetBySlug(c, c.Params("slug"))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, fiber.NewError(fiber.StatusNotFound, "Listing not found")
+	}
+	if err != nil {
+		return nil, err
+	}
+	return listing, nil
+}
@@ -0,0 +1,145 @@
+package handlers
+
+import (
+	"database/sql"
+	"errors"
+
+	"pets_rest/internal/config"
+	"pets_rest/internal/database"
+	"pets_rest/internal/realtime"
+	"pets_rest/pkg/helper"
+
+	"github.com/gofiber/fiber/v3"
+)
+
+const shortCodeLength = 6
+
+type ShortLinkHandler struct {
+	cfg        *config.Config
+	listings   *database.ListingRepository
+	shortLinks *database.ShortLinkRepository
+	events     *database.EventRepository
+	broker     realtime.Broker
+}
+
+func NewShortLinkHandler(db *database.DB, cfg *config.Config, broker realtime.Broker) *ShortLinkHandler {
+	return &ShortLinkHandler{
+		cfg:        cfg,
+		listings:   database.NewListingRepository(db),
+		shortLinks: database.NewShortLinkRepository(db),
+		events:     database.NewEventRepository(db),
+		broker:     broker,
+	}
+}
+
+type createShortLinkRequest struct {
+	Source database.ShortLinkSource `json:"source"`
+}
+
+// Create generates a new short code for a listing the current user may edit
+func (h *ShortLinkHandler) Create(c fiber.Ctx) error {
+	listing, err := editableListing(c, h.listings)
+	if err != nil {
+		return err
+	}
+
+	req := createShortLinkRequest{Source: database.ShortLinkSourceLink}
+	if len(c.Body()) > 0 {
+		if err := c.Bind().Body(&req); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": "Invalid request body",
+			})
+		}
+	}
+	if req.Source != database.ShortLinkSourceQR && req.Source != database.ShortLinkSourceLink {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Source must be qr or link",
+		})
+	}
+
+	link := &database.ShortLink{ListingID: listing.ID, Source: req.Source}
+	err = withUniqueCode(shortCodeLength, func(code string) error {
+		link.Code = code
+		return h.shortLinks.Create(c, link)
+	})
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to create short link",
+		})
+	}
+
+	return c.Status(fiber.StatusCreated).JSON(fiber.Map{
+		"short_link": link,
+		"url":        h.cfg.BaseURL + "/s/" + link.Code,
+	})
+}
+
+// List returns all short links of a listing the current user may edit
+func (h *ShortLinkHandler) List(c fiber.Ctx) error {
+	listing, err := editableListing(c, h.listings)
+	if err != nil {
+		return err
+	}
+
+	links, err := h.shortLinks.ListByListing(c, listing.ID)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to load short links",
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"short_links": links,
+	})
+}
+
+// Redirect resolves a short code, records a view or QR scan and redirects
+// to the listing's public page
+func (h *ShortLinkHandler) Redirect(c fiber.Ctx) error {
+	link, err := h.shortLinks.GetByCode(c, helper.NormalizeCode(c.Params("code")))
+	if errors.Is(err, sql.ErrNoRows) {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "Short link not found",
+		})
+	}
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to resolve short link",
+		})
+	}
+
+	listing, err := h.listings.GetByID(c, link.ListingID)
+	if err != nil || listing.Slug == nil || !listing.Visible() {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "Listing not found",
+		})
+	}
+
+	eventType := database.EventTypeView
+	if link.Source == database.ShortLinkSourceQR {
+		eventType = database.EventTypeQRScan
+	}
+
+	ip := c.IP()
+	ua := c.Get(fiber.HeaderUserAgent)
+	event := &database.Event{
+		ListingID: listing.ID,
+		Type:      eventType,
+		Payload: database.JSONPayload{
+			"short_code": link.Code,
+		},
+		IPAddress: &ip,
+		UserAgent: &ua,
+	}
+	if err := h.events.Create(c, event); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to record visit",
+		})
+	}
+
+	if eventType == database.EventTypeQRScan {
+		publishScan(c, h.broker, listing, event)
+	}
+
+	return c.Redirect().To(h.cfg.FrontendURL + "/p/" + *listing.Slug)
+}
@@ -0,0 +1,76 @@
+package handlers
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"time"
+
+	"pets_rest/internal/middleware"
+	"pets_rest/internal/realtime"
+
+	"github.com/gofiber/fiber/v3"
+)
+
+const (
+	heartbeatInterval = 15 * time.Second
+	reconnectDelay    = 3 * time.Second
+)
+
+type StreamHandler struct {
+	broker realtime.Broker
+}
+
+func NewStreamHandler(broker realtime.Broker) *StreamHandler {
+	return &StreamHandler{broker: broker}
+}
+
+// Stream sends the current user's notifications as Server-Sent Events.
+// Clients reconnecting with Last-Event-ID get missed events replayed.
+func (h *StreamHandler) Stream(c fiber.Ctx) error {
+	lastEventID := c.Get("Last-Event-ID", c.Query("last_event_id"))
+
+	// The stream outlives the handler, so it cannot use the request context
+	ctx, cancel := context.WithCancel(context.Background())
+	events, err := h.broker.Subscribe(ctx, middleware.UserID(c), lastEventID)
+	if err != nil {
+		cancel()
+		return c.Status(fiber.StatusServiceUnavailable).JSON(fiber.Map{
+			"error": "Failed to subscribe to notifications",
+		})
+	}
+
+	c.Set(fiber.HeaderContentType, "text/event-stream")
+	c.Set(fiber.HeaderCacheControl, "no-cache")
+	c.Set(fiber.HeaderConnection, "keep-alive")
+	c.Set("X-Accel-Buffering", "no")
+
+	return c.SendStreamWriter(func(w *bufio.Writer) {
+		defer cancel()
+
+		heartbeat := time.NewTicker(heartbeatInterval)
+		defer heartbeat.Stop()
+
+		_, _ = fmt.Fprintf(w, "retry: %d\n\n", reconnectDelay.Milliseconds())
+		if w.Flush() != nil {
+			return
+		}
+
+		for {
+			select {
+			case e, ok := <-events:
+				if !ok {
+					return
+				}
+				_, _ = fmt.Fprintf(w, "id: %s\nevent: %s\ndata: %s\n\n", e.ID, e.Type, e.Data)
+			case <-heartbeat.C:
+				_, _ = fmt.Fprint(w, ": ping\n\n")
+			}
+
+			// A failed flush means the client has gone away
+			if w.Flush() != nil {
+				return
+			}
+		}
+	})
+}
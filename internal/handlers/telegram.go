@@ -0,0 +1,59 @@
+package handlers
+
+import (
+	"crypto/subtle"
+	"log"
+
+	"pets_rest/internal/config"
+	"pets_rest/internal/middleware"
+	"pets_rest/internal/telegram"
+
+	"github.com/gofiber/fiber/v3"
+)
+
+type TelegramHandler struct {
+	cfg *config.Config
+	bot *telegram.Bot
+}
+
+func NewTelegramHandler(cfg *config.Config, bot *telegram.Bot) *TelegramHandler {
+	return &TelegramHandler{cfg: cfg, bot: bot}
+}
+
+// Webhook receives updates pushed by Telegram
+func (h *TelegramHandler) Webhook(c fiber.Ctx) error {
+	if !h.bot.Enabled() {
+		return c.SendStatus(fiber.StatusNotFound)
+	}
+
+	// An empty secret would match requests that do not send one
+	secret := c.Get("X-Telegram-Bot-Api-Secret-Token")
+	if h.cfg.TelegramWebhookSecret == "" || subtle.ConstantTimeCompare([]byte(secret), []byte(h.cfg.TelegramWebhookSecret)) != 1 {
+		return c.SendStatus(fiber.StatusUnauthorized)
+	}
+
+	var update telegram.Update
+	if err := c.Bind().Body(&update); err != nil {
+		return c.SendStatus(fiber.StatusBadRequest)
+	}
+
+	// Telegram retries failed deliveries, so errors are only logged
+	if err := h.bot.HandleUpdate(c, update); err != nil {
+		log.Printf("Failed to handle Telegram update %d: %v", update.UpdateID, err)
+	}
+
+	return c.SendStatus(fiber.StatusOK)
+}
+
+// Link returns a deep link that connects the current user's Telegram chat
+func (h *TelegramHandler) Link(c fiber.Ctx) error {
+	if !h.bot.Enabled() {
+		return c.Status(fiber.StatusServiceUnavailable).JSON(fiber.Map{
+			"error": "Telegram bot is not configured",
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"url": h.bot.LinkURL(middleware.UserID(c)),
+	})
+}
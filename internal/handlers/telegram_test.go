@@ -0,0 +1,51 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gofiber/fiber/v3"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"pets_rest/internal/config"
+	"pets_rest/internal/telegram"
+)
+
+func TestTelegramWebhookChecksSecret(t *testing.T) {
+	bot := telegram.NewBot(telegram.NewClient("http://127.0.0.1:0", "token"), nil, nil, nil, telegram.Options{})
+
+	tests := []struct {
+		name       string
+		configured string
+		sent       string
+		status     int
+	}{
+		{"правильний секрет", "s3cret", "s3cret", fiber.StatusOK},
+		{"хибний секрет", "s3cret", "guess", fiber.StatusUnauthorized},
+		{"без заголовка", "s3cret", "", fiber.StatusUnauthorized},
+		// Порожній секрет у конфігурації не пропускає запити без заголовка
+		{"секрет не налаштовано", "", "", fiber.StatusUnauthorized},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			handler := NewTelegramHandler(&config.Config{TelegramWebhookSecret: tt.configured}, bot)
+			app := fiber.New()
+			app.Post("/telegram/webhook", handler.Webhook)
+
+			req := httptest.NewRequest(http.MethodPost, "/telegram/webhook", strings.NewReader(`{"update_id": 1}`))
+			req.Header.Set(fiber.HeaderContentType, fiber.MIMEApplicationJSON)
+			if tt.sent != "" {
+				req.Header.Set("X-Telegram-Bot-Api-Secret-Token", tt.sent)
+			}
+
+			resp, err := app.Test(req)
+			require.NoError(t, err)
+			defer func() { _ = resp.Body.Close() }()
+
+			assert.Equal(t, tt.status, resp.StatusCode)
+		})
+	}
+}
@@ -0,0 +1,213 @@
+package handlers
+
+import (
+	"strings"
+
+	"pets_rest/internal/config"
+	"pets_rest/internal/database"
+	"pets_rest/internal/middleware"
+	"pets_rest/internal/policy"
+	"pets_rest/internal/webhooks"
+
+	"github.com/gofiber/fiber/v3"
+)
+
+type WebhookHandler struct {
+	cfg      *config.Config
+	webhooks *database.WebhookRepository
+}
+
+func NewWebhookHandler(db *database.DB, cfg *config.Config) *WebhookHandler {
+	return &WebhookHandler{
+		cfg:      cfg,
+		webhooks: database.NewWebhookRepository(db),
+	}
+}
+
+type webhookRequest struct {
+	URL        string   `json:"url"`
+	EventTypes []string `json:"event_types"`
+	Active     *bool    `json:"active"`
+}
+
+func (r *webhookRequest) validate(allowInsecure bool) error {
+	r.URL = strings.TrimSpace(r.URL)
+	if err := webhooks.ValidateURL(r.URL, allowInsecure); err != nil {
+		return fiber.NewError(fiber.StatusBadRequest, "Invalid webhook URL: "+err.Error())
+	}
+	if len(r.EventTypes) == 0 {
+		return fiber.NewError(fiber.StatusBadRequest, "At least one event type is required")
+	}
+	for _, t := range r.EventTypes {
+		if !webhooks.ValidEventType(t) {
+			return fiber.NewError(fiber.StatusBadRequest, "Event types must be one of "+strings.Join(webhooks.EventTypes, ", "))
+		}
+	}
+	return nil
+}
+
+// Create registers a webhook endpoint; the signing secret is only returned here
+func (h *WebhookHandler) Create(c fiber.Ctx) error {
+	var req webhookRequest
+	if err := c.Bind().Body(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid request body",
+		})
+	}
+	if err := req.validate(h.cfg.Env == "development"); err != nil {
+		return err
+	}
+
+	endpoint := &database.WebhookEndpoint{
+		UserID:     middleware.UserID(c),
+		URL:        req.URL,
+		Secret:     newToken(),
+		EventTypes: req.EventTypes,
+	}
+	if err := h.webhooks.CreateEndpoint(c, endpoint); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to create webhook",
+		})
+	}
+
+	return c.Status(fiber.StatusCreated).JSON(fiber.Map{
+		"webhook": endpoint,
+		"secret":  endpoint.Secret,
+	})
+}
+
+// List returns the webhook endpoints of the current user
+func (h *WebhookHandler) List(c fiber.Ctx) error {
+	endpoints, err := h.webhooks.ListEndpointsByUser(c, middleware.UserID(c))
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to load webhooks",
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"webhooks": endpoints,
+	})
+}
+
+// Update changes the URL or event types of an endpoint; setting active to
+// true re-enables an endpoint that was disabled after repeated failures
+func (h *WebhookHandler) Update(c fiber.Ctx) error {
+	endpoint, err := h.managedEndpoint(c)
+	if err != nil {
+		return err
+	}
+
+	var req webhookRequest
+	if err := c.Bind().Body(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid request body",
+		})
+	}
+	if err := req.validate(h.cfg.Env == "development"); err != nil {
+		return err
+	}
+
+	endpoint.URL = req.URL
+	endpoint.EventTypes = req.EventTypes
+	if req.Active != nil {
+		endpoint.Active = *req.Active
+	}
+	if err := h.webhooks.UpdateEndpoint(c, endpoint); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to update webhook",
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"webhook": endpoint,
+	})
+}
+
+// Delete removes a webhook endpoint and its delivery log
+func (h *WebhookHandler) Delete(c fiber.Ctx) error {
+	endpoint, err := h.managedEndpoint(c)
+	if err != nil {
+		return err
+	}
+
+	if err := h.webhooks.DeleteEndpoint(c, endpoint.ID); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to delete webhook",
+		})
+	}
+
+	return c.SendStatus(fiber.StatusNoContent)
+}
+
+// Deliveries returns the delivery log of an endpoint, newest first
+func (h *WebhookHandler) Deliveries(c fiber.Ctx) error {
+	endpoint, err := h.managedEndpoint(c)
+	if err != nil {
+		return err
+	}
+
+	limit, offset := pagination(c)
+	deliveries, err := h.webhooks.ListDeliveries(c, endpoint.ID, limit, offset)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to load deliveries",
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"deliveries": deliveries,
+	})
+}
+
+// Replay queues a logged delivery to be sent again
+func (h *WebhookHandler) Replay(c fiber.Ctx) error {
+	endpoint, err := h.managedEndpoint(c)
+	if err != nil {
+		return err
+	}
+	if !endpoint.Active {
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{
+			"error": "Webhook is disabled; re-enable it before replaying deliveries",
+		})
+	}
+
+	deliveryID, err := paramID(c, "deliveryId")
+	if err != nil {
+		return err
+	}
+
+	delivery, err := h.webhooks.GetDelivery(c, deliveryID)
+	if err != nil || delivery.EndpointID != endpoint.ID {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "Delivery not found",
+		})
+	}
+
+	replay, err := h.webhooks.Replay(c, delivery.ID)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to replay delivery",
+		})
+	}
+
+	return c.Status(fiber.StatusAccepted).JSON(fiber.Map{
+		"delivery": replay,
+	})
+}
+
+// managedEndpoint loads the endpoint from the :id parameter and checks that
+// the current user may manage it
+func (h *WebhookHandler) managedEndpoint(c fiber.Ctx) (*database.WebhookEndpoint, error) {
+	id, err := paramID(c, "id")
+	if err != nil {
+		return nil, err
+	}
+
+	endpoint, err := h.webhooks.GetEndpoint(c, id)
+	if err != nil || !policy.CanManageWebhook(middleware.Actor(c), endpoint) {
+		return nil, fiber.NewError(fiber.StatusNotFound, "Webhook not found")
+	}
+
+	return endpoint, nil
+}
@@ -0,0 +1,107 @@
+package jobs
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"pets_rest/internal/database"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func mustParse(t *testing.T, spec string) Schedule {
+	t.Helper()
+	s, err := ParseSchedule(spec)
+	require.NoError(t, err)
+	return s
+}
+
+func TestCronNext(t *testing.T) {
+	// Понеділок, 19 жовтня 2026, 10:17:30 UTC
+	now := time.Date(2026, 10, 19, 10, 17, 30, 0, time.UTC)
+
+	cases := []struct {
+		spec string
+		want time.Time
+	}{
+		{"* * * * *", time.Date(2026, 10, 19, 10, 18, 0, 0, time.UTC)},
+		{"*/15 * * * *", time.Date(2026, 10, 19, 10, 30, 0, 0, time.UTC)},
+		{"0 3 * * *", time.Date(2026, 10, 20, 3, 0, 0, 0, time.UTC)},
+		{"30 9-17/4 * * 1-5", time.Date(2026, 10, 19, 13, 30, 0, 0, time.UTC)},
+		{"0 0 1 * *", time.Date(2026, 11, 1, 0, 0, 0, 0, time.UTC)},
+		{"0 12 * * 0", time.Date(2026, 10, 25, 12, 0, 0, 0, time.UTC)},
+		{"0 12 * * 7", time.Date(2026, 10, 25, 12, 0, 0, 0, time.UTC)},
+		// Обидва поля дня обмежені: спрацьовує будь-яке з них
+		{"0 0 1 * 3", time.Date(2026, 10, 21, 0, 0, 0, 0, time.UTC)},
+		{"0 0 29 2 *", time.Date(2028, 2, 29, 0, 0, 0, 0, time.UTC)},
+		{"@daily", time.Date(2026, 10, 20, 0, 0, 0, 0, time.UTC)},
+		{"@every 90s", now.Add(90 * time.Second)},
+	}
+	for _, c := range cases {
+		assert.Equal(t, c.want, mustParse(t, c.spec).Next(now), c.spec)
+	}
+}
+
+func TestCronNeverMatches(t *testing.T) {
+	assert.True(t, mustParse(t, "0 0 31 2 *").Next(time.Now()).IsZero())
+}
+
+func TestParseScheduleErrors(t *testing.T) {
+	for _, spec := range []string{"", "* * * *", "60 * * * *", "* 24 * * *", "5-1 * * * *", "*/0 * * * *", "a * * * *", "@every 0s", "@every soon"} {
+		_, err := ParseSchedule(spec)
+		assert.Error(t, err, spec)
+	}
+}
+
+func TestBackoff(t *testing.T) {
+	assert.Equal(t, 10*time.Second, Backoff(1))
+	assert.Equal(t, 20*time.Second, Backoff(2))
+	assert.Equal(t, 80*time.Second, Backoff(4))
+	assert.Equal(t, time.Hour, Backoff(20))
+	assert.Equal(t, time.Hour, Backoff(200))
+}
+
+func TestRegisterDecodesPayload(t *testing.T) {
+	type resize struct {
+		ImageID int `json:"image_id"`
+	}
+
+	q := NewQueue(&database.DB{}, time.Second)
+	var got resize
+	Register(q, "images.resize", func(_ context.Context, p resize) error {
+		got = p
+		return nil
+	})
+
+	handler := q.handlers["images.resize"]
+	require.NoError(t, handler(context.Background(), &database.Job{Payload: []byte(`{"image_id":7}`)}))
+	assert.Equal(t, 7, got.ImageID)
+
+	// Зіпсований payload не варто повторювати
+	err := handler(context.Background(), &database.Job{Payload: []byte(`{`)})
+	var permanent *permanentError
+	assert.True(t, errors.As(err, &permanent))
+}
+
+func TestRunRecoversPanics(t *testing.T) {
+	err := run(context.Background(), func(context.Context, *database.Job) error {
+		panic("boom")
+	}, &database.Job{})
+	assert.EqualError(t, err, "panic: boom")
+}
+
+func TestRunOutlivesWorkerShutdown(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	// Зупинка воркера не перериває задачу, але дедлайн лишається
+	err := run(ctx, func(ctx context.Context, _ *database.Job) error {
+		_, ok := ctx.Deadline()
+		assert.True(t, ok)
+		return ctx.Err()
+	}, &database.Job{})
+	assert.NoError(t, err)
+}
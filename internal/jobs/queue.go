@@ -0,0 +1,319 @@
+// Package jobs runs durable background jobs stored in PostgreSQL. Workers
+// claim jobs with SELECT ... FOR UPDATE SKIP LOCKED, so any number of them
+// can run side by side in the API and in cmd/worker.
+package jobs
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"slices"
+	"sync"
+	"time"
+
+	"pets_rest/internal/database"
+
+	"github.com/jmoiron/sqlx"
+)
+
+const (
+	defaultMaxAttempts = 10
+	// lockDuration bounds a single run; a job still locked after it is
+	// considered abandoned and claimed again
+	lockDuration = 5 * time.Minute
+
+	baseBackoff = 10 * time.Second
+	maxBackoff  = time.Hour
+)
+
+// Handler processes one job; returning an error retries it with backoff
+type Handler func(ctx context.Context, job *database.Job) error
+
+// Register adds a handler whose payload is decoded into T
+func Register[T any](q *Queue, jobType string, fn func(ctx context.Context, payload T) error) {
+	q.Handle(jobType, func(ctx context.Context, job *database.Job) error {
+		var payload T
+		if len(job.Payload) > 0 {
+			if err := json.Unmarshal(job.Payload, &payload); err != nil {
+				return Permanent(fmt.Errorf("invalid payload: %w", err))
+			}
+		}
+		return fn(ctx, payload)
+	})
+}
+
+type permanentError struct {
+	err error
+}
+
+func (e *permanentError) Error() string { return e.err.Error() }
+func (e *permanentError) Unwrap() error { return e.err }
+
+// Permanent marks an error that retrying will not fix; the job goes straight
+// to the dead-letter state
+func Permanent(err error) error {
+	return &permanentError{err: err}
+}
+
+// Option customizes a queued job
+type Option func(*database.Job)
+
+// RunAt delays a job until the given time
+func RunAt(t time.Time) Option {
+	return func(j *database.Job) { j.RunAt = t }
+}
+
+// MaxAttempts sets how many times a job is tried before it is buried
+func MaxAttempts(n int) Option {
+	return func(j *database.Job) { j.MaxAttempts = n }
+}
+
+// UniqueKey skips the job while another unfinished job has the same key
+func UniqueKey(key string) Option {
+	return func(j *database.Job) { j.UniqueKey = &key }
+}
+
+type schedule struct {
+	name     string
+	spec     string
+	schedule Schedule
+	jobType  string
+	payload  any
+}
+
+// Queue enqueues jobs and runs workers for the registered job types
+type Queue struct {
+	db           *database.DB
+	jobs         *database.JobRepository
+	pollInterval time.Duration
+
+	mu        sync.RWMutex
+	handlers  map[string]Handler
+	schedules []schedule
+}
+
+// NewQueue creates a queue; idle workers poll for new jobs every pollInterval
+func NewQueue(db *database.DB, pollInterval time.Duration) *Queue {
+	return &Queue{
+		db:           db,
+		jobs:         database.NewJobRepository(db),
+		pollInterval: pollInterval,
+		handlers:     make(map[string]Handler),
+	}
+}
+
+// Handle registers the handler of a job type
+func (q *Queue) Handle(jobType string, h Handler) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.handlers[jobType] = h
+}
+
+// Schedule runs a job of the given type on a cron spec (see ParseSchedule);
+// only one worker enqueues each run and a run is skipped while the
+// previous one is unfinished
+func (q *Queue) Schedule(name, spec, jobType string, payload any) error {
+	s, err := ParseSchedule(spec)
+	if err != nil {
+		return err
+	}
+
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.schedules = append(q.schedules, schedule{name: name, spec: spec, schedule: s, jobType: jobType, payload: payload})
+	return nil
+}
+
+// Enqueue queues a job and reports whether it was added; it is skipped when
+// its unique key is taken
+func (q *Queue) Enqueue(ctx context.Context, jobType string, payload any, opts ...Option) (bool, error) {
+	return enqueue(ctx, q.jobs, jobType, payload, opts)
+}
+
+// EnqueueTx queues a job in tx so that it only runs once tx is committed
+func (q *Queue) EnqueueTx(ctx context.Context, tx *sqlx.Tx, jobType string, payload any, opts ...Option) (bool, error) {
+	return enqueue(ctx, q.jobs.WithTx(tx), jobType, payload, opts)
+}
+
+func enqueue(ctx context.Context, repo *database.JobRepository, jobType string, payload any, opts []Option) (bool, error) {
+	data := []byte("{}")
+	if payload != nil {
+		var err error
+		if data, err = json.Marshal(payload); err != nil {
+			return false, err
+		}
+	}
+
+	job := &database.Job{
+		Type:        jobType,
+		Payload:     data,
+		MaxAttempts: defaultMaxAttempts,
+		RunAt:       time.Now(),
+	}
+	for _, opt := range opts {
+		opt(job)
+	}
+
+	return repo.Create(ctx, job)
+}
+
+// PurgeFinished deletes jobs that finished successfully more than olderThan ago
+func (q *Queue) PurgeFinished(ctx context.Context, olderThan time.Duration) (int64, error) {
+	return q.jobs.PurgeFinished(ctx, time.Now().Add(-olderThan))
+}
+
+// Work runs the scheduler and the given number of workers until ctx is
+// cancelled, then waits for running jobs to finish
+func (q *Queue) Work(ctx context.Context, concurrency int) {
+	q.mu.RLock()
+	types := make([]string, 0, len(q.handlers))
+	for t := range q.handlers {
+		types = append(types, t)
+	}
+	slices.Sort(types)
+	q.mu.RUnlock()
+
+	log.Printf("Job worker started with %d goroutines for %v", concurrency, types)
+
+	var wg sync.WaitGroup
+	wg.Go(func() { q.runScheduler(ctx) })
+	for range max(concurrency, 1) {
+		wg.Go(func() { q.work(ctx, types) })
+	}
+	wg.Wait()
+}
+
+func (q *Queue) work(ctx context.Context, types []string) {
+	for {
+		found, err := q.runNext(ctx, types)
+		if err != nil {
+			log.Printf("Failed to run job: %v", err)
+		}
+		if found && err == nil {
+			continue
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(q.pollInterval):
+		}
+	}
+}
+
+// runNext claims and runs one due job and reports whether there was one
+func (q *Queue) runNext(ctx context.Context, types []string) (bool, error) {
+	if ctx.Err() != nil {
+		return false, nil
+	}
+
+	job, err := q.jobs.Claim(ctx, types, time.Now().Add(lockDuration))
+	if errors.Is(err, sql.ErrNoRows) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+
+	q.mu.RLock()
+	handler := q.handlers[job.Type]
+	q.mu.RUnlock()
+
+	runErr := run(ctx, handler, job)
+	return true, q.finish(ctx, job, runErr)
+}
+
+// finish records the outcome of a run. A job that outlived its lock may
+// have been claimed again by another worker, whose outcome then counts.
+func (q *Queue) finish(ctx context.Context, job *database.Job, runErr error) error {
+	lockedUntil := *job.LockedUntil
+
+	var err error
+	var permanent *permanentError
+	switch {
+	case runErr == nil:
+		err = q.jobs.Complete(ctx, job.ID, lockedUntil)
+	case errors.As(runErr, &permanent) || job.Attempts >= job.MaxAttempts:
+		log.Printf("Job %d (%s) moved to dead letters after %d attempts: %v", job.ID, job.Type, job.Attempts, runErr)
+		err = q.jobs.Bury(ctx, job.ID, lockedUntil, runErr.Error())
+	default:
+		err = q.jobs.Retry(ctx, job.ID, lockedUntil, runErr.Error(), time.Now().Add(Backoff(job.Attempts)))
+	}
+
+	if errors.Is(err, sql.ErrNoRows) {
+		log.Printf("Job %d (%s) lost its lock before it finished; its result is dropped", job.ID, job.Type)
+		return nil
+	}
+	return err
+}
+
+// run calls the handler with a deadline and turns panics into errors.
+// Stopping the worker does not cancel a running job, so it can finish
+// within the deadline instead of being retried from scratch.
+func run(ctx context.Context, handler Handler, job *database.Job) (err error) {
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), lockDuration)
+	defer cancel()
+
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("panic: %v", r)
+		}
+	}()
+
+	return handler(ctx, job)
+}
+
+func (q *Queue) runScheduler(ctx context.Context) {
+	q.mu.RLock()
+	schedules := slices.Clone(q.schedules)
+	q.mu.RUnlock()
+	if len(schedules) == 0 {
+		return
+	}
+
+	now := time.Now()
+	for _, s := range schedules {
+		if err := q.jobs.UpsertSchedule(ctx, s.name, s.spec, s.schedule.Next(now)); err != nil {
+			log.Printf("Failed to register schedule %s: %v", s.name, err)
+		}
+	}
+
+	ticker := time.NewTicker(q.pollInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			for _, s := range schedules {
+				if err := q.fire(ctx, s); err != nil {
+					log.Printf("Failed to run schedule %s: %v", s.name, err)
+				}
+			}
+		}
+	}
+}
+
+// fire enqueues a scheduled job if this worker wins the due run
+func (q *Queue) fire(ctx context.Context, s schedule) error {
+	return q.db.WithTx(ctx, func(tx *sqlx.Tx) error {
+		won, err := q.jobs.WithTx(tx).ClaimSchedule(ctx, s.name, s.schedule.Next(time.Now()))
+		if err != nil || !won {
+			return err
+		}
+
+		_, err = q.EnqueueTx(ctx, tx, s.jobType, s.payload, UniqueKey("schedule:"+s.name))
+		return err
+	})
+}
+
+// Backoff returns the delay before retrying a job after the given number of
+// attempts: 10s, 20s, 40s, ... capped at 1h
+func Backoff(attempts int) time.Duration {
+	delay := baseBackoff << min(max(attempts-1, 0), 20)
+	return min(delay, maxBackoff)
+}
@@ -0,0 +1,326 @@
+// Package listings contains listing business logic shared by the HTTP API
+// and other entry points such as the Telegram bot
+package listings
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"strings"
+	"sync"
+	"time"
+
+	"pets_rest/internal/audit"
+	"pets_rest/internal/database"
+	"pets_rest/internal/jobs"
+	"pets_rest/internal/sms"
+	"pets_rest/internal/spam"
+	"pets_rest/pkg/helper"
+
+	"github.com/jmoiron/sqlx"
+)
+
+const (
+	slugTitleLength  = 60
+	slugSuffixLength = 6
+	slugAttempts     = 5
+)
+
+// ErrVersionConflict is returned when a listing changed since the version
+// an edit was based on
+var ErrVersionConflict = errors.New("listing was changed by someone else")
+
+// Event is a listing lifecycle change that hooks can subscribe to
+type Event string
+
+const (
+	// EventActivated fires when a listing becomes active
+	EventActivated Event = "activated"
+	// EventResolved fires when an active listing is archived
+	EventResolved Event = "resolved"
+)
+
+// JobEvent is the job type that runs the hooks of a lifecycle event; its
+// payload is EventJob
+const JobEvent = "listings.event"
+
+// EventJob is the payload of a JobEvent job
+type EventJob struct {
+	Event     Event `json:"event"`
+	ListingID int   `json:"listing_id"`
+}
+
+// Hook is called after a listing lifecycle event
+type Hook func(ctx context.Context, listing *database.Listing)
+
+// Service creates and updates listings, screens them for spam, records the
+// changes in the audit log and notifies hooks about lifecycle events
+type Service struct {
+	db         *database.DB
+	listings   *database.ListingRepository
+	moderation *database.ModerationRepository
+	revisions  *database.ListingRevisionRepository
+	audit      *audit.Log
+	spam       *spam.Engine
+	jobs       *jobs.Queue
+
+	mu    sync.RWMutex
+	hooks map[Event][]Hook
+}
+
+// NewService creates a new listing service; hooks run from jobs enqueued
+// on queue
+func NewService(db *database.DB, engine *spam.Engine, queue *jobs.Queue) *Service {
+	return &Service{
+		db:         db,
+		listings:   database.NewListingRepository(db),
+		moderation: database.NewModerationRepository(db),
+		revisions:  database.NewListingRevisionRepository(db),
+		audit:      audit.NewLog(db),
+		spam:       engine,
+		jobs:       queue,
+		hooks:      make(map[Event][]Hook),
+	}
+}
+
+// On registers a hook for a lifecycle event
+func (s *Service) On(event Event, hook Hook) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.hooks[event] = append(s.hooks[event], hook)
+}
+
+// Create stores a new listing with a unique slug derived from its title
+func (s *Service) Create(ctx context.Context, listing *database.Listing) error {
+	normalizeContactPhone(listing)
+	result, err := s.screen(ctx, listing)
+	if err != nil {
+		return err
+	}
+
+	base := helper.Slugify(listing.Title, slugTitleLength)
+
+	// A slug collision aborts the transaction, so every attempt gets its own
+	for range slugAttempts {
+		slug := strings.TrimPrefix(base+"-"+helper.RandomCode(slugSuffixLength), "-")
+		listing.Slug = &slug
+		err = s.db.WithTx(ctx, func(tx *sqlx.Tx) error {
+			if err := s.listings.WithTx(tx).Create(ctx, listing); err != nil {
+				return err
+			}
+			if err := s.audit.WithTx(tx).Record(ctx, database.AuditEntityListing, listing.ID, nil, listing); err != nil {
+				return err
+			}
+			if _, err := s.revisions.WithTx(tx).Record(ctx, listing.ID, audit.FromContext(ctx).UserID); err != nil {
+				return err
+			}
+			if err := s.recordHold(ctx, tx, listing, result); err != nil {
+				return err
+			}
+			if !listing.Visible() {
+				return nil
+			}
+			return s.enqueue(ctx, tx, EventActivated, listing)
+		})
+		if !database.IsUniqueViolation(err) {
+			break
+		}
+	}
+	return err
+}
+
+// Update stores listing changes; previous is the status before the edit.
+// listing.Version is the version the changes were made to; ErrVersionConflict
+// is returned when the listing has changed since.
+func (s *Service) Update(ctx context.Context, listing *database.Listing, previous database.ListingStatus) error {
+	normalizeContactPhone(listing)
+	result, err := s.screen(ctx, listing)
+	if err != nil {
+		return err
+	}
+
+	return s.db.WithTx(ctx, func(tx *sqlx.Tx) error {
+		listings := s.listings.WithTx(tx)
+
+		before, err := listings.GetByIDForUpdate(ctx, listing.ID)
+		if err != nil {
+			return err
+		}
+		if before.Version != listing.Version {
+			return ErrVersionConflict
+		}
+		if err := listings.Update(ctx, listing); err != nil {
+			return err
+		}
+		if err := s.audit.WithTx(tx).Record(ctx, database.AuditEntityListing, listing.ID, before, listing); err != nil {
+			return err
+		}
+		if _, err := s.revisions.WithTx(tx).Record(ctx, listing.ID, audit.FromContext(ctx).UserID); err != nil {
+			return err
+		}
+		if err := s.recordHold(ctx, tx, listing, result); err != nil {
+			return err
+		}
+		return s.transitioned(ctx, tx, listing, previous)
+	})
+}
+
+// SetContactsHidden hides or shows the contacts of a listing on its public
+// page. listing.Version is the version the change was made to;
+// ErrVersionConflict is returned when the listing has changed since.
+func (s *Service) SetContactsHidden(ctx context.Context, listing *database.Listing, hidden bool) error {
+	return s.db.WithTx(ctx, func(tx *sqlx.Tx) error {
+		listings := s.listings.WithTx(tx)
+
+		before, err := listings.GetByIDForUpdate(ctx, listing.ID)
+		if err != nil {
+			return err
+		}
+		if before.Version != listing.Version {
+			return ErrVersionConflict
+		}
+
+		listing.ContactsHidden = hidden
+		if err := listings.SetContactsHidden(ctx, listing); err != nil {
+			return err
+		}
+		if err := s.audit.WithTx(tx).Record(ctx, database.AuditEntityListing, listing.ID, before, listing); err != nil {
+			return err
+		}
+		_, err = s.revisions.WithTx(tx).Record(ctx, listing.ID, audit.FromContext(ctx).UserID)
+		return err
+	})
+}
+
+// Archive takes a listing down regardless of its owner, as moderators do,
+// and announces it as resolved when it was active
+func (s *Service) Archive(ctx context.Context, id int) (*database.Listing, error) {
+	var listing *database.Listing
+	err := s.db.WithTx(ctx, func(tx *sqlx.Tx) error {
+		listings := s.listings.WithTx(tx)
+
+		before, err := listings.GetByIDForUpdate(ctx, id)
+		if err != nil {
+			return err
+		}
+		if _, err := listings.Archive(ctx, id); err != nil {
+			return err
+		}
+		if _, err := s.revisions.WithTx(tx).Record(ctx, id, audit.FromContext(ctx).UserID); err != nil {
+			return err
+		}
+		if listing, err = listings.GetByID(ctx, id); err != nil {
+			return err
+		}
+		if err := s.audit.WithTx(tx).Record(ctx, database.AuditEntityListing, id, before, listing); err != nil {
+			return err
+		}
+		return s.transitioned(ctx, tx, listing, before.Status)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return listing, nil
+}
+
+// ReleasedTx announces, within tx, a listing a moderator let through after
+// spam screening held it
+func (s *Service) ReleasedTx(ctx context.Context, tx *sqlx.Tx, listing *database.Listing) error {
+	if !listing.Visible() {
+		return nil
+	}
+	return s.enqueue(ctx, tx, EventActivated, listing)
+}
+
+// RunHooks calls the hooks of a queued event with the listing as it is now.
+// An activation is dropped when the listing was hidden or deleted since.
+func (s *Service) RunHooks(ctx context.Context, job EventJob) error {
+	listing, err := s.listings.GetByID(ctx, job.ListingID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if job.Event == EventActivated && !listing.Visible() {
+		return nil
+	}
+
+	s.mu.RLock()
+	hooks := s.hooks[job.Event]
+	s.mu.RUnlock()
+
+	for _, hook := range hooks {
+		// Every hook gets its own copy to change as it likes
+		snapshot := *listing
+		hook(ctx, &snapshot)
+	}
+	return nil
+}
+
+// screen scores a listing that is about to be published and keeps it in
+// draft, held for review, when the score is too high
+func (s *Service) screen(ctx context.Context, listing *database.Listing) (spam.Result, error) {
+	listing.HeldForReview = false
+	if listing.Status != database.ListingStatusActive {
+		return spam.Result{}, nil
+	}
+
+	signals, err := s.listings.Signals(ctx, listing, time.Now().Add(-s.spam.BurstWindow()))
+	if err != nil {
+		return spam.Result{}, err
+	}
+
+	result := s.spam.Score(&spam.Input{Listing: listing, ListingSignals: *signals})
+	if s.spam.NeedsReview(result) {
+		listing.HeldForReview = true
+		listing.Status = database.ListingStatusDraft
+	}
+	return result, nil
+}
+
+// recordHold explains a hold in the moderation audit trail
+func (s *Service) recordHold(ctx context.Context, tx *sqlx.Tx, listing *database.Listing, result spam.Result) error {
+	if !listing.HeldForReview {
+		return nil
+	}
+
+	note := result.String()
+	return s.moderation.WithTx(tx).CreateAction(ctx, &database.ModerationAction{
+		ListingID:    &listing.ID,
+		TargetUserID: &listing.UserID,
+		Action:       database.ModerationActionHold,
+		Note:         &note,
+	})
+}
+
+// normalizeContactPhone stores the contact phone in E.164 when it can be
+// parsed, so it can be compared with the owner's verified number; other
+// values are kept as typed
+func normalizeContactPhone(listing *database.Listing) {
+	if listing.ContactPhone == nil {
+		return
+	}
+	if phone, err := sms.NormalizePhone(*listing.ContactPhone); err == nil {
+		listing.ContactPhone = &phone
+	}
+}
+
+// transitioned enqueues the events of a status change within tx; listings
+// hidden by moderators are not announced
+func (s *Service) transitioned(ctx context.Context, tx *sqlx.Tx, listing *database.Listing, previous database.ListingStatus) error {
+	switch {
+	case previous != database.ListingStatusActive && listing.Visible():
+		return s.enqueue(ctx, tx, EventActivated, listing)
+	case previous == database.ListingStatusActive && listing.Status == database.ListingStatusArchived:
+		return s.enqueue(ctx, tx, EventResolved, listing)
+	}
+	return nil
+}
+
+// enqueue schedules the hooks of an event in tx, so they run only once the
+// change is committed and are not lost when the process stops
+func (s *Service) enqueue(ctx context.Context, tx *sqlx.Tx, event Event, listing *database.Listing) error {
+	_, err := s.jobs.EnqueueTx(ctx, tx, JobEvent, EventJob{Event: event, ListingID: listing.ID})
+	return err
+}
@@ -0,0 +1,91 @@
+// Package mailer sends transactional emails through SMTP
+package mailer
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"mime"
+	"net/smtp"
+	"strconv"
+	"strings"
+	"time"
+
+	"pets_rest/internal/config"
+)
+
+// Message is a plain-text email
+type Message struct {
+	To      string
+	ReplyTo string
+	Subject string
+	Body    string
+	Headers map[string]string
+}
+
+// Mailer delivers email messages
+type Mailer interface {
+	Send(ctx context.Context, msg Message) error
+}
+
+// New returns an SMTP mailer, or a log-only mailer when SMTP credentials are not configured
+func New(cfg *config.Config) Mailer {
+	if cfg.SMTPUsername == "" {
+		return &LogMailer{}
+	}
+
+	return &SMTPMailer{
+		addr: cfg.SMTPHost + ":" + strconv.Itoa(cfg.SMTPPort),
+		auth: smtp.PlainAuth("", cfg.SMTPUsername, cfg.SMTPPassword, cfg.SMTPHost),
+		from: cfg.FromEmail,
+	}
+}
+
+// SMTPMailer sends messages through an SMTP server
+type SMTPMailer struct {
+	addr string
+	auth smtp.Auth
+	from string
+}
+
+// Send delivers the message; the context is only checked before dialing
+// because net/smtp does not support cancellation
+func (m *SMTPMailer) Send(ctx context.Context, msg Message) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	if err := smtp.SendMail(m.addr, m.auth, m.from, []string{msg.To}, m.build(msg)); err != nil {
+		return fmt.Errorf("failed to send email: %w", err)
+	}
+
+	return nil
+}
+
+func (m *SMTPMailer) build(msg Message) []byte {
+	var b strings.Builder
+	b.WriteString("From: " + m.from + "\r\n")
+	b.WriteString("To: " + msg.To + "\r\n")
+	if msg.ReplyTo != "" {
+		b.WriteString("Reply-To: " + msg.ReplyTo + "\r\n")
+	}
+	for k, v := range msg.Headers {
+		b.WriteString(k + ": " + v + "\r\n")
+	}
+	b.WriteString("Subject: " + mime.QEncoding.Encode("utf-8", msg.Subject) + "\r\n")
+	b.WriteString("Date: " + time.Now().Format(time.RFC1123Z) + "\r\n")
+	b.WriteString("MIME-Version: 1.0\r\n")
+	b.WriteString("Content-Type: text/plain; charset=utf-8\r\n")
+	b.WriteString("\r\n")
+	b.WriteString(strings.ReplaceAll(msg.Body, "\n", "\r\n"))
+	return []byte(b.String())
+}
+
+// LogMailer writes messages to the log instead of sending them; used in development
+type LogMailer struct{}
+
+// Send logs the message
+func (m *LogMailer) Send(_ context.Context, msg Message) error {
+	log.Printf("📧 Email to %s (reply-to %q): %s\n%s", msg.To, msg.ReplyTo, msg.Subject, msg.Body)
+	return nil
+}
@@ -0,0 +1,28 @@
+package middleware
+
+import (
+	"pets_rest/internal/audit"
+
+	"github.com/gofiber/fiber/v3"
+	"github.com/gofiber/fiber/v3/middleware/requestid"
+)
+
+// Audit stores the client IP and request ID for the audit log, so that
+// handlers can pass the request as context to code that records changes;
+// RequireAuth adds the user. It must run after the requestid middleware.
+func Audit() fiber.Handler {
+	return func(c fiber.Ctx) error {
+		fiber.Locals(c, audit.ActorKey{}, audit.Actor{
+			IP:        c.IP(),
+			RequestID: requestid.FromContext(c),
+		})
+		return c.Next()
+	}
+}
+
+// setAuditUser adds the authenticated user to the audit actor of the request
+func setAuditUser(c fiber.Ctx, userID int) {
+	actor := audit.FromContext(c)
+	actor.UserID = &userID
+	fiber.Locals(c, audit.ActorKey{}, actor)
+}
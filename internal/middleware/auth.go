@@ -0,0 +1,104 @@
+// Package middleware contains Fiber middleware shared by the API routes
+package middleware
+
+import (
+	"database/sql"
+	"errors"
+	"strings"
+
+	"pets_rest/internal/auth"
+	"pets_rest/internal/config"
+	"pets_rest/internal/database"
+	"pets_rest/internal/policy"
+
+	"github.com/gofiber/fiber/v3"
+)
+
+const (
+	userIDKey = "user_id"
+	roleKey   = "user_role"
+)
+
+// RequireAuth rejects requests without a valid bearer access token or from
+// banned users and loads the role of the user, so that role changes and
+// bans apply immediately
+func RequireAuth(cfg *config.Config, db *database.DB) fiber.Handler {
+	return authenticate(cfg, db, false, false)
+}
+
+// RequireStreamAuth is RequireAuth for event streams: browsers' EventSource
+// cannot set headers, so the token may also be passed as ?access_token=
+func RequireStreamAuth(cfg *config.Config, db *database.DB) fiber.Handler {
+	return authenticate(cfg, db, true, false)
+}
+
+// OptionalAuth is RequireAuth for routes open to visitors: requests without
+// a token pass through anonymously, while an invalid token is still rejected
+func OptionalAuth(cfg *config.Config, db *database.DB) fiber.Handler {
+	return authenticate(cfg, db, false, true)
+}
+
+func authenticate(cfg *config.Config, db *database.DB, allowQuery, optional bool) fiber.Handler {
+	users := database.NewUserRepository(db)
+
+	return func(c fiber.Ctx) error {
+		header := c.Get(fiber.HeaderAuthorization)
+		token, ok := strings.CutPrefix(header, "Bearer ")
+		if !ok && allowQuery {
+			token = c.Query("access_token")
+		}
+		if token == "" && optional {
+			return c.Next()
+		}
+		if token == "" {
+			return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+				"error": "Missing access token",
+			})
+		}
+
+		userID, err := auth.ParseToken(cfg.JWTSecret, token)
+		if err != nil {
+			return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+				"error": err.Error(),
+			})
+		}
+
+		// Tokens of merged, deleted or banned accounts stay valid until
+		// they expire
+		role, banned, err := users.GetAccess(c, userID)
+		if errors.Is(err, sql.ErrNoRows) {
+			return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+				"error": "Account no longer exists",
+			})
+		}
+		if err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"error": "Failed to load account",
+			})
+		}
+		if banned {
+			return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+				"error": "Account is banned",
+			})
+		}
+
+		fiber.Locals(c, userIDKey, userID)
+		fiber.Locals(c, roleKey, role)
+		setAuditUser(c, userID)
+		return c.Next()
+	}
+}
+
+// UserID returns the ID of the authenticated user, or 0 for anonymous requests
+func UserID(c fiber.Ctx) int {
+	return fiber.Locals[int](c, userIDKey)
+}
+
+// Actor returns the authenticated user for policy checks; anonymous
+// requests get an actor without ID and role
+func Actor(c fiber.Ctx) policy.Actor {
+	return policy.Actor{
+		UserID: UserID(c),
+		Role:   fiber.Locals[database.Role](c, roleKey),
+	}
+}
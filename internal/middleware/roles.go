@@ -0,0 +1,20 @@
+package middleware
+
+import (
+	"pets_rest/internal/database"
+
+	"github.com/gofiber/fiber/v3"
+)
+
+// RequireRole lets through authenticated users with one of the roles; it
+// must run after RequireAuth, typically on a route group
+func RequireRole(roles ...database.Role) fiber.Handler {
+	return func(c fiber.Ctx) error {
+		if !Actor(c).Is(roles...) {
+			return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+				"error": "Insufficient permissions",
+			})
+		}
+		return c.Next()
+	}
+}
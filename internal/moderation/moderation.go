@@ -0,0 +1,283 @@
+// Package moderation handles reports about listings and the decisions
+// moderators take on them
+package moderation
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"pets_rest/internal/audit"
+	"pets_rest/internal/config"
+	"pets_rest/internal/database"
+	"pets_rest/internal/listings"
+	"pets_rest/internal/notify"
+	"pets_rest/internal/policy"
+
+	"github.com/jmoiron/sqlx"
+)
+
+var (
+	ErrAlreadyReported = errors.New("you have already reported this listing")
+	ErrUnknownAction   = errors.New("action must be approve, hide, require_edits or ban_user")
+	ErrNoteRequired    = errors.New("a note is required to explain the decision")
+	ErrCannotBan       = errors.New("you cannot ban this user")
+)
+
+// Service stores reports, hides listings that collect too many of them and
+// applies moderator decisions with an audit trail
+type Service struct {
+	db         *database.DB
+	cfg        *config.Config
+	listings   *database.ListingRepository
+	users      *database.UserRepository
+	moderation *database.ModerationRepository
+	revisions  *database.ListingRevisionRepository
+	audit      *audit.Log
+	notify     *notify.Service
+	announcer  *listings.Service
+}
+
+// NewService creates a new moderation service
+func NewService(db *database.DB, cfg *config.Config, notifier *notify.Service, announcer *listings.Service) *Service {
+	return &Service{
+		db:         db,
+		cfg:        cfg,
+		listings:   database.NewListingRepository(db),
+		users:      database.NewUserRepository(db),
+		moderation: database.NewModerationRepository(db),
+		revisions:  database.NewListingRevisionRepository(db),
+		audit:      audit.NewLog(db),
+		notify:     notifier,
+		announcer:  announcer,
+	}
+}
+
+// Report stores a visitor's report and hides the listing once it has
+// Config.ReportHideThreshold open reports, counting only those from
+// signed-in users made since the approval for approved listings; it reports
+// whether the listing was hidden
+func (s *Service) Report(ctx context.Context, listing *database.Listing, report *database.ListingReport) (bool, error) {
+	report.ListingID = listing.ID
+
+	var hidden bool
+	err := s.db.WithTx(ctx, func(tx *sqlx.Tx) error {
+		moderation := s.moderation.WithTx(tx)
+
+		created, err := moderation.CreateReport(ctx, report)
+		if err != nil {
+			return err
+		}
+		if !created {
+			return ErrAlreadyReported
+		}
+
+		// Reports a moderator already weighed do not count again
+		var since time.Time
+		if listing.Moderation == database.ModerationApproved {
+			approvedAt, err := moderation.LastApprovedAt(ctx, listing.ID)
+			if err != nil {
+				return err
+			}
+			if approvedAt != nil {
+				since = *approvedAt
+			}
+		}
+
+		counts, err := moderation.CountOpenReports(ctx, listing.ID, since)
+		if err != nil || !autoHideDue(listing.Moderation, counts, s.cfg.ReportHideThreshold) {
+			return err
+		}
+
+		// Only the report that crosses the threshold hides the listing, so
+		// the audit trail gets one entry
+		hidden, err = s.listings.WithTx(tx).SetModeration(ctx, listing.ID, database.ModerationHidden)
+		if err != nil || !hidden {
+			return err
+		}
+
+		after := *listing
+		after.Moderation = database.ModerationHidden
+		if err := s.audit.WithTx(tx).Record(ctx, database.AuditEntityListing, listing.ID, listing, &after); err != nil {
+			return err
+		}
+
+		note := fmt.Sprintf("%d open reports", counts.Open)
+		return moderation.CreateAction(ctx, &database.ModerationAction{
+			ListingID:    &listing.ID,
+			TargetUserID: &listing.UserID,
+			Action:       database.ModerationActionAutoHide,
+			Note:         &note,
+		})
+	})
+
+	return hidden, err
+}
+
+// Apply takes a moderator's decision on a listing, closes its open reports
+// and tells the owner when the listing is no longer shown; approving a
+// listing held by spam screening publishes it
+func (s *Service) Apply(ctx context.Context, actor policy.Actor, listingID int, action database.ModerationActionType, note string) (*database.Listing, error) {
+	state, err := decide(action, note)
+	if err != nil {
+		return nil, err
+	}
+
+	var listing *database.Listing
+	err = s.db.WithTx(ctx, func(tx *sqlx.Tx) error {
+		listings := s.listings.WithTx(tx)
+		moderation := s.moderation.WithTx(tx)
+
+		var err error
+		if listing, err = listings.GetByIDForUpdate(ctx, listingID); err != nil {
+			return err
+		}
+		before := *listing
+
+		if action == database.ModerationActionBanUser {
+			// Banning hides and records all listings of the owner, this one included
+			if err := s.ban(ctx, tx, actor, listing.UserID); err != nil {
+				return err
+			}
+		} else {
+			if _, err := listings.SetModeration(ctx, listing.ID, state); err != nil {
+				return err
+			}
+			if err := moderation.ResolveReports(ctx, listing.ID); err != nil {
+				return err
+			}
+		}
+		listing.Moderation = state
+
+		if action == database.ModerationActionApprove {
+			released, err := listings.Release(ctx, listing.ID)
+			if err != nil {
+				return err
+			}
+			if released {
+				listing.HeldForReview = false
+				listing.Status = database.ListingStatusActive
+				if _, err := s.revisions.WithTx(tx).Record(ctx, listing.ID, &actor.UserID); err != nil {
+					return err
+				}
+				if err := s.announcer.ReleasedTx(ctx, tx, listing); err != nil {
+					return err
+				}
+			}
+		}
+
+		if action != database.ModerationActionBanUser {
+			if err := s.audit.WithTx(tx).Record(ctx, database.AuditEntityListing, listing.ID, &before, listing); err != nil {
+				return err
+			}
+		}
+
+		entry := &database.ModerationAction{
+			ListingID:    &listing.ID,
+			TargetUserID: &listing.UserID,
+			ModeratorID:  &actor.UserID,
+			Action:       action,
+		}
+		if note != "" {
+			entry.Note = &note
+		}
+		if err := moderation.CreateAction(ctx, entry); err != nil {
+			return err
+		}
+
+		if action == database.ModerationActionApprove {
+			return nil
+		}
+		return s.notify.SendTx(ctx, tx, notify.Notification{
+			UserID: listing.UserID,
+			Kind:   notify.KindListingModerated,
+			Data: map[string]any{
+				"action":        string(action),
+				"note":          note,
+				"listing_title": listing.Title,
+				"listing_url":   s.cfg.FrontendURL + "/me/listings/" + strconv.Itoa(listing.ID),
+			},
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	return listing, nil
+}
+
+// autoHideDue reports whether open reports made since the last approval
+// hide a listing. An approved listing only counts reports from signed-in
+// users, since anonymous ones cost no more than a few addresses.
+func autoHideDue(state database.ModerationState, counts *database.ReportCounts, threshold int) bool {
+	if threshold <= 0 {
+		return false
+	}
+	if state == database.ModerationApproved {
+		return counts.SignedIn >= threshold
+	}
+	return counts.Open >= threshold
+}
+
+// decide returns the moderation state an action puts a listing in
+func decide(action database.ModerationActionType, note string) (database.ModerationState, error) {
+	state, ok := map[database.ModerationActionType]database.ModerationState{
+		database.ModerationActionApprove:      database.ModerationApproved,
+		database.ModerationActionHide:         database.ModerationHidden,
+		database.ModerationActionRequireEdits: database.ModerationChangesRequested,
+		database.ModerationActionBanUser:      database.ModerationHidden,
+	}[action]
+	if !ok {
+		return "", ErrUnknownAction
+	}
+	if note == "" && (action == database.ModerationActionRequireEdits || action == database.ModerationActionBanUser) {
+		return "", ErrNoteRequired
+	}
+	return state, nil
+}
+
+// ban blocks the owner and hides all their listings; staff can only be
+// banned by administrators, and nobody can ban themselves
+func (s *Service) ban(ctx context.Context, tx *sqlx.Tx, actor policy.Actor, userID int) error {
+	users := s.users.WithTx(tx)
+	listings := s.listings.WithTx(tx)
+	log := s.audit.WithTx(tx)
+
+	owner, err := users.GetByIDForUpdate(ctx, userID)
+	if err != nil {
+		return err
+	}
+	if !policy.CanBan(actor, owner) {
+		return ErrCannotBan
+	}
+
+	owned, err := listings.ListAllByUser(ctx, owner.ID)
+	if err != nil {
+		return err
+	}
+
+	if err := users.Ban(ctx, owner.ID); err != nil {
+		return err
+	}
+	banned, err := users.GetByID(ctx, owner.ID)
+	if err != nil {
+		return err
+	}
+	if err := log.Record(ctx, database.AuditEntityUser, owner.ID, owner, banned); err != nil {
+		return err
+	}
+
+	if err := listings.HideByUser(ctx, owner.ID); err != nil {
+		return err
+	}
+	for _, listing := range owned {
+		after := *listing
+		after.Moderation = database.ModerationHidden
+		if err := log.Record(ctx, database.AuditEntityListing, listing.ID, listing, &after); err != nil {
+			return err
+		}
+	}
+
+	return s.moderation.WithTx(tx).ResolveReportsByUser(ctx, owner.ID)
+}
@@ -0,0 +1,61 @@
+package moderation
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"pets_rest/internal/database"
+)
+
+func TestAutoHideDue(t *testing.T) {
+	tests := []struct {
+		name      string
+		state     database.ModerationState
+		counts    database.ReportCounts
+		threshold int
+		want      bool
+	}{
+		{"нижче порогу", database.ModerationPending, database.ReportCounts{Open: 2}, 3, false},
+		{"поріг досягнуто", database.ModerationPending, database.ReportCounts{Open: 3}, 3, true},
+		{"приховування вимкнено", database.ModerationPending, database.ReportCounts{Open: 10}, 0, false},
+		// Схвалене оголошення не ховають анонімні скарги з кількох адрес
+		{"схвалене, анонімні скарги", database.ModerationApproved, database.ReportCounts{Open: 5, SignedIn: 2}, 3, false},
+		{"схвалене, скарги користувачів", database.ModerationApproved, database.ReportCounts{Open: 3, SignedIn: 3}, 3, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, autoHideDue(tt.state, &tt.counts, tt.threshold))
+		})
+	}
+}
+
+func TestDecide(t *testing.T) {
+	tests := []struct {
+		action database.ModerationActionType
+		note   string
+		state  database.ModerationState
+		err    error
+	}{
+		{database.ModerationActionApprove, "", database.ModerationApproved, nil},
+		{database.ModerationActionHide, "", database.ModerationHidden, nil},
+		{database.ModerationActionRequireEdits, "Додайте фото", database.ModerationChangesRequested, nil},
+		{database.ModerationActionRequireEdits, "", "", ErrNoteRequired},
+		// Блокування ховає оголошення і потребує пояснення
+		{database.ModerationActionBanUser, "Шахрайство", database.ModerationHidden, nil},
+		{database.ModerationActionBanUser, "", "", ErrNoteRequired},
+		{database.ModerationActionAutoHide, "", "", ErrUnknownAction},
+	}
+	for _, tt := range tests {
+		t.Run(string(tt.action)+"/"+tt.note, func(t *testing.T) {
+			state, err := decide(tt.action, tt.note)
+			if tt.err != nil {
+				require.ErrorIs(t, err, tt.err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.state, state)
+		})
+	}
+}
@@ -0,0 +1,134 @@
+package notify
+
+import (
+	"context"
+
+	"pets_rest/internal/database"
+	"pets_rest/internal/mailer"
+	"pets_rest/internal/realtime"
+	"pets_rest/internal/webhooks"
+)
+
+// Message is a rendered notification addressed to a user
+type Message struct {
+	User    *database.User
+	Kind    Kind
+	Subject string
+	Body    string
+	Data    map[string]any
+}
+
+// Channel delivers rendered notifications
+type Channel interface {
+	Name() database.NotificationChannel
+	Send(ctx context.Context, msg *Message) error
+}
+
+// Notifier sends a text notification to a user through a messenger
+type Notifier interface {
+	NotifyUser(ctx context.Context, userID int, text string) error
+}
+
+// Publisher queues webhook events for the endpoints of a user
+type Publisher interface {
+	PublishToUser(ctx context.Context, userID int, eventType string, data any) error
+}
+
+// EmailChannel sends notifications by email. The optional "reply_to" and
+// "unsubscribe_url" data fields become the Reply-To and List-Unsubscribe
+// headers.
+type EmailChannel struct {
+	mailer mailer.Mailer
+}
+
+// NewEmailChannel creates an email channel
+func NewEmailChannel(m mailer.Mailer) *EmailChannel {
+	return &EmailChannel{mailer: m}
+}
+
+func (c *EmailChannel) Name() database.NotificationChannel {
+	return database.NotificationChannelEmail
+}
+
+func (c *EmailChannel) Send(ctx context.Context, msg *Message) error {
+	email := mailer.Message{
+		To:      msg.User.Email,
+		Subject: msg.Subject,
+		Body:    msg.Body,
+	}
+	if replyTo, ok := msg.Data["reply_to"].(string); ok {
+		email.ReplyTo = replyTo
+	}
+	if unsubscribeURL, ok := msg.Data["unsubscribe_url"].(string); ok {
+		email.Headers = map[string]string{
+			"List-Unsubscribe":      "<" + unsubscribeURL + ">",
+			"List-Unsubscribe-Post": "List-Unsubscribe=One-Click",
+		}
+	}
+	return c.mailer.Send(ctx, email)
+}
+
+// TelegramChannel sends notifications to the user's linked Telegram chat
+type TelegramChannel struct {
+	notifier Notifier
+}
+
+// NewTelegramChannel creates a Telegram channel
+func NewTelegramChannel(n Notifier) *TelegramChannel {
+	return &TelegramChannel{notifier: n}
+}
+
+func (c *TelegramChannel) Name() database.NotificationChannel {
+	return database.NotificationChannelTelegram
+}
+
+func (c *TelegramChannel) Send(ctx context.Context, msg *Message) error {
+	return c.notifier.NotifyUser(ctx, msg.User.ID, msg.Subject+"\n\n"+msg.Body)
+}
+
+// WebhookChannel queues notifications for the user's webhook endpoints
+// subscribed to the notification event
+type WebhookChannel struct {
+	publisher Publisher
+}
+
+// NewWebhookChannel creates a webhook channel
+func NewWebhookChannel(p Publisher) *WebhookChannel {
+	return &WebhookChannel{publisher: p}
+}
+
+func (c *WebhookChannel) Name() database.NotificationChannel {
+	return database.NotificationChannelWebhook
+}
+
+func (c *WebhookChannel) Send(ctx context.Context, msg *Message) error {
+	return c.publisher.PublishToUser(ctx, msg.User.ID, webhooks.EventNotification, payload(msg))
+}
+
+// InAppChannel pushes notifications to the user's live stream; the event
+// type is the notification kind
+type InAppChannel struct {
+	broker realtime.Broker
+}
+
+// NewInAppChannel creates an in-app channel
+func NewInAppChannel(broker realtime.Broker) *InAppChannel {
+	return &InAppChannel{broker: broker}
+}
+
+func (c *InAppChannel) Name() database.NotificationChannel {
+	return database.NotificationChannelInApp
+}
+
+func (c *InAppChannel) Send(ctx context.Context, msg *Message) error {
+	return c.broker.Publish(ctx, msg.User.ID, string(msg.Kind), payload(msg))
+}
+
+func payload(msg *Message) map[string]any {
+	return map[string]any{
+		"kind":    msg.Kind,
+		"subject": msg.Subject,
+		"body":    msg.Body,
+		"data":    msg.Data,
+	}
+}
@@ -0,0 +1,242 @@
+// Package notify renders user notifications from localized templates and
+// delivers them through pluggable channels via a transactional outbox
+package notify
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"time"
+
+	"pets_rest/internal/database"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// Kind identifies a notification and its template
+type Kind string
+
+const (
+	KindMessageReceived  Kind = "message.received"
+	KindContactRelay     Kind = "contact.relay"
+	KindAlertMatch       Kind = "alert.match"
+	KindListingModerated Kind = "listing.moderated"
+	KindDataExportReady  Kind = "data_export.ready"
+)
+
+// Defaults are the channels used for a kind when the user has not chosen any
+var Defaults = map[Kind][]database.NotificationChannel{
+	KindMessageReceived:  {database.NotificationChannelEmail, database.NotificationChannelTelegram},
+	KindContactRelay:     {database.NotificationChannelEmail},
+	KindAlertMatch:       {database.NotificationChannelEmail},
+	KindListingModerated: {database.NotificationChannelEmail, database.NotificationChannelInApp},
+	KindDataExportReady:  {database.NotificationChannelEmail, database.NotificationChannelInApp},
+}
+
+const (
+	batchSize   = 50
+	maxAttempts = 5
+	// lease keeps a claimed notification away from other workers while it is sent
+	lease = time.Minute
+)
+
+// ErrUnknownChannel is returned for channels without a registered implementation
+var ErrUnknownChannel = errors.New("unknown notification channel")
+
+// Notification is a request to notify a user
+type Notification struct {
+	UserID int
+	Kind   Kind
+	Data   map[string]any
+	// Channels overrides the user's preferences when set, e.g. for alert
+	// subscriptions that carry their own channel
+	Channels []database.NotificationChannel
+}
+
+// Service queues notifications in the outbox and delivers them
+type Service struct {
+	notifications *database.NotificationRepository
+	users         *database.UserRepository
+	templates     *Templates
+	channels      map[database.NotificationChannel]Channel
+}
+
+// NewService creates a notification service delivering through the given channels
+func NewService(db *database.DB, channels ...Channel) (*Service, error) {
+	templates, err := LoadTemplates()
+	if err != nil {
+		return nil, err
+	}
+
+	s := &Service{
+		notifications: database.NewNotificationRepository(db),
+		users:         database.NewUserRepository(db),
+		templates:     templates,
+		channels:      make(map[database.NotificationChannel]Channel),
+	}
+	for _, ch := range channels {
+		s.channels[ch.Name()] = ch
+	}
+	return s, nil
+}
+
+// Channels returns the names of the registered channels
+func (s *Service) Channels() []database.NotificationChannel {
+	names := make([]database.NotificationChannel, 0, len(s.channels))
+	for name := range s.channels {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	return names
+}
+
+// Send queues a notification outside of a transaction
+func (s *Service) Send(ctx context.Context, n Notification) error {
+	return s.enqueue(ctx, s.notifications, n)
+}
+
+// SendTx queues a notification in tx so that it is only sent once the
+// change it reports is committed
+func (s *Service) SendTx(ctx context.Context, tx *sqlx.Tx, n Notification) error {
+	return s.enqueue(ctx, s.notifications.WithTx(tx), n)
+}
+
+// Preferences returns the effective channels of every kind for a user
+func (s *Service) Preferences(ctx context.Context, userID int) (map[Kind][]database.NotificationChannel, error) {
+	stored, err := s.notifications.Preferences(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	prefs := make(map[Kind][]database.NotificationChannel, len(Defaults))
+	for kind, channels := range Defaults {
+		prefs[kind] = channels
+		if chosen, ok := stored[string(kind)]; ok {
+			prefs[kind] = chosen
+		}
+	}
+	return prefs, nil
+}
+
+// SetPreference stores the channels of one kind for a user; an empty list
+// mutes the kind
+func (s *Service) SetPreference(ctx context.Context, userID int, kind Kind, channels []database.NotificationChannel) error {
+	if _, ok := Defaults[kind]; !ok {
+		return fmt.Errorf("unknown notification kind %q", kind)
+	}
+	for _, ch := range channels {
+		if _, ok := s.channels[ch]; !ok {
+			return fmt.Errorf("%w %q", ErrUnknownChannel, ch)
+		}
+	}
+	return s.notifications.SetPreference(ctx, userID, string(kind), channels)
+}
+
+// ProcessOutbox sends due notifications and returns how many were tried
+func (s *Service) ProcessOutbox(ctx context.Context) (int, error) {
+	pending, err := s.notifications.ClaimDue(ctx, batchSize, time.Now().Add(lease))
+	if err != nil {
+		return 0, err
+	}
+
+	for _, n := range pending {
+		if sendErr := s.deliver(ctx, n); sendErr != nil {
+			var next *time.Time
+			if n.Attempts < maxAttempts && !errors.Is(sendErr, ErrUnknownChannel) {
+				at := time.Now().Add(retryDelay(n.Attempts))
+				next = &at
+			}
+			if err := s.notifications.MarkFailed(ctx, n.ID, sendErr.Error(), next); err != nil {
+				return 0, err
+			}
+			continue
+		}
+
+		if err := s.notifications.MarkSent(ctx, n.ID); err != nil {
+			return 0, err
+		}
+	}
+
+	return len(pending), nil
+}
+
+// Flush sends due outbox notifications in batches until none are left; it
+// runs as a scheduled job
+func (s *Service) Flush(ctx context.Context) error {
+	for ctx.Err() == nil {
+		n, err := s.ProcessOutbox(ctx)
+		if err != nil || n < batchSize {
+			return err
+		}
+	}
+	return ctx.Err()
+}
+
+func (s *Service) enqueue(ctx context.Context, repo *database.NotificationRepository, n Notification) error {
+	channels := n.Channels
+	if channels == nil {
+		stored, err := repo.Preferences(ctx, n.UserID)
+		if err != nil {
+			return err
+		}
+		var ok bool
+		if channels, ok = stored[string(n.Kind)]; !ok {
+			channels = Defaults[n.Kind]
+		}
+	}
+
+	data := database.JSONPayload(n.Data)
+	if data == nil {
+		data = database.JSONPayload{}
+	}
+
+	for _, ch := range channels {
+		if _, ok := s.channels[ch]; !ok {
+			continue
+		}
+		err := repo.Enqueue(ctx, &database.OutboxNotification{
+			UserID:  n.UserID,
+			Kind:    string(n.Kind),
+			Channel: ch,
+			Data:    data,
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (s *Service) deliver(ctx context.Context, n *database.OutboxNotification) error {
+	channel, ok := s.channels[n.Channel]
+	if !ok {
+		return fmt.Errorf("%w %q", ErrUnknownChannel, n.Channel)
+	}
+
+	user, err := s.users.GetByID(ctx, n.UserID)
+	if err != nil {
+		return err
+	}
+
+	kind := Kind(n.Kind)
+	subject, body, err := s.templates.Render(user.Locale, kind, n.Data)
+	if err != nil {
+		return err
+	}
+
+	return channel.Send(ctx, &Message{
+		User:    user,
+		Kind:    kind,
+		Subject: subject,
+		Body:    body,
+		Data:    n.Data,
+	})
+}
+
+// retryDelay returns the delay before the next attempt: 1m, 2m, 4m, ... capped at 1h
+func retryDelay(attempts int) time.Duration {
+	delay := time.Minute << max(attempts-1, 0)
+	return min(delay, time.Hour)
+}
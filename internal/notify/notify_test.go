@@ -0,0 +1,77 @@
+package notify
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEveryKindHasTemplates(t *testing.T) {
+	templates, err := LoadTemplates()
+	require.NoError(t, err)
+
+	for _, locale := range Locales {
+		for kind := range Defaults {
+			_, ok := templates.sets[locale][kind]
+			assert.True(t, ok, "missing %s template for %s", locale, kind)
+		}
+	}
+}
+
+func TestRenderAlertMatch(t *testing.T) {
+	templates, err := LoadTemplates()
+	require.NoError(t, err)
+
+	one := map[string]any{
+		"listings": []any{
+			map[string]any{"title": "Рудий кіт", "city": "Київ", "url": "https://pets.example/p/rudyi-kit"},
+		},
+		"unsubscribe_url": "https://api.example/alerts/unsubscribe/token",
+	}
+	subject, body, err := templates.Render("en", KindAlertMatch, one)
+	require.NoError(t, err)
+	assert.Equal(t, "New listing nearby: Рудий кіт", subject)
+	assert.Contains(t, body, "• Рудий кіт (Київ)\n  https://pets.example/p/rudyi-kit")
+	assert.Contains(t, body, "Unsubscribe: https://api.example/alerts/unsubscribe/token")
+
+	many := map[string]any{
+		"listings":        []any{map[string]any{"title": "a"}, map[string]any{"title": "b"}, map[string]any{"title": "c"}},
+		"unsubscribe_url": "https://api.example/alerts/unsubscribe/token",
+	}
+	subject, body, err = templates.Render("uk", KindAlertMatch, many)
+	require.NoError(t, err)
+	assert.Equal(t, "3 нові оголошення поруч", subject)
+	assert.Contains(t, body, "• a\n• b\n• c\n")
+}
+
+func TestRenderFallsBackToDefaultLocale(t *testing.T) {
+	templates, err := LoadTemplates()
+	require.NoError(t, err)
+
+	subject, _, err := templates.Render("de", KindMessageReceived, map[string]any{
+		"listing_title":    "Рудий кіт",
+		"body":             "Бачила його біля парку",
+		"conversation_url": "https://pets.example/conversations/1",
+	})
+	require.NoError(t, err)
+	assert.Equal(t, "Нове повідомлення щодо «Рудий кіт»", subject)
+
+	_, _, err = templates.Render("en", Kind("unknown"), nil)
+	assert.Error(t, err)
+}
+
+func TestPlural(t *testing.T) {
+	forms := []string{"оголошення", "оголошення-few", "оголошень"}
+	cases := map[int]string{1: forms[0], 2: forms[1], 4: forms[1], 5: forms[2], 11: forms[2], 14: forms[2], 21: forms[0], 22: forms[1], 111: forms[2]}
+	for n, want := range cases {
+		assert.Equal(t, want, plural(n, forms[0], forms[1], forms[2]), "n=%d", n)
+	}
+}
+
+func TestRetryDelay(t *testing.T) {
+	assert.Equal(t, time.Minute, retryDelay(1))
+	assert.Equal(t, 4*time.Minute, retryDelay(3))
+	assert.Equal(t, time.Hour, retryDelay(20))
+}
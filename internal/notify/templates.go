@@ -0,0 +1,86 @@
+package notify
+
+import (
+	"embed"
+	"fmt"
+	"io/fs"
+	"path"
+	"strings"
+	"text/template"
+)
+
+//go:embed templates
+var templateFS embed.FS
+
+// Locales supported by the templates; the first one is the default
+var Locales = []string{"uk", "en"}
+
+// Templates renders the subject and body of each notification kind; every
+// template file defines a "subject" and a "body" template
+type Templates struct {
+	sets map[string]map[Kind]*template.Template
+}
+
+// LoadTemplates parses the embedded templates of every locale
+func LoadTemplates() (*Templates, error) {
+	t := &Templates{sets: make(map[string]map[Kind]*template.Template)}
+	for _, locale := range Locales {
+		files, err := fs.Glob(templateFS, "templates/"+locale+"/*.tmpl")
+		if err != nil {
+			return nil, err
+		}
+
+		t.sets[locale] = make(map[Kind]*template.Template)
+		for _, file := range files {
+			kind := Kind(strings.TrimSuffix(path.Base(file), ".tmpl"))
+			tmpl, err := template.New(string(kind)).
+				Funcs(template.FuncMap{"plural": plural}).
+				ParseFS(templateFS, file)
+			if err != nil {
+				return nil, fmt.Errorf("failed to parse %s: %w", file, err)
+			}
+			t.sets[locale][kind] = tmpl
+		}
+	}
+	return t, nil
+}
+
+// Render returns the subject and body of a notification in the given
+// locale, falling back to the default locale
+func (t *Templates) Render(locale string, kind Kind, data map[string]any) (subject, body string, err error) {
+	tmpl, ok := t.sets[locale][kind]
+	if !ok {
+		tmpl, ok = t.sets[Locales[0]][kind]
+	}
+	if !ok {
+		return "", "", fmt.Errorf("no template for notification kind %q", kind)
+	}
+
+	var b strings.Builder
+	if err := tmpl.ExecuteTemplate(&b, "subject", data); err != nil {
+		return "", "", err
+	}
+	subject = strings.TrimSpace(b.String())
+
+	b.Reset()
+	if err := tmpl.ExecuteTemplate(&b, "body", data); err != nil {
+		return "", "", err
+	}
+	return subject, strings.TrimSpace(b.String()) + "\n", nil
+}
+
+// plural picks the Ukrainian plural form for n; English templates pass the
+// same word as few and many
+func plural(n int, one, few, many string) string {
+	n %= 100
+	switch {
+	case n >= 11 && n <= 14:
+		return many
+	case n%10 == 1:
+		return one
+	case n%10 >= 2 && n%10 <= 4:
+		return few
+	default:
+		return many
+	}
+}
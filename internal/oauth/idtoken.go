@@ -0,0 +1,79 @@
+package oauth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+var ErrInvalidNonce = errors.New("id_token nonce does not match the session")
+
+// idTokenLeeway tolerates clock skew between us and the provider
+const idTokenLeeway = time.Minute
+
+// idTokenVerifier checks ID tokens issued by one provider to one client
+type idTokenVerifier struct {
+	issuers  []string
+	clientID string
+	keys     *keySet
+}
+
+// verify checks the signature against the provider's keys, the issuer,
+// audience, expiry and that the nonce is the one stored with the sign-in,
+// which stops a token issued for another session from being replayed
+func (v *idTokenVerifier) verify(ctx context.Context, raw, nonce string) (*idTokenClaims, error) {
+	var claims idTokenClaims
+	_, err := jwt.ParseWithClaims(raw, &claims, func(tok *jwt.Token) (any, error) {
+		kid, _ := tok.Header["kid"].(string)
+		return v.keys.key(ctx, kid)
+	},
+		jwt.WithValidMethods([]string{"RS256", "RS384", "RS512", "ES256", "ES384", "ES512", "PS256"}),
+		jwt.WithAudience(v.clientID),
+		jwt.WithExpirationRequired(),
+		jwt.WithLeeway(idTokenLeeway),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("invalid id_token: %w", err)
+	}
+
+	if !slices.Contains(v.issuers, claims.Issuer) {
+		return nil, errors.New("invalid id_token: unexpected issuer")
+	}
+	// With several audiences the token must have been issued to us
+	if len(claims.Audience) > 1 && claims.AuthorizedParty != v.clientID {
+		return nil, errors.New("invalid id_token: authorized party mismatch")
+	}
+	if nonce == "" || claims.Nonce != nonce {
+		return nil, ErrInvalidNonce
+	}
+	if claims.Subject == "" {
+		return nil, errors.New("invalid id_token: missing subject")
+	}
+
+	return &claims, nil
+}
+
+// idTokenClaims are the registered claims of an ID token plus the profile
+type idTokenClaims struct {
+	jwt.RegisteredClaims
+	Nonce           string   `json:"nonce"`
+	AuthorizedParty string   `json:"azp"`
+	Email           string   `json:"email"`
+	EmailVerified   flexBool `json:"email_verified"`
+	Name            string   `json:"name"`
+	Picture         string   `json:"picture"`
+}
+
+func (c idTokenClaims) profile() profileClaims {
+	return profileClaims{
+		Sub:           c.Subject,
+		Email:         c.Email,
+		EmailVerified: c.EmailVerified,
+		Name:          c.Name,
+		Picture:       c.Picture,
+	}
+}
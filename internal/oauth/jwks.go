@@ -0,0 +1,156 @@
+package oauth
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"math/big"
+	"sync"
+	"time"
+)
+
+const (
+	// jwksTTL is how long fetched keys are trusted before a refresh
+	jwksTTL = time.Hour
+	// jwksMinRefresh limits refetches triggered by unknown key IDs, so a
+	// stream of forged tokens cannot turn into a stream of requests to the
+	// provider
+	jwksMinRefresh = time.Minute
+)
+
+var ErrUnknownKey = errors.New("id_token signed with an unknown key")
+
+// keySet caches a provider's JSON Web Key Set. Keys are refetched when they
+// expire or when a token names a key ID that is not cached yet, which is
+// how providers announce key rotation.
+type keySet struct {
+	url string
+
+	mu        sync.Mutex
+	keys      map[string]crypto.PublicKey
+	fetchedAt time.Time
+}
+
+func newKeySet(url string) *keySet {
+	return &keySet{url: url}
+}
+
+// key returns the public key with the given ID
+func (s *keySet) key(ctx context.Context, kid string) (crypto.PublicKey, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	age := time.Since(s.fetchedAt)
+	if key, ok := s.keys[kid]; ok && age < jwksTTL {
+		return key, nil
+	}
+
+	if s.keys == nil || age >= jwksMinRefresh {
+		if err := s.refresh(ctx); err != nil {
+			// A stale key is better than failing every sign-in while the
+			// provider is unreachable
+			if key, ok := s.keys[kid]; ok {
+				return key, nil
+			}
+			return nil, err
+		}
+	}
+
+	key, ok := s.keys[kid]
+	if !ok {
+		return nil, ErrUnknownKey
+	}
+	return key, nil
+}
+
+func (s *keySet) refresh(ctx context.Context) error {
+	var doc struct {
+		Keys []jwk `json:"keys"`
+	}
+	if err := getJSON(ctx, s.url, nil, &doc); err != nil {
+		return fmt.Errorf("failed to fetch jwks: %w", err)
+	}
+
+	keys := make(map[string]crypto.PublicKey, len(doc.Keys))
+	for _, k := range doc.Keys {
+		if k.Use != "" && k.Use != "sig" {
+			continue
+		}
+		key, err := k.publicKey()
+		if err != nil {
+			// Skip key types we cannot use instead of rejecting the set
+			continue
+		}
+		keys[k.Kid] = key
+	}
+
+	s.keys = keys
+	s.fetchedAt = time.Now()
+	return nil
+}
+
+// jwk is a single RSA or EC key of a JSON Web Key Set
+type jwk struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+	Crv string `json:"crv"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+}
+
+func (k jwk) publicKey() (crypto.PublicKey, error) {
+	switch k.Kty {
+	case "RSA":
+		n, err := base64.RawURLEncoding.DecodeString(k.N)
+		if err != nil {
+			return nil, err
+		}
+		e, err := base64.RawURLEncoding.DecodeString(k.E)
+		if err != nil {
+			return nil, err
+		}
+		exp := new(big.Int).SetBytes(e)
+		if !exp.IsInt64() || exp.Int64() > 1<<31-1 {
+			return nil, errors.New("rsa exponent too large")
+		}
+		return &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(exp.Int64())}, nil
+
+	case "EC":
+		var curve elliptic.Curve
+		switch k.Crv {
+		case "P-256":
+			curve = elliptic.P256()
+		case "P-384":
+			curve = elliptic.P384()
+		case "P-521":
+			curve = elliptic.P521()
+		default:
+			return nil, fmt.Errorf("unsupported curve %q", k.Crv)
+		}
+		x, err := base64.RawURLEncoding.DecodeString(k.X)
+		if err != nil {
+			return nil, err
+		}
+		y, err := base64.RawURLEncoding.DecodeString(k.Y)
+		if err != nil {
+			return nil, err
+		}
+		size := (curve.Params().BitSize + 7) / 8
+		if len(x) != size || len(y) != size {
+			return nil, errors.New("invalid ec point")
+		}
+		point := append(append([]byte{4}, x...), y...)
+		return ecdsa.ParseUncompressedPublicKey(curve, point)
+
+	default:
+		return nil, fmt.Errorf("unsupported key type %q", k.Kty)
+	}
+}
@@ -0,0 +1,454 @@
+package oauth
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"pets_rest/internal/config"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/oauth2"
+)
+
+// memSession — сесія в пам'яті замість middleware
+type memSession map[any]any
+
+func (s memSession) Get(key any) any    { return s[key] }
+func (s memSession) Set(key, value any) { s[key] = value }
+func (s memSession) Delete(key any)     { delete(s, key) }
+
+// fakeOIDC — локальний OpenID-провайдер: discovery, jwks, token і userinfo
+type fakeOIDC struct {
+	*httptest.Server
+	key       *rsa.PrivateKey
+	kid       string
+	challenge string // code_challenge з останнього authorize
+	nonce     string // nonce з останнього authorize
+	issuer    string // можна підмінити, щоб зламати discovery
+	noIDToken bool   // провайдер без id_token: лише userinfo
+
+	// claims дозволяє зіпсувати id_token у конкретному тесті
+	claims func(jwt.MapClaims)
+
+	jwksCalls     int
+	userinfoCalls int
+}
+
+func newFakeOIDC(t *testing.T) *fakeOIDC {
+	f := &fakeOIDC{}
+	f.rotate(t)
+	mux := http.NewServeMux()
+
+	mux.HandleFunc("/.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		_ = json.NewEncoder(w).Encode(Discovery{
+			Issuer:                f.iss(),
+			AuthorizationEndpoint: f.URL + "/authorize",
+			TokenEndpoint:         f.URL + "/token",
+			UserinfoEndpoint:      f.URL + "/userinfo",
+			JWKSURI:               f.URL + "/jwks",
+		})
+	})
+
+	mux.HandleFunc("/jwks", func(w http.ResponseWriter, r *http.Request) {
+		f.jwksCalls++
+		_ = json.NewEncoder(w).Encode(map[string]any{"keys": []map[string]string{{
+			"kty": "RSA",
+			"kid": f.kid,
+			"use": "sig",
+			"n":   b64url(f.key.N.Bytes()),
+			"e":   b64url(big.NewInt(int64(f.key.E)).Bytes()),
+		}}})
+	})
+
+	mux.HandleFunc("/token", func(w http.ResponseWriter, r *http.Request) {
+		require.NoError(t, r.ParseForm())
+		user, pass, _ := r.BasicAuth()
+		sum := sha256.Sum256([]byte(r.PostForm.Get("code_verifier")))
+
+		w.Header().Set("Content-Type", "application/json")
+		if r.PostForm.Get("code") != "good-code" || user != "client" || pass != "secret" || b64url(sum[:]) != f.challenge {
+			w.WriteHeader(http.StatusBadRequest)
+			_, _ = w.Write([]byte(`{"error":"invalid_grant"}`))
+			return
+		}
+
+		resp := map[string]any{"access_token": "access", "token_type": "Bearer", "expires_in": 3600}
+		if !f.noIDToken {
+			claims := jwt.MapClaims{
+				"iss":            f.iss(),
+				"aud":            "client",
+				"sub":            "42",
+				"exp":            time.Now().Add(time.Hour).Unix(),
+				"iat":            time.Now().Unix(),
+				"nonce":          f.nonce,
+				"email":          "olena@example.com",
+				"email_verified": true,
+				"name":           "Олена",
+				"picture":        "https://example.com/a.png",
+			}
+			if f.claims != nil {
+				f.claims(claims)
+			}
+			resp["id_token"] = f.sign(t, f.key, claims)
+		}
+		_ = json.NewEncoder(w).Encode(resp)
+	})
+
+	mux.HandleFunc("/userinfo", func(w http.ResponseWriter, r *http.Request) {
+		f.userinfoCalls++
+		if r.Header.Get("Authorization") != "Bearer access" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		// email_verified рядком, як у деяких провайдерів
+		_, _ = w.Write([]byte(`{"sub":"42","email":"olena@example.com","email_verified":"true","name":"Олена","picture":"https://example.com/a.png"}`))
+	})
+
+	f.Server = httptest.NewServer(mux)
+	t.Cleanup(f.Close)
+	return f
+}
+
+func (f *fakeOIDC) iss() string {
+	if f.issuer != "" {
+		return f.issuer
+	}
+	return f.URL
+}
+
+// rotate видає новий ключ підпису з новим kid
+func (f *fakeOIDC) rotate(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	f.key = key
+	f.kid = NewState()
+}
+
+func (f *fakeOIDC) sign(t *testing.T, key *rsa.PrivateKey, claims jwt.MapClaims) string {
+	tok := jwt.NewWithClaims(jwt.SigningMethodRS256, claims)
+	tok.Header["kid"] = f.kid
+	raw, err := tok.SignedString(key)
+	require.NoError(t, err)
+	return raw
+}
+
+func newTestOIDC(f *fakeOIDC) *OIDCProvider {
+	return NewOIDC("corp", f.URL, &oauth2.Config{
+		ClientID:     "client",
+		ClientSecret: "secret",
+		RedirectURL:  "http://localhost:8080/api/v1/auth/corp/callback",
+		Scopes:       []string{"openid", "email"},
+	})
+}
+
+// authorize проходить AuthURL і повертає state з посилання
+func authorize(t *testing.T, f *fakeOIDC, p Provider, sess Session) string {
+	raw, err := p.AuthURL(sess)
+	require.NoError(t, err)
+
+	u, err := url.Parse(raw)
+	require.NoError(t, err)
+	assert.Equal(t, f.URL+"/authorize", u.Scheme+"://"+u.Host+u.Path)
+	assert.Equal(t, "S256", u.Query().Get("code_challenge_method"))
+
+	f.challenge = u.Query().Get("code_challenge")
+	f.nonce = u.Query().Get("nonce")
+	return u.Query().Get("state")
+}
+
+func TestOIDCSignIn(t *testing.T) {
+	f := newFakeOIDC(t)
+	p := newTestOIDC(f)
+	sess := memSession{}
+
+	state := authorize(t, f, p, sess)
+	require.NotEmpty(t, state)
+
+	user, err := p.HandleCallback(context.Background(), sess, state, "good-code")
+	require.NoError(t, err)
+	assert.Equal(t, User{
+		Provider:      "corp",
+		ProviderID:    "42",
+		Email:         "olena@example.com",
+		EmailVerified: true,
+		Name:          "Олена",
+		AvatarURL:     "https://example.com/a.png",
+	}, user)
+
+	// Профіль узято з перевіреного id_token, без зайвого запиту
+	assert.Equal(t, 0, f.userinfoCalls)
+	assert.Equal(t, 1, f.jwksCalls)
+
+	// state одноразовий
+	_, err = p.HandleCallback(context.Background(), sess, state, "good-code")
+	assert.ErrorIs(t, err, ErrInvalidState)
+}
+
+func TestOIDCRejectsWrongState(t *testing.T) {
+	f := newFakeOIDC(t)
+	p := newTestOIDC(f)
+	sess := memSession{}
+
+	authorize(t, f, p, sess)
+	_, err := p.HandleCallback(context.Background(), sess, "forged", "good-code")
+	assert.ErrorIs(t, err, ErrInvalidState)
+
+	// Без попереднього AuthURL у сесії немає state взагалі
+	_, err = p.HandleCallback(context.Background(), memSession{}, "", "good-code")
+	assert.ErrorIs(t, err, ErrInvalidState)
+}
+
+func TestOIDCRejectsBadCode(t *testing.T) {
+	f := newFakeOIDC(t)
+	p := newTestOIDC(f)
+	sess := memSession{}
+
+	state := authorize(t, f, p, sess)
+	_, err := p.HandleCallback(context.Background(), sess, state, "stolen-code")
+	assert.Error(t, err)
+}
+
+func TestOIDCRejectsInvalidIDToken(t *testing.T) {
+	other, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+
+	cases := map[string]func(f *fakeOIDC, c jwt.MapClaims){
+		"чужий nonce":    func(f *fakeOIDC, c jwt.MapClaims) { c["nonce"] = "other-session" },
+		"без nonce":      func(f *fakeOIDC, c jwt.MapClaims) { delete(c, "nonce") },
+		"чужа аудиторія": func(f *fakeOIDC, c jwt.MapClaims) { c["aud"] = "another-client" },
+		"чужий azp":      func(f *fakeOIDC, c jwt.MapClaims) { c["aud"] = []string{"client", "x"}; c["azp"] = "x" },
+		"інший видавець": func(f *fakeOIDC, c jwt.MapClaims) { c["iss"] = "https://evil.example.com" },
+		"прострочений":   func(f *fakeOIDC, c jwt.MapClaims) { c["exp"] = time.Now().Add(-time.Hour).Unix() },
+		"без exp":        func(f *fakeOIDC, c jwt.MapClaims) { delete(c, "exp") },
+		"підпис чужим ключем": func(f *fakeOIDC, c jwt.MapClaims) {
+			// Той самий kid, але ключ не провайдера: підміна токена
+			f.key, other = other, f.key
+		},
+	}
+
+	for name, spoil := range cases {
+		t.Run(name, func(t *testing.T) {
+			f := newFakeOIDC(t)
+			p := newTestOIDC(f)
+			sess := memSession{}
+			state := authorize(t, f, p, sess)
+
+			// Ключі провайдера завантажені до псування токена
+			_, err := p.verifier.keys.key(context.Background(), f.kid)
+			require.NoError(t, err)
+			f.claims = func(c jwt.MapClaims) { spoil(f, c) }
+
+			_, err = p.HandleCallback(context.Background(), sess, state, "good-code")
+			assert.Error(t, err)
+			// Невдала перевірка не повинна відкочуватися на userinfo
+			assert.Equal(t, 0, f.userinfoCalls)
+		})
+	}
+}
+
+func TestOIDCUserinfoFallback(t *testing.T) {
+	f := newFakeOIDC(t)
+	f.noIDToken = true
+	p := newTestOIDC(f)
+	sess := memSession{}
+
+	state := authorize(t, f, p, sess)
+	user, err := p.HandleCallback(context.Background(), sess, state, "good-code")
+	require.NoError(t, err)
+	assert.Equal(t, "42", user.ProviderID)
+	assert.True(t, user.EmailVerified)
+	assert.Equal(t, 1, f.userinfoCalls)
+
+	// id_token без email: пошта з userinfo, але лише для того самого sub
+	f.noIDToken = false
+	f.claims = func(c jwt.MapClaims) { delete(c, "email"); c["sub"] = "43" }
+	state = authorize(t, f, p, sess)
+	_, err = p.HandleCallback(context.Background(), sess, state, "good-code")
+	assert.ErrorContains(t, err, "does not match")
+}
+
+func TestKeySetRotation(t *testing.T) {
+	f := newFakeOIDC(t)
+	keys := newKeySet(f.URL + "/jwks")
+	ctx := context.Background()
+
+	_, err := keys.key(ctx, f.kid)
+	require.NoError(t, err)
+
+	// Невідомий kid одразу після завантаження не смикає провайдера
+	old := f.kid
+	f.rotate(t)
+	_, err = keys.key(ctx, f.kid)
+	assert.ErrorIs(t, err, ErrUnknownKey)
+	assert.Equal(t, 1, f.jwksCalls)
+
+	// Після паузи невідомий kid перечитує набір ключів
+	keys.fetchedAt = time.Now().Add(-2 * jwksMinRefresh)
+	_, err = keys.key(ctx, f.kid)
+	require.NoError(t, err)
+	assert.Equal(t, 2, f.jwksCalls)
+
+	_, err = keys.key(ctx, old)
+	assert.ErrorIs(t, err, ErrUnknownKey)
+}
+
+func TestJWKECKey(t *testing.T) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	point, err := key.PublicKey.Bytes()
+	require.NoError(t, err)
+
+	pub, err := jwk{Kty: "EC", Crv: "P-256", X: b64url(point[1:33]), Y: b64url(point[33:])}.publicKey()
+	require.NoError(t, err)
+	assert.True(t, key.PublicKey.Equal(pub))
+
+	_, err = jwk{Kty: "EC", Crv: "P-256", X: b64url(point[1:33]), Y: b64url(point[1:33])}.publicKey()
+	assert.Error(t, err)
+}
+
+func TestDiscoverIssuerMismatch(t *testing.T) {
+	f := newFakeOIDC(t)
+	f.issuer = "https://evil.example.com"
+
+	_, err := Discover(context.Background(), f.URL)
+	assert.ErrorContains(t, err, "does not match")
+
+	// Невдала discovery не кешується: наступна спроба проходить
+	p := newTestOIDC(f)
+	_, err = p.AuthURL(memSession{})
+	assert.Error(t, err)
+
+	f.issuer = ""
+	_, err = p.AuthURL(memSession{})
+	assert.NoError(t, err)
+}
+
+func TestFacebookSignIn(t *testing.T) {
+	f := newFakeOIDC(t)
+	graph := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/me", r.URL.Path)
+		assert.Equal(t, "Bearer access", r.Header.Get("Authorization"))
+		_, _ = w.Write([]byte(`{"id":"7","name":"Тарас","email":"taras@example.com","picture":{"data":{"url":"https://example.com/t.png"}}}`))
+	}))
+	defer graph.Close()
+
+	p := NewFacebook(&config.Config{BaseURL: "http://localhost:8080", FacebookClientID: "client", FacebookClientSecret: "secret"})
+	assert.Equal(t, "http://localhost:8080/api/v1/auth/facebook/callback", p.cfg.RedirectURL)
+	p.cfg.Endpoint = oauth2.Endpoint{AuthURL: f.URL + "/authorize", TokenURL: f.URL + "/token", AuthStyle: oauth2.AuthStyleInHeader}
+	p.graphURL = graph.URL
+
+	sess := memSession{}
+	state := authorize(t, f, p, sess)
+
+	user, err := p.HandleCallback(context.Background(), sess, state, "good-code")
+	require.NoError(t, err)
+	assert.Equal(t, "7", user.ProviderID)
+	assert.Equal(t, "taras@example.com", user.Email)
+	assert.True(t, user.EmailVerified)
+	assert.Equal(t, "https://example.com/t.png", user.AvatarURL)
+}
+
+func TestRegistryFromConfig(t *testing.T) {
+	cfg := &config.Config{
+		BaseURL:        "http://localhost:8080",
+		GoogleClientID: "google-client",
+		OIDCName:       "corp",
+		OIDCIssuer:     "https://sso.example.com",
+		OIDCClientID:   "corp-client",
+	}
+
+	r, err := NewRegistryFromConfig(cfg)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"corp", "google"}, r.Names())
+
+	_, ok := r.Get("facebook")
+	assert.False(t, ok)
+
+	// Загальний OIDC не може перекрити вбудований провайдер
+	cfg.OIDCName = "google"
+	_, err = NewRegistryFromConfig(cfg)
+	assert.Error(t, err)
+
+	// Apple без ключа — помилка конфігурації, а не тихе вимкнення
+	_, err = NewRegistryFromConfig(&config.Config{AppleClientID: "apple-client", AppleTeamID: "team", AppleKeyID: "key"})
+	assert.ErrorContains(t, err, "APPLE_PRIVATE_KEY")
+}
+
+func TestAppleSignIn(t *testing.T) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	der, err := x509.MarshalPKCS8PrivateKey(key)
+	require.NoError(t, err)
+	// Ключ у змінній оточення зазвичай записаний з \n замість переносів
+	pemKey := strings.ReplaceAll(string(pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der})), "\n", `\n`)
+
+	p, err := NewApple(&config.Config{
+		BaseURL:         "http://localhost:8080",
+		AppleClientID:   "ua.pets.web",
+		AppleTeamID:     "TEAM123",
+		AppleKeyID:      "KEY123",
+		ApplePrivateKey: pemKey,
+	})
+	require.NoError(t, err)
+
+	// Ключі Apple віддає фейковий провайдер
+	f := newFakeOIDC(t)
+	p.verifier.keys = newKeySet(f.URL + "/jwks")
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.NoError(t, r.ParseForm())
+		assert.Empty(t, r.PostForm.Get("code_verifier"))
+
+		// client_secret — свіжий ES256 JWT, підписаний ключем команди
+		secret, err := jwt.Parse(r.PostForm.Get("client_secret"), func(tok *jwt.Token) (any, error) {
+			assert.Equal(t, "KEY123", tok.Header["kid"])
+			return &key.PublicKey, nil
+		}, jwt.WithValidMethods([]string{"ES256"}), jwt.WithIssuer("TEAM123"), jwt.WithAudience(appleIssuer))
+		require.NoError(t, err)
+		sub, _ := secret.Claims.GetSubject()
+		assert.Equal(t, "ua.pets.web", sub)
+
+		idToken := f.sign(t, f.key, jwt.MapClaims{
+			"iss": appleIssuer, "aud": "ua.pets.web", "sub": "apple-1", "nonce": f.nonce,
+			"exp": time.Now().Add(time.Hour).Unix(), "email": "relay@privaterelay.appleid.com", "email_verified": "true",
+		})
+
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(map[string]any{"access_token": "access", "token_type": "Bearer", "id_token": idToken})
+	}))
+	defer server.Close()
+	p.cfg.Endpoint.TokenURL = server.URL
+
+	sess := memSession{}
+	raw, err := p.AuthURL(sess)
+	require.NoError(t, err)
+	u, err := url.Parse(raw)
+	require.NoError(t, err)
+	assert.Equal(t, "form_post", u.Query().Get("response_mode"))
+	assert.Empty(t, u.Query().Get("code_challenge"))
+	f.nonce = u.Query().Get("nonce")
+	require.NotEmpty(t, f.nonce)
+
+	user, err := p.HandleCallback(context.Background(), sess, u.Query().Get("state"), "code")
+	require.NoError(t, err)
+	assert.Equal(t, "apple", user.Provider)
+	assert.Equal(t, "apple-1", user.ProviderID)
+	assert.Equal(t, "relay@privaterelay.appleid.com", user.Email)
+	assert.True(t, user.EmailVerified)
+}
@@ -0,0 +1,237 @@
+package oauth
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+
+	"golang.org/x/oauth2"
+)
+
+// Discovery is the subset of an OpenID Provider's metadata the sign-in
+// flow uses
+type Discovery struct {
+	Issuer                string `json:"issuer"`
+	AuthorizationEndpoint string `json:"authorization_endpoint"`
+	TokenEndpoint         string `json:"token_endpoint"`
+	UserinfoEndpoint      string `json:"userinfo_endpoint"`
+	JWKSURI               string `json:"jwks_uri"`
+}
+
+// OIDCProvider signs users in with any OpenID Connect provider. The
+// metadata is fetched from the issuer's discovery document on first use, so
+// an unreachable provider does not stop the API from starting.
+type OIDCProvider struct {
+	name   string
+	issuer string
+	flow   codeFlow
+
+	mu        sync.Mutex
+	cfg       *oauth2.Config
+	discovery *Discovery
+	verifier  *idTokenVerifier
+}
+
+// NewOIDC creates a provider that discovers its endpoints from issuer; cfg
+// carries the client credentials, redirect URL and scopes
+func NewOIDC(name, issuer string, cfg *oauth2.Config) *OIDCProvider {
+	return &OIDCProvider{
+		name:   name,
+		issuer: strings.TrimRight(issuer, "/"),
+		flow:   codeFlow{name: name, pkce: true, nonce: true},
+		cfg:    cfg,
+	}
+}
+
+// newStaticOIDC creates a provider with known metadata, skipping discovery
+func newStaticOIDC(name string, d *Discovery, cfg *oauth2.Config) *OIDCProvider {
+	p := NewOIDC(name, d.Issuer, cfg)
+	p.setDiscovery(d)
+	return p
+}
+
+// Name returns the route segment of the provider
+func (p *OIDCProvider) Name() string {
+	return p.name
+}
+
+// AuthURL starts a sign-in with state, PKCE and nonce
+func (p *OIDCProvider) AuthURL(sess Session) (string, error) {
+	cfg, _, _, err := p.config(context.Background())
+	if err != nil {
+		return "", err
+	}
+	return p.flow.start(sess, cfg), nil
+}
+
+// HandleCallback exchanges the code and reads the profile from the verified
+// ID token. The userinfo endpoint is only a fallback: for providers that
+// return no ID token, and to fill in an email the token leaves out.
+func (p *OIDCProvider) HandleCallback(ctx context.Context, sess Session, state, code string) (User, error) {
+	cfg, d, verifier, err := p.config(ctx)
+	if err != nil {
+		return User{}, err
+	}
+
+	tok, nonce, err := p.flow.exchange(ctx, sess, cfg, state, code)
+	if err != nil {
+		return User{}, err
+	}
+
+	raw, _ := tok.Extra("id_token").(string)
+	if raw == "" || verifier == nil {
+		return p.userinfo(ctx, d, tok, "")
+	}
+
+	// A token that fails verification is an error, never a reason to fall
+	// back: that would let a substituted token through
+	claims, err := verifier.verify(ctx, raw, nonce)
+	if err != nil {
+		return User{}, err
+	}
+	if claims.Email == "" && d.UserinfoEndpoint != "" {
+		return p.userinfo(ctx, d, tok, claims.Subject)
+	}
+
+	return claims.profile().user(p.name), nil
+}
+
+// userinfo loads the profile from the userinfo endpoint; when the ID token
+// was verified, sub must match it
+func (p *OIDCProvider) userinfo(ctx context.Context, d *Discovery, tok *oauth2.Token, sub string) (User, error) {
+	if d.UserinfoEndpoint == "" {
+		return User{}, errors.New("provider has no userinfo endpoint")
+	}
+
+	var claims profileClaims
+	if err := getJSON(ctx, d.UserinfoEndpoint, tok, &claims); err != nil {
+		return User{}, fmt.Errorf("failed to load profile: %w", err)
+	}
+	if claims.Sub == "" {
+		return User{}, errors.New("provider returned a profile without subject")
+	}
+	if sub != "" && claims.Sub != sub {
+		return User{}, errors.New("userinfo subject does not match id_token")
+	}
+
+	return claims.user(p.name), nil
+}
+
+// config returns the client config with endpoints filled in and the ID
+// token verifier, running discovery if it has not succeeded yet
+func (p *OIDCProvider) config(ctx context.Context) (*oauth2.Config, *Discovery, *idTokenVerifier, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.discovery == nil {
+		d, err := Discover(ctx, p.issuer)
+		if err != nil {
+			return nil, nil, nil, err
+		}
+		p.setDiscoveryLocked(d)
+	}
+	return p.cfg, p.discovery, p.verifier, nil
+}
+
+func (p *OIDCProvider) setDiscovery(d *Discovery) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.setDiscoveryLocked(d)
+}
+
+func (p *OIDCProvider) setDiscoveryLocked(d *Discovery) {
+	cfg := *p.cfg
+	cfg.Endpoint = oauth2.Endpoint{
+		AuthURL:  d.AuthorizationEndpoint,
+		TokenURL: d.TokenEndpoint,
+	}
+	p.cfg = &cfg
+	p.discovery = d
+
+	p.verifier = nil
+	if d.JWKSURI != "" {
+		p.verifier = &idTokenVerifier{
+			issuers:  []string{d.Issuer},
+			clientID: cfg.ClientID,
+			keys:     newKeySet(d.JWKSURI),
+		}
+	}
+}
+
+// Discover fetches the OpenID Provider metadata of issuer
+func Discover(ctx context.Context, issuer string) (*Discovery, error) {
+	issuer = strings.TrimRight(issuer, "/")
+
+	var d Discovery
+	if err := getJSON(ctx, issuer+"/.well-known/openid-configuration", nil, &d); err != nil {
+		return nil, fmt.Errorf("oidc discovery failed: %w", err)
+	}
+
+	// The document must describe the issuer it was fetched from, otherwise
+	// a compromised or misconfigured host could impersonate another one
+	if strings.TrimRight(d.Issuer, "/") != issuer {
+		return nil, fmt.Errorf("oidc discovery: issuer %q does not match %q", d.Issuer, issuer)
+	}
+	if d.AuthorizationEndpoint == "" || d.TokenEndpoint == "" {
+		return nil, errors.New("oidc discovery: authorization or token endpoint missing")
+	}
+
+	return &d, nil
+}
+
+// getJSON decodes a GET response, authorizing with tok when given
+func getJSON(ctx context.Context, url string, tok *oauth2.Token, v any) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/json")
+	if tok != nil {
+		tok.SetAuthHeader(req)
+	}
+
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("unexpected status %d from %s", resp.StatusCode, url)
+	}
+	return json.NewDecoder(resp.Body).Decode(v)
+}
+
+// profileClaims are the standard OpenID Connect profile claims
+type profileClaims struct {
+	Sub           string   `json:"sub"`
+	Email         string   `json:"email"`
+	EmailVerified flexBool `json:"email_verified"`
+	Name          string   `json:"name"`
+	Picture       string   `json:"picture"`
+}
+
+func (c profileClaims) user(provider string) User {
+	return User{
+		Provider:      provider,
+		ProviderID:    c.Sub,
+		Email:         c.Email,
+		EmailVerified: bool(c.EmailVerified),
+		Name:          c.Name,
+		AvatarURL:     c.Picture,
+	}
+}
+
+// flexBool accepts both true and "true"; some providers, Apple among them,
+// send boolean claims as strings
+type flexBool bool
+
+func (b *flexBool) UnmarshalJSON(data []byte) error {
+	s := strings.Trim(string(data), `"`)
+	*b = flexBool(s == "true")
+	return nil
+}
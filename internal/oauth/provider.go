@@ -0,0 +1,190 @@
+package oauth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"pets_rest/internal/config"
+	"pets_rest/pkg/helper"
+
+	"golang.org/x/oauth2"
+)
+
+const (
+	stateKey = "oauth_state:"
+	pkceKey  = "oauth_pkce:"
+	nonceKey = "oauth_nonce:"
+)
+
+var (
+	ErrInvalidState = errors.New("invalid state parameter")
+	ErrInvalidPKCE  = errors.New("invalid PKCE verifier")
+)
+
+// httpClient is used for every call to a provider: discovery, token
+// exchange and profile requests
+var httpClient = &http.Client{Timeout: 10 * time.Second}
+
+// Session is the part of a server-side session the sign-in flow needs;
+// both session.Session and session.Middleware satisfy it
+type Session interface {
+	Get(key any) any
+	Set(key, value any)
+	Delete(key any)
+}
+
+// Provider signs users in through an external identity provider
+type Provider interface {
+	// Name is the route segment of the provider, e.g. "google"
+	Name() string
+	// AuthURL stores the state of a new sign-in in the session and returns
+	// the provider URL to redirect the user to
+	AuthURL(sess Session) (string, error)
+	// HandleCallback checks the state, exchanges the code and returns the
+	// user's profile
+	HandleCallback(ctx context.Context, sess Session, state, code string) (User, error)
+}
+
+// Registry holds the enabled providers by name
+type Registry struct {
+	providers map[string]Provider
+}
+
+func NewRegistry(providers ...Provider) *Registry {
+	r := &Registry{providers: make(map[string]Provider)}
+	for _, p := range providers {
+		r.Register(p)
+	}
+	return r
+}
+
+// NewRegistryFromConfig enables every provider whose client ID is configured
+func NewRegistryFromConfig(cfg *config.Config) (*Registry, error) {
+	r := NewRegistry()
+
+	if cfg.GoogleClientID != "" {
+		r.Register(NewGoogle(cfg))
+	}
+	if cfg.FacebookClientID != "" {
+		r.Register(NewFacebook(cfg))
+	}
+	if cfg.AppleClientID != "" {
+		apple, err := NewApple(cfg)
+		if err != nil {
+			return nil, err
+		}
+		r.Register(apple)
+	}
+	if cfg.OIDCClientID != "" {
+		if _, ok := r.Get(cfg.OIDCName); ok {
+			return nil, fmt.Errorf("oidc provider name %q is already taken", cfg.OIDCName)
+		}
+		r.Register(NewOIDC(cfg.OIDCName, cfg.OIDCIssuer, &oauth2.Config{
+			ClientID:     cfg.OIDCClientID,
+			ClientSecret: cfg.OIDCClientSecret,
+			RedirectURL:  CallbackURL(cfg, cfg.OIDCName),
+			Scopes:       []string{"openid", "email", "profile"},
+		}))
+	}
+
+	return r, nil
+}
+
+// Register adds a provider, replacing one with the same name
+func (r *Registry) Register(p Provider) {
+	r.providers[p.Name()] = p
+}
+
+// Get returns the provider with the given name
+func (r *Registry) Get(name string) (Provider, bool) {
+	p, ok := r.providers[name]
+	return p, ok
+}
+
+// Names returns the names of the enabled providers in alphabetical order
+func (r *Registry) Names() []string {
+	names := make([]string, 0, len(r.providers))
+	for name := range r.providers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// CallbackURL is the redirect URL registered with a provider
+func CallbackURL(cfg *config.Config, name string) string {
+	return strings.TrimRight(cfg.BaseURL, "/") + "/api/v1/auth/" + name + "/callback"
+}
+
+// codeFlow is the authorization code flow shared by all providers: a random
+// state against CSRF, where the provider supports it PKCE against code
+// interception, and for OpenID Connect a nonce that ties the ID token to
+// this session. Session keys are scoped by provider so that sign-ins
+// started with two providers do not clobber each other.
+type codeFlow struct {
+	name  string
+	pkce  bool
+	nonce bool
+}
+
+// start stores a fresh state (PKCE verifier, nonce) and returns the auth URL
+func (f codeFlow) start(sess Session, cfg *oauth2.Config, opts ...oauth2.AuthCodeOption) string {
+	state := NewState()
+	sess.Set(stateKey+f.name, state)
+
+	if f.nonce {
+		nonce := NewState()
+		sess.Set(nonceKey+f.name, nonce)
+		opts = append(opts, oauth2.SetAuthURLParam("nonce", nonce))
+	}
+
+	if f.pkce {
+		ver, ch := NewPKCE()
+		sess.Set(pkceKey+f.name, ver)
+		opts = append(opts,
+			oauth2.SetAuthURLParam("code_challenge", ch),
+			oauth2.SetAuthURLParam("code_challenge_method", "S256"),
+		)
+	}
+
+	return cfg.AuthCodeURL(state, opts...)
+}
+
+// exchange checks the state and trades the code for a token, returning it
+// with the nonce the ID token must carry; the stored values are single use
+func (f codeFlow) exchange(ctx context.Context, sess Session, cfg *oauth2.Config, state, code string) (*oauth2.Token, string, error) {
+	expected := helper.GetString(sess.Get(stateKey + f.name))
+	ver := helper.GetString(sess.Get(pkceKey + f.name))
+	nonce := helper.GetString(sess.Get(nonceKey + f.name))
+	sess.Delete(stateKey + f.name)
+	sess.Delete(pkceKey + f.name)
+	sess.Delete(nonceKey + f.name)
+
+	if state == "" || state != expected {
+		return nil, "", ErrInvalidState
+	}
+
+	var opts []oauth2.AuthCodeOption
+	if f.pkce {
+		if ver == "" {
+			return nil, "", ErrInvalidPKCE
+		}
+		opts = append(opts, oauth2.SetAuthURLParam("code_verifier", ver))
+	}
+
+	tok, err := cfg.Exchange(clientContext(ctx), code, opts...)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to exchange code: %w", err)
+	}
+	return tok, nonce, nil
+}
+
+// clientContext makes the oauth2 package use httpClient
+func clientContext(ctx context.Context) context.Context {
+	return context.WithValue(ctx, oauth2.HTTPClient, httpClient)
+}
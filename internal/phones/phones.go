@@ -0,0 +1,201 @@
+// Package phones verifies users' phone numbers with one-time codes sent by SMS
+package phones
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"math/big"
+	"time"
+
+	"pets_rest/internal/config"
+	"pets_rest/internal/database"
+	"pets_rest/internal/sms"
+)
+
+const (
+	codeLength = 6
+	// CodeTTL is how long a sent code can be entered
+	CodeTTL = 10 * time.Minute
+	// resendCooldown is the pause before another code can be requested
+	resendCooldown = time.Minute
+	// maxAttempts is how many guesses one code allows
+	maxAttempts = 5
+	// maxCodesPerDay limits codes per user and per number, so the endpoint
+	// cannot be used to pump SMS to one number from many accounts
+	maxCodesPerDay = 5
+)
+
+var (
+	ErrAlreadyVerified = errors.New("phone number is already verified")
+	ErrNoActiveCode    = errors.New("no active code, request a new one")
+	ErrWrongCode       = errors.New("wrong code")
+)
+
+// RateLimitError is returned when a code was requested too often
+type RateLimitError struct {
+	RetryAfter time.Duration
+}
+
+func (e *RateLimitError) Error() string {
+	return fmt.Sprintf("too many codes requested, retry in %s", e.RetryAfter.Round(time.Second))
+}
+
+var messages = map[string]string{
+	"uk": "Код підтвердження: %s. Нікому його не повідомляйте.",
+	"en": "Your verification code: %s. Do not share it with anyone.",
+}
+
+// Store keeps the codes and the verified numbers of users
+type Store interface {
+	// Tx runs fn with a Store whose methods share one transaction
+	Tx(ctx context.Context, fn func(Store) error) error
+	// LockUser returns a user and locks the row until the transaction ends
+	LockUser(ctx context.Context, userID int) (*database.User, error)
+	// LatestCode returns the most recent code sent for a user
+	LatestCode(ctx context.Context, userID int) (*database.PhoneVerification, error)
+	// CountCodesSince returns how many codes were sent to a user and to a
+	// phone number since the given time
+	CountCodesSince(ctx context.Context, userID int, phone string, since time.Time) (byUser, byPhone int, err error)
+	CreateCode(ctx context.Context, v *database.PhoneVerification) error
+	// Attempt counts a guess against the user's latest active code and
+	// returns it, or sql.ErrNoRows when there is none with attempts left
+	Attempt(ctx context.Context, userID, maxAttempts int) (*database.PhoneVerification, error)
+	// SetVerifiedPhone marks the code used and stores its number as the
+	// user's verified phone; it must run inside Tx
+	SetVerifiedPhone(ctx context.Context, v *database.PhoneVerification) (*database.User, error)
+}
+
+// Service sends and checks phone verification codes
+type Service struct {
+	store  Store
+	secret []byte
+	sender sms.Sender
+}
+
+// NewService creates a new phone verification service
+func NewService(db *database.DB, cfg *config.Config, sender sms.Sender) *Service {
+	return &Service{
+		store:  newStore(db),
+		secret: []byte(cfg.JWTSecret),
+		sender: sender,
+	}
+}
+
+// SendCode sends a new code to phone for the user and returns the number
+// in E.164 format
+func (s *Service) SendCode(ctx context.Context, userID int, phone string) (string, error) {
+	phone, err := sms.NormalizePhone(phone)
+	if err != nil {
+		return "", err
+	}
+
+	code := newCode()
+	var locale string
+
+	// The user row lock serializes requests of one user, so two parallel
+	// requests cannot both pass the cooldown
+	err = s.store.Tx(ctx, func(store Store) error {
+		user, err := store.LockUser(ctx, userID)
+		if err != nil {
+			return err
+		}
+		if user.PhoneVerified && user.Phone != nil && *user.Phone == phone {
+			return ErrAlreadyVerified
+		}
+		locale = user.Locale
+
+		now := time.Now()
+
+		latest, err := store.LatestCode(ctx, userID)
+		if err != nil && !errors.Is(err, sql.ErrNoRows) {
+			return err
+		}
+		if latest != nil && now.Sub(latest.CreatedAt) < resendCooldown {
+			return &RateLimitError{RetryAfter: resendCooldown - now.Sub(latest.CreatedAt)}
+		}
+
+		byUser, byPhone, err := store.CountCodesSince(ctx, userID, phone, now.Add(-24*time.Hour))
+		if err != nil {
+			return err
+		}
+		if byUser >= maxCodesPerDay || byPhone >= maxCodesPerDay {
+			return &RateLimitError{RetryAfter: 24 * time.Hour}
+		}
+
+		return store.CreateCode(ctx, &database.PhoneVerification{
+			UserID:    userID,
+			Phone:     phone,
+			CodeHash:  s.hash(phone, code),
+			ExpiresAt: now.Add(CodeTTL),
+		})
+	})
+	if err != nil {
+		return "", err
+	}
+
+	text, ok := messages[locale]
+	if !ok {
+		text = messages["uk"]
+	}
+	if err := s.sender.Send(ctx, phone, fmt.Sprintf(text, code)); err != nil {
+		return "", fmt.Errorf("failed to send code: %w", err)
+	}
+
+	return phone, nil
+}
+
+// Confirm checks a code against the user's latest one and, when it
+// matches, stores the number as the user's verified phone
+func (s *Service) Confirm(ctx context.Context, userID int, code string) (*database.User, error) {
+	// The attempt is counted outside the transaction below so that a wrong
+	// guess is not rolled back
+	v, err := s.store.Attempt(ctx, userID, maxAttempts)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrNoActiveCode
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if !hmac.Equal([]byte(s.hash(v.Phone, code)), []byte(v.CodeHash)) {
+		return nil, ErrWrongCode
+	}
+
+	var user *database.User
+	err = s.store.Tx(ctx, func(store Store) error {
+		user, err = store.SetVerifiedPhone(ctx, v)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return user, nil
+}
+
+// hash binds a code to the number it was sent to; codes are short, so the
+// key keeps a leaked table from being enough to recover them
+func (s *Service) hash(phone, code string) string {
+	mac := hmac.New(sha256.New, s.secret)
+	mac.Write([]byte(phone + ":" + code))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// newCode returns a random numeric code
+func newCode() string {
+	max := big.NewInt(1)
+	for range codeLength {
+		max.Mul(max, big.NewInt(10))
+	}
+	n, err := rand.Int(rand.Reader, max)
+	if err != nil {
+		panic(err)
+	}
+	return fmt.Sprintf("%0*d", codeLength, n)
+}
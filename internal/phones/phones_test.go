@@ -0,0 +1,335 @@
+package phones
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"regexp"
+	"sync"
+	"testing"
+	"time"
+
+	"pets_rest/internal/database"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeSender запам'ятовує надіслані SMS
+type fakeSender struct {
+	mu   sync.Mutex
+	sent []sentSMS
+	err  error
+}
+
+type sentSMS struct {
+	phone, text string
+}
+
+func (f *fakeSender) Send(_ context.Context, phone, text string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.err != nil {
+		return f.err
+	}
+	f.sent = append(f.sent, sentSMS{phone, text})
+	return nil
+}
+
+var codePattern = regexp.MustCompile(`\d{6}`)
+
+// lastCode дістає код з останнього SMS
+func (f *fakeSender) lastCode(t *testing.T) string {
+	t.Helper()
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	require.NotEmpty(t, f.sent)
+	code := codePattern.FindString(f.sent[len(f.sent)-1].text)
+	require.NotEmpty(t, code)
+	return code
+}
+
+// fakeStore тримає користувачів і коди в пам'яті та повторює семантику
+// запитів PhoneVerificationRepository
+type fakeStore struct {
+	mu    sync.Mutex
+	users map[int]*database.User
+	codes []*database.PhoneVerification
+}
+
+func newFakeStore(users ...*database.User) *fakeStore {
+	f := &fakeStore{users: make(map[int]*database.User)}
+	for _, u := range users {
+		f.users[u.ID] = u
+	}
+	return f
+}
+
+func (f *fakeStore) Tx(_ context.Context, fn func(Store) error) error {
+	return fn(f)
+}
+
+func (f *fakeStore) LockUser(_ context.Context, userID int) (*database.User, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	user, ok := f.users[userID]
+	if !ok {
+		return nil, sql.ErrNoRows
+	}
+	copied := *user
+	return &copied, nil
+}
+
+func (f *fakeStore) latest(userID int) *database.PhoneVerification {
+	var latest *database.PhoneVerification
+	for _, v := range f.codes {
+		if v.UserID == userID {
+			latest = v
+		}
+	}
+	return latest
+}
+
+func (f *fakeStore) LatestCode(_ context.Context, userID int) (*database.PhoneVerification, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if v := f.latest(userID); v != nil {
+		copied := *v
+		return &copied, nil
+	}
+	return nil, sql.ErrNoRows
+}
+
+func (f *fakeStore) CountCodesSince(_ context.Context, userID int, phone string, since time.Time) (byUser, byPhone int, err error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for _, v := range f.codes {
+		if v.CreatedAt.Before(since) {
+			continue
+		}
+		if v.UserID == userID {
+			byUser++
+		}
+		if v.Phone == phone {
+			byPhone++
+		}
+	}
+	return byUser, byPhone, nil
+}
+
+func (f *fakeStore) CreateCode(_ context.Context, v *database.PhoneVerification) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	v.ID = len(f.codes) + 1
+	v.CreatedAt = time.Now()
+	copied := *v
+	f.codes = append(f.codes, &copied)
+	return nil
+}
+
+func (f *fakeStore) Attempt(_ context.Context, userID, maxAttempts int) (*database.PhoneVerification, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	v := f.latest(userID)
+	if v == nil || v.VerifiedAt != nil || !v.ExpiresAt.After(time.Now()) || v.Attempts >= maxAttempts {
+		return nil, sql.ErrNoRows
+	}
+	v.Attempts++
+	copied := *v
+	return &copied, nil
+}
+
+func (f *fakeStore) SetVerifiedPhone(_ context.Context, v *database.PhoneVerification) (*database.User, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	now := time.Now()
+	f.codes[v.ID-1].VerifiedAt = &now
+
+	user := f.users[v.UserID]
+	phone := v.Phone
+	user.Phone = &phone
+	user.PhoneVerified = true
+	copied := *user
+	return &copied, nil
+}
+
+// age зсуває час створення всіх кодів у минуле
+func (f *fakeStore) age(d time.Duration) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for _, v := range f.codes {
+		v.CreatedAt = v.CreatedAt.Add(-d)
+	}
+}
+
+func newTestService(users ...*database.User) (*Service, *fakeStore, *fakeSender) {
+	store := newFakeStore(users...)
+	sender := &fakeSender{}
+	return &Service{store: store, secret: []byte("secret"), sender: sender}, store, sender
+}
+
+func TestSendCode(t *testing.T) {
+	s, store, sender := newTestService(&database.User{ID: 1, Locale: "en"})
+
+	phone, err := s.SendCode(context.Background(), 1, "067 123-45-67")
+	require.NoError(t, err)
+	assert.Equal(t, "+380671234567", phone)
+
+	require.Len(t, sender.sent, 1)
+	assert.Equal(t, "+380671234567", sender.sent[0].phone)
+	assert.Contains(t, sender.sent[0].text, "verification code")
+
+	require.Len(t, store.codes, 1)
+	assert.WithinDuration(t, time.Now().Add(CodeTTL), store.codes[0].ExpiresAt, time.Second)
+}
+
+func TestSendCodeHashesCode(t *testing.T) {
+	s, store, sender := newTestService(&database.User{ID: 1})
+
+	_, err := s.SendCode(context.Background(), 1, "+380671234567")
+	require.NoError(t, err)
+	code := sender.lastCode(t)
+
+	// У базі лише HMAC, прив'язаний до номера і секрету
+	stored := store.codes[0].CodeHash
+	assert.NotContains(t, stored, code)
+	assert.Equal(t, s.hash("+380671234567", code), stored)
+	assert.NotEqual(t, s.hash("+380501234567", code), stored)
+
+	other := &Service{secret: []byte("other")}
+	assert.NotEqual(t, other.hash("+380671234567", code), stored)
+}
+
+func TestSendCodeCooldown(t *testing.T) {
+	s, store, _ := newTestService(&database.User{ID: 1})
+	ctx := context.Background()
+
+	_, err := s.SendCode(ctx, 1, "+380671234567")
+	require.NoError(t, err)
+
+	_, err = s.SendCode(ctx, 1, "+380671234567")
+	var limit *RateLimitError
+	require.ErrorAs(t, err, &limit)
+	assert.Greater(t, limit.RetryAfter, time.Duration(0))
+	assert.LessOrEqual(t, limit.RetryAfter, resendCooldown)
+
+	// Після паузи новий код можна запросити
+	store.age(resendCooldown)
+	_, err = s.SendCode(ctx, 1, "+380671234567")
+	assert.NoError(t, err)
+}
+
+func TestSendCodeDailyCaps(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("на користувача", func(t *testing.T) {
+		s, store, _ := newTestService(&database.User{ID: 1})
+		for range maxCodesPerDay {
+			_, err := s.SendCode(ctx, 1, "+380671234567")
+			require.NoError(t, err)
+			store.age(resendCooldown)
+		}
+
+		// Інший номер не обходить ліміт користувача
+		_, err := s.SendCode(ctx, 1, "+380501234567")
+		var limit *RateLimitError
+		require.ErrorAs(t, err, &limit)
+		assert.Equal(t, 24*time.Hour, limit.RetryAfter)
+
+		// Коди, старші за добу, не рахуються
+		store.age(24 * time.Hour)
+		_, err = s.SendCode(ctx, 1, "+380501234567")
+		assert.NoError(t, err)
+	})
+
+	t.Run("на номер", func(t *testing.T) {
+		users := []*database.User{{ID: 1}, {ID: 2}, {ID: 3}, {ID: 4}, {ID: 5}, {ID: 6}}
+		s, _, sender := newTestService(users...)
+		for id := 1; id <= maxCodesPerDay; id++ {
+			_, err := s.SendCode(ctx, id, "+380671234567")
+			require.NoError(t, err)
+		}
+
+		// Новий акаунт не може накачувати SMS на той самий номер
+		_, err := s.SendCode(ctx, 6, "+380671234567")
+		var limit *RateLimitError
+		require.ErrorAs(t, err, &limit)
+		assert.Len(t, sender.sent, maxCodesPerDay)
+	})
+}
+
+func TestSendCodeAlreadyVerified(t *testing.T) {
+	phone := "+380671234567"
+	s, store, sender := newTestService(&database.User{ID: 1, Phone: &phone, PhoneVerified: true})
+
+	_, err := s.SendCode(context.Background(), 1, "0671234567")
+	assert.ErrorIs(t, err, ErrAlreadyVerified)
+	assert.Empty(t, store.codes)
+	assert.Empty(t, sender.sent)
+}
+
+func TestSendCodeSenderFailure(t *testing.T) {
+	s, _, sender := newTestService(&database.User{ID: 1})
+	sender.err = errors.New("gateway down")
+
+	_, err := s.SendCode(context.Background(), 1, "+380671234567")
+	assert.ErrorIs(t, err, sender.err)
+}
+
+func TestConfirm(t *testing.T) {
+	s, _, sender := newTestService(&database.User{ID: 1})
+	ctx := context.Background()
+
+	_, err := s.SendCode(ctx, 1, "+380671234567")
+	require.NoError(t, err)
+
+	user, err := s.Confirm(ctx, 1, sender.lastCode(t))
+	require.NoError(t, err)
+	assert.True(t, user.PhoneVerified)
+	assert.Equal(t, "+380671234567", *user.Phone)
+
+	// Використаний код не спрацює вдруге
+	_, err = s.Confirm(ctx, 1, sender.lastCode(t))
+	assert.ErrorIs(t, err, ErrNoActiveCode)
+}
+
+func TestConfirmExhaustsAttempts(t *testing.T) {
+	s, _, sender := newTestService(&database.User{ID: 1})
+	ctx := context.Background()
+
+	_, err := s.SendCode(ctx, 1, "+380671234567")
+	require.NoError(t, err)
+	code := sender.lastCode(t)
+	wrong := "000000"
+	if code == wrong {
+		wrong = "111111"
+	}
+
+	for range maxAttempts {
+		_, err := s.Confirm(ctx, 1, wrong)
+		assert.ErrorIs(t, err, ErrWrongCode)
+	}
+
+	// Після вичерпання спроб не підходить навіть правильний код
+	_, err = s.Confirm(ctx, 1, code)
+	assert.ErrorIs(t, err, ErrNoActiveCode)
+}
+
+func TestConfirmExpiredCode(t *testing.T) {
+	s, store, sender := newTestService(&database.User{ID: 1})
+	ctx := context.Background()
+
+	_, err := s.SendCode(ctx, 1, "+380671234567")
+	require.NoError(t, err)
+	store.codes[0].ExpiresAt = time.Now().Add(-time.Second)
+
+	_, err = s.Confirm(ctx, 1, sender.lastCode(t))
+	assert.ErrorIs(t, err, ErrNoActiveCode)
+}
+
+func TestConfirmWithoutCode(t *testing.T) {
+	s, _, _ := newTestService(&database.User{ID: 1})
+
+	_, err := s.Confirm(context.Background(), 1, "123456")
+	assert.ErrorIs(t, err, ErrNoActiveCode)
+}
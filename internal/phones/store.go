@@ -0,0 +1,81 @@
+package phones
+
+import (
+	"context"
+	"time"
+
+	"pets_rest/internal/audit"
+	"pets_rest/internal/database"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// dbStore is the database implementation of Store; verifying a number is
+// recorded in the audit log
+type dbStore struct {
+	db            *database.DB
+	users         *database.UserRepository
+	verifications *database.PhoneVerificationRepository
+	audit         *audit.Log
+}
+
+func newStore(db *database.DB) *dbStore {
+	return &dbStore{
+		db:            db,
+		users:         database.NewUserRepository(db),
+		verifications: database.NewPhoneVerificationRepository(db),
+		audit:         audit.NewLog(db),
+	}
+}
+
+func (s *dbStore) Tx(ctx context.Context, fn func(Store) error) error {
+	return s.db.WithTx(ctx, func(tx *sqlx.Tx) error {
+		return fn(&dbStore{
+			db:            s.db,
+			users:         s.users.WithTx(tx),
+			verifications: s.verifications.WithTx(tx),
+			audit:         s.audit.WithTx(tx),
+		})
+	})
+}
+
+func (s *dbStore) LockUser(ctx context.Context, userID int) (*database.User, error) {
+	return s.users.GetByIDForUpdate(ctx, userID)
+}
+
+func (s *dbStore) LatestCode(ctx context.Context, userID int) (*database.PhoneVerification, error) {
+	return s.verifications.Latest(ctx, userID)
+}
+
+func (s *dbStore) CountCodesSince(ctx context.Context, userID int, phone string, since time.Time) (int, int, error) {
+	return s.verifications.CountSince(ctx, userID, phone, since)
+}
+
+func (s *dbStore) CreateCode(ctx context.Context, v *database.PhoneVerification) error {
+	return s.verifications.Create(ctx, v)
+}
+
+func (s *dbStore) Attempt(ctx context.Context, userID, maxAttempts int) (*database.PhoneVerification, error) {
+	return s.verifications.Attempt(ctx, userID, maxAttempts)
+}
+
+func (s *dbStore) SetVerifiedPhone(ctx context.Context, v *database.PhoneVerification) (*database.User, error) {
+	if err := s.verifications.MarkVerified(ctx, v.ID); err != nil {
+		return nil, err
+	}
+	before, err := s.users.GetByIDForUpdate(ctx, v.UserID)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.users.SetVerifiedPhone(ctx, v.UserID, v.Phone); err != nil {
+		return nil, err
+	}
+	user, err := s.users.GetByID(ctx, v.UserID)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.audit.Record(ctx, database.AuditEntityUser, v.UserID, before, user); err != nil {
+		return nil, err
+	}
+	return user, nil
+}
@@ -0,0 +1,59 @@
+// Package policy decides who may act on which resource, so that handlers
+// apply the same rules for owners and staff
+package policy
+
+import "pets_rest/internal/database"
+
+// Actor is the authenticated user a permission is checked for
+type Actor struct {
+	UserID int
+	Role   database.Role
+}
+
+// Is reports whether the actor has one of the roles
+func (a Actor) Is(roles ...database.Role) bool {
+	for _, role := range roles {
+		if a.Role == role {
+			return true
+		}
+	}
+	return false
+}
+
+// IsStaff reports whether the actor moderates the platform
+func (a Actor) IsStaff() bool {
+	return a.Is(database.RoleModerator, database.RoleAdmin)
+}
+
+// CanEditListing lets the owner or a moderator change a listing and its
+// placements, short links and contact settings
+func CanEditListing(a Actor, listing *database.Listing) bool {
+	return listing.UserID == a.UserID || a.IsStaff()
+}
+
+// CanRevertListing lets only the owner bring back an earlier revision;
+// moderators review the history but edit the current version
+func CanRevertListing(a Actor, listing *database.Listing) bool {
+	return listing.UserID == a.UserID
+}
+
+// CanManageWebhook lets the owner or an administrator change an endpoint and
+// see its deliveries, which carry partner secrets and payloads
+func CanManageWebhook(a Actor, endpoint *database.WebhookEndpoint) bool {
+	return endpoint.UserID == a.UserID || a.Is(database.RoleAdmin)
+}
+
+// CanManageAlert lets the owner or an administrator remove a subscription
+func CanManageAlert(a Actor, sub *database.AlertSubscription) bool {
+	return sub.UserID == a.UserID || a.Is(database.RoleAdmin)
+}
+
+// CanBan lets staff ban regular users and shelters; other staff can only be
+// banned by an administrator, and nobody can ban themselves
+func CanBan(a Actor, user *database.User) bool {
+	if !a.IsStaff() || user.ID == a.UserID {
+		return false
+	}
+	target := Actor{UserID: user.ID, Role: user.Role}
+	return !target.IsStaff() || a.Is(database.RoleAdmin)
+}
@@ -0,0 +1,55 @@
+package policy
+
+import (
+	"testing"
+
+	"pets_rest/internal/database"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCanEditListing(t *testing.T) {
+	listing := &database.Listing{UserID: 7}
+
+	assert.True(t, CanEditListing(Actor{UserID: 7, Role: database.RoleUser}, listing))
+	assert.True(t, CanEditListing(Actor{UserID: 8, Role: database.RoleModerator}, listing))
+	assert.True(t, CanEditListing(Actor{UserID: 8, Role: database.RoleAdmin}, listing))
+
+	// Притулок не редагує чужі оголошення, як і анонімний запит
+	assert.False(t, CanEditListing(Actor{UserID: 8, Role: database.RoleShelter}, listing))
+	assert.False(t, CanEditListing(Actor{}, listing))
+}
+
+func TestCanRevertListing(t *testing.T) {
+	listing := &database.Listing{UserID: 7}
+
+	assert.True(t, CanRevertListing(Actor{UserID: 7, Role: database.RoleUser}, listing))
+
+	// Модератор бачить історію, але не повертає чужі ревізії
+	assert.False(t, CanRevertListing(Actor{UserID: 8, Role: database.RoleModerator}, listing))
+	assert.False(t, CanRevertListing(Actor{}, listing))
+}
+
+func TestCanManageWebhook(t *testing.T) {
+	endpoint := &database.WebhookEndpoint{UserID: 7}
+
+	assert.True(t, CanManageWebhook(Actor{UserID: 7, Role: database.RoleShelter}, endpoint))
+	assert.True(t, CanManageWebhook(Actor{UserID: 8, Role: database.RoleAdmin}, endpoint))
+
+	// Модератор не бачить секретів і доставок партнерів
+	assert.False(t, CanManageWebhook(Actor{UserID: 8, Role: database.RoleModerator}, endpoint))
+}
+
+func TestCanBan(t *testing.T) {
+	moderator := Actor{UserID: 1, Role: database.RoleModerator}
+	admin := Actor{UserID: 2, Role: database.RoleAdmin}
+
+	assert.True(t, CanBan(moderator, &database.User{ID: 7, Role: database.RoleUser}))
+	assert.True(t, CanBan(moderator, &database.User{ID: 7, Role: database.RoleShelter}))
+	assert.True(t, CanBan(admin, &database.User{ID: 7, Role: database.RoleModerator}))
+
+	// Модератор не блокує колег, а ніхто не блокує сам себе
+	assert.False(t, CanBan(moderator, &database.User{ID: 8, Role: database.RoleModerator}))
+	assert.False(t, CanBan(admin, &database.User{ID: 2, Role: database.RoleAdmin}))
+	assert.False(t, CanBan(Actor{UserID: 3, Role: database.RoleUser}, &database.User{ID: 7, Role: database.RoleUser}))
+}
@@ -0,0 +1,188 @@
+package privacy
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"strings"
+	"time"
+
+	"pets_rest/internal/database"
+)
+
+const (
+	// maxPhotoSize and maxPhotosSize bound one downloaded photo and all of
+	// them; photos over the limits are listed in photos.json but left out
+	maxPhotoSize  = 10 << 20
+	maxPhotosSize = 200 << 20
+	photoTimeout  = 30 * time.Second
+)
+
+var errPhotosTooLarge = errors.New("export size limit reached")
+
+// photoExtensions are the usual extensions of common image types, which
+// mime.ExtensionsByType does not pick
+var photoExtensions = map[string]string{
+	"image/jpeg": ".jpg",
+	"image/png":  ".png",
+	"image/gif":  ".gif",
+	"image/webp": ".webp",
+}
+
+// Photo is an entry of photos.json: where a photo came from and the file
+// it was saved as, or why it could not be downloaded
+type Photo struct {
+	URL   string `json:"url"`
+	File  string `json:"file,omitempty"`
+	Error string `json:"error,omitempty"`
+}
+
+// buildArchive writes the data as JSON files together with the photos of
+// the listings and messages into a ZIP archive
+func buildArchive(ctx context.Context, client *http.Client, data *database.UserData) ([]byte, error) {
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+
+	files := []struct {
+		name string
+		v    any
+	}{
+		{"profile.json", map[string]any{"user": data.User, "identities": data.Identities}},
+		{"listings.json", data.Listings},
+		{"events.json", data.Events},
+		{"messages.json", map[string]any{"conversations": data.Conversations, "messages": data.Messages}},
+	}
+	for _, f := range files {
+		if err := writeJSON(zw, f.name, f.v); err != nil {
+			return nil, err
+		}
+	}
+
+	photos := []Photo{}
+	var total int64
+	addPhoto := func(prefix string, id int, urls []string) error {
+		for i, url := range urls {
+			photo := Photo{URL: url}
+			name := fmt.Sprintf("photos/%s-%d-%d", prefix, id, i+1)
+
+			saved, err := writePhoto(ctx, client, zw, name, url, maxPhotosSize-total)
+			switch {
+			case err == nil:
+				photo.File = saved.name
+				total += saved.size
+			case ctx.Err() != nil:
+				return ctx.Err()
+			default:
+				photo.Error = err.Error()
+			}
+			photos = append(photos, photo)
+		}
+		return nil
+	}
+
+	for _, l := range data.Listings {
+		if err := addPhoto("listing", l.ID, l.Images); err != nil {
+			return nil, err
+		}
+	}
+	for _, m := range data.Messages {
+		if err := addPhoto("message", m.ID, m.Images); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := writeJSON(zw, "photos.json", photos); err != nil {
+		return nil, err
+	}
+	if err := zw.Close(); err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
+
+func writeJSON(zw *zip.Writer, name string, v any) error {
+	w, err := zw.Create(name)
+	if err != nil {
+		return err
+	}
+
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(v)
+}
+
+// savedPhoto is the file name and size of a photo written to the archive
+type savedPhoto struct {
+	name string
+	size int64
+}
+
+// writePhoto downloads an image into the archive as name with an extension
+// matching its content type; it fails without writing anything when the
+// image is larger than maxPhotoSize or the remaining budget
+func writePhoto(ctx context.Context, client *http.Client, zw *zip.Writer, name, url string, budget int64) (*savedPhoto, error) {
+	if budget <= 0 {
+		return nil, errPhotosTooLarge
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, photoTimeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status %d", resp.StatusCode)
+	}
+
+	contentType, _, _ := mime.ParseMediaType(resp.Header.Get("Content-Type"))
+	if !strings.HasPrefix(contentType, "image/") {
+		return nil, fmt.Errorf("not an image: %q", contentType)
+	}
+
+	// The photo is read whole first, so that an oversized one does not
+	// leave a truncated file in the archive
+	limit := min(int64(maxPhotoSize), budget)
+	body, err := io.ReadAll(io.LimitReader(resp.Body, limit+1))
+	if err != nil {
+		return nil, err
+	}
+	if int64(len(body)) > limit {
+		if limit < maxPhotoSize {
+			return nil, errPhotosTooLarge
+		}
+		return nil, fmt.Errorf("larger than %d bytes", maxPhotoSize)
+	}
+
+	ext := photoExtensions[contentType]
+	if ext == "" {
+		if exts, _ := mime.ExtensionsByType(contentType); len(exts) > 0 {
+			ext = exts[0]
+		}
+	}
+
+	w, err := zw.Create(name + ext)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := w.Write(body); err != nil {
+		return nil, err
+	}
+
+	return &savedPhoto{name: name + ext, size: int64(len(body))}, nil
+}
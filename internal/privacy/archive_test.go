@@ -0,0 +1,98 @@
+package privacy
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"pets_rest/internal/database"
+	"pets_rest/internal/webhooks"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBuildArchive(t *testing.T) {
+	png := []byte("\x89PNG\r\n\x1a\nimage")
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/cat.png":
+			w.Header().Set("Content-Type", "image/png")
+			_, _ = w.Write(png)
+		case "/page.html":
+			w.Header().Set("Content-Type", "text/html")
+			_, _ = w.Write([]byte("<html></html>"))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	data := &database.UserData{
+		User:     &database.User{ID: 7, Email: "owner@example.com"},
+		Listings: []*database.Listing{{ID: 3, Title: "Рудий кіт", Images: []string{server.URL + "/cat.png", server.URL + "/gone.png"}}},
+		Messages: []*database.Message{{ID: 9, Body: "Бачила його біля парку", Images: []string{server.URL + "/page.html"}}},
+	}
+
+	archive, err := buildArchive(context.Background(), webhooks.NewClient(true), data)
+	require.NoError(t, err)
+
+	files := readArchive(t, archive)
+	for _, name := range []string{"profile.json", "listings.json", "events.json", "messages.json", "photos.json"} {
+		assert.Contains(t, files, name)
+	}
+	assert.Contains(t, string(files["profile.json"]), "owner@example.com")
+	assert.Equal(t, png, files["photos/listing-3-1.png"])
+
+	// Фото, які не вдалося завантажити, лишаються в переліку з причиною
+	var photos []Photo
+	require.NoError(t, json.Unmarshal(files["photos.json"], &photos))
+	require.Len(t, photos, 3)
+	assert.Equal(t, "photos/listing-3-1.png", photos[0].File)
+	assert.Equal(t, "unexpected status 404", photos[1].Error)
+	assert.Empty(t, photos[2].File)
+	assert.Contains(t, photos[2].Error, "not an image")
+}
+
+func TestBuildArchiveRefusesPrivateAddresses(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "image/png")
+	}))
+	defer server.Close()
+
+	data := &database.UserData{
+		User:     &database.User{ID: 7},
+		Listings: []*database.Listing{{ID: 3, Images: []string{server.URL + "/cat.png"}}},
+	}
+
+	// Адреси оголошень задають користувачі, тож внутрішні сервіси недоступні
+	archive, err := buildArchive(context.Background(), webhooks.NewClient(false), data)
+	require.NoError(t, err)
+
+	var photos []Photo
+	require.NoError(t, json.Unmarshal(readArchive(t, archive)["photos.json"], &photos))
+	require.Len(t, photos, 1)
+	assert.Contains(t, photos[0].Error, "not publicly routable")
+}
+
+func readArchive(t *testing.T, archive []byte) map[string][]byte {
+	t.Helper()
+
+	zr, err := zip.NewReader(bytes.NewReader(archive), int64(len(archive)))
+	require.NoError(t, err)
+
+	files := make(map[string][]byte)
+	for _, f := range zr.File {
+		rc, err := f.Open()
+		require.NoError(t, err)
+		files[f.Name], err = io.ReadAll(rc)
+		require.NoError(t, err)
+		require.NoError(t, rc.Close())
+	}
+	return files
+}
@@ -0,0 +1,186 @@
+// Package privacy gives users a copy of their personal data and erases
+// their account on request
+package privacy
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"pets_rest/internal/audit"
+	"pets_rest/internal/config"
+	"pets_rest/internal/database"
+	"pets_rest/internal/jobs"
+	"pets_rest/internal/notify"
+	"pets_rest/internal/webhooks"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// JobExport is the job type that builds a requested export; its payload is
+// ExportJob
+const JobExport = "users.export"
+
+// ExportJob is the payload of a JobExport job
+type ExportJob struct {
+	ExportID int `json:"export_id"`
+}
+
+// Service builds personal data exports and erases accounts
+type Service struct {
+	db      *database.DB
+	cfg     *config.Config
+	users   *database.UserRepository
+	exports *database.DataExportRepository
+	audit   *audit.Log
+	jobs    *jobs.Queue
+	notify  *notify.Service
+	// client downloads listing and message photos into exports
+	client *http.Client
+}
+
+// NewService creates a new privacy service
+func NewService(db *database.DB, cfg *config.Config, queue *jobs.Queue, notifier *notify.Service) *Service {
+	return &Service{
+		db:      db,
+		cfg:     cfg,
+		users:   database.NewUserRepository(db),
+		exports: database.NewDataExportRepository(db),
+		audit:   audit.NewLog(db),
+		jobs:    queue,
+		notify:  notifier,
+		client:  webhooks.NewClient(cfg.Env == "development"),
+	}
+}
+
+// RequestExport returns the user's latest export that has not expired or
+// failed, or queues a new one
+func (s *Service) RequestExport(ctx context.Context, userID int) (*database.DataExport, error) {
+	var export *database.DataExport
+	err := s.db.WithTx(ctx, func(tx *sqlx.Tx) error {
+		// The user row serializes concurrent requests, so only one export
+		// is queued
+		if _, err := s.users.WithTx(tx).GetByIDForUpdate(ctx, userID); err != nil {
+			return err
+		}
+
+		exports := s.exports.WithTx(tx)
+
+		latest, err := exports.Latest(ctx, userID)
+		if err != nil && !errors.Is(err, sql.ErrNoRows) {
+			return err
+		}
+		if latest != nil && latest.Status != database.DataExportStatusFailed {
+			export = latest
+			return nil
+		}
+
+		export = &database.DataExport{UserID: userID}
+		if err := exports.Create(ctx, export); err != nil {
+			return err
+		}
+
+		_, err = s.jobs.EnqueueTx(ctx, tx, JobExport, ExportJob{ExportID: export.ID})
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return export, nil
+}
+
+// Archive returns the ZIP archive of a ready export
+func (s *Service) Archive(ctx context.Context, export *database.DataExport) ([]byte, error) {
+	return s.exports.Archive(ctx, export.ID)
+}
+
+// BuildExport collects the data of a pending export into its archive and
+// notifies the user. A failed export is marked failed rather than retried;
+// the user can request a new one.
+func (s *Service) BuildExport(ctx context.Context, exportID int) error {
+	export, err := s.exports.GetByID(ctx, exportID)
+	if errors.Is(err, sql.ErrNoRows) {
+		// The account was erased in the meantime
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if export.Status != database.DataExportStatusPending {
+		return nil
+	}
+
+	if err := s.buildExport(ctx, export); err != nil {
+		if failErr := s.exports.Fail(ctx, export.ID, err.Error()); failErr != nil {
+			log.Printf("Failed to mark data export %d failed: %v", export.ID, failErr)
+		}
+		return jobs.Permanent(err)
+	}
+
+	return nil
+}
+
+func (s *Service) buildExport(ctx context.Context, export *database.DataExport) error {
+	data, err := s.exports.UserData(ctx, export.UserID)
+	if err != nil {
+		return fmt.Errorf("failed to collect data: %w", err)
+	}
+
+	archive, err := buildArchive(ctx, s.client, data)
+	if err != nil {
+		return fmt.Errorf("failed to build archive: %w", err)
+	}
+
+	expiresAt := time.Now().Add(s.cfg.DataExportTTL)
+	return s.db.WithTx(ctx, func(tx *sqlx.Tx) error {
+		if err := s.exports.WithTx(tx).Complete(ctx, export.ID, archive, expiresAt); err != nil {
+			return err
+		}
+
+		return s.notify.SendTx(ctx, tx, notify.Notification{
+			UserID: export.UserID,
+			Kind:   notify.KindDataExportReady,
+			Data: map[string]any{
+				"download_url": s.cfg.FrontendURL + "/me/export",
+				"expires_at":   expiresAt.UTC().Format("2006-01-02 15:04 UTC"),
+			},
+		})
+	})
+}
+
+// PurgeExpiredExports removes exports that can no longer be downloaded
+func (s *Service) PurgeExpiredExports(ctx context.Context) (int64, error) {
+	return s.exports.PurgeExpired(ctx, time.Now())
+}
+
+// Erase removes the user's account for good on their own request: the
+// profile, listings with their photos, conversations, messages, exports
+// and sign-in methods are deleted, and the events and reports the user
+// left are kept without anything identifying them. Access tokens stop
+// working with the account gone. The audit log keeps that the records
+// existed, without their values.
+func (s *Service) Erase(ctx context.Context, userID int) error {
+	return s.db.WithTx(ctx, func(tx *sqlx.Tx) error {
+		listingIDs, err := s.users.WithTx(tx).Erase(ctx, userID)
+		if err != nil {
+			return err
+		}
+
+		auditLog := s.audit.WithTx(tx)
+		if len(listingIDs) > 0 {
+			if err := auditLog.Erase(ctx, database.AuditEntityListing, listingIDs...); err != nil {
+				return err
+			}
+		}
+		if err := auditLog.Erase(ctx, database.AuditEntityUser, userID); err != nil {
+			return err
+		}
+
+		return auditLog.ForgetActor(ctx, userID)
+	})
+}
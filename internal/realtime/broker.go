@@ -0,0 +1,148 @@
+// Package realtime delivers per-user notifications to connected clients
+package realtime
+
+import (
+	"context"
+	"encoding/json"
+	"sync"
+
+	"pets_rest/internal/config"
+)
+
+// Event types published to users
+const (
+	EventMessageCreated = "message.created"
+	EventListingScanned = "listing.scanned"
+	// EventMatchFound is a new listing matching an alert subscription
+	EventMatchFound = "match.found"
+	// EventMatchSuggested is a listing that may be about the same animal
+	// as one of the user's own listings
+	EventMatchSuggested = "match.suggested"
+)
+
+// Event is a notification delivered to a single user
+type Event struct {
+	ID   string          `json:"id"`
+	Type string          `json:"type"`
+	Data json.RawMessage `json:"data"`
+}
+
+// Broker publishes events to users and lets clients subscribe to them
+type Broker interface {
+	// Publish sends an event of the given type to all subscriptions of the user
+	Publish(ctx context.Context, userID int, eventType string, data any) error
+	// Subscribe returns a channel with events published after lastEventID
+	// (replayed from history when available) followed by live events. The
+	// channel is closed when ctx is done or the broker is closed.
+	Subscribe(ctx context.Context, userID int, lastEventID string) (<-chan Event, error)
+	// Close stops delivery and closes all subscriptions
+	Close() error
+}
+
+// subscriberBuffer is how many events a slow client may lag behind before
+// it is disconnected and has to reconnect with Last-Event-ID
+const subscriberBuffer = 32
+
+type subscriber struct {
+	ch   chan Event
+	done chan struct{}
+}
+
+// hub fans events out to the local subscribers of each user
+type hub struct {
+	mu     sync.Mutex
+	subs   map[int]map[*subscriber]struct{}
+	closed bool
+}
+
+func newHub() *hub {
+	return &hub{subs: make(map[int]map[*subscriber]struct{})}
+}
+
+// add registers a subscriber; replay is delivered before any live event
+func (h *hub) add(ctx context.Context, userID int, replay []Event) (<-chan Event, bool) {
+	sub := &subscriber{
+		ch:   make(chan Event, subscriberBuffer+len(replay)),
+		done: make(chan struct{}),
+	}
+	for _, e := range replay {
+		sub.ch <- e
+	}
+
+	h.mu.Lock()
+	if h.closed {
+		h.mu.Unlock()
+		close(sub.ch)
+		return sub.ch, false
+	}
+	if h.subs[userID] == nil {
+		h.subs[userID] = make(map[*subscriber]struct{})
+	}
+	h.subs[userID][sub] = struct{}{}
+	h.mu.Unlock()
+
+	go func() {
+		select {
+		case <-ctx.Done():
+			h.remove(userID, sub)
+		case <-sub.done:
+		}
+	}()
+
+	return sub.ch, true
+}
+
+func (h *hub) remove(userID int, sub *subscriber) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if _, ok := h.subs[userID][sub]; !ok {
+		return
+	}
+	delete(h.subs[userID], sub)
+	if len(h.subs[userID]) == 0 {
+		delete(h.subs, userID)
+	}
+	close(sub.done)
+	close(sub.ch)
+}
+
+// broadcast delivers an event to the local subscribers of a user; a
+// subscriber whose buffer is full is dropped
+func (h *hub) broadcast(userID int, e Event) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	for sub := range h.subs[userID] {
+		select {
+		case sub.ch <- e:
+		default:
+			delete(h.subs[userID], sub)
+			close(sub.done)
+			close(sub.ch)
+		}
+	}
+}
+
+func (h *hub) close() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.closed = true
+	for userID, subs := range h.subs {
+		for sub := range subs {
+			close(sub.done)
+			close(sub.ch)
+		}
+		delete(h.subs, userID)
+	}
+}
+
+// New creates the broker selected by Config.RealtimeBackend: "redis" for
+// multi-instance deployments using Config.RedisURL, "memory" otherwise
+func New(ctx context.Context, cfg *config.Config) (Broker, error) {
+	if cfg.RealtimeBackend == "redis" {
+		return NewRedisBroker(ctx, cfg.RedisURL)
+	}
+	return NewMemoryBroker(), nil
+}
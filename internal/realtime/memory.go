@@ -0,0 +1,73 @@
+package realtime
+
+import (
+	"context"
+	"encoding/json"
+	"strconv"
+	"sync"
+)
+
+// historySize is how many recent events per user are kept for Last-Event-ID replay
+const historySize = 100
+
+// MemoryBroker delivers events within a single process
+type MemoryBroker struct {
+	hub *hub
+
+	mu      sync.Mutex
+	lastID  uint64
+	history map[int][]Event
+}
+
+// NewMemoryBroker creates an in-process broker
+func NewMemoryBroker() *MemoryBroker {
+	return &MemoryBroker{
+		hub:     newHub(),
+		history: make(map[int][]Event),
+	}
+}
+
+// Publish stores the event in the user's history and delivers it to subscribers
+func (b *MemoryBroker) Publish(_ context.Context, userID int, eventType string, data any) error {
+	raw, err := json.Marshal(data)
+	if err != nil {
+		return err
+	}
+
+	b.mu.Lock()
+	b.lastID++
+	e := Event{ID: strconv.FormatUint(b.lastID, 10), Type: eventType, Data: raw}
+	history := append(b.history[userID], e)
+	if len(history) > historySize {
+		history = history[len(history)-historySize:]
+	}
+	b.history[userID] = history
+	b.hub.broadcast(userID, e)
+	b.mu.Unlock()
+
+	return nil
+}
+
+// Subscribe replays events after lastEventID and streams new ones
+func (b *MemoryBroker) Subscribe(ctx context.Context, userID int, lastEventID string) (<-chan Event, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	var replay []Event
+	if last, err := strconv.ParseUint(lastEventID, 10, 64); err == nil {
+		for _, e := range b.history[userID] {
+			if id, _ := strconv.ParseUint(e.ID, 10, 64); id > last {
+				replay = append(replay, e)
+			}
+		}
+	}
+
+	ch, _ := b.hub.add(ctx, userID, replay)
+	return ch, nil
+}
+
+// Close closes all subscriptions
+func (b *MemoryBroker) Close() error {
+	b.hub.close()
+	return nil
+}
@@ -0,0 +1,81 @@
+package realtime
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func receive(t *testing.T, ch <-chan Event) Event {
+	t.Helper()
+	select {
+	case e, ok := <-ch:
+		require.True(t, ok, "channel closed")
+		return e
+	case <-time.After(time.Second):
+		t.Fatal("timed out waiting for event")
+		return Event{}
+	}
+}
+
+func TestMemoryBrokerDeliversToUser(t *testing.T) {
+	b := NewMemoryBroker()
+	defer func() { _ = b.Close() }()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	mine, err := b.Subscribe(ctx, 1, "")
+	require.NoError(t, err)
+	other, err := b.Subscribe(ctx, 2, "")
+	require.NoError(t, err)
+
+	require.NoError(t, b.Publish(ctx, 1, "message.created", map[string]int{"id": 7}))
+
+	e := receive(t, mine)
+	assert.Equal(t, "message.created", e.Type)
+	assert.JSONEq(t, `{"id":7}`, string(e.Data))
+
+	// Інший користувач нічого не отримує
+	select {
+	case e := <-other:
+		t.Fatalf("unexpected event %v", e)
+	default:
+	}
+}
+
+func TestMemoryBrokerReplaysAfterLastEventID(t *testing.T) {
+	b := NewMemoryBroker()
+	defer func() { _ = b.Close() }()
+
+	ctx := context.Background()
+	for i := 0; i < 3; i++ {
+		require.NoError(t, b.Publish(ctx, 1, "listing.scanned", i))
+	}
+
+	ch, err := b.Subscribe(ctx, 1, "1")
+	require.NoError(t, err)
+
+	assert.Equal(t, "2", receive(t, ch).ID)
+	assert.Equal(t, "3", receive(t, ch).ID)
+}
+
+func TestMemoryBrokerClosesOnContextDone(t *testing.T) {
+	b := NewMemoryBroker()
+	defer func() { _ = b.Close() }()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	ch, err := b.Subscribe(ctx, 1, "")
+	require.NoError(t, err)
+
+	cancel()
+	select {
+	case _, ok := <-ch:
+		assert.False(t, ok)
+	case <-time.After(time.Second):
+		t.Fatal("subscription was not closed")
+	}
+}
@@ -0,0 +1,178 @@
+package realtime
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+
+	"github.com/redis/go-redis/v9"
+)
+
+const (
+	redisStreamPrefix = "realtime:user:"
+	redisChannel      = "realtime:events"
+)
+
+// RedisBroker delivers events across API instances. Every event is appended
+// to a capped per-user stream, which provides IDs and Last-Event-ID replay,
+// and announced on a shared pub/sub channel that each instance fans out to
+// its local subscribers.
+type RedisBroker struct {
+	client *redis.Client
+	pubsub *redis.PubSub
+	hub    *hub
+}
+
+type redisEnvelope struct {
+	UserID int   `json:"user_id"`
+	Event  Event `json:"event"`
+}
+
+// NewRedisBroker connects to Redis and starts listening for events
+func NewRedisBroker(ctx context.Context, redisURL string) (*RedisBroker, error) {
+	opts, err := redis.ParseURL(redisURL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid redis url: %w", err)
+	}
+
+	client := redis.NewClient(opts)
+	if err := client.Ping(ctx).Err(); err != nil {
+		_ = client.Close()
+		return nil, fmt.Errorf("failed to connect to redis: %w", err)
+	}
+
+	b := &RedisBroker{
+		client: client,
+		pubsub: client.Subscribe(ctx, redisChannel),
+		hub:    newHub(),
+	}
+	go b.listen()
+
+	return b, nil
+}
+
+func (b *RedisBroker) listen() {
+	for msg := range b.pubsub.Channel() {
+		var env redisEnvelope
+		if err := json.Unmarshal([]byte(msg.Payload), &env); err != nil {
+			log.Printf("Skipping malformed realtime event: %v", err)
+			continue
+		}
+		b.hub.broadcast(env.UserID, env.Event)
+	}
+}
+
+// Publish appends the event to the user's stream and announces it to all instances
+func (b *RedisBroker) Publish(ctx context.Context, userID int, eventType string, data any) error {
+	raw, err := json.Marshal(data)
+	if err != nil {
+		return err
+	}
+
+	id, err := b.client.XAdd(ctx, &redis.XAddArgs{
+		Stream: streamKey(userID),
+		MaxLen: historySize,
+		Approx: true,
+		Values: map[string]any{"type": eventType, "data": string(raw)},
+	}).Result()
+	if err != nil {
+		return fmt.Errorf("failed to store realtime event: %w", err)
+	}
+
+	payload, err := json.Marshal(redisEnvelope{
+		UserID: userID,
+		Event:  Event{ID: id, Type: eventType, Data: raw},
+	})
+	if err != nil {
+		return err
+	}
+
+	return b.client.Publish(ctx, redisChannel, payload).Err()
+}
+
+// Subscribe replays events after lastEventID from the user's stream and
+// streams new ones
+func (b *RedisBroker) Subscribe(ctx context.Context, userID int, lastEventID string) (<-chan Event, error) {
+	// Register before reading history so that nothing published in between
+	// is lost; live events already covered by the replay are skipped below.
+	live, _ := b.hub.add(ctx, userID, nil)
+
+	var replay []Event
+	if _, _, ok := parseStreamID(lastEventID); ok {
+		msgs, err := b.client.XRange(ctx, streamKey(userID), "("+lastEventID, "+").Result()
+		if err != nil {
+			return nil, fmt.Errorf("failed to replay realtime events: %w", err)
+		}
+		for _, msg := range msgs {
+			eventType, _ := msg.Values["type"].(string)
+			data, _ := msg.Values["data"].(string)
+			replay = append(replay, Event{ID: msg.ID, Type: eventType, Data: json.RawMessage(data)})
+		}
+	}
+
+	out := make(chan Event)
+	go func() {
+		defer close(out)
+
+		last := lastEventID
+		for _, e := range replay {
+			select {
+			case out <- e:
+				last = e.ID
+			case <-ctx.Done():
+				return
+			}
+		}
+
+		for e := range live {
+			if !streamIDAfter(e.ID, last) {
+				continue
+			}
+			select {
+			case out <- e:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	return out, nil
+}
+
+// Close stops listening and closes all subscriptions
+func (b *RedisBroker) Close() error {
+	b.hub.close()
+	if err := b.pubsub.Close(); err != nil {
+		return err
+	}
+	return b.client.Close()
+}
+
+func streamKey(userID int) string {
+	return redisStreamPrefix + strconv.Itoa(userID)
+}
+
+// parseStreamID splits a Redis stream ID ("ms-seq") into its parts
+func parseStreamID(id string) (ms, seq uint64, ok bool) {
+	msStr, seqStr, found := strings.Cut(id, "-")
+	if !found {
+		return 0, 0, false
+	}
+	ms, err1 := strconv.ParseUint(msStr, 10, 64)
+	seq, err2 := strconv.ParseUint(seqStr, 10, 64)
+	return ms, seq, err1 == nil && err2 == nil
+}
+
+// streamIDAfter reports whether stream ID a comes after b; any ID is after
+// an empty or malformed b
+func streamIDAfter(a, b string) bool {
+	bms, bseq, ok := parseStreamID(b)
+	if !ok {
+		return true
+	}
+	ams, aseq, _ := parseStreamID(a)
+	return ams > bms || (ams == bms && aseq > bseq)
+}
@@ -0,0 +1,14 @@
+package realtime
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStreamIDAfter(t *testing.T) {
+	assert.True(t, streamIDAfter("5-1", "5-0"))
+	assert.True(t, streamIDAfter("6-0", "5-9"))
+	assert.False(t, streamIDAfter("5-0", "5-0"))
+	assert.True(t, streamIDAfter("1-0", ""))
+}
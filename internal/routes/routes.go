@@ -1,23 +1,168 @@
 package routes
 
 import (
+	"pets_rest/internal/alerts"
 	"pets_rest/internal/database"
 	"pets_rest/internal/handlers"
+	"pets_rest/internal/listings"
+	"pets_rest/internal/middleware"
+	"pets_rest/internal/moderation"
+	"pets_rest/internal/notify"
+	"pets_rest/internal/oauth"
+	"pets_rest/internal/phones"
+	"pets_rest/internal/privacy"
+	"pets_rest/internal/realtime"
+	"pets_rest/internal/telegram"
+	"pets_rest/internal/webhooks"
 
 	"pets_rest/internal/config"
 
 	"github.com/gofiber/fiber/v3"
 )
 
-func SetupRoutes(app *fiber.App, db *database.DB, cfg *config.Config) {
+// Services holds long-lived components shared by handlers and background workers
+type Services struct {
+	Notify     *notify.Service
+	Broker     realtime.Broker
+	Alerts     *alerts.Service
+	Listings   *listings.Service
+	Telegram   *telegram.Bot
+	Webhooks   *webhooks.Dispatcher
+	OAuth      *oauth.Registry
+	Phones     *phones.Service
+	Moderation *moderation.Service
+	Privacy    *privacy.Service
+}
+
+func SetupRoutes(app *fiber.App, db *database.DB, cfg *config.Config, svc *Services) {
+	requireAuth := middleware.RequireAuth(cfg, db)
+
 	healthHandler := handlers.NewHealthHandler(db)
 	app.Get("/health", healthHandler.HealthCheck)
 
+	placementHandler := handlers.NewPlacementHandler(db, cfg, svc.Broker)
+	app.Get("/q/:code", placementHandler.Scan)
+
+	shortLinkHandler := handlers.NewShortLinkHandler(db, cfg, svc.Broker)
+	app.Get("/s/:code", shortLinkHandler.Redirect)
+
+	publicHandler := handlers.NewPublicHandler(db)
+	contactHandler := handlers.NewContactHandler(db, cfg, svc.Notify, svc.Listings)
+	moderationHandler := handlers.NewModerationHandler(db, cfg, svc.Moderation)
+
+	public := app.Group("/p/:slug")
+	public.Get("/", publicHandler.Show)
+	public.Get("/contacts/challenge", contactHandler.Challenge)
+	public.Post("/contacts/reveal", contactHandler.Reveal)
+	public.Post("/messages", contactHandler.Relay)
+	public.Post("/report", middleware.OptionalAuth(cfg, db), moderationHandler.Report)
+
+	alertHandler := handlers.NewAlertHandler(db, svc.Alerts)
+	app.Get("/alerts/unsubscribe/:token", alertHandler.Unsubscribe)
+	app.Post("/alerts/unsubscribe/:token", alertHandler.Unsubscribe)
+
+	telegramHandler := handlers.NewTelegramHandler(cfg, svc.Telegram)
+	app.Post("/telegram/webhook", telegramHandler.Webhook)
+
 	v1 := app.Group("/api/v1")
 
-	authHandler := handlers.NewAuthHandler(db, cfg)
+	authHandler := handlers.NewAuthHandler(db, cfg, svc.OAuth)
 
 	auth := v1.Group("/auth")
-	auth.Get("/google/login", authHandler.GoogleLogin)
-	auth.Get("/google/callback", authHandler.GoogleCallback)
+	auth.Get("/providers", authHandler.Providers)
+	auth.Get("/:provider/login", authHandler.Login)
+	auth.Get("/:provider/callback", authHandler.Callback)
+	auth.Post("/:provider/callback", authHandler.Callback)
+
+	listingHandler := handlers.NewListingHandler(db, cfg, svc.Listings)
+
+	listings := v1.Group("/listings")
+	listings.Get("/", listingHandler.List)
+	listings.Get("/:id", listingHandler.Get)
+	listings.Post("/", requireAuth, listingHandler.Create)
+	listings.Put("/:id", requireAuth, listingHandler.Update)
+	listings.Patch("/:id", requireAuth, listingHandler.Patch)
+	listings.Delete("/:id", requireAuth, listingHandler.Delete)
+	listings.Post("/:id/restore", requireAuth, listingHandler.Restore)
+	listings.Get("/:id/revisions", requireAuth, listingHandler.Revisions)
+	listings.Get("/:id/revisions/diff", requireAuth, listingHandler.RevisionDiff)
+	listings.Post("/:id/revisions/:version/revert", requireAuth, listingHandler.Revert)
+	listings.Get("/:id/analytics", requireAuth, placementHandler.Analytics)
+	listings.Get("/:id/placements", requireAuth, placementHandler.List)
+	listings.Post("/:id/placements", requireAuth, placementHandler.Create)
+	listings.Delete("/:id/placements/:placementId", requireAuth, placementHandler.Delete)
+	listings.Get("/:id/short-links", requireAuth, shortLinkHandler.List)
+	listings.Post("/:id/short-links", requireAuth, shortLinkHandler.Create)
+	listings.Put("/:id/contacts/visibility", requireAuth, contactHandler.SetVisibility)
+
+	conversationHandler := handlers.NewConversationHandler(db, cfg, svc.Notify, svc.Broker)
+	listings.Post("/:id/conversations", requireAuth, conversationHandler.Start)
+
+	conversations := v1.Group("/conversations", requireAuth)
+	conversations.Get("/", conversationHandler.List)
+	conversations.Get("/:id/messages", conversationHandler.Messages)
+	conversations.Post("/:id/messages", conversationHandler.Send)
+	conversations.Post("/:id/read", conversationHandler.MarkRead)
+
+	me := v1.Group("/me", requireAuth)
+	me.Get("/listings", listingHandler.Mine)
+	me.Post("/telegram/link", telegramHandler.Link)
+
+	identityHandler := handlers.NewIdentityHandler(db, cfg, svc.OAuth)
+	me.Get("/identities", identityHandler.List)
+	me.Post("/identities/:provider", identityHandler.Link)
+	me.Delete("/identities/:id", identityHandler.Unlink)
+
+	phoneHandler := handlers.NewPhoneHandler(svc.Phones)
+	me.Post("/phone/code", phoneHandler.SendCode)
+	me.Post("/phone/verify", phoneHandler.Confirm)
+
+	notificationHandler := handlers.NewNotificationHandler(db, svc.Notify)
+	me.Get("/notifications", notificationHandler.Settings)
+	me.Put("/notifications", notificationHandler.UpdateSettings)
+
+	accountHandler := handlers.NewAccountHandler(svc.Privacy)
+	me.Get("/export", accountHandler.Export)
+	me.Delete("/", accountHandler.Delete)
+
+	alertsGroup := v1.Group("/alerts", requireAuth)
+	alertsGroup.Get("/", alertHandler.List)
+	alertsGroup.Post("/", alertHandler.Create)
+	alertsGroup.Delete("/:id", alertHandler.Delete)
+
+	webhookHandler := handlers.NewWebhookHandler(db, cfg)
+
+	webhooksGroup := v1.Group("/webhooks", requireAuth, middleware.RequireRole(database.RoleShelter, database.RoleAdmin))
+	webhooksGroup.Get("/", webhookHandler.List)
+	webhooksGroup.Post("/", webhookHandler.Create)
+	webhooksGroup.Put("/:id", webhookHandler.Update)
+	webhooksGroup.Delete("/:id", webhookHandler.Delete)
+	webhooksGroup.Get("/:id/deliveries", webhookHandler.Deliveries)
+	webhooksGroup.Post("/:id/deliveries/:deliveryId/replay", webhookHandler.Replay)
+
+	moderationGroup := v1.Group("/moderation", requireAuth, middleware.RequireRole(database.RoleModerator, database.RoleAdmin))
+	moderationGroup.Get("/queue", moderationHandler.Queue)
+	moderationGroup.Get("/listings/:id/reports", moderationHandler.Reports)
+	moderationGroup.Get("/listings/:id/history", moderationHandler.History)
+	moderationGroup.Post("/listings/:id/actions", moderationHandler.Act)
+
+	adminHandler := handlers.NewAdminHandler(db, cfg, svc.Listings)
+
+	admin := v1.Group("/admin", requireAuth, middleware.RequireRole(database.RoleAdmin))
+	admin.Get("/users", adminHandler.Users)
+	admin.Post("/users/:id/merge", adminHandler.MergeUsers)
+	admin.Put("/users/:id/role", adminHandler.SetRole)
+	admin.Post("/users/:id/suspend", adminHandler.Suspend)
+	admin.Delete("/users/:id/suspend", adminHandler.Unsuspend)
+	admin.Delete("/users/:id", adminHandler.DeleteUser)
+	admin.Post("/users/:id/restore", adminHandler.RestoreUser)
+	admin.Get("/listings", adminHandler.Listings)
+	admin.Post("/listings/:id/archive", adminHandler.ArchiveListing)
+	admin.Get("/stats", adminHandler.Stats)
+	admin.Get("/audit", adminHandler.Audit)
+	admin.Get("/jobs", adminHandler.DeadJobs)
+	admin.Post("/jobs/:id/requeue", adminHandler.RequeueJob)
+
+	streamHandler := handlers.NewStreamHandler(svc.Broker)
+	v1.Get("/notifications/stream", middleware.RequireStreamAuth(cfg, db), streamHandler.Stream)
 }
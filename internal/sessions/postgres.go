@@ -0,0 +1,100 @@
+package sessions
+
+import (
+	"context"
+	"log"
+	"sync"
+	"time"
+
+	"pets_rest/internal/database"
+)
+
+// gcInterval is how often expired sessions are deleted
+const gcInterval = 10 * time.Minute
+
+// PostgresStorage keeps sessions in the sessions table
+type PostgresStorage struct {
+	sessions *database.SessionRepository
+	done     chan struct{}
+	once     sync.Once
+}
+
+// NewPostgresStorage creates PostgreSQL session storage and starts its
+// garbage collector
+func NewPostgresStorage(db *database.DB) *PostgresStorage {
+	s := &PostgresStorage{
+		sessions: database.NewSessionRepository(db),
+		done:     make(chan struct{}),
+	}
+	go s.gc()
+	return s
+}
+
+func (s *PostgresStorage) gc() {
+	ticker := time.NewTicker(gcInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-s.done:
+			return
+		case <-ticker.C:
+			if _, err := s.sessions.DeleteExpired(context.Background()); err != nil {
+				log.Printf("Failed to delete expired sessions: %v", err)
+			}
+		}
+	}
+}
+
+func (s *PostgresStorage) GetWithContext(ctx context.Context, key string) ([]byte, error) {
+	if key == "" {
+		return nil, nil
+	}
+	return s.sessions.Get(ctx, key)
+}
+
+func (s *PostgresStorage) Get(key string) ([]byte, error) {
+	return s.GetWithContext(context.Background(), key)
+}
+
+func (s *PostgresStorage) SetWithContext(ctx context.Context, key string, val []byte, exp time.Duration) error {
+	if key == "" || len(val) == 0 {
+		return nil
+	}
+
+	var expiresAt *time.Time
+	if exp > 0 {
+		t := time.Now().Add(exp)
+		expiresAt = &t
+	}
+	return s.sessions.Set(ctx, key, val, expiresAt)
+}
+
+func (s *PostgresStorage) Set(key string, val []byte, exp time.Duration) error {
+	return s.SetWithContext(context.Background(), key, val, exp)
+}
+
+func (s *PostgresStorage) DeleteWithContext(ctx context.Context, key string) error {
+	if key == "" {
+		return nil
+	}
+	return s.sessions.Delete(ctx, key)
+}
+
+func (s *PostgresStorage) Delete(key string) error {
+	return s.DeleteWithContext(context.Background(), key)
+}
+
+func (s *PostgresStorage) ResetWithContext(ctx context.Context) error {
+	return s.sessions.DeleteAll(ctx)
+}
+
+func (s *PostgresStorage) Reset() error {
+	return s.ResetWithContext(context.Background())
+}
+
+// Close stops the garbage collector; the database connection is owned by the caller
+func (s *PostgresStorage) Close() error {
+	s.once.Do(func() { close(s.done) })
+	return nil
+}
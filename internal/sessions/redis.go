@@ -0,0 +1,89 @@
+package sessions
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+const redisKeyPrefix = "session:"
+
+// RedisStorage keeps sessions in Redis with native key expiry
+type RedisStorage struct {
+	client *redis.Client
+}
+
+// NewRedisStorage connects to Redis
+func NewRedisStorage(ctx context.Context, redisURL string) (*RedisStorage, error) {
+	opts, err := redis.ParseURL(redisURL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid redis url: %w", err)
+	}
+
+	client := redis.NewClient(opts)
+	if err := client.Ping(ctx).Err(); err != nil {
+		_ = client.Close()
+		return nil, fmt.Errorf("failed to connect to redis: %w", err)
+	}
+
+	return &RedisStorage{client: client}, nil
+}
+
+func (s *RedisStorage) GetWithContext(ctx context.Context, key string) ([]byte, error) {
+	if key == "" {
+		return nil, nil
+	}
+	val, err := s.client.Get(ctx, redisKeyPrefix+key).Bytes()
+	if errors.Is(err, redis.Nil) {
+		return nil, nil
+	}
+	return val, err
+}
+
+func (s *RedisStorage) Get(key string) ([]byte, error) {
+	return s.GetWithContext(context.Background(), key)
+}
+
+func (s *RedisStorage) SetWithContext(ctx context.Context, key string, val []byte, exp time.Duration) error {
+	if key == "" || len(val) == 0 {
+		return nil
+	}
+	return s.client.Set(ctx, redisKeyPrefix+key, val, exp).Err()
+}
+
+func (s *RedisStorage) Set(key string, val []byte, exp time.Duration) error {
+	return s.SetWithContext(context.Background(), key, val, exp)
+}
+
+func (s *RedisStorage) DeleteWithContext(ctx context.Context, key string) error {
+	if key == "" {
+		return nil
+	}
+	return s.client.Del(ctx, redisKeyPrefix+key).Err()
+}
+
+func (s *RedisStorage) Delete(key string) error {
+	return s.DeleteWithContext(context.Background(), key)
+}
+
+// ResetWithContext deletes all sessions; other keys in the database are kept
+func (s *RedisStorage) ResetWithContext(ctx context.Context) error {
+	iter := s.client.Scan(ctx, 0, redisKeyPrefix+"*", 100).Iterator()
+	for iter.Next(ctx) {
+		if err := s.client.Del(ctx, iter.Val()).Err(); err != nil {
+			return err
+		}
+	}
+	return iter.Err()
+}
+
+func (s *RedisStorage) Reset() error {
+	return s.ResetWithContext(context.Background())
+}
+
+func (s *RedisStorage) Close() error {
+	return s.client.Close()
+}
@@ -0,0 +1,61 @@
+// Package sessions provides shared storage for HTTP sessions so that OAuth
+// state survives restarts and works behind a load balancer
+package sessions
+
+import (
+	"context"
+	"time"
+
+	"pets_rest/internal/config"
+	"pets_rest/internal/database"
+
+	"github.com/gofiber/fiber/v3"
+	"github.com/gofiber/fiber/v3/middleware/session"
+)
+
+var (
+	_ fiber.Storage = (*RedisStorage)(nil)
+	_ fiber.Storage = (*PostgresStorage)(nil)
+)
+
+// idleTimeout bounds how long an unused session lives; OAuth round trips
+// take seconds, so this is generous
+const idleTimeout = 30 * time.Minute
+
+// NewStorage returns Redis storage when Config.RedisURL is set and
+// PostgreSQL storage otherwise
+func NewStorage(ctx context.Context, cfg *config.Config, db *database.DB) (fiber.Storage, error) {
+	if cfg.RedisURL != "" {
+		return NewRedisStorage(ctx, cfg.RedisURL)
+	}
+	return NewPostgresStorage(db), nil
+}
+
+// Middleware returns the session middleware backed by storage
+func Middleware(cfg *config.Config, storage fiber.Storage) fiber.Handler {
+	return session.New(middlewareConfig(cfg, storage))
+}
+
+// middlewareConfig sets secure cookie flags; plain HTTP is only accepted in
+// development
+func middlewareConfig(cfg *config.Config, storage fiber.Storage) session.Config {
+	secure := cfg.Env != "development"
+
+	// Lax keeps the cookie on the top-level redirect back from the provider,
+	// but not on Apple's cross-site form POST; that needs None, which
+	// browsers only accept on secure cookies. The session holds nothing but
+	// sign-in state, and the API itself authenticates with bearer tokens.
+	sameSite := fiber.CookieSameSiteLaxMode
+	if secure {
+		sameSite = fiber.CookieSameSiteNoneMode
+	}
+
+	return session.Config{
+		Storage:        storage,
+		IdleTimeout:    idleTimeout,
+		CookieSecure:   secure,
+		CookieHTTPOnly: true,
+		CookieSameSite: sameSite,
+		Extractor:      session.FromCookie("session_id"),
+	}
+}
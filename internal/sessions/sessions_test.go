@@ -0,0 +1,24 @@
+package sessions
+
+import (
+	"testing"
+
+	"pets_rest/internal/config"
+
+	"github.com/gofiber/fiber/v3"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMiddlewareConfigCookieFlags(t *testing.T) {
+	prod := middlewareConfig(&config.Config{Env: "production"}, nil)
+	assert.True(t, prod.CookieSecure)
+	assert.True(t, prod.CookieHTTPOnly)
+	// Apple повертає користувача крос-сайтовим POST
+	assert.Equal(t, fiber.CookieSameSiteNoneMode, prod.CookieSameSite)
+
+	// Локально працюємо без HTTPS
+	dev := middlewareConfig(&config.Config{Env: "development"}, nil)
+	assert.False(t, dev.CookieSecure)
+	assert.True(t, dev.CookieHTTPOnly)
+	assert.Equal(t, fiber.CookieSameSiteLaxMode, dev.CookieSameSite)
+}
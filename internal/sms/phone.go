@@ -0,0 +1,42 @@
+package sms
+
+import (
+	"errors"
+	"regexp"
+	"strings"
+)
+
+// ErrInvalidPhone is returned for numbers that cannot be brought to E.164
+var ErrInvalidPhone = errors.New("invalid phone number")
+
+var e164 = regexp.MustCompile(`^\+[1-9]\d{7,14}$`)
+
+// NormalizePhone brings a phone number to E.164 (+380671234567). Spaces,
+// dashes, dots and brackets are dropped; Ukrainian numbers may be written
+// in the local form 0671234567 or without the plus sign.
+func NormalizePhone(phone string) (string, error) {
+	digits := strings.Map(func(r rune) rune {
+		switch r {
+		case ' ', '-', '.', '(', ')':
+			return -1
+		}
+		return r
+	}, strings.TrimSpace(phone))
+
+	switch {
+	case strings.HasPrefix(digits, "+"):
+	case strings.HasPrefix(digits, "00"):
+		digits = "+" + digits[2:]
+	case strings.HasPrefix(digits, "380") && len(digits) == 12:
+		digits = "+" + digits
+	case strings.HasPrefix(digits, "0") && len(digits) == 10:
+		digits = "+38" + digits
+	default:
+		return "", ErrInvalidPhone
+	}
+
+	if !e164.MatchString(digits) {
+		return "", ErrInvalidPhone
+	}
+	return digits, nil
+}
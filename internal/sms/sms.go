@@ -0,0 +1,36 @@
+// Package sms sends text messages, such as phone verification codes,
+// through a pluggable provider
+package sms
+
+import (
+	"context"
+	"fmt"
+	"log"
+
+	"pets_rest/internal/config"
+)
+
+// Sender delivers a text message to a phone number in E.164 format
+type Sender interface {
+	Send(ctx context.Context, phone, text string) error
+}
+
+// NewSender returns the sender selected by Config.SMSProvider
+func NewSender(cfg *config.Config) (Sender, error) {
+	switch cfg.SMSProvider {
+	case "", "log":
+		return LogSender{}, nil
+	default:
+		return nil, fmt.Errorf("unknown SMS provider %q", cfg.SMSProvider)
+	}
+}
+
+// LogSender writes messages to the log instead of sending them; it stands in
+// for a real provider in development
+type LogSender struct{}
+
+// Send logs the message
+func (LogSender) Send(_ context.Context, phone, text string) error {
+	log.Printf("SMS to %s: %s", phone, text)
+	return nil
+}
@@ -0,0 +1,30 @@
+package sms
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNormalizePhone(t *testing.T) {
+	cases := map[string]string{
+		"+380671234567":       "+380671234567",
+		"+38 (067) 123-45-67": "+380671234567",
+		"067 123 45 67":       "+380671234567",
+		"380671234567":        "+380671234567",
+		"00380671234567":      "+380671234567",
+		"+48 601 234 567":     "+48601234567",
+	}
+	for in, want := range cases {
+		got, err := NormalizePhone(in)
+		if assert.NoError(t, err, in) {
+			assert.Equal(t, want, got, in)
+		}
+	}
+
+	// Без коду країни і не схоже на український номер — не вгадуємо
+	for _, in := range []string{"", "12345", "671234567", "+0671234567", "+38067abc4567", "+3806712345678901"} {
+		_, err := NormalizePhone(in)
+		assert.ErrorIs(t, err, ErrInvalidPhone, in)
+	}
+}
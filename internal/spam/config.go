@@ -0,0 +1,83 @@
+package spam
+
+import (
+	"log"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"pets_rest/internal/config"
+)
+
+// DefaultWeights is the score of each built-in rule
+var DefaultWeights = map[string]int{
+	"shared_phone":          40,
+	"payment_phrase":        50,
+	"external_link":         20,
+	"duplicate_description": 30,
+	"posting_burst":         30,
+}
+
+// minDuplicateLength is the description length from which copies count
+const minDuplicateLength = 40
+
+// NewEngineFromConfig builds the engine with the built-in rules; weights,
+// phrases and limits come from the configuration. Links to the API and
+// frontend hosts are always allowed.
+func NewEngineFromConfig(cfg *config.Config) *Engine {
+	weights := make(map[string]int, len(DefaultWeights))
+	for name, weight := range DefaultWeights {
+		weights[name] = weight
+	}
+	for name, weight := range parsePairs(cfg.SpamRuleWeights) {
+		n, err := strconv.Atoi(weight)
+		if err != nil {
+			log.Printf("Ignoring spam rule weight %s=%s: not a number", name, weight)
+			continue
+		}
+		weights[name] = n
+	}
+
+	phrases := DefaultPaymentPhrases
+	if cfg.SpamPaymentPhrases != "" {
+		phrases = splitList(cfg.SpamPaymentPhrases)
+	}
+
+	hosts := splitList(cfg.SpamAllowedLinkHosts)
+	for _, raw := range []string{cfg.BaseURL, cfg.FrontendURL} {
+		if u, err := url.Parse(raw); err == nil && u.Hostname() != "" {
+			hosts = append(hosts, strings.ToLower(u.Hostname()))
+		}
+	}
+
+	return NewEngine(cfg.SpamReviewScore, weights,
+		&SharedPhone{MaxAccounts: cfg.SpamMaxPhoneAccounts},
+		&PaymentPhrase{Phrases: phrases},
+		&ExternalLink{AllowedHosts: hosts},
+		&DuplicateDescription{MinLength: minDuplicateLength},
+		&PostingBurst{MaxListings: cfg.SpamBurstListings, Window: cfg.SpamBurstWindow},
+	)
+}
+
+// splitList splits a comma-separated setting into lower-case items
+func splitList(value string) []string {
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.ToLower(strings.TrimSpace(item)); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// parsePairs parses "name=value,name=value"
+func parsePairs(value string) map[string]string {
+	pairs := make(map[string]string)
+	for _, item := range strings.Split(value, ",") {
+		name, v, ok := strings.Cut(item, "=")
+		if name = strings.TrimSpace(name); ok && name != "" {
+			pairs[name] = strings.TrimSpace(v)
+		}
+	}
+	return pairs
+}
@@ -0,0 +1,124 @@
+package spam
+
+import (
+	"fmt"
+	"net/url"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// DefaultPaymentPhrases are requests for money typical of "found pet" scams
+var DefaultPaymentPhrases = []string{
+	"передоплат", "предоплат", "на карту", "номер карти", "номер карты",
+	"оплатіть", "оплатите", "за доставку", "western union", "moneygram",
+	"prepayment", "send money", "card number", "gift card", "crypto",
+}
+
+// SharedPhone matches when the contact phone is used by many other
+// accounts; MaxAccounts 0 disables it
+type SharedPhone struct {
+	MaxAccounts int
+}
+
+func (r *SharedPhone) Name() string { return "shared_phone" }
+
+func (r *SharedPhone) Check(in *Input) (bool, string) {
+	if r.MaxAccounts <= 0 || in.Listing.ContactPhone == nil || in.PhoneAccounts < r.MaxAccounts {
+		return false, ""
+	}
+	return true, fmt.Sprintf("contact phone is used by %d other accounts", in.PhoneAccounts)
+}
+
+// PaymentPhrase matches requests for payment in the title or description
+type PaymentPhrase struct {
+	Phrases []string
+}
+
+func (r *PaymentPhrase) Name() string { return "payment_phrase" }
+
+func (r *PaymentPhrase) Check(in *Input) (bool, string) {
+	text := strings.ToLower(listingText(in))
+	for _, phrase := range r.Phrases {
+		if phrase != "" && strings.Contains(text, strings.ToLower(phrase)) {
+			return true, fmt.Sprintf("mentions %q", phrase)
+		}
+	}
+	return false, ""
+}
+
+// linkPattern finds URLs and bare domains such as t.me/name in free text
+var linkPattern = regexp.MustCompile(`(?i)\b(?:https?://|www\.)[^\s<>"']+|\b[a-z0-9-]+(?:\.[a-z0-9-]+)*\.(?:com|net|org|me|ru|ua|io|info|biz|link|site|xyz|top)(?:/[^\s<>"']*)?`)
+
+// ExternalLink matches links in the title or description except to
+// allowed hosts and their subdomains
+type ExternalLink struct {
+	AllowedHosts []string
+}
+
+func (r *ExternalLink) Name() string { return "external_link" }
+
+func (r *ExternalLink) Check(in *Input) (bool, string) {
+	for _, link := range linkPattern.FindAllString(listingText(in), -1) {
+		raw := link
+		if !strings.Contains(raw, "://") {
+			raw = "http://" + raw
+		}
+		u, err := url.Parse(raw)
+		if err != nil || !r.allowed(strings.ToLower(u.Hostname())) {
+			return true, fmt.Sprintf("links to %s", link)
+		}
+	}
+	return false, ""
+}
+
+func (r *ExternalLink) allowed(host string) bool {
+	host = strings.TrimPrefix(host, "www.")
+	return slices.ContainsFunc(r.AllowedHosts, func(allowed string) bool {
+		return host == allowed || strings.HasSuffix(host, "."+allowed)
+	})
+}
+
+// DuplicateDescription matches a description copied from listings of other
+// accounts; short descriptions like "ginger cat" repeat naturally and are
+// skipped
+type DuplicateDescription struct {
+	MinLength int
+}
+
+func (r *DuplicateDescription) Name() string { return "duplicate_description" }
+
+func (r *DuplicateDescription) Check(in *Input) (bool, string) {
+	d := in.Listing.Description
+	if d == nil || utf8.RuneCountInString(strings.TrimSpace(*d)) < r.MinLength || in.DuplicateDescriptions == 0 {
+		return false, ""
+	}
+	return true, fmt.Sprintf("description matches %d listings of other accounts", in.DuplicateDescriptions)
+}
+
+// PostingBurst matches owners who created many listings in a short time;
+// MaxListings 0 disables it
+type PostingBurst struct {
+	MaxListings int
+	Window      time.Duration
+}
+
+func (r *PostingBurst) Name() string { return "posting_burst" }
+
+func (r *PostingBurst) Check(in *Input) (bool, string) {
+	if r.MaxListings <= 0 || in.RecentListings < r.MaxListings {
+		return false, ""
+	}
+	return true, fmt.Sprintf("%d other listings created within %s", in.RecentListings, r.Window)
+}
+
+// listingText is the free text written by the owner
+func listingText(in *Input) string {
+	text := in.Listing.Title
+	if in.Listing.Description != nil {
+		text += "\n" + *in.Listing.Description
+	}
+	return text
+}
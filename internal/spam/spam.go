@@ -0,0 +1,96 @@
+// Package spam scores new and edited listings with simple rules so that
+// likely scams wait for a moderator instead of being published
+package spam
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"pets_rest/internal/database"
+)
+
+// Input is what the rules look at: the listing and counts gathered from the
+// database beforehand, so rules themselves need no database
+type Input struct {
+	Listing *database.Listing
+	database.ListingSignals
+}
+
+// Hit is a rule that matched a listing
+type Hit struct {
+	Rule   string `json:"rule"`
+	Score  int    `json:"score"`
+	Detail string `json:"detail"`
+}
+
+// Result is the total score of a listing and the rules that matched
+type Result struct {
+	Score int
+	Hits  []Hit
+}
+
+// String lists the matched rules for the moderation audit trail
+func (r Result) String() string {
+	parts := make([]string, len(r.Hits))
+	for i, hit := range r.Hits {
+		parts[i] = fmt.Sprintf("%s (+%d): %s", hit.Rule, hit.Score, hit.Detail)
+	}
+	return fmt.Sprintf("spam score %d; %s", r.Score, strings.Join(parts, "; "))
+}
+
+// Rule checks one signal; it returns whether it matched and why
+type Rule interface {
+	Name() string
+	Check(in *Input) (bool, string)
+}
+
+// Engine adds up the weights of the matched rules
+type Engine struct {
+	rules   []Rule
+	weights map[string]int
+	// reviewScore is the score from which a listing is held; 0 disables holding
+	reviewScore int
+}
+
+// NewEngine creates an engine; rules without a weight score DefaultWeight
+func NewEngine(reviewScore int, weights map[string]int, rules ...Rule) *Engine {
+	return &Engine{rules: rules, weights: weights, reviewScore: reviewScore}
+}
+
+// DefaultWeight is the score of a rule missing from the weights
+const DefaultWeight = 25
+
+// BurstWindow returns the window of the posting burst rule, which tells how
+// far back the owner's recent listings are counted; 0 without such a rule
+func (e *Engine) BurstWindow() time.Duration {
+	for _, rule := range e.rules {
+		if burst, ok := rule.(*PostingBurst); ok {
+			return burst.Window
+		}
+	}
+	return 0
+}
+
+// Score runs all rules against a listing
+func (e *Engine) Score(in *Input) Result {
+	var result Result
+	for _, rule := range e.rules {
+		matched, detail := rule.Check(in)
+		if !matched {
+			continue
+		}
+		weight, ok := e.weights[rule.Name()]
+		if !ok {
+			weight = DefaultWeight
+		}
+		result.Score += weight
+		result.Hits = append(result.Hits, Hit{Rule: rule.Name(), Score: weight, Detail: detail})
+	}
+	return result
+}
+
+// NeedsReview reports whether a result is high enough to hold the listing
+func (e *Engine) NeedsReview(result Result) bool {
+	return e.reviewScore > 0 && result.Score >= e.reviewScore
+}
@@ -0,0 +1,96 @@
+package spam
+
+import (
+	"testing"
+	"time"
+
+	"pets_rest/internal/config"
+	"pets_rest/internal/database"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func input(title, description string, signals database.ListingSignals) *Input {
+	listing := &database.Listing{Title: title}
+	if description != "" {
+		listing.Description = &description
+	}
+	return &Input{Listing: listing, ListingSignals: signals}
+}
+
+func testEngine() *Engine {
+	return NewEngineFromConfig(&config.Config{
+		BaseURL:              "http://localhost:8080",
+		FrontendURL:          "https://pets.example",
+		SpamReviewScore:      50,
+		SpamMaxPhoneAccounts: 3,
+		SpamBurstListings:    5,
+		SpamBurstWindow:      time.Hour,
+	})
+}
+
+func TestCleanListingPasses(t *testing.T) {
+	engine := testEngine()
+
+	result := engine.Score(input("Загубився рудий кіт", "Зник біля парку Шевченка, відгукується на Барсик", database.ListingSignals{}))
+	assert.Zero(t, result.Score)
+	assert.False(t, engine.NeedsReview(result))
+}
+
+func TestPaymentRequestIsHeld(t *testing.T) {
+	engine := testEngine()
+
+	result := engine.Score(input("Знайдено собаку", "Віддам господарю після передоплати за доставку на карту", database.ListingSignals{}))
+	assert.Equal(t, 50, result.Score)
+	assert.True(t, engine.NeedsReview(result))
+	if assert.Len(t, result.Hits, 1) {
+		assert.Equal(t, "payment_phrase", result.Hits[0].Rule)
+	}
+}
+
+func TestExternalLinks(t *testing.T) {
+	rule := &ExternalLink{AllowedHosts: []string{"pets.example"}}
+
+	for _, text := range []string{"Пишіть сюди t.me/scammer", "Фото: https://evil.site/cat", "www.example.com"} {
+		matched, _ := rule.Check(input("Кіт", text, database.ListingSignals{}))
+		assert.True(t, matched, text)
+	}
+
+	// Посилання на власний сайт і звичайний текст з крапками не рахуються
+	for _, text := range []string{"Див. https://pets.example/p/cat", "Рудий, 3 роки. Дуже ласкавий"} {
+		matched, _ := rule.Check(input("Кіт", text, database.ListingSignals{}))
+		assert.False(t, matched, text)
+	}
+}
+
+func TestSignalsFromDatabase(t *testing.T) {
+	engine := testEngine()
+	phone := "+380671234567"
+	long := "Знайдено цуценя, дуже схоже на лабрадора, шукаємо господаря терміново"
+
+	in := input("Цуценя", long, database.ListingSignals{PhoneAccounts: 4, DuplicateDescriptions: 2, RecentListings: 6})
+	in.Listing.ContactPhone = &phone
+	result := engine.Score(in)
+	assert.Equal(t, 40+30+30, result.Score)
+	assert.Len(t, result.Hits, 3)
+
+	// Короткий опис на кшталт «Рудий кіт» повторюється природно
+	short := input("Кіт", "Рудий кіт", database.ListingSignals{DuplicateDescriptions: 10})
+	assert.Zero(t, engine.Score(short).Score)
+}
+
+func TestConfiguredWeights(t *testing.T) {
+	engine := NewEngineFromConfig(&config.Config{
+		SpamReviewScore:    10,
+		SpamRuleWeights:    "payment_phrase=5, external_link=7",
+		SpamPaymentPhrases: "bitcoin",
+	})
+
+	result := engine.Score(input("Кіт", "Оплата в bitcoin, деталі на scam.xyz", database.ListingSignals{}))
+	assert.Equal(t, 12, result.Score)
+	assert.True(t, engine.NeedsReview(result))
+
+	// Вбудований список фраз замінено налаштуванням
+	result = engine.Score(input("Кіт", "Передоплата на карту", database.ListingSignals{}))
+	assert.Zero(t, result.Score)
+}
@@ -0,0 +1,274 @@
+package telegram
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"pets_rest/internal/audit"
+	"pets_rest/internal/config"
+	"pets_rest/internal/database"
+)
+
+const (
+	pollTimeout    = 30 * time.Second
+	pollRetryDelay = 5 * time.Second
+)
+
+// Users is the subset of the user repository the bot needs
+type Users interface {
+	GetByID(ctx context.Context, id int) (*database.User, error)
+	GetByTelegramChatID(ctx context.Context, chatID int64) (*database.User, error)
+	SetTelegramChatID(ctx context.Context, userID int, chatID int64) error
+}
+
+// Dialogues stores the listing creation dialogues of chats; Get returns
+// sql.ErrNoRows when a chat has none
+type Dialogues interface {
+	Get(ctx context.Context, chatID int64) (*database.TelegramDialogue, error)
+	Save(ctx context.Context, dialogue *database.TelegramDialogue) error
+	Delete(ctx context.Context, chatID int64) error
+}
+
+// Listings creates listings on behalf of users
+type Listings interface {
+	Create(ctx context.Context, listing *database.Listing) error
+}
+
+// Options configures the bot
+type Options struct {
+	// Secret signs account link tokens
+	Secret string
+	// Username is the bot's @username without the @, used for deep links
+	Username string
+	// FrontendURL is used to build links to public listing pages
+	FrontendURL string
+	// CityChannels maps a lowercase city name to the channel that new
+	// active listings in that city are broadcast to
+	CityChannels map[string]string
+}
+
+// Bot handles Telegram updates and sends notifications
+type Bot struct {
+	client    *Client
+	users     Users
+	dialogues Dialogues
+	listings  Listings
+	opts      Options
+}
+
+// NewBot creates a bot; a nil client disables it, turning notifications into no-ops
+func NewBot(client *Client, users Users, dialogues Dialogues, listings Listings, opts Options) *Bot {
+	return &Bot{
+		client:    client,
+		users:     users,
+		dialogues: dialogues,
+		listings:  listings,
+		opts:      opts,
+	}
+}
+
+// New creates the bot from configuration; it is disabled when no token is set
+func New(cfg *config.Config, users Users, dialogues Dialogues, listings Listings) *Bot {
+	var client *Client
+	if cfg.TelegramBotToken != "" {
+		client = NewClient(cfg.TelegramAPIURL, cfg.TelegramBotToken)
+	}
+
+	return NewBot(client, users, dialogues, listings, Options{
+		Secret:       cfg.JWTSecret,
+		Username:     cfg.TelegramBotUsername,
+		FrontendURL:  cfg.FrontendURL,
+		CityChannels: parseCityChannels(cfg.TelegramCityChannels),
+	})
+}
+
+// Enabled reports whether a bot token is configured
+func (b *Bot) Enabled() bool {
+	return b.client != nil
+}
+
+// Client returns the Bot API client, or nil when the bot is disabled
+func (b *Bot) Client() *Client {
+	return b.client
+}
+
+// LinkURL returns a deep link that connects the user's Telegram chat to their account
+func (b *Bot) LinkURL(userID int) string {
+	return fmt.Sprintf("https://t.me/%s?start=%s", b.opts.Username, newLinkToken(b.opts.Secret, userID, time.Now()))
+}
+
+// NotifyUser sends a message to the user's linked chat; users without a
+// linked chat are skipped silently
+func (b *Bot) NotifyUser(ctx context.Context, userID int, text string) error {
+	if !b.Enabled() {
+		return nil
+	}
+
+	user, err := b.users.GetByID(ctx, userID)
+	if err != nil {
+		return err
+	}
+	if user.TelegramChatID == nil {
+		return nil
+	}
+
+	return b.client.SendMessage(ctx, *user.TelegramChatID, text, nil)
+}
+
+// Broadcast posts a newly active listing to the channel of its city, if any;
+// it is registered as a listing activation hook
+func (b *Bot) Broadcast(ctx context.Context, listing *database.Listing) {
+	if !b.Enabled() || listing.City == nil {
+		return
+	}
+
+	channel, ok := b.opts.CityChannels[strings.ToLower(strings.TrimSpace(*listing.City))]
+	if !ok {
+		return
+	}
+
+	if err := b.client.SendMessage(ctx, channel, b.describe(listing.Public()), nil); err != nil {
+		log.Printf("Failed to broadcast listing %d to %s: %v", listing.ID, channel, err)
+	}
+}
+
+// Poll receives updates with getUpdates until ctx is cancelled; used for
+// local development where Telegram cannot reach a webhook
+func (b *Bot) Poll(ctx context.Context) {
+	if err := b.client.DeleteWebhook(ctx); err != nil {
+		log.Printf("Failed to delete Telegram webhook: %v", err)
+	}
+
+	offset := 0
+	for ctx.Err() == nil {
+		updates, err := b.client.GetUpdates(ctx, offset, pollTimeout)
+		if err != nil {
+			if ctx.Err() == nil {
+				log.Printf("Failed to get Telegram updates: %v", err)
+			}
+			select {
+			case <-ctx.Done():
+			case <-time.After(pollRetryDelay):
+			}
+			continue
+		}
+
+		for _, u := range updates {
+			offset = u.UpdateID + 1
+			if err := b.HandleUpdate(ctx, u); err != nil {
+				log.Printf("Failed to handle Telegram update %d: %v", u.UpdateID, err)
+			}
+		}
+	}
+}
+
+// HandleUpdate processes a single update from the webhook or polling loop
+func (b *Bot) HandleUpdate(ctx context.Context, u Update) error {
+	msg := u.Message
+	if msg == nil || msg.Chat.Type != "private" {
+		return nil
+	}
+
+	text := strings.TrimSpace(msg.Text)
+	command, arg, _ := strings.Cut(text, " ")
+
+	switch command {
+	case "/start":
+		if arg != "" {
+			return b.link(ctx, msg.Chat.ID, arg)
+		}
+		return b.reply(ctx, msg.Chat.ID, helpText)
+	case "/help":
+		return b.reply(ctx, msg.Chat.ID, helpText)
+	case "/new":
+		return b.startDialogue(ctx, msg)
+	case "/cancel":
+		if err := b.endDialogue(ctx, msg.Chat.ID); err != nil {
+			return err
+		}
+		return b.client.SendMessage(ctx, msg.Chat.ID, "Cancelled.", &ReplyKeyboard{RemoveKeyboard: true})
+	}
+
+	d, err := b.dialogue(ctx, msg.Chat.ID)
+	if err != nil {
+		return err
+	}
+	if d != nil {
+		return b.continueDialogue(ctx, msg, d)
+	}
+
+	return b.reply(ctx, msg.Chat.ID, helpText)
+}
+
+const helpText = `I can help you publish lost and found pet listings.
+
+/new — create a listing
+/cancel — cancel the current dialogue
+/help — show this message
+
+To receive notifications, connect Telegram from your profile on the website.`
+
+// link connects the chat to the user from a signed /start token
+func (b *Bot) link(ctx context.Context, chatID int64, token string) error {
+	userID, err := parseLinkToken(b.opts.Secret, token, time.Now())
+	if err != nil {
+		return b.reply(ctx, chatID, "This link is invalid or has expired. Please request a new one on the website.")
+	}
+
+	// The signed token stands for the user, who makes the change
+	if err := b.users.SetTelegramChatID(audit.NewContext(ctx, audit.Actor{UserID: &userID}), userID, chatID); err != nil {
+		return err
+	}
+
+	return b.reply(ctx, chatID, "Telegram is connected to your account. You will receive message and match notifications here.")
+}
+
+// linkedUser returns the user linked to the chat, or nil when there is none
+func (b *Bot) linkedUser(ctx context.Context, chatID int64) (*database.User, error) {
+	user, err := b.users.GetByTelegramChatID(ctx, chatID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	return user, err
+}
+
+func (b *Bot) reply(ctx context.Context, chatID int64, text string) error {
+	return b.client.SendMessage(ctx, chatID, text, nil)
+}
+
+func (b *Bot) describe(l *database.Listing) string {
+	var s strings.Builder
+	s.WriteString(strings.ToUpper(string(l.Type)) + ": " + l.Title + "\n")
+	if l.City != nil {
+		s.WriteString("📍 " + *l.City)
+		if l.Location != nil {
+			s.WriteString(", " + *l.Location)
+		}
+		s.WriteString("\n")
+	}
+	if l.Description != nil {
+		s.WriteString("\n" + *l.Description + "\n")
+	}
+	if l.Slug != nil {
+		s.WriteString("\n" + b.opts.FrontendURL + "/p/" + *l.Slug)
+	}
+	return s.String()
+}
+
+// parseCityChannels parses "Kyiv=@pets_kyiv,Lviv=@pets_lviv"
+func parseCityChannels(s string) map[string]string {
+	channels := make(map[string]string)
+	for _, pair := range strings.Split(s, ",") {
+		city, channel, ok := strings.Cut(pair, "=")
+		if !ok {
+			continue
+		}
+		channels[strings.ToLower(strings.TrimSpace(city))] = strings.TrimSpace(channel)
+	}
+	return channels
+}
@@ -0,0 +1,339 @@
+package telegram
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"pets_rest/internal/database"
+)
+
+// fakeBotAPI імітує Telegram Bot API: записує надіслані повідомлення
+// та віддає заздалегідь підготовлені оновлення
+type fakeBotAPI struct {
+	*httptest.Server
+
+	mu      sync.Mutex
+	sent    []map[string]any
+	updates []Update
+}
+
+func newFakeBotAPI(t *testing.T) *fakeBotAPI {
+	f := &fakeBotAPI{}
+	f.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var params map[string]any
+		_ = json.NewDecoder(r.Body).Decode(&params)
+
+		f.mu.Lock()
+		defer f.mu.Unlock()
+
+		var result any = true
+		switch {
+		case strings.HasSuffix(r.URL.Path, "/sendMessage"):
+			f.sent = append(f.sent, params)
+		case strings.HasSuffix(r.URL.Path, "/getUpdates"):
+			result = f.updates
+			f.updates = nil
+		}
+
+		_ = json.NewEncoder(w).Encode(map[string]any{"ok": true, "result": result})
+	}))
+	t.Cleanup(f.Close)
+	return f
+}
+
+func (f *fakeBotAPI) messages() []map[string]any {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]map[string]any(nil), f.sent...)
+}
+
+func (f *fakeBotAPI) lastText(t *testing.T) string {
+	t.Helper()
+	msgs := f.messages()
+	require.NotEmpty(t, msgs)
+	return msgs[len(msgs)-1]["text"].(string)
+}
+
+type fakeUsers struct {
+	mu    sync.Mutex
+	users map[int]*database.User
+}
+
+func (u *fakeUsers) GetByID(_ context.Context, id int) (*database.User, error) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	if user, ok := u.users[id]; ok {
+		return user, nil
+	}
+	return nil, sql.ErrNoRows
+}
+
+func (u *fakeUsers) GetByTelegramChatID(_ context.Context, chatID int64) (*database.User, error) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	for _, user := range u.users {
+		if user.TelegramChatID != nil && *user.TelegramChatID == chatID {
+			return user, nil
+		}
+	}
+	return nil, sql.ErrNoRows
+}
+
+func (u *fakeUsers) SetTelegramChatID(_ context.Context, userID int, chatID int64) error {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	u.users[userID].TelegramChatID = &chatID
+	return nil
+}
+
+// fakeDialogues зберігає діалоги так само, як таблиця: кожне читання
+// повертає окрему копію
+type fakeDialogues struct {
+	mu        sync.Mutex
+	dialogues map[int64]database.TelegramDialogue
+}
+
+func newFakeDialogues() *fakeDialogues {
+	return &fakeDialogues{dialogues: make(map[int64]database.TelegramDialogue)}
+}
+
+func (d *fakeDialogues) Get(_ context.Context, chatID int64) (*database.TelegramDialogue, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	saved, ok := d.dialogues[chatID]
+	if !ok || !saved.ExpiresAt.After(time.Now()) {
+		return nil, sql.ErrNoRows
+	}
+	return &saved, nil
+}
+
+func (d *fakeDialogues) Save(_ context.Context, dialogue *database.TelegramDialogue) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.dialogues[dialogue.ChatID] = *dialogue
+	return nil
+}
+
+func (d *fakeDialogues) Delete(_ context.Context, chatID int64) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	delete(d.dialogues, chatID)
+	return nil
+}
+
+func (d *fakeDialogues) len() int {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return len(d.dialogues)
+}
+
+type fakeListings struct {
+	created []*database.Listing
+}
+
+func (l *fakeListings) Create(_ context.Context, listing *database.Listing) error {
+	slug := "test-slug"
+	listing.ID = len(l.created) + 1
+	listing.Slug = &slug
+	l.created = append(l.created, listing)
+	return nil
+}
+
+func newTestBot(t *testing.T) (*Bot, *fakeBotAPI, *fakeUsers, *fakeListings) {
+	api := newFakeBotAPI(t)
+	users := &fakeUsers{users: map[int]*database.User{7: {ID: 7, Email: "owner@example.com"}}}
+	listings := &fakeListings{}
+	return restartBot(api, users, newFakeDialogues(), listings), api, users, listings
+}
+
+// restartBot створює новий екземпляр бота над тими самими сховищами
+func restartBot(api *fakeBotAPI, users *fakeUsers, dialogues *fakeDialogues, listings *fakeListings) *Bot {
+	return NewBot(NewClient(api.URL, "TOKEN"), users, dialogues, listings, Options{
+		Secret:       "secret",
+		Username:     "pets_bot",
+		FrontendURL:  "https://pets.example",
+		CityChannels: parseCityChannels("Kyiv=@pets_kyiv"),
+	})
+}
+
+func send(t *testing.T, bot *Bot, chatID int64, text string) {
+	t.Helper()
+	err := bot.HandleUpdate(context.Background(), Update{Message: &Message{
+		Chat: Chat{ID: chatID, Type: "private"},
+		From: &User{ID: chatID, Username: "finder"},
+		Text: text,
+	}})
+	require.NoError(t, err)
+}
+
+func TestBotLinksChatWithStartToken(t *testing.T) {
+	bot, api, users, _ := newTestBot(t)
+
+	token := newLinkToken("secret", 7, time.Now())
+	send(t, bot, 100, "/start "+token)
+
+	require.NotNil(t, users.users[7].TelegramChatID)
+	assert.Equal(t, int64(100), *users.users[7].TelegramChatID)
+	assert.Contains(t, api.lastText(t), "connected")
+}
+
+func TestBotRejectsForgedStartToken(t *testing.T) {
+	bot, api, users, _ := newTestBot(t)
+
+	send(t, bot, 100, "/start 7-9999999999-000000000000000000000000")
+
+	assert.Nil(t, users.users[7].TelegramChatID)
+	assert.Contains(t, api.lastText(t), "invalid")
+}
+
+func TestBotCreatesListingThroughDialogue(t *testing.T) {
+	bot, api, users, listings := newTestBot(t)
+	chatID := int64(100)
+	require.NoError(t, users.SetTelegramChatID(context.Background(), 7, chatID))
+
+	for _, answer := range []string{"/new", "lost", "Grey cat", "Kyiv", "/skip", "+380501234567", "publish"} {
+		send(t, bot, chatID, answer)
+	}
+
+	require.Len(t, listings.created, 1)
+	l := listings.created[0]
+	assert.Equal(t, 7, l.UserID)
+	assert.Equal(t, database.ListingTypeLost, l.Type)
+	assert.Equal(t, "Grey cat", l.Title)
+	assert.Equal(t, "Kyiv", *l.City)
+	assert.Nil(t, l.Description)
+	assert.Equal(t, "+380501234567", *l.ContactPhone)
+	assert.Equal(t, "@finder", *l.ContactTg)
+	assert.Equal(t, database.ListingStatusActive, l.Status)
+	assert.Contains(t, api.lastText(t), "https://pets.example/p/test-slug")
+	d, err := bot.dialogue(context.Background(), chatID)
+	require.NoError(t, err)
+	assert.Nil(t, d)
+}
+
+func TestBotDialogueSurvivesRestart(t *testing.T) {
+	bot, api, users, listings := newTestBot(t)
+	chatID := int64(100)
+	require.NoError(t, users.SetTelegramChatID(context.Background(), 7, chatID))
+	dialogues := bot.dialogues.(*fakeDialogues)
+
+	for _, answer := range []string{"/new", "found", "Рудий кіт"} {
+		send(t, bot, chatID, answer)
+	}
+	assert.Equal(t, 1, dialogues.len())
+
+	// Інший екземпляр продовжує діалог з того самого кроку
+	bot = restartBot(api, users, dialogues, listings)
+	for _, answer := range []string{"Київ", "Біля метро", "/skip", "publish"} {
+		send(t, bot, chatID, answer)
+	}
+
+	require.Len(t, listings.created, 1)
+	l := listings.created[0]
+	assert.Equal(t, database.ListingTypeFound, l.Type)
+	assert.Equal(t, "Рудий кіт", l.Title)
+	assert.Equal(t, "Київ", *l.City)
+	assert.Equal(t, "Біля метро", *l.Description)
+	assert.Equal(t, 0, dialogues.len())
+}
+
+func TestBotDialogueExpires(t *testing.T) {
+	bot, api, users, listings := newTestBot(t)
+	chatID := int64(100)
+	require.NoError(t, users.SetTelegramChatID(context.Background(), 7, chatID))
+
+	send(t, bot, chatID, "/new")
+	dialogues := bot.dialogues.(*fakeDialogues)
+	saved := dialogues.dialogues[chatID]
+	saved.ExpiresAt = time.Now().Add(-time.Minute)
+	dialogues.dialogues[chatID] = saved
+
+	// Відповідь на покинутий діалог отримує довідку, а не продовжує його
+	send(t, bot, chatID, "lost")
+	assert.Empty(t, listings.created)
+	assert.Contains(t, api.lastText(t), "/new")
+}
+
+func TestBotTitleLengthCountsCharacters(t *testing.T) {
+	bot, api, users, _ := newTestBot(t)
+	chatID := int64(100)
+	require.NoError(t, users.SetTelegramChatID(context.Background(), 7, chatID))
+
+	send(t, bot, chatID, "/new")
+	send(t, bot, chatID, "lost")
+	send(t, bot, chatID, strings.Repeat("ї", maxTitleLength))
+	assert.Equal(t, "City:", api.lastText(t))
+}
+
+func TestBotRequiresLinkedAccountForNew(t *testing.T) {
+	bot, api, _, listings := newTestBot(t)
+
+	send(t, bot, 100, "/new")
+
+	assert.Empty(t, listings.created)
+	assert.Contains(t, api.lastText(t), "connect Telegram")
+}
+
+func TestBotBroadcastsToCityChannel(t *testing.T) {
+	bot, api, _, _ := newTestBot(t)
+	city := "kyiv"
+	phone := "+380501234567"
+	slug := "grey-cat"
+
+	bot.Broadcast(context.Background(), &database.Listing{
+		ID: 1, Type: database.ListingTypeFound, Title: "Grey cat", City: &city,
+		ContactPhone: &phone, ContactsHidden: true, Slug: &slug,
+	})
+
+	msgs := api.messages()
+	require.Len(t, msgs, 1)
+	assert.Equal(t, "@pets_kyiv", msgs[0]["chat_id"])
+	assert.NotContains(t, msgs[0]["text"], phone)
+}
+
+func TestBotPollHandlesUpdates(t *testing.T) {
+	bot, api, _, _ := newTestBot(t)
+	api.updates = []Update{{UpdateID: 1, Message: &Message{Chat: Chat{ID: 5, Type: "private"}, Text: "/help"}}}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		bot.Poll(ctx)
+		close(done)
+	}()
+
+	require.Eventually(t, func() bool { return len(api.messages()) == 1 }, time.Second, 10*time.Millisecond)
+	cancel()
+	<-done
+	assert.Contains(t, api.lastText(t), "/new")
+}
+
+func TestBotPollStopsDuringRetry(t *testing.T) {
+	// Недосяжний API змушує цикл чекати перед повтором
+	bot := NewBot(NewClient("http://127.0.0.1:0", "TOKEN"), nil, nil, nil, Options{})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		bot.Poll(ctx)
+		close(done)
+	}()
+
+	time.Sleep(50 * time.Millisecond)
+	cancel()
+	select {
+	case <-done:
+	case <-time.After(pollRetryDelay / 2):
+		t.Fatal("Poll did not stop while waiting to retry")
+	}
+}
@@ -0,0 +1,148 @@
+// Package telegram implements the Telegram bot: a minimal Bot API client,
+// webhook and long-polling update sources, listing creation dialogue and
+// user notifications
+package telegram
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// Update is an incoming Bot API update
+type Update struct {
+	UpdateID int      `json:"update_id"`
+	Message  *Message `json:"message,omitempty"`
+}
+
+// Message is a Bot API message
+type Message struct {
+	MessageID int    `json:"message_id"`
+	Chat      Chat   `json:"chat"`
+	From      *User  `json:"from,omitempty"`
+	Text      string `json:"text"`
+}
+
+// Chat is a Bot API chat
+type Chat struct {
+	ID   int64  `json:"id"`
+	Type string `json:"type"`
+}
+
+// User is a Bot API user
+type User struct {
+	ID       int64  `json:"id"`
+	Username string `json:"username,omitempty"`
+}
+
+// ReplyKeyboard is a custom keyboard shown instead of the regular one
+type ReplyKeyboard struct {
+	Keyboard        [][]KeyboardButton `json:"keyboard,omitempty"`
+	OneTimeKeyboard bool               `json:"one_time_keyboard,omitempty"`
+	ResizeKeyboard  bool               `json:"resize_keyboard,omitempty"`
+	RemoveKeyboard  bool               `json:"remove_keyboard,omitempty"`
+}
+
+// KeyboardButton is a button of a reply keyboard
+type KeyboardButton struct {
+	Text string `json:"text"`
+}
+
+// Client calls the Telegram Bot API
+type Client struct {
+	baseURL string
+	token   string
+	http    *http.Client
+}
+
+// NewClient creates a Bot API client; baseURL is https://api.telegram.org
+// in production and a fake server in tests
+func NewClient(baseURL, token string) *Client {
+	return &Client{
+		baseURL: baseURL,
+		token:   token,
+		http:    &http.Client{Timeout: 60 * time.Second},
+	}
+}
+
+type apiResponse struct {
+	OK          bool            `json:"ok"`
+	Result      json.RawMessage `json:"result"`
+	Description string          `json:"description"`
+}
+
+// call invokes a Bot API method and decodes its result into out
+func (c *Client) call(ctx context.Context, method string, params, out any) error {
+	body, err := json.Marshal(params)
+	if err != nil {
+		return err
+	}
+
+	url := fmt.Sprintf("%s/bot%s/%s", c.baseURL, c.token, method)
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return fmt.Errorf("telegram %s: %w", method, err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	var res apiResponse
+	if err := json.NewDecoder(resp.Body).Decode(&res); err != nil {
+		return fmt.Errorf("telegram %s: %w", method, err)
+	}
+	if !res.OK {
+		return fmt.Errorf("telegram %s: %s", method, res.Description)
+	}
+
+	if out != nil {
+		return json.Unmarshal(res.Result, out)
+	}
+	return nil
+}
+
+// SendMessage sends a text message; chatID is a numeric chat ID or a
+// "@channel" username
+func (c *Client) SendMessage(ctx context.Context, chatID any, text string, keyboard *ReplyKeyboard) error {
+	params := map[string]any{
+		"chat_id":                  chatID,
+		"text":                     text,
+		"disable_web_page_preview": true,
+	}
+	if keyboard != nil {
+		params["reply_markup"] = keyboard
+	}
+	return c.call(ctx, "sendMessage", params, nil)
+}
+
+// GetUpdates long-polls for updates starting at offset
+func (c *Client) GetUpdates(ctx context.Context, offset int, timeout time.Duration) ([]Update, error) {
+	var updates []Update
+	err := c.call(ctx, "getUpdates", map[string]any{
+		"offset":          offset,
+		"timeout":         int(timeout.Seconds()),
+		"allowed_updates": []string{"message"},
+	}, &updates)
+	return updates, err
+}
+
+// SetWebhook registers the URL Telegram posts updates to
+func (c *Client) SetWebhook(ctx context.Context, url, secret string) error {
+	return c.call(ctx, "setWebhook", map[string]any{
+		"url":             url,
+		"secret_token":    secret,
+		"allowed_updates": []string{"message"},
+	}, nil)
+}
+
+// DeleteWebhook removes the webhook so that getUpdates can be used
+func (c *Client) DeleteWebhook(ctx context.Context) error {
+	return c.call(ctx, "deleteWebhook", map[string]any{}, nil)
+}
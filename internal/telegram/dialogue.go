@@ -0,0 +1,180 @@
+package telegram
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"pets_rest/internal/audit"
+	"pets_rest/internal/database"
+)
+
+// step is a stage of the listing creation dialogue
+type step int
+
+const (
+	stepType step = iota
+	stepTitle
+	stepCity
+	stepDescription
+	stepPhone
+	stepConfirm
+)
+
+const maxTitleLength = 255
+
+// dialogueTTL is how long an unanswered dialogue is kept
+const dialogueTTL = 24 * time.Hour
+
+// dialogue holds the listing being created in a chat; every update works
+// on its own copy loaded from Dialogues
+type dialogue struct {
+	step    step
+	listing database.Listing
+}
+
+var typeKeyboard = &ReplyKeyboard{
+	Keyboard: [][]KeyboardButton{{
+		{Text: string(database.ListingTypeLost)},
+		{Text: string(database.ListingTypeFound)},
+		{Text: string(database.ListingTypeAdopt)},
+	}},
+	OneTimeKeyboard: true,
+	ResizeKeyboard:  true,
+}
+
+var confirmKeyboard = &ReplyKeyboard{
+	Keyboard:        [][]KeyboardButton{{{Text: "publish"}, {Text: "/cancel"}}},
+	OneTimeKeyboard: true,
+	ResizeKeyboard:  true,
+}
+
+// dialogue loads the dialogue of a chat, or returns nil when there is none
+func (b *Bot) dialogue(ctx context.Context, chatID int64) (*dialogue, error) {
+	saved, err := b.dialogues.Get(ctx, chatID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	d := &dialogue{step: step(saved.Step)}
+	if err := json.Unmarshal(saved.Listing, &d.listing); err != nil {
+		return nil, err
+	}
+	return d, nil
+}
+
+func (b *Bot) saveDialogue(ctx context.Context, chatID int64, d *dialogue) error {
+	listing, err := json.Marshal(&d.listing)
+	if err != nil {
+		return err
+	}
+	return b.dialogues.Save(ctx, &database.TelegramDialogue{
+		ChatID:    chatID,
+		Step:      int(d.step),
+		Listing:   listing,
+		ExpiresAt: time.Now().Add(dialogueTTL),
+	})
+}
+
+func (b *Bot) endDialogue(ctx context.Context, chatID int64) error {
+	return b.dialogues.Delete(ctx, chatID)
+}
+
+// ask moves the dialogue to the next step and asks its question
+func (b *Bot) ask(ctx context.Context, chatID int64, d *dialogue, next step, question string, keyboard *ReplyKeyboard) error {
+	d.step = next
+	if err := b.saveDialogue(ctx, chatID, d); err != nil {
+		return err
+	}
+	return b.client.SendMessage(ctx, chatID, question, keyboard)
+}
+
+// startDialogue begins creating a listing for a linked user
+func (b *Bot) startDialogue(ctx context.Context, msg *Message) error {
+	user, err := b.linkedUser(ctx, msg.Chat.ID)
+	if err != nil {
+		return err
+	}
+	if user == nil {
+		return b.reply(ctx, msg.Chat.ID, "Please connect Telegram from your profile on the website first.")
+	}
+
+	d := &dialogue{step: stepType, listing: database.Listing{UserID: user.ID}}
+	if msg.From != nil && msg.From.Username != "" {
+		tg := "@" + msg.From.Username
+		d.listing.ContactTg = &tg
+	}
+
+	return b.ask(ctx, msg.Chat.ID, d, stepType, "Is the pet lost, found or up for adoption?", typeKeyboard)
+}
+
+// continueDialogue handles an answer to the current question
+func (b *Bot) continueDialogue(ctx context.Context, msg *Message, d *dialogue) error {
+	chatID := msg.Chat.ID
+	text := strings.TrimSpace(msg.Text)
+	skip := text == "/skip"
+
+	switch d.step {
+	case stepType:
+		t := database.ListingType(strings.ToLower(text))
+		if t != database.ListingTypeLost && t != database.ListingTypeFound && t != database.ListingTypeAdopt {
+			return b.client.SendMessage(ctx, chatID, "Please choose lost, found or adopt.", typeKeyboard)
+		}
+		d.listing.Type = t
+		return b.ask(ctx, chatID, d, stepTitle, "Short title, e.g. \"Grey cat with white paws\":", &ReplyKeyboard{RemoveKeyboard: true})
+
+	case stepTitle:
+		if text == "" || skip || utf8.RuneCountInString(text) > maxTitleLength {
+			return b.reply(ctx, chatID, "The title is required and must be at most 255 characters.")
+		}
+		d.listing.Title = text
+		return b.ask(ctx, chatID, d, stepCity, "City:", nil)
+
+	case stepCity:
+		if text == "" || skip {
+			return b.reply(ctx, chatID, "The city is required.")
+		}
+		d.listing.City = &text
+		return b.ask(ctx, chatID, d, stepDescription, "Description: appearance, where and when it happened (or /skip):", nil)
+
+	case stepDescription:
+		if !skip {
+			d.listing.Description = &text
+		}
+		return b.ask(ctx, chatID, d, stepPhone, "Contact phone in +380… format (or /skip):", nil)
+
+	case stepPhone:
+		if !skip {
+			d.listing.ContactPhone = &text
+		}
+		return b.ask(ctx, chatID, d, stepConfirm, b.describe(&d.listing)+"\n\nPublish this listing?", confirmKeyboard)
+
+	case stepConfirm:
+		if strings.ToLower(text) != "publish" {
+			return b.client.SendMessage(ctx, chatID, "Send \"publish\" to publish or /cancel to discard.", confirmKeyboard)
+		}
+
+		listing := d.listing
+		listing.Status = database.ListingStatusActive
+		if err := b.listings.Create(audit.NewContext(ctx, audit.Actor{UserID: &listing.UserID}), &listing); err != nil {
+			return err
+		}
+
+		if err := b.endDialogue(ctx, chatID); err != nil {
+			return err
+		}
+		if listing.HeldForReview {
+			return b.client.SendMessage(ctx, chatID, "Thanks! Your listing will be published after a moderator checks it.", &ReplyKeyboard{RemoveKeyboard: true})
+		}
+		return b.client.SendMessage(ctx, chatID, "Published! "+b.opts.FrontendURL+"/p/"+*listing.Slug, &ReplyKeyboard{RemoveKeyboard: true})
+	}
+
+	return nil
+}
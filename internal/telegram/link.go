@@ -0,0 +1,53 @@
+package telegram
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// linkTokenTTL is how long a "connect Telegram" deep link stays valid
+const linkTokenTTL = 15 * time.Minute
+
+var errInvalidLinkToken = errors.New("invalid or expired link token")
+
+// newLinkToken creates a signed /start payload that links a chat to a user.
+// Telegram allows only [A-Za-z0-9_-] and 64 characters in start parameters.
+func newLinkToken(secret string, userID int, now time.Time) string {
+	payload := strconv.Itoa(userID) + "-" + strconv.FormatInt(now.Add(linkTokenTTL).Unix(), 10)
+	return payload + "-" + signLink(secret, payload)
+}
+
+// parseLinkToken verifies a link token and returns the user ID
+func parseLinkToken(secret, token string, now time.Time) (int, error) {
+	parts := strings.Split(token, "-")
+	if len(parts) != 3 {
+		return 0, errInvalidLinkToken
+	}
+
+	payload := parts[0] + "-" + parts[1]
+	if !hmac.Equal([]byte(parts[2]), []byte(signLink(secret, payload))) {
+		return 0, errInvalidLinkToken
+	}
+
+	expires, err := strconv.ParseInt(parts[1], 10, 64)
+	if err != nil || now.Unix() > expires {
+		return 0, errInvalidLinkToken
+	}
+
+	userID, err := strconv.Atoi(parts[0])
+	if err != nil {
+		return 0, errInvalidLinkToken
+	}
+	return userID, nil
+}
+
+func signLink(secret, payload string) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte("telegram-link|" + payload))
+	return hex.EncodeToString(mac.Sum(nil))[:24]
+}
@@ -0,0 +1,47 @@
+package telegram
+
+import (
+	"context"
+
+	"pets_rest/internal/audit"
+	"pets_rest/internal/database"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// userStore is the database implementation of Users; linking a chat is
+// recorded in the audit log
+type userStore struct {
+	*database.UserRepository
+	db    *database.DB
+	audit *audit.Log
+}
+
+// NewUsers creates the Users the bot works with in production
+func NewUsers(db *database.DB) Users {
+	return &userStore{
+		UserRepository: database.NewUserRepository(db),
+		db:             db,
+		audit:          audit.NewLog(db),
+	}
+}
+
+// SetTelegramChatID links a Telegram chat to a user, unlinking it from any
+// other user
+func (s *userStore) SetTelegramChatID(ctx context.Context, userID int, chatID int64) error {
+	return s.db.WithTx(ctx, func(tx *sqlx.Tx) error {
+		users := s.UserRepository.WithTx(tx)
+
+		before, err := users.GetByIDForUpdate(ctx, userID)
+		if err != nil {
+			return err
+		}
+		if err := users.SetTelegramChatID(ctx, userID, chatID); err != nil {
+			return err
+		}
+
+		after := *before
+		after.TelegramChatID = &chatID
+		return s.audit.WithTx(tx).Record(ctx, database.AuditEntityUser, userID, before, &after)
+	})
+}
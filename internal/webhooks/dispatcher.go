@@ -0,0 +1,235 @@
+package webhooks
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"strconv"
+	"syscall"
+	"time"
+
+	"pets_rest/internal/config"
+	"pets_rest/internal/database"
+)
+
+const (
+	batchSize      = 20
+	requestTimeout = 10 * time.Second
+	// lease keeps a claimed delivery away from other workers while it is sent
+	lease = time.Minute
+	// maxErrorLength bounds the response excerpt stored in the delivery log
+	maxErrorLength = 512
+)
+
+// envelope is the JSON body of every delivery
+type envelope struct {
+	Event     string    `json:"event"`
+	CreatedAt time.Time `json:"created_at"`
+	Data      any       `json:"data"`
+}
+
+// Dispatcher queues events for subscribed endpoints and delivers them with retries
+type Dispatcher struct {
+	cfg      *config.Config
+	webhooks *database.WebhookRepository
+	client   *http.Client
+}
+
+// NewDispatcher creates a new dispatcher; outside development requests to
+// loopback and private networks are refused
+func NewDispatcher(db *database.DB, cfg *config.Config) *Dispatcher {
+	return &Dispatcher{
+		cfg:      cfg,
+		webhooks: database.NewWebhookRepository(db),
+		client:   NewClient(cfg.Env == "development"),
+	}
+}
+
+// Publish queues an event for every endpoint subscribed to its type
+func (d *Dispatcher) Publish(ctx context.Context, eventType string, data any) error {
+	return d.enqueue(ctx, eventType, data, nil)
+}
+
+// PublishToUser queues an event for the subscribed endpoints of one user
+func (d *Dispatcher) PublishToUser(ctx context.Context, userID int, eventType string, data any) error {
+	return d.enqueue(ctx, eventType, data, &userID)
+}
+
+// OnListingActivated publishes listing.created when a listing goes live
+func (d *Dispatcher) OnListingActivated(ctx context.Context, listing *database.Listing) {
+	if err := d.Publish(ctx, EventListingCreated, d.listingData(listing)); err != nil {
+		log.Printf("Failed to queue %s webhooks for listing %d: %v", EventListingCreated, listing.ID, err)
+	}
+}
+
+// OnListingResolved publishes listing.resolved when a listing is archived
+func (d *Dispatcher) OnListingResolved(ctx context.Context, listing *database.Listing) {
+	if err := d.Publish(ctx, EventListingResolved, d.listingData(listing)); err != nil {
+		log.Printf("Failed to queue %s webhooks for listing %d: %v", EventListingResolved, listing.ID, err)
+	}
+}
+
+// ProcessDue sends deliveries whose attempt is due and returns how many were tried
+func (d *Dispatcher) ProcessDue(ctx context.Context) (int, error) {
+	deliveries, err := d.webhooks.ClaimDueDeliveries(ctx, batchSize, time.Now().Add(lease))
+	if err != nil {
+		return 0, err
+	}
+
+	for _, delivery := range deliveries {
+		if err := d.attempt(ctx, delivery); err != nil {
+			return 0, err
+		}
+	}
+
+	return len(deliveries), nil
+}
+
+// Deliver sends due deliveries in batches until none are left; it runs as
+// a scheduled job
+func (d *Dispatcher) Deliver(ctx context.Context) error {
+	for ctx.Err() == nil {
+		n, err := d.ProcessDue(ctx)
+		if err != nil || n < batchSize {
+			return err
+		}
+	}
+	return ctx.Err()
+}
+
+func (d *Dispatcher) enqueue(ctx context.Context, eventType string, data any, userID *int) error {
+	payload, err := json.Marshal(envelope{
+		Event:     eventType,
+		CreatedAt: time.Now().UTC(),
+		Data:      data,
+	})
+	if err != nil {
+		return err
+	}
+
+	_, err = d.webhooks.EnqueueEvent(ctx, eventType, payload, userID)
+	return err
+}
+
+func (d *Dispatcher) listingData(listing *database.Listing) map[string]any {
+	data := map[string]any{
+		"listing": listing.Public(),
+	}
+	if listing.Slug != nil {
+		data["url"] = d.cfg.FrontendURL + "/p/" + *listing.Slug
+	}
+	return data
+}
+
+// attempt sends one delivery and records the outcome; the returned error is
+// only set when the outcome could not be stored
+func (d *Dispatcher) attempt(ctx context.Context, delivery *database.WebhookDelivery) error {
+	endpoint, err := d.webhooks.GetEndpoint(ctx, delivery.EndpointID)
+	if err != nil {
+		return err
+	}
+
+	status, sendErr := send(ctx, d.client, endpoint, delivery)
+	if sendErr == nil {
+		if err := d.webhooks.MarkDelivered(ctx, delivery.ID, status); err != nil {
+			return err
+		}
+		return d.webhooks.RecordEndpointSuccess(ctx, endpoint.ID)
+	}
+
+	var responseStatus *int
+	if status != 0 {
+		responseStatus = &status
+	}
+	var next *time.Time
+	if delivery.Attempts < MaxAttempts {
+		at := time.Now().Add(Backoff(delivery.Attempts))
+		next = &at
+	}
+	if err := d.webhooks.MarkAttemptFailed(ctx, delivery.ID, responseStatus, sendErr.Error(), next); err != nil {
+		return err
+	}
+
+	disabled, err := d.webhooks.RecordEndpointFailure(ctx, endpoint.ID, DisableAfter)
+	if err != nil {
+		return err
+	}
+	if disabled {
+		log.Printf("Disabled webhook endpoint %d after %d consecutive failures", endpoint.ID, DisableAfter)
+	}
+	return nil
+}
+
+// send posts a delivery to its endpoint and returns the response status;
+// any status outside 2xx is an error
+func send(ctx context.Context, client *http.Client, endpoint *database.WebhookEndpoint, delivery *database.WebhookDelivery) (int, error) {
+	ctx, cancel := context.WithTimeout(ctx, requestTimeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint.URL, bytes.NewReader(delivery.Payload))
+	if err != nil {
+		return 0, err
+	}
+
+	timestamp := time.Now().Unix()
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "pets-rest-webhooks/1.0")
+	req.Header.Set(HeaderEvent, delivery.EventType)
+	req.Header.Set(HeaderDelivery, strconv.Itoa(delivery.ID))
+	req.Header.Set(HeaderTimestamp, strconv.FormatInt(timestamp, 10))
+	req.Header.Set(HeaderSignature, Sign(endpoint.Secret, timestamp, delivery.Payload))
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		excerpt, _ := io.ReadAll(io.LimitReader(resp.Body, maxErrorLength))
+		return resp.StatusCode, fmt.Errorf("unexpected status %d: %s", resp.StatusCode, bytes.TrimSpace(excerpt))
+	}
+
+	_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, maxErrorLength))
+	return resp.StatusCode, nil
+}
+
+var errForbiddenAddress = errors.New("address is not publicly routable")
+
+// NewClient returns an HTTP client that does not follow redirects and, unless
+// allowPrivate is set, refuses to connect to non-public addresses so that
+// user-supplied URLs, such as webhook endpoints or listing photos, cannot be
+// pointed at internal services
+func NewClient(allowPrivate bool) *http.Client {
+	dialer := &net.Dialer{Timeout: requestTimeout}
+	if !allowPrivate {
+		dialer.Control = func(_, address string, _ syscall.RawConn) error {
+			host, _, err := net.SplitHostPort(address)
+			if err != nil {
+				return err
+			}
+			ip := net.ParseIP(host)
+			if ip == nil || !ip.IsGlobalUnicast() || ip.IsPrivate() {
+				return errForbiddenAddress
+			}
+			return nil
+		}
+	}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.DialContext = dialer.DialContext
+	transport.Proxy = nil
+
+	return &http.Client{
+		Transport: transport,
+		CheckRedirect: func(*http.Request, []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+}
@@ -0,0 +1,92 @@
+// Package webhooks delivers signed listing events to partner endpoints
+package webhooks
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"net/url"
+	"slices"
+	"strconv"
+	"time"
+)
+
+// Event types that endpoints can subscribe to
+const (
+	EventListingCreated  = "listing.created"
+	EventListingResolved = "listing.resolved"
+	EventMatchFound      = "match.found"
+	// EventNotification carries user notifications routed to the webhook channel
+	EventNotification = "notification"
+)
+
+// EventTypes lists every supported event type
+var EventTypes = []string{EventListingCreated, EventListingResolved, EventMatchFound, EventNotification}
+
+// Headers sent with every delivery
+const (
+	HeaderEvent     = "X-Webhook-Event"
+	HeaderDelivery  = "X-Webhook-Delivery"
+	HeaderTimestamp = "X-Webhook-Timestamp"
+	HeaderSignature = "X-Webhook-Signature"
+)
+
+const (
+	// MaxAttempts is the number of attempts before a delivery is given up
+	MaxAttempts = 8
+	// DisableAfter is the number of consecutive failed attempts after which
+	// an endpoint is disabled
+	DisableAfter = 20
+
+	baseBackoff = 30 * time.Second
+	maxBackoff  = 6 * time.Hour
+)
+
+// ValidEventType reports whether t is a supported event type
+func ValidEventType(t string) bool {
+	return slices.Contains(EventTypes, t)
+}
+
+// ValidateURL checks that raw is an absolute http(s) URL; plain http is
+// only accepted when allowInsecure is set
+func ValidateURL(raw string, allowInsecure bool) error {
+	u, err := url.Parse(raw)
+	if err != nil || u.Host == "" {
+		return errors.New("url must be absolute")
+	}
+	if u.Scheme != "https" && (u.Scheme != "http" || !allowInsecure) {
+		return errors.New("url must use https")
+	}
+	if u.User != nil {
+		return errors.New("url must not contain credentials")
+	}
+	return nil
+}
+
+// Sign returns the signature header value for a payload. Receivers compute
+// HMAC-SHA256 over "<timestamp>.<body>" with the endpoint secret and compare
+// it to the hex digest after the "sha256=" prefix.
+func Sign(secret string, timestamp int64, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(strconv.FormatInt(timestamp, 10)))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// Backoff returns the delay before the next attempt after the given number
+// of failed attempts: 30s, 1m, 2m, ... capped at 6h
+func Backoff(attempts int) time.Duration {
+	if attempts < 1 {
+		attempts = 1
+	}
+	delay := baseBackoff
+	for i := 1; i < attempts; i++ {
+		delay *= 2
+		if delay >= maxBackoff {
+			return maxBackoff
+		}
+	}
+	return delay
+}
@@ -0,0 +1,6 @@
+-- Drop indexes
+DROP INDEX IF EXISTS idx_events_placement_id;
+DROP INDEX IF EXISTS idx_qr_placements_listing_id;
+
+-- Drop table
+DROP TABLE IF EXISTS qr_placements;
@@ -0,0 +1,16 @@
+-- Create qr_placements table
+CREATE TABLE IF NOT EXISTS qr_placements (
+    id SERIAL PRIMARY KEY,
+    listing_id INTEGER NOT NULL REFERENCES listings(id) ON DELETE CASCADE,
+    name VARCHAR(255) NOT NULL,
+    code VARCHAR(16) NOT NULL UNIQUE,
+    latitude DOUBLE PRECISION CHECK (latitude IS NULL OR latitude BETWEEN -90 AND 90),
+    longitude DOUBLE PRECISION CHECK (longitude IS NULL OR longitude BETWEEN -180 AND 180),
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW()
+);
+
+-- Create indexes for qr_placements table
+CREATE INDEX IF NOT EXISTS idx_qr_placements_listing_id ON qr_placements(listing_id);
+
+-- Speed up per-placement scan reports
+CREATE INDEX IF NOT EXISTS idx_events_placement_id ON events(((payload->>'placement_id')::INTEGER)) WHERE type = 'qr_scan';
@@ -0,0 +1,5 @@
+-- Drop indexes
+DROP INDEX IF EXISTS idx_short_links_listing_id;
+
+-- Drop table
+DROP TABLE IF EXISTS short_links;
@@ -0,0 +1,11 @@
+-- Create short_links table
+CREATE TABLE IF NOT EXISTS short_links (
+    id SERIAL PRIMARY KEY,
+    listing_id INTEGER NOT NULL REFERENCES listings(id) ON DELETE CASCADE,
+    code VARCHAR(16) NOT NULL UNIQUE,
+    source VARCHAR(10) NOT NULL DEFAULT 'link' CHECK (source IN ('qr', 'link')),
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW()
+);
+
+-- Create indexes for short_links table
+CREATE INDEX IF NOT EXISTS idx_short_links_listing_id ON short_links(listing_id);
@@ -0,0 +1,2 @@
+-- Drop column
+ALTER TABLE listings DROP COLUMN IF EXISTS contacts_hidden;
@@ -0,0 +1,2 @@
+-- Allow owners to hide contacts behind a reveal action
+ALTER TABLE listings ADD COLUMN IF NOT EXISTS contacts_hidden BOOLEAN NOT NULL DEFAULT FALSE;
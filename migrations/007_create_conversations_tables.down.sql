@@ -0,0 +1,11 @@
+-- Drop trigger
+DROP TRIGGER IF EXISTS update_conversations_updated_at ON conversations;
+
+-- Drop indexes
+DROP INDEX IF EXISTS idx_messages_conversation_id;
+DROP INDEX IF EXISTS idx_conversations_finder_id;
+DROP INDEX IF EXISTS idx_conversations_owner_id;
+
+-- Drop tables
+DROP TABLE IF EXISTS messages;
+DROP TABLE IF EXISTS conversations;
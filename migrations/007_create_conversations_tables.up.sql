@@ -0,0 +1,34 @@
+-- Create conversations table
+CREATE TABLE IF NOT EXISTS conversations (
+    id SERIAL PRIMARY KEY,
+    listing_id INTEGER NOT NULL REFERENCES listings(id) ON DELETE CASCADE,
+    owner_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    finder_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    owner_unread INTEGER NOT NULL DEFAULT 0,
+    finder_unread INTEGER NOT NULL DEFAULT 0,
+    last_message_at TIMESTAMP WITH TIME ZONE,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMP WITH TIME ZONE,
+    UNIQUE (listing_id, finder_id),
+    CHECK (owner_id <> finder_id)
+);
+
+-- Create messages table
+CREATE TABLE IF NOT EXISTS messages (
+    id SERIAL PRIMARY KEY,
+    conversation_id INTEGER NOT NULL REFERENCES conversations(id) ON DELETE CASCADE,
+    sender_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    body TEXT NOT NULL DEFAULT '',
+    images TEXT[], -- Array of image URLs
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    CHECK (body <> '' OR COALESCE(cardinality(images), 0) > 0)
+);
+
+-- Create indexes for conversations and messages tables
+CREATE INDEX IF NOT EXISTS idx_conversations_owner_id ON conversations(owner_id);
+CREATE INDEX IF NOT EXISTS idx_conversations_finder_id ON conversations(finder_id);
+CREATE INDEX IF NOT EXISTS idx_messages_conversation_id ON messages(conversation_id, created_at DESC);
+
+-- Add trigger for updated_at timestamps on conversations
+CREATE TRIGGER update_conversations_updated_at BEFORE UPDATE ON conversations
+FOR EACH ROW EXECUTE FUNCTION update_updated_at_column();
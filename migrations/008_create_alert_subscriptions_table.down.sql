@@ -0,0 +1,14 @@
+-- Drop indexes
+DROP INDEX IF EXISTS idx_alert_matches_unsent;
+DROP INDEX IF EXISTS idx_alert_subscriptions_city;
+DROP INDEX IF EXISTS idx_alert_subscriptions_user_id;
+DROP INDEX IF EXISTS idx_listings_species;
+
+-- Drop tables
+DROP TABLE IF EXISTS alert_matches;
+DROP TABLE IF EXISTS alert_subscriptions;
+
+-- Drop listing columns
+ALTER TABLE listings DROP COLUMN IF EXISTS longitude;
+ALTER TABLE listings DROP COLUMN IF EXISTS latitude;
+ALTER TABLE listings DROP COLUMN IF EXISTS species;
@@ -0,0 +1,38 @@
+-- Add species and coordinates to listings for area alerts
+ALTER TABLE listings ADD COLUMN IF NOT EXISTS species VARCHAR(30);
+ALTER TABLE listings ADD COLUMN IF NOT EXISTS latitude DOUBLE PRECISION CHECK (latitude IS NULL OR latitude BETWEEN -90 AND 90);
+ALTER TABLE listings ADD COLUMN IF NOT EXISTS longitude DOUBLE PRECISION CHECK (longitude IS NULL OR longitude BETWEEN -180 AND 180);
+
+CREATE INDEX IF NOT EXISTS idx_listings_species ON listings(species);
+
+-- Create alert_subscriptions table
+CREATE TABLE IF NOT EXISTS alert_subscriptions (
+    id SERIAL PRIMARY KEY,
+    user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    city VARCHAR(100),
+    latitude DOUBLE PRECISION CHECK (latitude IS NULL OR latitude BETWEEN -90 AND 90),
+    longitude DOUBLE PRECISION CHECK (longitude IS NULL OR longitude BETWEEN -180 AND 180),
+    radius_km DOUBLE PRECISION CHECK (radius_km IS NULL OR radius_km > 0),
+    types TEXT[] NOT NULL DEFAULT '{}', -- Empty means all listing types
+    species TEXT[] NOT NULL DEFAULT '{}', -- Empty means all species
+    channel VARCHAR(10) NOT NULL DEFAULT 'email' CHECK (channel IN ('email', 'push')),
+    frequency VARCHAR(10) NOT NULL DEFAULT 'instant' CHECK (frequency IN ('instant', 'digest')),
+    unsubscribe_token VARCHAR(64) NOT NULL UNIQUE,
+    last_digest_at TIMESTAMP WITH TIME ZONE,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    CHECK (city IS NOT NULL OR (latitude IS NOT NULL AND longitude IS NOT NULL AND radius_km IS NOT NULL))
+);
+
+-- Create alert_matches table (delivery log and digest queue)
+CREATE TABLE IF NOT EXISTS alert_matches (
+    subscription_id INTEGER NOT NULL REFERENCES alert_subscriptions(id) ON DELETE CASCADE,
+    listing_id INTEGER NOT NULL REFERENCES listings(id) ON DELETE CASCADE,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    sent_at TIMESTAMP WITH TIME ZONE,
+    PRIMARY KEY (subscription_id, listing_id)
+);
+
+-- Create indexes for alert tables
+CREATE INDEX IF NOT EXISTS idx_alert_subscriptions_user_id ON alert_subscriptions(user_id);
+CREATE INDEX IF NOT EXISTS idx_alert_subscriptions_city ON alert_subscriptions(LOWER(city));
+CREATE INDEX IF NOT EXISTS idx_alert_matches_unsent ON alert_matches(subscription_id) WHERE sent_at IS NULL;
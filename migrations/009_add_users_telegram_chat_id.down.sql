@@ -0,0 +1,7 @@
+-- Restore alert channels
+UPDATE alert_subscriptions SET channel = 'email' WHERE channel = 'telegram';
+ALTER TABLE alert_subscriptions DROP CONSTRAINT IF EXISTS alert_subscriptions_channel_check;
+ALTER TABLE alert_subscriptions ADD CONSTRAINT alert_subscriptions_channel_check CHECK (channel IN ('email', 'push'));
+
+-- Drop column
+ALTER TABLE users DROP COLUMN IF EXISTS telegram_chat_id;
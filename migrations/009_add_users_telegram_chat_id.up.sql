@@ -0,0 +1,6 @@
+-- Link users to their Telegram chat with the bot
+ALTER TABLE users ADD COLUMN IF NOT EXISTS telegram_chat_id BIGINT UNIQUE;
+
+-- Allow alerts to be delivered through Telegram
+ALTER TABLE alert_subscriptions DROP CONSTRAINT IF EXISTS alert_subscriptions_channel_check;
+ALTER TABLE alert_subscriptions ADD CONSTRAINT alert_subscriptions_channel_check CHECK (channel IN ('email', 'push', 'telegram'));
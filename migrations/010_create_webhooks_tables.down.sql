@@ -0,0 +1,9 @@
+-- Drop indexes
+DROP INDEX IF EXISTS idx_webhook_deliveries_due;
+DROP INDEX IF EXISTS idx_webhook_deliveries_endpoint_id;
+DROP INDEX IF EXISTS idx_webhook_endpoints_event_types;
+DROP INDEX IF EXISTS idx_webhook_endpoints_user_id;
+
+-- Drop tables
+DROP TABLE IF EXISTS webhook_deliveries;
+DROP TABLE IF EXISTS webhook_endpoints;
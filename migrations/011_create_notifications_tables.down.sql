@@ -0,0 +1,10 @@
+-- Drop indexes
+DROP INDEX IF EXISTS idx_notification_outbox_user_id;
+DROP INDEX IF EXISTS idx_notification_outbox_due;
+
+-- Drop tables
+DROP TABLE IF EXISTS notification_outbox;
+DROP TABLE IF EXISTS notification_preferences;
+
+-- Drop user columns
+ALTER TABLE users DROP COLUMN IF EXISTS locale;
@@ -0,0 +1,9 @@
+-- Drop indexes
+DROP INDEX IF EXISTS idx_jobs_unique_key;
+DROP INDEX IF EXISTS idx_jobs_dead;
+DROP INDEX IF EXISTS idx_jobs_locked;
+DROP INDEX IF EXISTS idx_jobs_due;
+
+-- Drop tables
+DROP TABLE IF EXISTS job_schedules;
+DROP TABLE IF EXISTS jobs;
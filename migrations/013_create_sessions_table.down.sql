@@ -0,0 +1,5 @@
+-- Drop indexes
+DROP INDEX IF EXISTS idx_sessions_expires_at;
+
+-- Drop tables
+DROP TABLE IF EXISTS sessions;
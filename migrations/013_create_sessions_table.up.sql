@@ -0,0 +1,9 @@
+-- Create sessions table (session storage when Redis is not configured)
+CREATE TABLE IF NOT EXISTS sessions (
+    key VARCHAR(64) PRIMARY KEY,
+    data BYTEA NOT NULL,
+    expires_at TIMESTAMP WITH TIME ZONE
+);
+
+-- Create indexes for sessions table
+CREATE INDEX IF NOT EXISTS idx_sessions_expires_at ON sessions(expires_at) WHERE expires_at IS NOT NULL;
@@ -0,0 +1,5 @@
+-- Drop indexes
+DROP INDEX IF EXISTS idx_user_identities_user_id;
+
+-- Drop tables
+DROP TABLE IF EXISTS user_identities;
@@ -0,0 +1,14 @@
+-- Create user_identities table (sign-in methods linked to an account)
+CREATE TABLE IF NOT EXISTS user_identities (
+    id SERIAL PRIMARY KEY,
+    user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    provider VARCHAR(50) NOT NULL,
+    provider_id VARCHAR(255) NOT NULL,
+    email VARCHAR(255),
+    last_login_at TIMESTAMP WITH TIME ZONE,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    UNIQUE (provider, provider_id)
+);
+
+-- Create indexes for user_identities table
+CREATE INDEX IF NOT EXISTS idx_user_identities_user_id ON user_identities(user_id);
@@ -0,0 +1,10 @@
+-- Drop indexes
+DROP INDEX IF EXISTS idx_phone_verifications_phone;
+DROP INDEX IF EXISTS idx_phone_verifications_user_id;
+
+-- Drop tables
+DROP TABLE IF EXISTS phone_verifications;
+
+-- Drop user and listing columns
+ALTER TABLE listings DROP COLUMN IF EXISTS verified_contact;
+ALTER TABLE users DROP COLUMN IF EXISTS phone_verified;
@@ -0,0 +1,21 @@
+-- Add verified phone flag to users
+ALTER TABLE users ADD COLUMN IF NOT EXISTS phone_verified BOOLEAN NOT NULL DEFAULT FALSE;
+
+-- Add verified contact badge to listings
+ALTER TABLE listings ADD COLUMN IF NOT EXISTS verified_contact BOOLEAN NOT NULL DEFAULT FALSE;
+
+-- Create phone_verifications table (one-time codes sent by SMS)
+CREATE TABLE IF NOT EXISTS phone_verifications (
+    id SERIAL PRIMARY KEY,
+    user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    phone VARCHAR(20) NOT NULL CHECK (phone ~ '^\+[1-9]\d{1,14}$'),
+    code_hash VARCHAR(64) NOT NULL,
+    attempts INTEGER NOT NULL DEFAULT 0,
+    expires_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    verified_at TIMESTAMP WITH TIME ZONE,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW()
+);
+
+-- Create indexes for phone_verifications table
+CREATE INDEX IF NOT EXISTS idx_phone_verifications_user_id ON phone_verifications(user_id, created_at DESC);
+CREATE INDEX IF NOT EXISTS idx_phone_verifications_phone ON phone_verifications(phone, created_at DESC);
@@ -0,0 +1,5 @@
+-- Drop indexes
+DROP INDEX IF EXISTS idx_users_role;
+
+-- Drop user columns
+ALTER TABLE users DROP COLUMN IF EXISTS role;
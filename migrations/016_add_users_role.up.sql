@@ -0,0 +1,10 @@
+-- Add role to users
+ALTER TABLE users ADD COLUMN IF NOT EXISTS role VARCHAR(20) NOT NULL DEFAULT 'user'
+    CHECK (role IN ('user', 'moderator', 'admin', 'shelter'));
+
+-- Webhooks are reserved for shelters, so partners that already use them keep access
+UPDATE users SET role = 'shelter'
+WHERE role = 'user' AND id IN (SELECT user_id FROM webhook_endpoints);
+
+-- Create indexes for users table
+CREATE INDEX IF NOT EXISTS idx_users_role ON users(role) WHERE role <> 'user';
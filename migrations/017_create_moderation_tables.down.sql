@@ -0,0 +1,14 @@
+-- Drop indexes
+DROP INDEX IF EXISTS idx_moderation_actions_target_user_id;
+DROP INDEX IF EXISTS idx_moderation_actions_listing_id;
+DROP INDEX IF EXISTS idx_listing_reports_open;
+DROP INDEX IF EXISTS idx_listing_reports_open_ip;
+DROP INDEX IF EXISTS idx_listings_moderation;
+
+-- Drop tables
+DROP TABLE IF EXISTS moderation_actions;
+DROP TABLE IF EXISTS listing_reports;
+
+-- Drop user and listing columns
+ALTER TABLE users DROP COLUMN IF EXISTS banned_at;
+ALTER TABLE listings DROP COLUMN IF EXISTS moderation;
@@ -0,0 +1,41 @@
+-- Add moderation state to listings; hidden listings and those waiting for
+-- edits are not shown publicly whatever their status
+ALTER TABLE listings ADD COLUMN IF NOT EXISTS moderation VARCHAR(20) NOT NULL DEFAULT 'pending'
+    CHECK (moderation IN ('pending', 'approved', 'hidden', 'changes_requested'));
+
+-- Listings published before moderation existed do not need a review
+UPDATE listings SET moderation = 'approved';
+
+-- Add ban flag to users
+ALTER TABLE users ADD COLUMN IF NOT EXISTS banned_at TIMESTAMP WITH TIME ZONE;
+
+-- Create listing_reports table (complaints from visitors)
+CREATE TABLE IF NOT EXISTS listing_reports (
+    id SERIAL PRIMARY KEY,
+    listing_id INTEGER NOT NULL REFERENCES listings(id) ON DELETE CASCADE,
+    reporter_id INTEGER REFERENCES users(id) ON DELETE SET NULL,
+    reason VARCHAR(20) NOT NULL CHECK (reason IN ('scam', 'spam', 'abuse', 'animal_welfare', 'other')),
+    comment TEXT,
+    ip_address INET,
+    resolved_at TIMESTAMP WITH TIME ZONE,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW()
+);
+
+-- Create moderation_actions table (audit trail of moderator decisions)
+CREATE TABLE IF NOT EXISTS moderation_actions (
+    id SERIAL PRIMARY KEY,
+    listing_id INTEGER REFERENCES listings(id) ON DELETE SET NULL,
+    target_user_id INTEGER REFERENCES users(id) ON DELETE SET NULL,
+    moderator_id INTEGER REFERENCES users(id) ON DELETE SET NULL,
+    action VARCHAR(20) NOT NULL CHECK (action IN ('approve', 'hide', 'require_edits', 'ban_user', 'auto_hide')),
+    note TEXT,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW()
+);
+
+-- Create indexes for moderation tables
+CREATE INDEX IF NOT EXISTS idx_listings_moderation ON listings(moderation) WHERE moderation <> 'approved';
+-- One open report per visitor and listing, so one person cannot hide a listing alone
+CREATE UNIQUE INDEX IF NOT EXISTS idx_listing_reports_open_ip ON listing_reports(listing_id, ip_address) WHERE resolved_at IS NULL;
+CREATE INDEX IF NOT EXISTS idx_listing_reports_open ON listing_reports(listing_id) WHERE resolved_at IS NULL;
+CREATE INDEX IF NOT EXISTS idx_moderation_actions_listing_id ON moderation_actions(listing_id, created_at DESC);
+CREATE INDEX IF NOT EXISTS idx_moderation_actions_target_user_id ON moderation_actions(target_user_id, created_at DESC);
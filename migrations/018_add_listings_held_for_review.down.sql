@@ -0,0 +1,14 @@
+-- Drop indexes
+DROP INDEX IF EXISTS idx_listings_held_for_review;
+DROP INDEX IF EXISTS idx_listings_user_created_at;
+DROP INDEX IF EXISTS idx_listings_description_md5;
+DROP INDEX IF EXISTS idx_listings_contact_phone;
+
+-- Restore moderation actions without the screening hold
+DELETE FROM moderation_actions WHERE action = 'hold';
+ALTER TABLE moderation_actions DROP CONSTRAINT IF EXISTS moderation_actions_action_check;
+ALTER TABLE moderation_actions ADD CONSTRAINT moderation_actions_action_check
+    CHECK (action IN ('approve', 'hide', 'require_edits', 'ban_user', 'auto_hide'));
+
+-- Drop listing columns
+ALTER TABLE listings DROP COLUMN IF EXISTS held_for_review;
@@ -0,0 +1,14 @@
+-- Add hold flag to listings; spam screening keeps suspicious listings in
+-- draft until a moderator approves them
+ALTER TABLE listings ADD COLUMN IF NOT EXISTS held_for_review BOOLEAN NOT NULL DEFAULT FALSE;
+
+-- Allow the screening hold in the moderation audit trail
+ALTER TABLE moderation_actions DROP CONSTRAINT IF EXISTS moderation_actions_action_check;
+ALTER TABLE moderation_actions ADD CONSTRAINT moderation_actions_action_check
+    CHECK (action IN ('approve', 'hide', 'require_edits', 'ban_user', 'auto_hide', 'hold'));
+
+-- Create indexes for spam screening lookups
+CREATE INDEX IF NOT EXISTS idx_listings_contact_phone ON listings(contact_phone) WHERE contact_phone IS NOT NULL;
+CREATE INDEX IF NOT EXISTS idx_listings_description_md5 ON listings(md5(lower(btrim(description)))) WHERE description IS NOT NULL;
+CREATE INDEX IF NOT EXISTS idx_listings_user_created_at ON listings(user_id, created_at DESC);
+CREATE INDEX IF NOT EXISTS idx_listings_held_for_review ON listings(created_at) WHERE held_for_review;
@@ -0,0 +1,6 @@
+-- Drop indexes
+DROP INDEX IF EXISTS idx_audit_log_actor_id;
+DROP INDEX IF EXISTS idx_audit_log_entity;
+
+-- Drop audit_log table
+DROP TABLE IF EXISTS audit_log;
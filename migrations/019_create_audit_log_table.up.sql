@@ -0,0 +1,18 @@
+-- Create audit_log table (who created, changed or deleted users and
+-- listings); entries outlive the rows they describe, so there are no
+-- foreign keys
+CREATE TABLE IF NOT EXISTS audit_log (
+    id BIGSERIAL PRIMARY KEY,
+    actor_id INTEGER,
+    action VARCHAR(10) NOT NULL CHECK (action IN ('create', 'update', 'delete')),
+    entity_type VARCHAR(20) NOT NULL CHECK (entity_type IN ('user', 'listing')),
+    entity_id INTEGER NOT NULL,
+    changes JSONB NOT NULL DEFAULT '{}',
+    ip_address INET,
+    request_id VARCHAR(64),
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW()
+);
+
+-- Create indexes for audit_log table
+CREATE INDEX IF NOT EXISTS idx_audit_log_entity ON audit_log(entity_type, entity_id, created_at DESC);
+CREATE INDEX IF NOT EXISTS idx_audit_log_actor_id ON audit_log(actor_id, created_at DESC) WHERE actor_id IS NOT NULL;
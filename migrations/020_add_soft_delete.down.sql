@@ -0,0 +1,15 @@
+-- Drop indexes
+DROP INDEX IF EXISTS idx_listings_deleted_at;
+DROP INDEX IF EXISTS idx_users_deleted_at;
+
+-- Deleted rows would reappear without the column, so remove them for good
+DELETE FROM listings WHERE deleted_at IS NOT NULL;
+DELETE FROM users WHERE deleted_at IS NOT NULL;
+
+-- Restore the email constraint
+DROP INDEX IF EXISTS idx_users_email_active;
+ALTER TABLE users ADD CONSTRAINT users_email_key UNIQUE (email);
+
+-- Drop soft delete columns
+ALTER TABLE listings DROP COLUMN IF EXISTS deleted_at;
+ALTER TABLE users DROP COLUMN IF EXISTS deleted_at;
@@ -0,0 +1,6 @@
+-- Drop indexes
+DROP INDEX IF EXISTS idx_data_exports_expires_at;
+DROP INDEX IF EXISTS idx_data_exports_user_id;
+
+-- Drop data_exports table
+DROP TABLE IF EXISTS data_exports;
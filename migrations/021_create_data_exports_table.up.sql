@@ -0,0 +1,17 @@
+-- Create data_exports table (archives of a user's personal data built by a
+-- background job and downloaded until they expire)
+CREATE TABLE IF NOT EXISTS data_exports (
+    id SERIAL PRIMARY KEY,
+    user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    status VARCHAR(20) NOT NULL DEFAULT 'pending' CHECK (status IN ('pending', 'ready', 'failed')),
+    archive BYTEA,
+    size BIGINT NOT NULL DEFAULT 0,
+    error TEXT,
+    expires_at TIMESTAMP WITH TIME ZONE,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    completed_at TIMESTAMP WITH TIME ZONE
+);
+
+-- Create indexes for data_exports table
+CREATE INDEX IF NOT EXISTS idx_data_exports_user_id ON data_exports(user_id, created_at DESC);
+CREATE INDEX IF NOT EXISTS idx_data_exports_expires_at ON data_exports(expires_at) WHERE expires_at IS NOT NULL;
@@ -0,0 +1,6 @@
+-- Drop trigger
+DROP TRIGGER IF EXISTS increment_listings_version ON listings;
+DROP FUNCTION IF EXISTS increment_version_column();
+
+-- Drop version column
+ALTER TABLE listings DROP COLUMN IF EXISTS version;
@@ -0,0 +1,15 @@
+-- Add version to listings for optimistic concurrency: clients send the
+-- version they edited as If-Match and conflicting edits are rejected
+ALTER TABLE listings ADD COLUMN IF NOT EXISTS version INTEGER NOT NULL DEFAULT 1;
+
+-- Every change of a listing row gets a new version, whichever query made it
+CREATE OR REPLACE FUNCTION increment_version_column()
+RETURNS TRIGGER AS $$
+BEGIN
+    NEW.version = OLD.version + 1;
+    RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER increment_listings_version BEFORE UPDATE ON listings
+FOR EACH ROW WHEN (OLD.* IS DISTINCT FROM NEW.*) EXECUTE FUNCTION increment_version_column();
@@ -0,0 +1,2 @@
+-- Drop listing_revisions table
+DROP TABLE IF EXISTS listing_revisions;
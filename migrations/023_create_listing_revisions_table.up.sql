@@ -0,0 +1,23 @@
+-- Create listing_revisions table (snapshots of the edited content of a
+-- listing, one per version that changed it)
+CREATE TABLE IF NOT EXISTS listing_revisions (
+    id SERIAL PRIMARY KEY,
+    listing_id INTEGER NOT NULL REFERENCES listings(id) ON DELETE CASCADE,
+    version INTEGER NOT NULL,
+    editor_id INTEGER REFERENCES users(id) ON DELETE SET NULL,
+    title VARCHAR(255) NOT NULL,
+    description TEXT,
+    contact_phone VARCHAR(20),
+    contact_tg VARCHAR(100),
+    contacts_hidden BOOLEAN NOT NULL DEFAULT FALSE,
+    status VARCHAR(10) NOT NULL CHECK (status IN ('draft', 'active', 'archived')),
+    images TEXT[],
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    UNIQUE (listing_id, version)
+);
+
+-- Existing listings start their history with their current content
+INSERT INTO listing_revisions (listing_id, version, title, description, contact_phone, contact_tg, contacts_hidden, status, images, created_at)
+SELECT id, version, title, description, contact_phone, contact_tg, contacts_hidden, status, images, COALESCE(updated_at, created_at)
+FROM listings
+ON CONFLICT (listing_id, version) DO NOTHING;
@@ -0,0 +1,5 @@
+-- Drop indexes
+DROP INDEX IF EXISTS idx_used_challenges_expires_at;
+
+-- Drop used_challenges table
+DROP TABLE IF EXISTS used_challenges;
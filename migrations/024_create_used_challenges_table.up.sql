@@ -0,0 +1,9 @@
+-- Create used_challenges table (solved proof-of-work challenges, kept until
+-- they expire so that every API instance refuses a replayed one)
+CREATE TABLE IF NOT EXISTS used_challenges (
+    id VARCHAR(64) PRIMARY KEY,
+    expires_at TIMESTAMP WITH TIME ZONE NOT NULL
+);
+
+-- Create indexes for used_challenges table
+CREATE INDEX IF NOT EXISTS idx_used_challenges_expires_at ON used_challenges(expires_at);
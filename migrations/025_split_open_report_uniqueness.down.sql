@@ -0,0 +1,13 @@
+-- Drop indexes
+DROP INDEX IF EXISTS idx_listing_reports_open_reporter;
+DROP INDEX IF EXISTS idx_listing_reports_open_ip;
+
+-- Restore one open report per address; duplicates from signed-in reporters
+-- sharing one are resolved first
+UPDATE listing_reports r SET resolved_at = NOW()
+WHERE resolved_at IS NULL AND EXISTS (
+    SELECT 1 FROM listing_reports o
+    WHERE o.listing_id = r.listing_id AND o.ip_address = r.ip_address
+        AND o.resolved_at IS NULL AND o.id < r.id
+);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_listing_reports_open_ip ON listing_reports(listing_id, ip_address) WHERE resolved_at IS NULL;
@@ -0,0 +1,5 @@
+-- Open reports are unique per signed-in reporter, and per address only for
+-- anonymous visitors, so that people behind a shared address can each report
+DROP INDEX IF EXISTS idx_listing_reports_open_ip;
+CREATE UNIQUE INDEX IF NOT EXISTS idx_listing_reports_open_ip ON listing_reports(listing_id, ip_address) WHERE resolved_at IS NULL AND reporter_id IS NULL;
+CREATE UNIQUE INDEX IF NOT EXISTS idx_listing_reports_open_reporter ON listing_reports(listing_id, reporter_id) WHERE resolved_at IS NULL AND reporter_id IS NOT NULL;
@@ -0,0 +1,5 @@
+-- Drop indexes
+DROP INDEX IF EXISTS idx_telegram_dialogues_expires_at;
+
+-- Drop telegram_dialogues table
+DROP TABLE IF EXISTS telegram_dialogues;
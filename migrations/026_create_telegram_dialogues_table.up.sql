@@ -0,0 +1,11 @@
+-- Create telegram_dialogues table (the listing a chat is creating with the
+-- bot, so that the dialogue survives restarts and works across instances)
+CREATE TABLE IF NOT EXISTS telegram_dialogues (
+    chat_id BIGINT PRIMARY KEY,
+    step SMALLINT NOT NULL,
+    listing JSONB NOT NULL,
+    expires_at TIMESTAMP WITH TIME ZONE NOT NULL
+);
+
+-- Create indexes for telegram_dialogues table
+CREATE INDEX IF NOT EXISTS idx_telegram_dialogues_expires_at ON telegram_dialogues(expires_at);
@@ -0,0 +1,6 @@
+-- Drop indexes
+DROP INDEX IF EXISTS idx_link_tickets_expires_at;
+DROP INDEX IF EXISTS idx_link_tickets_user_id;
+
+-- Drop link_tickets table
+DROP TABLE IF EXISTS link_tickets;
@@ -0,0 +1,15 @@
+-- Create link_tickets table (tickets that start linking a sign-in method to
+-- an account; each one is redeemed once, by the session that opened it)
+CREATE TABLE IF NOT EXISTS link_tickets (
+    id VARCHAR(64) PRIMARY KEY,
+    user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    provider VARCHAR(50) NOT NULL,
+    session_id VARCHAR(128),
+    redeemed_at TIMESTAMP WITH TIME ZONE,
+    expires_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW()
+);
+
+-- Create indexes for link_tickets table
+CREATE INDEX IF NOT EXISTS idx_link_tickets_user_id ON link_tickets(user_id);
+CREATE INDEX IF NOT EXISTS idx_link_tickets_expires_at ON link_tickets(expires_at);
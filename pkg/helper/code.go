@@ -0,0 +1,37 @@
+package helper
+
+import (
+	"crypto/rand"
+	"math/big"
+	"strings"
+)
+
+// codeAlphabet leaves out characters that are easy to confuse when a code
+// is typed from a printed poster: 0/o, 1/l/i
+const codeAlphabet = "abcdefghjkmnpqrstuvwxyz23456789"
+
+/*
+RandomCode returns a random lowercase code of length n built from
+characters that cannot be mistaken for one another when read by hand.
+It is used for short, printable identifiers such as QR and short link codes.
+*/
+func RandomCode(n int) string {
+	max := big.NewInt(int64(len(codeAlphabet)))
+	b := make([]byte, n)
+	for i := range b {
+		idx, err := rand.Int(rand.Reader, max)
+		if err != nil {
+			panic(err)
+		}
+		b[i] = codeAlphabet[idx.Int64()]
+	}
+	return string(b)
+}
+
+/*
+NormalizeCode prepares a hand-typed code for lookup.
+Codes are case-insensitive and surrounding whitespace is ignored.
+*/
+func NormalizeCode(code string) string {
+	return strings.ToLower(strings.TrimSpace(code))
+}
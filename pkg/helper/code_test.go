@@ -0,0 +1,50 @@
+package helper
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRandomCode(t *testing.T) {
+	seen := make(map[string]bool)
+	for range 200 {
+		code := RandomCode(8)
+		assert.Len(t, code, 8)
+		// Лише символи алфавіту і жодного з тих, що плутаються на папері
+		for _, r := range code {
+			assert.Contains(t, codeAlphabet, string(r))
+		}
+		assert.False(t, strings.ContainsAny(code, "0o1liOLI"), code)
+		seen[code] = true
+	}
+	// 31^8 варіантів: збіг за 200 спроб означав би поламаний генератор
+	assert.Len(t, seen, 200)
+
+	assert.Empty(t, RandomCode(0))
+}
+
+func TestNormalizeCode(t *testing.T) {
+	tests := []struct {
+		name string
+		code string
+		want string
+	}{
+		{"як надруковано", "k7mp2x", "k7mp2x"},
+		{"великі літери", "K7MP2X", "k7mp2x"},
+		{"змішаний регістр", "K7mP2x", "k7mp2x"},
+		{"пробіли навколо", "  k7mp2x ", "k7mp2x"},
+		{"табуляція і перенос рядка", "\tK7MP2X\n", "k7mp2x"},
+		{"порожній", "   ", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, NormalizeCode(tt.code))
+		})
+	}
+
+	// Нормалізація не змінює згенерованих кодів
+	code := RandomCode(6)
+	assert.Equal(t, code, NormalizeCode(code))
+}
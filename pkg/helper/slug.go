@@ -0,0 +1,48 @@
+package helper
+
+import (
+	"strings"
+	"unicode"
+)
+
+// translit maps Ukrainian letters to Latin following the official 2010 table
+var translit = map[rune]string{
+	'а': "a", 'б': "b", 'в': "v", 'г': "h", 'ґ': "g", 'д': "d", 'е': "e", 'є': "ie",
+	'ж': "zh", 'з': "z", 'и': "y", 'і': "i", 'ї': "i", 'й': "i", 'к': "k", 'л': "l",
+	'м': "m", 'н': "n", 'о': "o", 'п': "p", 'р': "r", 'с': "s", 'т': "t", 'у': "u",
+	'ф': "f", 'х': "kh", 'ц': "ts", 'ч': "ch", 'ш': "sh", 'щ': "shch", 'ь': "", 'ю': "iu",
+	'я': "ia", 'ы': "y", 'э': "e", 'ё': "io", 'ъ': "",
+}
+
+/*
+Slugify converts a title into a lowercase, URL-friendly slug.
+Cyrillic is transliterated, other characters become single dashes and
+the result is cut to maxLen characters.
+*/
+func Slugify(s string, maxLen int) string {
+	var b strings.Builder
+	dash := false
+	for _, r := range strings.ToLower(s) {
+		switch {
+		case r < unicode.MaxASCII && (unicode.IsLetter(r) || unicode.IsDigit(r)):
+			b.WriteRune(r)
+			dash = false
+		case translit[r] != "":
+			b.WriteString(translit[r])
+			dash = false
+		case r == '\'' || r == '’' || r == 'ь':
+			// apostrophes and soft signs are dropped without a separator
+		default:
+			if !dash && b.Len() > 0 {
+				b.WriteByte('-')
+				dash = true
+			}
+		}
+	}
+
+	slug := strings.Trim(b.String(), "-")
+	if len(slug) > maxLen {
+		slug = strings.TrimRight(slug[:maxLen], "-")
+	}
+	return slug
+}
@@ -0,0 +1,35 @@
+package helper
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSlugify(t *testing.T) {
+	tests := []struct {
+		name   string
+		title  string
+		maxLen int
+		want   string
+	}{
+		{"латиниця", "Lost Cat", 60, "lost-cat"},
+		{"транслітерація", "Загубився рудий кіт", 60, "zahubyvsia-rudyi-kit"},
+		{"багатолітерні звуки", "Щеня Жужа, Хвостик і Цьоця", 60, "shchenia-zhuzha-khvostyk-i-tsotsia"},
+		{"ґ та є", "Ґанок біля Євбазу", 60, "ganok-bilia-ievbazu"},
+		// Апостроф і м'який знак не розривають слово
+		{"апостроф", "М'ячик п’ять", 60, "miachyk-piat"},
+		{"розділювачі", "  Кіт!!! -- (Київ)  ", 60, "kit-kyiv"},
+		{"цифри", "Пес №2 2024", 60, "pes-2-2024"},
+		{"лише символи", "!!! ???", 60, ""},
+		{"інші алфавіти", "Κατ 猫 cat", 60, "cat"},
+		// Обрізання не лишає дефіс у кінці
+		{"обрізання", "Загубився рудий кіт", 11, "zahubyvsia"},
+		{"обрізання в слові", "Загубився рудий кіт", 14, "zahubyvsia-rud"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, Slugify(tt.title, tt.maxLen))
+		})
+	}
+}